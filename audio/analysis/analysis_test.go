@@ -0,0 +1,116 @@
+package analysis
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"code.google.com/p/gordon-go/audio"
+)
+
+const testRate = 22050
+
+func tone(freq, amp, dur float64) []float64 {
+	x := make([]float64, int(dur*testRate))
+	for i := range x {
+		t := float64(i) / testRate
+		x[i] = amp * (math.Sin(2*math.Pi*freq*t) + .5*math.Sin(4*math.Pi*freq*t+1) + .25*math.Sin(6*math.Pi*freq*t+2)) / 1.75
+	}
+	return x
+}
+
+func TestPitchDetector(t *testing.T) {
+	for _, method := range []PitchMethod{YIN, MPM} {
+		d := NewPitchDetector(method, testRate)
+		for _, freq := range []float64{60, 110, 440, 1000, 1900} {
+			x := tone(freq, 1, 1)[:d.WindowSize()]
+			f, clarity := d.Detect(x)
+			if math.Abs(f/freq-1) > .002 || clarity < .9 {
+				t.Errorf("method %d: detected %v Hz with clarity %v; want %v Hz", method, f, clarity, freq)
+			}
+		}
+
+		r := rand.New(rand.NewSource(1))
+		x := make([]float64, d.WindowSize())
+		for i := range x {
+			x[i] = r.Float64()*2 - 1
+		}
+		if _, clarity := d.Detect(x); clarity > .6 {
+			t.Errorf("method %d: noise has clarity %v", method, clarity)
+		}
+	}
+}
+
+func TestTranscribe(t *testing.T) {
+	x := tone(440, .5, .2)
+	x = append(x, make([]float64, testRate/10)...)
+	x = append(x, tone(660, .25, .3)...)
+	x = append(x, tone(880, .25, .2)...) // legato
+
+	notes := Transcribe(x, testRate)
+	want := []struct{ time, freq, amp float64 }{
+		{0, 440, .5},
+		{.3, 660, .25},
+		{.6, 880, .25},
+	}
+	if len(notes) != len(want) {
+		t.Fatalf("got %d notes; want %d", len(notes), len(want))
+	}
+	for i, n := range notes {
+		w := want[i]
+		if math.Abs(n.Time-w.time) > .015 {
+			t.Errorf("note %d starts at %v; want %v", i, n.Time, w.time)
+		}
+		pitch, amp := n.Attributes["Pitch"], n.Attributes["Amplitude"]
+		for _, p := range pitch {
+			if math.Abs(p.Value-math.Log2(w.freq)) > .01 {
+				t.Errorf("note %d has pitch %v at %v; want %v", i, p.Value, p.Time, math.Log2(w.freq))
+				break
+			}
+		}
+		rms := w.amp * math.Sqrt((1+.25+.0625)/2) / 1.75
+		if mid := pointValue(amp, .1); math.Abs(mid-math.Log2(rms)) > .2 {
+			t.Errorf("note %d has amplitude %v; want %v", i, mid, math.Log2(rms))
+		}
+		if last := amp[len(amp)-1]; last.Value > math.Log2(rms)-fadeDepth+.5 {
+			t.Errorf("note %d doesn't fade out", i)
+		}
+	}
+}
+
+// pointValue returns the value of a curve through points at time t.
+func pointValue(points []*audio.ControlPoint, t float64) float64 {
+	for i := 1; i < len(points); i++ {
+		a, b := points[i-1], points[i]
+		if b.Time >= t {
+			return a.Value + (b.Value-a.Value)*(t-a.Time)/(b.Time-a.Time)
+		}
+	}
+	return points[len(points)-1].Value
+}
+
+type testVoice struct {
+	x []float64
+	i int
+}
+
+func (v *testVoice) Sing() float64 { v.i++; return v.x[v.i-1] }
+func (v *testVoice) Done() bool    { return v.i == len(v.x) }
+
+func TestTap(t *testing.T) {
+	a := NewAnalyzer(44100)
+	tap := NewTap(&testVoice{x: tone(330, .5, .3)}, a)
+	audio.Init(tap, audio.Params{SampleRate: testRate})
+	for !tap.Done() {
+		tap.Sing()
+	}
+	tap.Close()
+	notes := a.Notes()
+	if len(notes) != 1 || math.Abs(notes[0].Attributes["Pitch"][0].Value-math.Log2(330)) > .01 {
+		t.Errorf("got notes %v; want one at %v", notes, math.Log2(330))
+	}
+	if tap.Dropped() > 0 {
+		// The analysis is slow enough that this can happen on a loaded machine.
+		t.Logf("dropped %d samples", tap.Dropped())
+	}
+}
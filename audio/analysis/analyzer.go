@@ -0,0 +1,232 @@
+package analysis
+
+import (
+	"math"
+	"sync"
+
+	"code.google.com/p/gordon-go/audio"
+)
+
+// A Frame is the analysis of a signal around a point in time.
+type Frame struct {
+	Time      float64 // of the middle of the hop, in seconds from the start of the signal
+	Pitch     float64 // log2 frequency, or NaN if there is none
+	Clarity   float64 // of the pitch, from 0 to 1
+	Amplitude float64 // log2 RMS amplitude
+	Onset     bool
+}
+
+// An Analyzer divides a signal into hops and analyzes a window around each
+// one into a Frame.  Samples may be written to it from one goroutine while
+// the frames are read from another.
+type Analyzer struct {
+	SampleRate float64
+	Hop        int // in samples
+	Pitch      *PitchDetector
+	Onset      *OnsetDetector
+
+	// A frame is pitched if its clarity is at least MinClarity and its
+	// amplitude at least Onset.MinLevel.  Notes shorter than MinNote
+	// seconds are ignored.
+	MinClarity, MinNote float64
+
+	mu     sync.Mutex
+	x      []float64 // input from sample start onward
+	start  int
+	frames []Frame
+}
+
+// NewAnalyzer returns an Analyzer with 5 ms hops, using the YIN pitch
+// detector.
+func NewAnalyzer(sampleRate float64) *Analyzer {
+	a := &Analyzer{MinClarity: .8, MinNote: .05}
+	a.reset(sampleRate)
+	return a
+}
+
+func (a *Analyzer) reset(sampleRate float64) {
+	a.SampleRate = sampleRate
+	a.Hop = int(sampleRate / 200)
+	a.Pitch = NewPitchDetector(YIN, sampleRate)
+	a.Onset = NewOnsetDetector(int(.05 * sampleRate / float64(a.Hop)))
+	a.mu.Lock()
+	a.x, a.start, a.frames = nil, 0, nil
+	a.mu.Unlock()
+}
+
+// Write adds samples to the signal and analyzes every frame whose window is
+// complete.
+func (a *Analyzer) Write(x []float64) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	w := a.Pitch.WindowSize()
+	if a.x == nil {
+		// the first windows start before the signal
+		a.x = make([]float64, w/2)
+		a.start = -w / 2
+	}
+	a.x = append(a.x, x...)
+	for {
+		i := len(a.frames)
+		hop := i * a.Hop
+		from, to := hop+a.Hop/2-w/2, hop+a.Hop/2+w/2
+		if to > a.start+len(a.x) {
+			break
+		}
+		a.frames = append(a.frames, a.frame(i, a.x[from-a.start:to-a.start], a.x[hop-a.start:hop+a.Hop-a.start]))
+	}
+	if n := len(a.frames)*a.Hop - w/2 - a.start; n > 0 {
+		a.x = append(a.x[:0], a.x[n:]...)
+		a.start += n
+	}
+}
+
+// Flush analyzes the frames at the end of the signal, as though it were
+// followed by silence.
+func (a *Analyzer) Flush() {
+	a.Write(make([]float64, a.Pitch.WindowSize()/2+a.Hop))
+}
+
+func (a *Analyzer) frame(i int, window, hop []float64) Frame {
+	f := Frame{Time: (float64(i*a.Hop) + float64(a.Hop)/2) / a.SampleRate, Pitch: math.NaN(), Amplitude: rmsLevel(window)}
+	f.Onset, _ = a.Onset.Detect(hop)
+	freq, clarity := a.Pitch.Detect(window)
+	f.Clarity = clarity
+	if freq > 0 && clarity >= a.MinClarity && f.Amplitude >= a.Onset.MinLevel {
+		f.Pitch = math.Log2(freq)
+	}
+	return f
+}
+
+// Frames returns the frames analyzed so far.
+func (a *Analyzer) Frames() []Frame {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]Frame(nil), a.frames...)
+}
+
+// Notes returns the notes in the frames analyzed so far.
+func (a *Analyzer) Notes() []*audio.Note {
+	return Notes(a.Frames(), a.MinNote)
+}
+
+// Transcribe returns the notes in x, using a new Analyzer.
+func Transcribe(x []float64, sampleRate float64) []*audio.Note {
+	a := NewAnalyzer(sampleRate)
+	a.Write(x)
+	a.Flush()
+	return a.Notes()
+}
+
+// The tolerances within which Notes simplifies a note's curves:  a few cents
+// of pitch and a fraction of a decibel.
+const (
+	pitchTolerance     = .005
+	amplitudeTolerance = .05
+)
+
+// A note ends when it has been unpitched for this long, in seconds.  An
+// onset starts a note up to this long before its pitch is found.
+const maxGap = .03
+
+// Notes turns frames into Notes with Pitch and Amplitude attributes, ready to
+// be played or added to a Pattern.  A note starts at an onset, or when a
+// pitch appears or jumps by more than a semitone, and ends when the pitch
+// stops.  Because the pitch is only found once it fills most of a window, a
+// note that follows another without a rest starts where the first one's pitch
+// was lost.  The attributes' control points are simplified, and the
+// Amplitude fades away after the last one.  Notes shorter than minLength
+// seconds are dropped.
+func Notes(frames []Frame, minLength float64) []*audio.Note {
+	notes := []*audio.Note{}
+	var cur []Frame
+	start := 0.0
+	end := func() {
+		if len(cur) > 0 && cur[len(cur)-1].Time-start >= minLength {
+			notes = append(notes, noteFromFrames(start, cur))
+		}
+		cur = nil
+	}
+	gap, onset := -1.0, -1.0 // the times of the first unpitched frame and the last onset, if recent
+	for _, f := range frames {
+		if f.Onset {
+			onset = f.Time
+		}
+		if onset >= 0 && f.Time-onset > maxGap {
+			onset = -1
+		}
+		if math.IsNaN(f.Pitch) {
+			if gap < 0 {
+				gap = f.Time
+			}
+			if f.Time-gap > maxGap {
+				end()
+			}
+			continue
+		}
+		if len(cur) > 0 && (onset >= 0 || math.Abs(f.Pitch-cur[len(cur)-1].Pitch) > 1./12) {
+			end()
+			start = f.Time
+			if gap >= 0 {
+				start = gap
+			}
+		} else if len(cur) == 0 {
+			start = f.Time
+		}
+		if len(cur) == 0 && onset >= 0 && onset < start {
+			start = onset
+		}
+		gap, onset = -1, -1
+		cur = append(cur, f)
+	}
+	end()
+	return notes
+}
+
+// The time over which a transcribed note's Amplitude fades after its last
+// frame, and by how much, in log2 units.
+const (
+	fadeTime  = .02
+	fadeDepth = 10
+)
+
+// noteFromFrames returns a note starting at time t0, with the pitch and
+// amplitude of the first frame until then.
+func noteFromFrames(t0 float64, frames []Frame) *audio.Note {
+	pitch := []*audio.ControlPoint{{Time: 0, Value: frames[0].Pitch}}
+	amp := []*audio.ControlPoint{{Time: 0, Value: frames[0].Amplitude}}
+	for _, f := range frames {
+		pitch = append(pitch, &audio.ControlPoint{Time: f.Time - t0, Value: f.Pitch})
+		amp = append(amp, &audio.ControlPoint{Time: f.Time - t0, Value: f.Amplitude})
+	}
+	pitch = simplify(pitch, pitchTolerance)
+	amp = simplify(amp, amplitudeTolerance)
+	last := amp[len(amp)-1]
+	amp = append(amp, &audio.ControlPoint{Time: last.Time + fadeTime, Value: last.Value - fadeDepth})
+	return &audio.Note{Time: t0, Attributes: map[string][]*audio.ControlPoint{"Pitch": pitch, "Amplitude": amp}}
+}
+
+// simplify returns the points of a curve that keep it within tol of the
+// original, by the Ramer-Douglas-Peucker algorithm.
+func simplify(points []*audio.ControlPoint, tol float64) []*audio.ControlPoint {
+	if len(points) <= 2 {
+		return points
+	}
+	a, b := points[0], points[len(points)-1]
+	worst, worstErr := 0, 0.0
+	for i, p := range points[1 : len(points)-1] {
+		v := a.Value
+		if b.Time > a.Time {
+			v += (b.Value - a.Value) * (p.Time - a.Time) / (b.Time - a.Time)
+		}
+		if e := math.Abs(p.Value - v); e > worstErr {
+			worst, worstErr = i+1, e
+		}
+	}
+	if worstErr <= tol {
+		return []*audio.ControlPoint{a, b}
+	}
+	left := simplify(points[:worst+1], tol)
+	right := simplify(points[worst:], tol)
+	return append(left[:len(left)-1:len(left)-1], right...)
+}
@@ -0,0 +1,68 @@
+package analysis
+
+import "math"
+
+// An OnsetDetector finds the starts of notes from the rise in level between
+// successive hops of a signal.
+type OnsetDetector struct {
+	// Threshold is how far the level must rise above the average of the
+	// last few hops, in log2 units.
+	Threshold float64
+
+	// MinLevel is the level, in log2 units, below which the signal is
+	// silence.
+	MinLevel float64
+
+	// MinInterval is the least number of hops between onsets.
+	MinInterval int
+
+	levels []float64
+	since  int
+}
+
+// The number of previous hops whose level an onset is measured against.
+const onsetHistory = 4
+
+func NewOnsetDetector(minInterval int) *OnsetDetector {
+	return &OnsetDetector{Threshold: 1, MinLevel: -10, MinInterval: minInterval, since: minInterval}
+}
+
+// Detect reports whether there is an onset in the hop x, and returns its
+// level in log2 units.
+func (o *OnsetDetector) Detect(x []float64) (onset bool, level float64) {
+	level = rmsLevel(x)
+	mean := 0.0
+	for _, l := range o.levels {
+		mean += l
+	}
+	if n := len(o.levels); n > 0 {
+		mean /= float64(n)
+	} else {
+		mean = silence
+	}
+	o.since++
+	if level > o.MinLevel && level-mean > o.Threshold && o.since >= o.MinInterval {
+		onset = true
+		o.since = 0
+	}
+	o.levels = append(o.levels, level)
+	if len(o.levels) > onsetHistory {
+		o.levels = o.levels[1:]
+	}
+	return
+}
+
+// The level of silence, in log2 units.
+const silence = -30
+
+// rmsLevel returns the log2 of the RMS amplitude of x.
+func rmsLevel(x []float64) float64 {
+	sum := 0.0
+	for _, x := range x {
+		sum += x * x
+	}
+	if sum == 0 {
+		return silence
+	}
+	return math.Max(silence, math.Log2(math.Sqrt(sum/float64(len(x)))))
+}
@@ -0,0 +1,178 @@
+// Package analysis detects the pitches and onsets in audio, to transcribe
+// sung or played material into notes.
+package analysis
+
+import "math"
+
+// A PitchMethod is an algorithm for finding the period of a signal.
+type PitchMethod int
+
+const (
+	// YIN finds the first dip in the cumulative mean normalized difference
+	// of the signal and its delayed copy that is below Threshold.
+	YIN PitchMethod = iota
+
+	// MPM, the McLeod pitch method, finds the first peak in the normalized
+	// square difference function that is at least Threshold times the
+	// highest peak.
+	MPM
+)
+
+// A PitchDetector finds the fundamental frequency of a window of samples.
+type PitchDetector struct {
+	Method     PitchMethod
+	SampleRate float64
+	Min, Max   float64 // the range of frequencies to look for, in Hz
+	Threshold  float64
+}
+
+// NewPitchDetector returns a PitchDetector for frequencies from 50 to 2000 Hz,
+// with the usual threshold for the method.
+func NewPitchDetector(method PitchMethod, sampleRate float64) *PitchDetector {
+	d := &PitchDetector{method, sampleRate, 50, 2000, .15}
+	if method == MPM {
+		d.Threshold = .9
+	}
+	return d
+}
+
+// WindowSize returns the number of samples that Detect needs to find the
+// lowest frequency:  two of its periods.
+func (d *PitchDetector) WindowSize() int {
+	return 2 * int(math.Ceil(d.SampleRate/d.Min))
+}
+
+// Detect returns the frequency of the signal in x, and its clarity, from 0
+// for noise to 1 for a pure periodic signal.  The frequency is 0 if none is
+// found.
+func (d *PitchDetector) Detect(x []float64) (freq, clarity float64) {
+	minLag := int(math.Floor(d.SampleRate / d.Max))
+	maxLag := int(math.Ceil(d.SampleRate / d.Min))
+	if minLag < 2 {
+		minLag = 2
+	}
+	if maxLag > len(x)/2 {
+		maxLag = len(x) / 2
+	}
+	if minLag >= maxLag {
+		return 0, 0
+	}
+	var lag float64
+	switch d.Method {
+	case YIN:
+		lag, clarity = d.yin(x, minLag, maxLag)
+	case MPM:
+		lag, clarity = d.mpm(x, minLag, maxLag)
+	default:
+		panic("unknown pitch method")
+	}
+	if lag == 0 {
+		return 0, clarity
+	}
+	return d.SampleRate / lag, clarity
+}
+
+func (d *PitchDetector) yin(x []float64, minLag, maxLag int) (lag, clarity float64) {
+	w := len(x) - maxLag - 1
+	diff := make([]float64, maxLag+2)
+	for tau := 1; tau < len(diff); tau++ {
+		for j := 0; j < w; j++ {
+			e := x[j] - x[j+tau]
+			diff[tau] += e * e
+		}
+	}
+	// cumulative mean normalized difference
+	sum := 0.0
+	cmnd := make([]float64, len(diff))
+	cmnd[0] = 1
+	for tau := 1; tau < len(diff); tau++ {
+		sum += diff[tau]
+		if sum == 0 {
+			cmnd[tau] = 1
+		} else {
+			cmnd[tau] = diff[tau] * float64(tau) / sum
+		}
+	}
+
+	best := -1
+	for tau := minLag; tau <= maxLag; tau++ {
+		if cmnd[tau] < d.Threshold {
+			for tau < maxLag && cmnd[tau+1] < cmnd[tau] {
+				tau++
+			}
+			best = tau
+			break
+		}
+	}
+	if best < 0 {
+		// no dip below the threshold:  take the lowest, as an unclear pitch
+		best = minLag
+		for tau := minLag; tau <= maxLag; tau++ {
+			if cmnd[tau] < cmnd[best] {
+				best = tau
+			}
+		}
+		return 0, math.Max(0, 1-cmnd[best])
+	}
+	return float64(best) + parabolicPeak(cmnd[best-1], cmnd[best], cmnd[best+1]), math.Max(0, 1-cmnd[best])
+}
+
+func (d *PitchDetector) mpm(x []float64, minLag, maxLag int) (lag, clarity float64) {
+	n := len(x)
+	nsdf := make([]float64, maxLag+2)
+	for tau := range nsdf {
+		r, m := 0.0, 0.0
+		for j := 0; j+tau < n; j++ {
+			r += x[j] * x[j+tau]
+			m += x[j]*x[j] + x[j+tau]*x[j+tau]
+		}
+		if m > 0 {
+			nsdf[tau] = 2 * r / m
+		}
+	}
+
+	// the highest peak of each positive region after the first zero crossing
+	peaks := []int{}
+	tau := 1
+	for tau < len(nsdf)-1 && nsdf[tau] > 0 {
+		tau++
+	}
+	for tau < len(nsdf)-1 {
+		for tau < len(nsdf)-1 && nsdf[tau] <= 0 {
+			tau++
+		}
+		peak := -1
+		for tau < len(nsdf)-1 && nsdf[tau] > 0 {
+			if tau >= minLag && tau <= maxLag && (peak < 0 || nsdf[tau] > nsdf[peak]) {
+				peak = tau
+			}
+			tau++
+		}
+		if peak >= 0 {
+			peaks = append(peaks, peak)
+		}
+	}
+	if len(peaks) == 0 {
+		return 0, 0
+	}
+	highest := 0.0
+	for _, p := range peaks {
+		highest = math.Max(highest, nsdf[p])
+	}
+	for _, p := range peaks {
+		if nsdf[p] >= d.Threshold*highest {
+			return float64(p) + parabolicPeak(nsdf[p-1], nsdf[p], nsdf[p+1]), nsdf[p]
+		}
+	}
+	panic("unreachable")
+}
+
+// parabolicPeak returns the offset from the middle of three equally spaced
+// points to the extremum of the parabola through them.
+func parabolicPeak(a, b, c float64) float64 {
+	d := a - 2*b + c
+	if d == 0 {
+		return 0
+	}
+	return (a - c) / (2 * d)
+}
@@ -0,0 +1,95 @@
+package analysis
+
+import (
+	"sync/atomic"
+
+	"code.google.com/p/gordon-go/audio"
+)
+
+// A Tap is a Voice that passes another Voice through unchanged while
+// analyzing it, such as an audio.Input for live transcription.  The analysis
+// runs on another goroutine; if it falls behind, whole buffers are dropped
+// and analyzed as silence rather than holding up the audio thread.
+type Tap struct {
+	v       audio.Voice
+	a       *Analyzer
+	buf     []float64
+	bufs    chan tapBuf
+	skipped int // samples dropped since the last buffer was handed over
+	done    chan bool
+	dropped int64 // samples; accessed atomically
+}
+
+// A tapBuf is a buffer of samples, after the given number of dropped samples.
+type tapBuf struct {
+	skipped int
+	x       []float64
+}
+
+// The number of samples that a Tap hands to its Analyzer at once.
+const tapBuffer = 1024
+
+func NewTap(v audio.Voice, a *Analyzer) *Tap {
+	return &Tap{v: v, a: a}
+}
+
+// InitAudio starts the analysis, at the playback rate.
+func (t *Tap) InitAudio(p audio.Params) {
+	audio.Init(t.v, p)
+	if t.bufs != nil {
+		return
+	}
+	if t.a.SampleRate != p.SampleRate {
+		t.a.reset(p.SampleRate)
+	}
+	bufs, done := make(chan tapBuf, 64), make(chan bool)
+	t.bufs, t.done = bufs, done
+	go func() {
+		for b := range bufs {
+			if b.skipped > 0 {
+				t.a.Write(make([]float64, b.skipped))
+			}
+			t.a.Write(b.x)
+		}
+		done <- true
+	}()
+}
+
+func (t *Tap) Sing() float64 {
+	x := t.v.Sing()
+	if t.bufs == nil {
+		return x
+	}
+	t.buf = append(t.buf, x)
+	if len(t.buf) == tapBuffer {
+		select {
+		case t.bufs <- tapBuf{t.skipped, t.buf}:
+			t.skipped = 0
+			t.buf = make([]float64, 0, tapBuffer)
+		default:
+			atomic.AddInt64(&t.dropped, tapBuffer)
+			t.skipped += tapBuffer
+			t.buf = t.buf[:0]
+		}
+	}
+	return x
+}
+
+func (t *Tap) Done() bool { return t.v.Done() }
+
+// Dropped returns the number of samples that were dropped because the
+// analysis couldn't keep up.  It may be called from any goroutine.
+func (t *Tap) Dropped() int64 { return atomic.LoadInt64(&t.dropped) }
+
+// Close analyzes the rest of the signal and flushes the Analyzer.  It must not
+// be called while the Tap is being played.
+func (t *Tap) Close() {
+	if t.bufs == nil {
+		return
+	}
+	t.bufs <- tapBuf{t.skipped, t.buf}
+	close(t.bufs)
+	<-t.done
+	t.bufs, t.buf, t.skipped = nil, nil, 0
+	t.a.Flush()
+}
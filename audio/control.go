@@ -7,6 +7,7 @@ type Control struct {
 	points  []*ControlPoint
 	periods []*controlPeriod
 	x       float64
+	mod     float64 // added by a Pattern's modulation
 }
 
 type ControlPoint struct {
@@ -67,7 +68,7 @@ func (c *Control) Sing() float64 {
 		c.x = p.value // this is necessary for zero-length controlPeriods that mark discontinuities
 		c.periods = c.periods[1:]
 	}
-	return c.x
+	return c.x + c.mod
 }
 
 func (c *Control) Done() bool {
@@ -0,0 +1,316 @@
+package audio
+
+import (
+	"fmt"
+	"math"
+	"math/cmplx"
+
+	"code.google.com/p/gordon-go/audio/pcm"
+)
+
+// A Convolver convolves a signal with an impulse response, without latency.
+// The first block of the response is convolved directly; the rest is split
+// into partitions that are convolved by FFT, each stage of partitions twice
+// the size of the one before, up to 64 blocks.  So the cost per sample grows
+// slowly with the length of the response, but it is uneven:  the larger
+// partitions are computed all at once, at the end of each of their blocks.
+type Convolver struct {
+	head   []float64
+	x      []float64 // the last len(head) input samples, doubled
+	j      int
+	stages []*convStage
+}
+
+// The largest partition, in blocks.
+const maxConvPartition = 64
+
+// NewConvolver returns a Convolver for the impulse response ir.  Block is the
+// size of the smallest partition, which must be a power of two; smaller blocks
+// cost more but spread the work more evenly.
+func NewConvolver(ir []float64, block int) *Convolver {
+	if block < 1 || block&(block-1) != 0 {
+		panic("NewConvolver:  block must be a power of two")
+	}
+	head := ir
+	if len(head) > block {
+		head = ir[:block]
+	}
+	c := &Convolver{head: append([]float64(nil), head...), x: make([]float64, 2*len(head))}
+
+	// The first stage starts one block in; each later stage starts two of
+	// its own blocks in and holds two partitions, except the last, which
+	// holds the rest.
+	for start, n := block, block; start < len(ir); n *= 2 {
+		parts := 2
+		if n == block {
+			parts = 3
+		}
+		if rest := (len(ir) - start + n - 1) / n; n == block*maxConvPartition || rest < parts {
+			parts = rest
+		}
+		c.stages = append(c.stages, newConvStage(ir, start, n, parts))
+		start += parts * n
+	}
+	return c
+}
+
+// Convolve returns the next sample of the convolution of the input with the
+// impulse response.
+func (c *Convolver) Convolve(x float64) float64 {
+	y := 0.0
+	if n := len(c.head); n > 0 {
+		c.x[c.j], c.x[c.j+n] = x, x
+		c.j = (c.j + 1) % n
+		w := c.x[c.j : c.j+n] // oldest first
+		for k, h := range c.head {
+			y += h * w[n-1-k]
+		}
+	}
+	for _, s := range c.stages {
+		y += s.process(x)
+	}
+	return y
+}
+
+// Reset clears the input history, silencing the tail of the convolution.
+func (c *Convolver) Reset() {
+	for i := range c.x {
+		c.x[i] = 0
+	}
+	c.j = 0
+	for _, s := range c.stages {
+		s.reset()
+	}
+}
+
+// A convStage convolves with partitions of n taps by uniformly partitioned
+// overlap-save.  The input is buffered in blocks of n; at the end of each
+// block, the spectrum of the last two blocks is added to a delay line and
+// the output for the next block is computed.  So the first partition must
+// start at least one block into the impulse response, and it starts q blocks
+// in.
+type convStage struct {
+	n, q  int
+	fft   *fft
+	h     [][]complex128 // the spectra of the partitions
+	s     [][]complex128 // the spectra of the input, a ring indexed by block
+	k     int            // the number of blocks completed
+	in    []float64      // the last two blocks of input
+	out   []float64      // the output for this block
+	i     int
+	accum []complex128
+}
+
+func newConvStage(ir []float64, start, n, parts int) *convStage {
+	s := &convStage{n: n, q: start / n, fft: newFFT(2 * n), in: make([]float64, 2*n), out: make([]float64, n), accum: make([]complex128, 2*n)}
+	for p := 0; p < parts; p++ {
+		h := make([]complex128, 2*n)
+		for i := 0; i < n && start+p*n+i < len(ir); i++ {
+			h[i] = complex(ir[start+p*n+i], 0)
+		}
+		s.fft.transform(h, false)
+		s.h = append(s.h, h)
+	}
+	for i := 0; i < parts+s.q-1; i++ {
+		s.s = append(s.s, make([]complex128, 2*n))
+	}
+	return s
+}
+
+func (s *convStage) process(x float64) float64 {
+	s.in[s.n+s.i] = x
+	y := s.out[s.i]
+	s.i++
+	if s.i == s.n {
+		s.block()
+		s.i = 0
+	}
+	return y
+}
+
+func (s *convStage) block() {
+	spec := s.s[s.k%len(s.s)]
+	for i, x := range s.in {
+		spec[i] = complex(x, 0)
+	}
+	s.fft.transform(spec, false)
+	copy(s.in, s.in[s.n:])
+
+	for i := range s.accum {
+		s.accum[i] = 0
+	}
+	for p, h := range s.h {
+		k := s.k + 1 - s.q - p
+		if k < 0 {
+			break
+		}
+		x := s.s[k%len(s.s)]
+		for i := range s.accum {
+			s.accum[i] += x[i] * h[i]
+		}
+	}
+	s.fft.transform(s.accum, true)
+	for i := range s.out {
+		s.out[i] = real(s.accum[s.n+i])
+	}
+	s.k++
+}
+
+func (s *convStage) reset() {
+	for i := range s.in {
+		s.in[i] = 0
+	}
+	for i := range s.out {
+		s.out[i] = 0
+	}
+	s.i, s.k = 0, 0
+}
+
+// An fft computes discrete Fourier transforms of a power-of-two size, in
+// place.
+type fft struct {
+	rev     []int
+	twiddle []complex128 // exp(-2πik/n) for k < n/2
+}
+
+func newFFT(n int) *fft {
+	f := &fft{rev: make([]int, n), twiddle: make([]complex128, n/2)}
+	bits := uint(0)
+	for 1<<bits < n {
+		bits++
+	}
+	for i := range f.rev {
+		r := 0
+		for b := uint(0); b < bits; b++ {
+			r |= (i >> b & 1) << (bits - 1 - b)
+		}
+		f.rev[i] = r
+	}
+	for k := range f.twiddle {
+		f.twiddle[k] = cmplx.Exp(complex(0, -2*math.Pi*float64(k)/float64(n)))
+	}
+	return f
+}
+
+// transform replaces x with its transform, or with its inverse transform,
+// scaled by 1/n.
+func (f *fft) transform(x []complex128, inverse bool) {
+	n := len(x)
+	for i, r := range f.rev {
+		if i < r {
+			x[i], x[r] = x[r], x[i]
+		}
+	}
+	for size := 2; size <= n; size *= 2 {
+		half, step := size/2, n/size
+		for start := 0; start < n; start += size {
+			for k := 0; k < half; k++ {
+				w := f.twiddle[k*step]
+				if inverse {
+					w = cmplx.Conj(w)
+				}
+				a, b := x[start+k], x[start+k+half]*w
+				x[start+k], x[start+k+half] = a+b, a-b
+			}
+		}
+	}
+	if inverse {
+		scale := complex(1/float64(n), 0)
+		for i := range x {
+			x[i] *= scale
+		}
+	}
+}
+
+// A Convolution is a convolution reverb, or any other effect made from an
+// impulse response, such as a speaker cabinet.  It is an Effect, so it can be
+// the instrument of a part in a Band; or it can process a Voice with Convolve.
+type Convolution struct {
+	Wet, Dry Control // gains in log2 units, like Strip.Gain
+	ir       []float64
+	rate     float64 // the sample rate of ir
+	c        *Convolver
+	n        int // the length of ir at the playback rate
+}
+
+// The smallest partition of a Convolution's impulse response.
+const convolutionBlock = 64
+
+// NewConvolution returns a Convolution with the impulse response ir, recorded
+// at the given sample rate.  It is resampled to the playback rate.
+func NewConvolution(ir []float64, sampleRate float64) *Convolution {
+	return &Convolution{ir: ir, rate: sampleRate}
+}
+
+// LoadConvolution reads an impulse response from an audio file.  A response
+// with more than one channel is mixed down to mono.
+func LoadConvolution(filename string) (*Convolution, error) {
+	r, err := pcm.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	x, err := pcm.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", filename, err)
+	}
+	f := r.Format()
+	ir := make([]float64, len(x)/f.Channels)
+	for i := range ir {
+		for ch := 0; ch < f.Channels; ch++ {
+			ir[i] += x[i*f.Channels+ch]
+		}
+		ir[i] /= float64(f.Channels)
+	}
+	return NewConvolution(ir, float64(f.SampleRate)), nil
+}
+
+func (c *Convolution) InitAudio(p Params) {
+	c.Wet.InitAudio(p)
+	c.Dry.InitAudio(p)
+	ir := c.ir
+	if p.SampleRate != c.rate {
+		ir = Resample(ir, c.rate, p.SampleRate)
+	}
+	c.c = NewConvolver(ir, convolutionBlock)
+	c.n = len(ir)
+}
+
+func (c *Convolution) Process(x float64) float64 {
+	return math.Exp2(c.Dry.Sing())*x + math.Exp2(c.Wet.Sing())*c.c.Convolve(x)
+}
+
+func (c *Convolution) Play(struct{}) {}
+func (c *Convolution) Sing() float64 { return 0 }
+func (c *Convolution) Done() bool    { return true }
+func (c *Convolution) Stop()         { c.c.Reset() }
+
+// A ConvolvedVoice is a Voice processed by a Convolution.  It is done when the
+// Voice is done and the tail of the convolution has rung out.
+type ConvolvedVoice struct {
+	v    Voice
+	c    *Convolution
+	tail int
+}
+
+func Convolve(v Voice, c *Convolution) *ConvolvedVoice {
+	return &ConvolvedVoice{v: v, c: c}
+}
+
+func (v *ConvolvedVoice) InitAudio(p Params) {
+	Init(v.v, p)
+	v.c.InitAudio(p)
+	v.tail = 0
+}
+
+func (v *ConvolvedVoice) Sing() float64 {
+	x := 0.0
+	if v.v.Done() {
+		v.tail++
+	} else {
+		x = v.v.Sing()
+	}
+	return v.c.Process(x)
+}
+
+func (v *ConvolvedVoice) Done() bool { return v.v.Done() && v.tail >= v.c.n }
@@ -0,0 +1,76 @@
+package audio
+
+import (
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"code.google.com/p/gordon-go/audio/pcm"
+)
+
+func TestConvolver(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	x := make([]float64, 20000)
+	for i := range x {
+		x[i] = r.Float64()*2 - 1
+	}
+	for _, test := range []struct{ len, block int }{
+		{0, 4},
+		{3, 4},
+		{4, 4},
+		{100, 1},
+		{1000, 16},
+		{5000, 8}, // reaches the largest partitions
+	} {
+		ir := make([]float64, test.len)
+		for i := range ir {
+			ir[i] = (r.Float64()*2 - 1) * math.Exp(-float64(i)/1000)
+		}
+		c := NewConvolver(ir, test.block)
+		for i := range x {
+			y := c.Convolve(x[i])
+			want := 0.0
+			for k, h := range ir {
+				if k <= i {
+					want += h * x[i-k]
+				}
+			}
+			if math.Abs(y-want) > 1e-9 {
+				t.Errorf("%d taps in blocks of %d: sample %d is %v; want %v", test.len, test.block, i, y, want)
+				break
+			}
+		}
+	}
+}
+
+func TestConvolution(t *testing.T) {
+	dir, err := ioutil.TempDir("", "convolve")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// A stereo response, mixed down to 1, .5, .25.
+	filename := filepath.Join(dir, "ir.wav")
+	writeTestWAV(t, filename, pcm.Format{SampleRate: 100, Channels: 2, Bits: 32, Float: true}, []float64{1, 1, 0, 1, .5, 0})
+	c, err := LoadConvolution(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Dry.SetPoints([]*ControlPoint{{0, -1}})
+	v := Convolve(&testVoice{NewControl([]*ControlPoint{{0, 1}, {.01, 1}})}, c)
+	Init(v, Params{SampleRate: 100})
+	// Two samples of 1, convolved and mixed with half of the dry signal.
+	want := []float64{1 + .5, 1.5 + .5, .75, .25, 0}
+	for i := 0; !v.Done(); i++ {
+		if i == len(want) {
+			t.Fatal("convolved voice didn't finish")
+		}
+		if y := v.Sing(); math.Abs(y-want[i]) > 1e-9 {
+			t.Errorf("sample %d is %v; want %v", i, y, want[i])
+		}
+	}
+}
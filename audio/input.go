@@ -0,0 +1,152 @@
+package audio
+
+import (
+	"fmt"
+
+	"code.google.com/p/gordon-go/audio/pcm"
+)
+
+// A Capturer supplies captured audio to an Input, on the audio thread.
+type Capturer interface {
+	Channels() int
+
+	// Capture fills frame, of length Channels, with the next frame of
+	// input.  It returns false when there is no more input.
+	Capture(frame []float64) bool
+}
+
+// An Input is a Voice that sings captured audio.  It takes a frame from its
+// Capturer each time it sings, so it should be sung exactly once per sample.
+type Input struct {
+	c     Capturer
+	frame []float64
+	done  bool
+}
+
+func NewInput(c Capturer) *Input {
+	return &Input{c: c, frame: make([]float64, c.Channels())}
+}
+
+func (in *Input) InitAudio(p Params) { Init(in.c, p) }
+
+func (in *Input) capture() {
+	if !in.done && !in.c.Capture(in.frame) {
+		in.done = true
+	}
+	if in.done {
+		for i := range in.frame {
+			in.frame[i] = 0
+		}
+	}
+}
+
+// Sing returns the average of the input channels.
+func (in *Input) Sing() float64 {
+	in.capture()
+	x := 0.0
+	for _, y := range in.frame {
+		x += y
+	}
+	return x / float64(len(in.frame))
+}
+
+// SingStereo returns the first two input channels, or the only channel twice.
+func (in *Input) SingStereo() (l, r float64) {
+	in.capture()
+	if len(in.frame) == 1 {
+		return in.frame[0], in.frame[0]
+	}
+	return in.frame[0], in.frame[1]
+}
+
+func (in *Input) Done() bool { return in.done }
+
+// A LiveCapture captures from the default input device while it is played
+// with PlayDuplex.
+type LiveCapture struct {
+	channels int
+	buf      []float32
+	i        int
+}
+
+func NewLiveCapture(channels int) *LiveCapture {
+	return &LiveCapture{channels: channels}
+}
+
+func (c *LiveCapture) Channels() int { return c.channels }
+
+// startBuffer hands the capture the input for the buffer about to be played.
+func (c *LiveCapture) startBuffer(in []float32) {
+	c.buf, c.i = in, 0
+}
+
+// Capture never runs out; if it is called more than once per sample it
+// returns silence until the next buffer.
+func (c *LiveCapture) Capture(frame []float64) bool {
+	if c.i+c.channels > len(c.buf) {
+		for i := range frame {
+			frame[i] = 0
+		}
+		return true
+	}
+	for i := range frame {
+		frame[i] = float64(c.buf[c.i+i])
+	}
+	c.i += c.channels
+	return true
+}
+
+// A FileCapture captures from an audio file, resampled to the playback rate.
+// It stands in for a live input in tests and offline renders.
+type FileCapture struct {
+	f       pcm.Format
+	samples []float64 // as read
+	x       []float64 // at the playback rate
+	i       int
+}
+
+func NewFileCapture(filename string) (*FileCapture, error) {
+	r, err := pcm.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	x, err := pcm.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", filename, err)
+	}
+	return &FileCapture{f: r.Format(), samples: x, x: x}, nil
+}
+
+func (c *FileCapture) InitAudio(p Params) {
+	c.x = c.samples
+	if from := float64(c.f.SampleRate); p.SampleRate != from {
+		n := c.f.Channels
+		channels := make([][]float64, n)
+		for ch := range channels {
+			x := make([]float64, len(c.samples)/n)
+			for i := range x {
+				x[i] = c.samples[i*n+ch]
+			}
+			channels[ch] = Resample(x, from, p.SampleRate)
+		}
+		c.x = make([]float64, n*len(channels[0]))
+		for ch, x := range channels {
+			for i, x := range x {
+				c.x[i*n+ch] = x
+			}
+		}
+	}
+	c.i = 0
+}
+
+func (c *FileCapture) Channels() int { return c.f.Channels }
+
+func (c *FileCapture) Capture(frame []float64) bool {
+	if c.i >= len(c.x) {
+		return false
+	}
+	copy(frame, c.x[c.i:])
+	c.i += len(frame)
+	return true
+}
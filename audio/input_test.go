@@ -0,0 +1,122 @@
+package audio
+
+import (
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"code.google.com/p/gordon-go/audio/pcm"
+)
+
+func writeTestWAV(t *testing.T, filename string, f pcm.Format, x []float64) {
+	w, err := pcm.Create(filename, f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Write(x); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRecordInput(t *testing.T) {
+	dir, err := ioutil.TempDir("", "input")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	x := make([]float64, 2*10000)
+	for i := range x {
+		x[i] = math.Sin(float64(i) / 7)
+	}
+	in := filepath.Join(dir, "in.wav")
+	writeTestWAV(t, in, pcm.Format{SampleRate: 48000, Channels: 2, Bits: 32, Float: true}, x)
+
+	c, err := NewFileCapture(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := filepath.Join(dir, "out.wav")
+	r := NewRecorder(NewInput(c), out, pcm.Format{})
+	Init(r, Params{48000})
+	for !r.Done() {
+		r.SingStereo()
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if r.Dropped() != 0 {
+		t.Errorf("dropped %d frames", r.Dropped())
+	}
+
+	f, err := pcm.Open(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if f.Format().Channels != 2 || f.Format().SampleRate != 48000 {
+		t.Errorf("recorded format %v", f.Format())
+	}
+	y, err := pcm.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The recording includes the silent sample on which the input ran out.
+	if len(y) != len(x)+2 {
+		t.Fatalf("recorded %d samples; want %d", len(y), len(x)+2)
+	}
+	for i := range x {
+		if y[i] != float64(float32(x[i])) {
+			t.Fatalf("sample %d is %v; want %v", i, y[i], x[i])
+		}
+	}
+}
+
+func TestFileCaptureResamples(t *testing.T) {
+	dir, err := ioutil.TempDir("", "input")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	x := sineWave(1000, 48000, 4800)
+	in := filepath.Join(dir, "in.wav")
+	writeTestWAV(t, in, pcm.Format{SampleRate: 48000, Channels: 1, Bits: 32, Float: true}, x)
+	c, err := NewFileCapture(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := NewInput(c)
+	Init(input, Params{96000})
+	n := 0
+	for ; !input.Done(); n++ {
+		input.Sing()
+	}
+	if n < 2*len(x) || n > 2*len(x)+100 {
+		t.Errorf("sang %d samples; want about %d", n, 2*len(x))
+	}
+}
+
+func TestLiveCapture(t *testing.T) {
+	c := NewLiveCapture(2)
+	in := NewInput(c)
+	c.startBuffer([]float32{1, 2, 3, 4})
+	if l, r := in.SingStereo(); l != 1 || r != 2 {
+		t.Errorf("got %v, %v; want 1, 2", l, r)
+	}
+	if x := in.Sing(); x != 3.5 {
+		t.Errorf("got %v; want 3.5", x)
+	}
+	if x := in.Sing(); x != 0 || in.Done() {
+		t.Errorf("got %v past the end of the buffer; want silence", x)
+	}
+	c.startBuffer([]float32{5, 6})
+	if l, r := in.SingStereo(); l != 5 || r != 6 {
+		t.Errorf("got %v, %v; want 5, 6", l, r)
+	}
+}
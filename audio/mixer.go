@@ -0,0 +1,229 @@
+package audio
+
+import (
+	"math"
+	"sort"
+)
+
+// A Strip holds the mixer settings for a Part.  Gain and send levels are in
+// log2 units, like Amplitude attributes; Pan ranges from -1 (left) to 1
+// (right).  All three are automated by control points on the score's timeline.
+type Strip struct {
+	Gain, Pan  []*ControlPoint
+	Mute, Solo bool
+
+	// Inserts names the Effect parts that process this part's signal, in order.
+	// An Effect has one state, so it may be inserted in only one part:  if
+	// several name it, only the first of them by name gets it, and in the
+	// others it is skipped.
+	Inserts []string
+
+	// Sends maps the name of an Effect part to the level of this part's
+	// (post-fader) signal that is sent to it.
+	Sends map[string][]*ControlPoint
+}
+
+// An Effect processes a signal.  An Instrument that is also an Effect is not
+// mixed as a source; instead its part acts as an insert on the parts that
+// name it in their Strip.Inserts, or otherwise as an aux bus fed by the
+// Strip.Sends of other parts.  Either way, patterns in its part automate it.
+type Effect interface {
+	Process(x float64) float64
+}
+
+func (s *Strip) copy() *Strip {
+	if s == nil {
+		return nil
+	}
+	c := &Strip{
+		Gain:    copyPoints(s.Gain),
+		Pan:     copyPoints(s.Pan),
+		Mute:    s.Mute,
+		Solo:    s.Solo,
+		Inserts: append([]string(nil), s.Inserts...),
+	}
+	if s.Sends != nil {
+		c.Sends = copyAttributes(s.Sends)
+	}
+	return c
+}
+
+// A mixer plays the instruments of a ScorePlayer's band through their parts'
+// Strips.  Commit builds one with each snapshot, and it belongs to the audio
+// thread once the ScorePlayer picks that snapshot up.
+type mixer struct {
+	sources  []*channel
+	buses    []*channel
+	channels map[string]*channel
+	stem     string // if not empty, the only channel that is output
+}
+
+type channel struct {
+	name      string
+	inst      Instrument
+	inserts   []Effect
+	gain, pan Control
+	sends     []send
+	mute      bool
+	in        float64 // for buses, the sum of the sends to it
+	out       float64 // the last output of inst, for envelope followers
+}
+
+type send struct {
+	level Control
+	bus   *channel
+}
+
+func newMixer(snap *scoreSnapshot, instruments map[string]Instrument, params Params, t float64, stem string) *mixer {
+	m := &mixer{stem: stem}
+	parts := []string{}
+	for name := range snap.strips {
+		parts = append(parts, name)
+	}
+	sort.Strings(parts)
+	inserted := map[string]string{} // the part into which each insert goes
+	solo := false
+	for _, part := range parts {
+		s := snap.strips[part]
+		if s == nil {
+			continue
+		}
+		for _, name := range s.Inserts {
+			if _, ok := inserted[name]; !ok {
+				inserted[name] = part
+			}
+		}
+		solo = solo || s.Solo
+	}
+
+	channels := map[string]*channel{}
+	names := []string{}
+	for name := range instruments {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		inst := instruments[name]
+		if _, ok := inserted[name]; ok {
+			continue
+		}
+		c := &channel{name: name, inst: inst}
+		channels[name] = c
+		if _, ok := inst.(Effect); ok {
+			m.buses = append(m.buses, c)
+		} else {
+			m.sources = append(m.sources, c)
+		}
+	}
+
+	for name, c := range channels {
+		s := snap.strips[name]
+		if s == nil {
+			s = &Strip{}
+		}
+		_, isBus := c.inst.(Effect)
+		c.mute = s.Mute || solo && !s.Solo && !isBus
+		for _, ins := range s.Inserts {
+			if e, ok := instruments[ins].(Effect); ok && inserted[ins] == name {
+				c.inserts = append(c.inserts, e)
+			}
+		}
+		initControl(&c.gain, s.Gain, params, t)
+		initControl(&c.pan, s.Pan, params, t)
+		if isBus {
+			continue // buses don't feed each other
+		}
+		sends := []string{}
+		for name := range s.Sends {
+			sends = append(sends, name)
+		}
+		sort.Strings(sends)
+		for _, name := range sends {
+			if bus, ok := channels[name]; ok {
+				if _, ok := bus.inst.(Effect); ok {
+					snd := send{bus: bus}
+					initControl(&snd.level, s.Sends[name], params, t)
+					c.sends = append(c.sends, snd)
+				}
+			}
+		}
+	}
+	m.channels = channels
+	return m
+}
+
+// advance moves the mixer's controls on by n samples, as if it had played
+// them, without allocating.
+func (m *mixer) advance(n int) {
+	for _, c := range m.channels {
+		for i := 0; i < n; i++ {
+			c.gain.Sing()
+			c.pan.Sing()
+			for j := range c.sends {
+				c.sends[j].level.Sing()
+			}
+		}
+	}
+}
+
+// level returns the last output of the named part's instrument, before its
+// inserts and fader.
+func (m *mixer) level(part string) float64 {
+	if c, ok := m.channels[part]; ok {
+		return c.out
+	}
+	return 0
+}
+
+func initControl(c *Control, points []*ControlPoint, params Params, t float64) {
+	c.InitAudio(params)
+	c.SetPoints(points)
+	c.SetTime(t)
+}
+
+func (m *mixer) sing() (l, r float64) {
+	for _, c := range m.sources {
+		x := c.inst.Sing()
+		c.out = x
+		for _, e := range c.inserts {
+			x = e.Process(x)
+		}
+		x = c.fader(x)
+		for i := range c.sends {
+			s := &c.sends[i]
+			s.bus.in += x * math.Exp2(s.level.Sing())
+		}
+		l, r = m.add(c, l, r, x)
+	}
+	for _, c := range m.buses {
+		x := c.inst.(Effect).Process(c.in)
+		c.in = 0
+		c.out = x
+		for _, e := range c.inserts {
+			x = e.Process(x)
+		}
+		l, r = m.add(c, l, r, c.fader(x))
+	}
+	return
+}
+
+// fader applies the channel's gain and mute.  The gain control keeps moving
+// while muted so that automation stays in time.
+func (c *channel) fader(x float64) float64 {
+	g := math.Exp2(c.gain.Sing())
+	if c.mute {
+		return 0
+	}
+	return g * x
+}
+
+// add pans the output x of channel c into l and r.  A centered signal goes to
+// both sides at full level, so that the average of the two is the unpanned
+// signal.
+func (m *mixer) add(c *channel, l, r, x float64) (float64, float64) {
+	p := math.Max(-1, math.Min(1, c.pan.Sing()))
+	if m.stem != "" && c.name != m.stem {
+		return l, r
+	}
+	return l + x*math.Min(1, 1-p), r + x*math.Min(1, 1+p)
+}
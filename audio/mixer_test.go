@@ -0,0 +1,120 @@
+package audio
+
+import (
+	"math"
+	"testing"
+)
+
+type mixerBand struct {
+	A, B  constInst
+	Delay testEffect
+}
+
+type constInst struct {
+	x float64
+}
+
+func (c *constInst) Play(struct{}) {}
+func (c *constInst) Sing() float64 { return c.x }
+func (c *constInst) Done() bool    { return true }
+func (c *constInst) Stop()         {}
+
+// testEffect doubles its input and delays it by one sample.
+type testEffect struct {
+	last float64
+}
+
+func (e *testEffect) Play(struct{}) {}
+func (e *testEffect) Sing() float64 { return 0 }
+func (e *testEffect) Done() bool    { return true }
+func (e *testEffect) Stop()         {}
+
+func (e *testEffect) Process(x float64) float64 {
+	y := e.last
+	e.last = 2 * x
+	return y
+}
+
+func TestMixer(t *testing.T) {
+	points := func(v float64) []*ControlPoint { return []*ControlPoint{{0, v}} }
+	for _, test := range []struct {
+		name    string
+		a, b, d *Strip
+		l, r    float64 // after the second sample
+	}{
+		{"unity", nil, nil, nil, 3, 3},
+		{"gain and pan", &Strip{Gain: points(1), Pan: points(-1)}, &Strip{Pan: points(.5)}, nil, 2 + 2*.5, 0 + 2},
+		{"mute", &Strip{Mute: true}, nil, nil, 2, 2},
+		{"solo", nil, &Strip{Solo: true}, nil, 2, 2},
+		{"send", &Strip{Sends: map[string][]*ControlPoint{"Delay": points(-1)}}, nil, &Strip{Gain: points(2)}, 3 + 4, 3 + 4},
+		{"insert", &Strip{Inserts: []string{"Delay"}}, nil, nil, 2 + 2, 2 + 2},
+		{"shared insert", &Strip{Inserts: []string{"Delay"}}, &Strip{Inserts: []string{"Delay"}}, nil, 2 + 2, 2 + 2},
+		{"muted bus", &Strip{Sends: map[string][]*ControlPoint{"Delay": points(0)}}, nil, &Strip{Mute: true}, 3, 3},
+	} {
+		band := &mixerBand{A: constInst{1}, B: constInst{2}}
+		score := &Score{[]*Part{{"A", nil, test.a}, {"B", nil, test.b}, {"Delay", nil, test.d}}}
+		p := NewScorePlayer(score, band)
+		Init(p, Params{SampleRate: 100})
+		p.SingStereo()
+		if l, r := p.SingStereo(); l != test.l || r != test.r {
+			t.Errorf("%s: got (%v, %v); want (%v, %v)", test.name, l, r, test.l, test.r)
+		}
+	}
+}
+
+func TestStems(t *testing.T) {
+	points := func(v float64) []*ControlPoint { return []*ControlPoint{{0, v}} }
+	score := &Score{[]*Part{
+		{"A", nil, &Strip{Pan: points(-.5), Sends: map[string][]*ControlPoint{"Delay": points(-1)}}},
+		{"B", nil, &Strip{Gain: points(-1), Sends: map[string][]*ControlPoint{"Delay": points(0)}}},
+		{"Delay", nil, &Strip{Pan: points(1)}},
+	}}
+	render := func(stem string) (l, r float64) {
+		p := NewScorePlayer(score, &mixerBand{A: constInst{1}, B: constInst{2}})
+		p.Stem(stem)
+		Init(p, Params{SampleRate: 100})
+		p.SingStereo()
+		return p.SingStereo()
+	}
+	var l, r float64
+	for _, stem := range []string{"A", "B", "Delay"} {
+		l1, r1 := render(stem)
+		l, r = l+l1, r+r1
+	}
+	if l0, r0 := render(""); l != l0 || r != r0 {
+		t.Errorf("stems sum to (%v, %v); want (%v, %v)", l, r, l0, r0)
+	}
+}
+
+// TestMixerCommit checks that a mixer built by Commit is caught up to the
+// player's position when the audio thread picks it up.
+func TestMixerCommit(t *testing.T) {
+	ramp := func(v float64) []*ControlPoint { return []*ControlPoint{{0, 0}, {1, v}} }
+	score := &Score{[]*Part{
+		{"A", nil, &Strip{Gain: ramp(-2), Pan: ramp(1), Sends: map[string][]*ControlPoint{"Delay": ramp(-1)}}},
+		{"B", nil, nil},
+		{"Delay", nil, &Strip{Gain: ramp(1)}},
+	}}
+	newPlayer := func() *ScorePlayer {
+		p := NewScorePlayer(score, &mixerBand{A: constInst{1}, B: constInst{2}})
+		Init(p, Params{SampleRate: 100})
+		return p
+	}
+	p1, p2 := newPlayer(), newPlayer()
+	p1.Commit()
+	for i := 0; i < 50; i++ {
+		p1.SingStereo()
+		p2.SingStereo()
+	}
+	p1.StartBuffer()
+	if p1.mixer != p1.snap.mixer {
+		t.Error("StartBuffer rebuilt the committed mixer")
+	}
+	for i := 0; i < 10; i++ {
+		l1, r1 := p1.SingStereo()
+		l2, r2 := p2.SingStereo()
+		if math.Abs(l1-l2) > 1e-9 || math.Abs(r1-r2) > 1e-9 {
+			t.Fatalf("sample %d after the commit: got (%v, %v); want (%v, %v)", i, l1, r1, l2, r2)
+		}
+	}
+}
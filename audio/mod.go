@@ -0,0 +1,331 @@
+package audio
+
+import "math"
+
+// Modulation routes modulation sources to the parameters of a Pattern's
+// instrument.
+type Modulation struct {
+	Tempo  float64 // in beats per minute, for LFOs with Sync set
+	Routes []*Route
+}
+
+// A Route adds a source, shaped by Curve and scaled by Depth, to a target.
+// The target is one of the instrument's exported Controls or, for a source
+// that is per-voice, one of its note attributes.  Depth is in the target's
+// units and is automated on the pattern's timeline.  A positive Curve bends
+// the source's value v to |v|^(2^Curve), keeping its sign, so that small
+// values move the target less; a negative Curve does the opposite.
+type Route struct {
+	Source ModSource
+	Target string
+	Depth  []*ControlPoint
+	Curve  float64
+}
+
+// A ModSource is an LFO or an EnvFollower.
+type ModSource interface {
+	newSignal(ctx *modContext, t float64) modSignal
+	copySource() ModSource
+}
+
+type modSignal interface {
+	next() float64
+}
+
+// An LFO is a low-frequency oscillator ranging from -1 to 1.  A global LFO
+// runs on the pattern's timeline, so it is in the same state whenever playback
+// passes a given time.  A PerVoice LFO starts afresh with each note and
+// modulates the note's attributes.
+type LFO struct {
+	Shape    LFOShape
+	Rate     float64 // in cycles per second, or per beat if Sync is set
+	Sync     bool
+	Phase    float64 // in cycles, at time zero
+	PerVoice bool
+}
+
+type LFOShape int
+
+const (
+	Sine LFOShape = iota
+	Triangle
+	Saw  // rising
+	Ramp // falling
+	Square
+	Random       // a new random value each cycle
+	SmoothRandom // random values joined by smooth curves
+)
+
+var LFOShapes = []string{"sine", "triangle", "saw", "ramp", "square", "random", "smooth random"}
+
+func (s LFOShape) String() string { return LFOShapes[s] }
+
+// An EnvFollower follows the level of an instrument's output, ranging from 0
+// up.  Part names the part whose instrument is followed; if it is empty, it is
+// the modulated instrument itself.
+type EnvFollower struct {
+	Part            string
+	Attack, Release float64 // in seconds, to move 99% of the way
+}
+
+// A modContext gives modulation sources what they need from the player.
+type modContext struct {
+	params Params
+	tempo  float64
+	level  func(part string) float64 // the last output of the part's instrument
+}
+
+func (l *LFO) copySource() ModSource { c := *l; return &c }
+
+// cyclesPerSecond returns the LFO's rate in Hz.
+func (l *LFO) cyclesPerSecond(tempo float64) float64 {
+	if l.Sync {
+		return l.Rate * tempo / 60
+	}
+	return l.Rate
+}
+
+func (l *LFO) newSignal(ctx *modContext, t float64) modSignal {
+	rate := l.cyclesPerSecond(ctx.tempo)
+	return &lfoSignal{l.Shape, l.Phase + rate*t, rate / ctx.params.SampleRate}
+}
+
+type lfoSignal struct {
+	shape        LFOShape
+	phase, delta float64 // in cycles
+}
+
+func (s *lfoSignal) next() float64 {
+	x := lfoValue(s.shape, s.phase)
+	s.phase += s.delta
+	return x
+}
+
+// lfoValue returns the value of an LFO of the given shape at the given phase.
+func lfoValue(shape LFOShape, phase float64) float64 {
+	cycle := math.Floor(phase)
+	p := phase - cycle
+	switch shape {
+	case Sine:
+		return math.Sin(2 * math.Pi * p)
+	case Triangle:
+		return 1 - 4*math.Abs(p-.5)
+	case Saw:
+		return 2*p - 1
+	case Ramp:
+		return 1 - 2*p
+	case Square:
+		if p < .5 {
+			return 1
+		}
+		return -1
+	case Random:
+		return noise(cycle)
+	case SmoothRandom:
+		a, b := noise(cycle), noise(cycle+1)
+		return a + (b-a)*(1-math.Cos(math.Pi*p))/2
+	}
+	panic("unknown LFO shape")
+}
+
+// noise returns a pseudorandom value between -1 and 1 that depends only on n.
+func noise(n float64) float64 {
+	x := uint64(int64(n)) * 0x9E3779B97F4A7C15
+	x ^= x >> 31
+	x *= 0xBF58476D1CE4E5B9
+	x ^= x >> 27
+	return float64(x>>11)/(1<<52) - 1
+}
+
+func (e *EnvFollower) copySource() ModSource { c := *e; return &c }
+
+func (e *EnvFollower) newSignal(ctx *modContext, t float64) modSignal {
+	coef := func(time float64) float64 {
+		if time <= 0 {
+			return 0
+		}
+		return math.Pow(.01, 1/(time*ctx.params.SampleRate))
+	}
+	return &envSignal{e.Part, ctx.level, coef(e.Attack), coef(e.Release), 0}
+}
+
+type envSignal struct {
+	part            string
+	level           func(string) float64
+	attack, release float64
+	env             float64
+}
+
+func (s *envSignal) next() float64 {
+	x := math.Abs(s.level(s.part))
+	a := s.release
+	if x > s.env {
+		a = s.attack
+	}
+	s.env = x + (s.env-x)*a
+	return s.env
+}
+
+func (r *Route) shape(v float64) float64 {
+	if r.Curve == 0 {
+		return v
+	}
+	return math.Copysign(math.Pow(math.Abs(v), math.Exp2(r.Curve)), v)
+}
+
+func (r *Route) perVoice() bool {
+	l, ok := r.Source.(*LFO)
+	return ok && l.PerVoice
+}
+
+func (m *Modulation) copy() *Modulation {
+	if m == nil {
+		return nil
+	}
+	c := &Modulation{Tempo: m.Tempo}
+	for _, r := range m.Routes {
+		c.Routes = append(c.Routes, &Route{r.Source.copySource(), r.Target, copyPoints(r.Depth), r.Curve})
+	}
+	return c
+}
+
+// A modMatrix applies a Pattern's global routes to its instrument's Controls,
+// once per sample, and its per-voice routes to each note as it is played.
+type modMatrix struct {
+	ctx     *modContext
+	global  []*modRoute
+	voice   []*Route
+	targets []*Control
+}
+
+type modRoute struct {
+	signal modSignal
+	route  *Route
+	depth  Control
+	target *Control
+}
+
+func newModMatrix(m *Modulation, inst Instrument, params Params, t float64, level func(string) float64) *modMatrix {
+	if m == nil || len(m.Routes) == 0 {
+		return nil
+	}
+	ctx := &modContext{params, m.Tempo, level}
+	mm := &modMatrix{ctx: ctx}
+	controls := map[string]*Control{}
+	for _, c := range InstrumentControls(inst) {
+		controls[c.Name] = c.Control
+	}
+	targeted := map[*Control]bool{}
+	for _, r := range m.Routes {
+		if r.perVoice() {
+			mm.voice = append(mm.voice, r)
+			continue
+		}
+		c, ok := controls[r.Target]
+		if !ok {
+			continue
+		}
+		mr := &modRoute{signal: r.Source.newSignal(ctx, t), route: r, target: c}
+		initControl(&mr.depth, r.Depth, params, t)
+		mm.global = append(mm.global, mr)
+		if !targeted[c] {
+			targeted[c] = true
+			mm.targets = append(mm.targets, c)
+		}
+	}
+	return mm
+}
+
+// step sets the modulation of the targeted Controls for the next sample.
+func (m *modMatrix) step() {
+	if m == nil {
+		return
+	}
+	for _, c := range m.targets {
+		c.mod = 0
+	}
+	for _, r := range m.global {
+		r.target.mod += r.depth.Sing() * r.route.shape(r.signal.next())
+	}
+}
+
+// modulating reports whether the matrix modulates any Controls.
+func (m *modMatrix) modulating() bool {
+	return m != nil && len(m.global) > 0
+}
+
+// clear removes the matrix's modulation from its targets.
+func (m *modMatrix) clear() {
+	if m == nil {
+		return
+	}
+	for _, c := range m.targets {
+		c.mod = 0
+	}
+}
+
+// The longest interval between the control points that a per-voice LFO
+// adds to a note, and the number of points per LFO cycle.
+const (
+	voiceModInterval = .01
+	voiceModPoints   = 32
+)
+
+// note returns n with its per-voice modulation applied:  each modulated
+// attribute is resampled into control points, to the end of the note's
+// longest attribute, with the LFO added.  Depths are read at the note's time
+// in the pattern.
+func (m *modMatrix) note(n *Note) *Note {
+	if m == nil || len(m.voice) == 0 {
+		return n
+	}
+	end := 0.0
+	for _, points := range n.Attributes {
+		if len(points) > 0 {
+			end = math.Max(end, points[len(points)-1].Time)
+		}
+	}
+	attrs := map[string][]*ControlPoint{}
+	for name, points := range n.Attributes {
+		attrs[name] = points
+	}
+	for _, r := range m.voice {
+		points, ok := attrs[r.Target]
+		if !ok {
+			continue
+		}
+		l := r.Source.(*LFO)
+		rate := l.cyclesPerSecond(m.ctx.tempo)
+		dt := voiceModInterval
+		if rate > 0 {
+			dt = math.Min(dt, 1/(rate*voiceModPoints))
+		}
+		modulated := []*ControlPoint{}
+		for t := 0.0; ; t += dt {
+			t = math.Min(t, end)
+			v := pointValue(points, t) + pointValue(r.Depth, n.Time+t)*r.shape(lfoValue(l.Shape, l.Phase+rate*t))
+			modulated = append(modulated, &ControlPoint{t, v})
+			if t == end {
+				break
+			}
+		}
+		attrs[r.Target] = modulated
+	}
+	return &Note{n.Time, attrs}
+}
+
+// pointValue returns the value at time t of the curve through points, as a
+// Control would play it.
+func pointValue(points []*ControlPoint, t float64) float64 {
+	prev := &ControlPoint{}
+	for _, p := range points {
+		if p.Time > t {
+			if p.Time == prev.Time {
+				return prev.Value
+			}
+			return prev.Value + (p.Value-prev.Value)*(t-prev.Time)/(p.Time-prev.Time)
+		}
+		prev = p
+	}
+	return prev.Value
+}
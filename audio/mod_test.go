@@ -0,0 +1,155 @@
+package audio
+
+import (
+	"math"
+	"testing"
+)
+
+func TestLFOShapes(t *testing.T) {
+	for _, test := range []struct {
+		shape LFOShape
+		phase []float64
+		want  []float64
+	}{
+		{Sine, []float64{0, .25, .75, 1.25}, []float64{0, 1, -1, 1}},
+		{Triangle, []float64{0, .25, .5, .75}, []float64{-1, 0, 1, 0}},
+		{Saw, []float64{0, .25, .5, 2.75}, []float64{-1, -.5, 0, .5}},
+		{Ramp, []float64{0, .25, .5, .75}, []float64{1, .5, 0, -.5}},
+		{Square, []float64{0, .49, .5, 1.99}, []float64{1, 1, -1, -1}},
+	} {
+		for i, phase := range test.phase {
+			if x := lfoValue(test.shape, phase); math.Abs(x-test.want[i]) > 1e-12 {
+				t.Errorf("%s at %v = %v; want %v", test.shape, phase, x, test.want[i])
+			}
+		}
+	}
+
+	for cycle := 0.0; cycle < 100; cycle++ {
+		x := lfoValue(Random, cycle+.3)
+		if x < -1 || x > 1 || x != lfoValue(Random, cycle+.7) {
+			t.Fatalf("random value %v in cycle %v is out of range or changes", x, cycle)
+		}
+		if y := lfoValue(SmoothRandom, cycle); y != x {
+			t.Fatalf("smooth random starts cycle %v at %v; want %v", cycle, y, x)
+		}
+		if y := lfoValue(SmoothRandom, cycle+.999999); math.Abs(y-lfoValue(Random, cycle+1)) > 1e-9 {
+			t.Fatalf("smooth random is discontinuous at the end of cycle %v", cycle)
+		}
+	}
+}
+
+func TestModulateControl(t *testing.T) {
+	// A square wave synced to 2 cycles per second raises and lowers the gain by 1/2.
+	pattern := &Pattern{Name: "p", Attributes: map[string][]*ControlPoint{"Gain": {{0, 1}}}, Modulation: &Modulation{120, []*Route{
+		{&LFO{Square, 1, true, 0, false}, "Gain", []*ControlPoint{{0, .5}}, 0},
+	}}}
+	inst := &testInst{}
+	p := NewPatternPlayer(pattern, inst)
+	Init(p, Params{SampleRate: 100})
+	for i, want := range map[int]float64{0: 1.5, 24: 1.5, 25: .5, 49: .5, 50: 1.5} {
+		p.SetTime(float64(i) / 100)
+		p.Play()
+		if g := inst.Gain.Sing(); math.Abs(g-want) > 1e-9 {
+			t.Errorf("gain at sample %d is %v; want %v", i, g, want)
+		}
+	}
+
+	// A curve of 1 squares the source.
+	pattern.Modulation.Routes[0] = &Route{&LFO{Saw, 1, false, 0, false}, "Gain", []*ControlPoint{{0, 1}}, 1}
+	p.Commit()
+	p.SetTime(.25)
+	p.Play()
+	if g := inst.Gain.Sing(); math.Abs(g-(1-.25)) > 1e-9 {
+		t.Errorf("curved gain is %v; want .75", g)
+	}
+
+	p.Stop()
+	if g := inst.Gain.Sing(); g != 1 {
+		t.Errorf("gain after Stop is %v; want 1", g)
+	}
+}
+
+type modInst struct {
+	Gain   Control
+	played [][]*ControlPoint
+}
+
+func (i *modInst) Play(n struct{ Pitch []*ControlPoint }) { i.played = append(i.played, n.Pitch) }
+func (i *modInst) Sing() float64                          { return 0 }
+func (i *modInst) Done() bool                             { return true }
+func (i *modInst) Stop()                                  {}
+
+func TestModulateVoice(t *testing.T) {
+	note := func(t float64) *Note {
+		return &Note{t, map[string][]*ControlPoint{"Pitch": {{0, 8}, {.5, 9}}}}
+	}
+	pattern := &Pattern{Name: "p", Notes: []*Note{note(0), note(1)}, Attributes: map[string][]*ControlPoint{}, Modulation: &Modulation{60, []*Route{
+		{&LFO{Sine, 2, false, 0, true}, "Pitch", []*ControlPoint{{0, 0}, {1, 1}}, 0},
+	}}}
+	inst := &modInst{}
+	p := NewPatternPlayer(pattern, inst)
+	Init(p, Params{SampleRate: 100})
+	for i := 0; i < 200; i++ {
+		p.Play()
+	}
+	if len(inst.played) != 2 {
+		t.Fatalf("played %d notes; want 2", len(inst.played))
+	}
+	if n := len(pattern.Notes[0].Attributes["Pitch"]); n != 2 {
+		t.Errorf("the pattern's note was changed")
+	}
+
+	// The depth rises from 0 to 1 over the first second.  Each note's LFO starts
+	// with the note.  The LFO is resampled, so it is only close.
+	for _, test := range []struct {
+		note    int
+		t, want float64
+	}{
+		{0, .125, 8.25 + .125},
+		{1, 0, 8},
+		{1, .125, 8.25 + 1},
+		{1, .25, 8.5},
+		{1, .375, 8.75 - 1},
+		{1, .5, 9},
+	} {
+		if x := pointValue(inst.played[test.note], test.t); math.Abs(x-test.want) > .005 {
+			t.Errorf("pitch of note %d at %v is %v; want %v", test.note, test.t, x, test.want)
+		}
+	}
+	if points := inst.played[1]; points[len(points)-1].Time != .5 {
+		t.Errorf("modulated note ends at %v; want .5", points[len(points)-1].Time)
+	}
+}
+
+type followBand struct {
+	Src  constInst
+	Inst testInst
+}
+
+func TestEnvFollower(t *testing.T) {
+	pattern := &Pattern{Name: "p", Attributes: map[string][]*ControlPoint{"Gain": {{0, 1}}}, Modulation: &Modulation{120, []*Route{
+		{&EnvFollower{"Src", 0, 1}, "Gain", []*ControlPoint{{0, 2}}, 0},
+	}}}
+	band := &followBand{Src: constInst{-.5}}
+	score := &Score{[]*Part{{"Src", nil, nil}, {"Inst", []*PatternEvent{{0, pattern}}, nil}}}
+	p := NewScorePlayer(score, band)
+	Init(p, Params{SampleRate: 100})
+	for i := 0; i < 10; i++ {
+		p.Sing()
+	}
+	if g := band.Inst.Gain.Sing(); g != 2 {
+		t.Errorf("gain is %v; want 2", g)
+	}
+
+	// The follower releases to 1% in a second.
+	band.Src.x = 0
+	for i := 0; i < 101; i++ { // the follower hears the previous sample
+		p.Sing()
+	}
+	if g := band.Inst.Gain.Sing(); math.Abs(g-1.01) > 1e-9 {
+		t.Errorf("released gain is %v; want 1.01", g)
+	}
+	if !p.Done() {
+		t.Error("a modulated pattern with no notes keeps the score from finishing")
+	}
+}
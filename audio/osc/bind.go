@@ -0,0 +1,168 @@
+package osc
+
+import (
+	"fmt"
+	"reflect"
+
+	"code.google.com/p/gordon-go/audio"
+)
+
+// BindBand adds handlers to s for the instruments of band, by the names
+// given by audio.BandInstruments:
+//
+//	/name/play attribute values... [attribute values...]...
+//		plays a note on an instrument with a Play method
+//	/name/Control values...
+//		sets the points of an exported Control
+//
+// Values are either a single number, for a constant, or pairs of numbers,
+// each a time in seconds and a value.  The changes are passed to do, which
+// must run them where it is safe to change the band, such as Transport.Do.
+func BindBand(s *Server, band audio.Band, do func(func())) {
+	for name, inst := range audio.BandInstruments(band) {
+		inst := inst
+		if reflect.ValueOf(inst).MethodByName("Play").IsValid() {
+			play := audio.InstrumentPlayMethod(inst)
+			s.Handle("/"+name+"/play", func(m *Message) {
+				n, err := note(m.Args)
+				if err != nil {
+					fmt.Printf("%s:  %s\n", m.Address, err)
+					return
+				}
+				for attr := range n.Attributes {
+					if f, ok := play.Type().In(0).FieldByName(attr); !ok || f.PkgPath != "" {
+						fmt.Printf("%s:  %T has no note attribute %s\n", m.Address, inst, attr)
+						return
+					}
+				}
+				do(func() { audio.PlayNote(inst, n) })
+			})
+		}
+		for _, c := range audio.InstrumentControls(inst) {
+			c := c
+			s.Handle("/"+name+"/"+c.Name, func(m *Message) {
+				p, err := points(m.Args)
+				if err != nil {
+					fmt.Printf("%s:  %s\n", m.Address, err)
+					return
+				}
+				do(func() { c.SetPoints(p) })
+			})
+		}
+	}
+}
+
+// BindTransport adds handlers to s for t:
+//
+//	/transport/play
+//	/transport/pause
+//	/transport/seek time
+//	/transport/loop start end
+//	/transport/loop
+//		clears the loop
+//	/transport/tempo bpm beatsPerBar
+//	/transport/seekbeat beat
+func BindTransport(s *Server, t *audio.Transport) {
+	s.Handle("/transport/play", func(*Message) { t.Play() })
+	s.Handle("/transport/pause", func(*Message) { t.Pause() })
+	s.Handle("/transport/seek", func(m *Message) {
+		x, err := numbers(m.Args)
+		if err != nil || len(x) != 1 {
+			fmt.Printf("%s:  want a time\n", m.Address)
+			return
+		}
+		t.Seek(x[0])
+	})
+	s.Handle("/transport/tempo", func(m *Message) {
+		x, err := numbers(m.Args)
+		if err != nil || len(x) != 2 || x[0] <= 0 || x[1] < 1 {
+			fmt.Printf("%s:  want a tempo and a number of beats per bar\n", m.Address)
+			return
+		}
+		t.SetTempo(x[0], int(x[1]))
+	})
+	s.Handle("/transport/seekbeat", func(m *Message) {
+		x, err := numbers(m.Args)
+		if err != nil || len(x) != 1 {
+			fmt.Printf("%s:  want a beat\n", m.Address)
+			return
+		}
+		t.SeekBeat(x[0])
+	})
+	s.Handle("/transport/loop", func(m *Message) {
+		x, err := numbers(m.Args)
+		switch {
+		case err == nil && len(x) == 0:
+			t.ClearLoop()
+		case err == nil && len(x) == 2:
+			t.SetLoop(x[0], x[1])
+		default:
+			fmt.Printf("%s:  want a start and end time, or nothing\n", m.Address)
+		}
+	})
+}
+
+// note makes a Note from pairs of attribute names and values.
+func note(args []interface{}) (*audio.Note, error) {
+	n := &audio.Note{Attributes: map[string][]*audio.ControlPoint{}}
+	for len(args) > 0 {
+		name, ok := args[0].(string)
+		if !ok {
+			return nil, fmt.Errorf("want an attribute name, got %v", args[0])
+		}
+		i := 1
+		for i < len(args) {
+			if _, ok := args[i].(string); ok {
+				break
+			}
+			i++
+		}
+		p, err := points(args[1:i])
+		if err != nil {
+			return nil, fmt.Errorf("%s:  %s", name, err)
+		}
+		n.Attributes[name] = p
+		args = args[i:]
+	}
+	return n, nil
+}
+
+func points(args []interface{}) ([]*audio.ControlPoint, error) {
+	x, err := numbers(args)
+	if err != nil {
+		return nil, err
+	}
+	if len(x) == 1 {
+		return []*audio.ControlPoint{{Time: 0, Value: x[0]}}, nil
+	}
+	if len(x) == 0 || len(x)%2 != 0 {
+		return nil, fmt.Errorf("want a value or time/value pairs, got %d numbers", len(x))
+	}
+	p := []*audio.ControlPoint{}
+	for i := 0; i < len(x); i += 2 {
+		if i > 0 && x[i] < x[i-2] {
+			return nil, fmt.Errorf("times out of order")
+		}
+		p = append(p, &audio.ControlPoint{Time: x[i], Value: x[i+1]})
+	}
+	return p, nil
+}
+
+func numbers(args []interface{}) ([]float64, error) {
+	x := make([]float64, len(args))
+	for i, a := range args {
+		switch a := a.(type) {
+		case int32:
+			x[i] = float64(a)
+		case int64:
+			x[i] = float64(a)
+		case float32:
+			x[i] = float64(a)
+		case float64:
+			x[i] = a
+		default:
+			return nil, fmt.Errorf("want a number, got %v", a)
+		}
+	}
+	return x, nil
+}
@@ -0,0 +1,282 @@
+// Package osc implements Open Sound Control 1.0 over UDP, and binds OSC
+// addresses to the instruments of an audio.Band and to an audio.Transport so
+// that a running song can be played and controlled from other programs.
+package osc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// A Message is an OSC message.  Its Args may be int32, float32, string or
+// []byte, the OSC 1.0 types; int and float64 are sent as int32 and float32.
+// Received messages may also carry float64, int64, bool and nil arguments,
+// from the common nonstandard types d, h, T, F and N.
+type Message struct {
+	Address string
+	Args    []interface{}
+}
+
+// A Bundle is an OSC bundle of messages.  Time is an OSC time tag; 1 means
+// immediately.
+type Bundle struct {
+	Time     uint64
+	Messages []*Message
+}
+
+func (m *Message) MarshalBinary() ([]byte, error) {
+	if !strings.HasPrefix(m.Address, "/") {
+		return nil, fmt.Errorf("osc:  address %q doesn't start with /", m.Address)
+	}
+	var b bytes.Buffer
+	writeString(&b, m.Address)
+	tags := []byte{','}
+	var args bytes.Buffer
+	for _, a := range m.Args {
+		switch a := a.(type) {
+		case int32:
+			tags = append(tags, 'i')
+			binary.Write(&args, binary.BigEndian, a)
+		case int:
+			tags = append(tags, 'i')
+			binary.Write(&args, binary.BigEndian, int32(a))
+		case float32:
+			tags = append(tags, 'f')
+			binary.Write(&args, binary.BigEndian, a)
+		case float64:
+			tags = append(tags, 'f')
+			binary.Write(&args, binary.BigEndian, float32(a))
+		case string:
+			tags = append(tags, 's')
+			writeString(&args, a)
+		case []byte:
+			tags = append(tags, 'b')
+			binary.Write(&args, binary.BigEndian, int32(len(a)))
+			args.Write(a)
+			pad(&args)
+		default:
+			return nil, fmt.Errorf("osc:  can't send argument of type %T", a)
+		}
+	}
+	writeString(&b, string(tags))
+	b.Write(args.Bytes())
+	return b.Bytes(), nil
+}
+
+func (b *Bundle) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	writeString(&buf, "#bundle")
+	binary.Write(&buf, binary.BigEndian, b.Time)
+	for _, m := range b.Messages {
+		p, err := m.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		binary.Write(&buf, binary.BigEndian, int32(len(p)))
+		buf.Write(p)
+	}
+	return buf.Bytes(), nil
+}
+
+func writeString(b *bytes.Buffer, s string) {
+	b.WriteString(s)
+	b.WriteByte(0)
+	pad(b)
+}
+
+func pad(b *bytes.Buffer) {
+	for b.Len()%4 != 0 {
+		b.WriteByte(0)
+	}
+}
+
+var errShort = errors.New("osc:  packet too short")
+
+// Parse returns the messages in an OSC packet, which is either a message or a
+// bundle.  The messages of nested bundles are returned in order; time tags
+// are ignored.
+func Parse(p []byte) ([]*Message, error) {
+	if len(p)%4 != 0 {
+		return nil, errors.New("osc:  packet size is not a multiple of 4")
+	}
+	if !bytes.HasPrefix(p, []byte("#bundle\x00")) {
+		m, err := parseMessage(p)
+		if err != nil {
+			return nil, err
+		}
+		return []*Message{m}, nil
+	}
+	if len(p) < 16 {
+		return nil, errShort
+	}
+	msgs := []*Message{}
+	for p = p[16:]; len(p) > 0; {
+		if len(p) < 4 {
+			return nil, errShort
+		}
+		n := int(int32(binary.BigEndian.Uint32(p)))
+		if n < 0 || n > len(p)-4 {
+			return nil, errShort
+		}
+		m, err := Parse(p[4 : 4+n])
+		if err != nil {
+			return nil, err
+		}
+		msgs = append(msgs, m...)
+		p = p[4+n:]
+	}
+	return msgs, nil
+}
+
+func parseMessage(p []byte) (*Message, error) {
+	addr, p, err := readString(p)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(addr, "/") {
+		return nil, fmt.Errorf("osc:  address %q doesn't start with /", addr)
+	}
+	m := &Message{Address: addr}
+	if len(p) == 0 {
+		return m, nil // some old implementations omit the type tag string
+	}
+	tags, p, err := readString(p)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(tags, ",") {
+		return nil, fmt.Errorf("osc:  bad type tag string %q", tags)
+	}
+	for _, t := range tags[1:] {
+		var a interface{}
+		switch t {
+		case 'i', 'f':
+			if len(p) < 4 {
+				return nil, errShort
+			}
+			x := binary.BigEndian.Uint32(p)
+			if a = int32(x); t == 'f' {
+				a = math.Float32frombits(x)
+			}
+			p = p[4:]
+		case 'h', 'd':
+			if len(p) < 8 {
+				return nil, errShort
+			}
+			x := binary.BigEndian.Uint64(p)
+			if a = int64(x); t == 'd' {
+				a = math.Float64frombits(x)
+			}
+			p = p[8:]
+		case 's', 'S':
+			if a, p, err = readString(p); err != nil {
+				return nil, err
+			}
+		case 'b':
+			if len(p) < 4 {
+				return nil, errShort
+			}
+			n := int(int32(binary.BigEndian.Uint32(p)))
+			if n < 0 || 4+n > len(p) {
+				return nil, errShort
+			}
+			a = append([]byte(nil), p[4:4+n]...)
+			p = p[(4+n+3)/4*4:]
+		case 'T':
+			a = true
+		case 'F':
+			a = false
+		case 'N':
+			a = nil
+		default:
+			return nil, fmt.Errorf("osc:  unknown type tag %q", t)
+		}
+		m.Args = append(m.Args, a)
+	}
+	return m, nil
+}
+
+func readString(p []byte) (string, []byte, error) {
+	i := bytes.IndexByte(p, 0)
+	if i < 0 {
+		return "", nil, errors.New("osc:  unterminated string")
+	}
+	n := (i + 4) / 4 * 4
+	if n > len(p) {
+		return "", nil, errShort
+	}
+	return string(p[:i]), p[n:], nil
+}
+
+// Match reports whether an OSC address pattern matches an address.  Patterns
+// may contain ? (any character), * (any characters), [chars] (any of chars,
+// which may include ranges a-z, or none of them if the first is !) and
+// {strings,...} (any of the comma-separated strings).  None of these match /.
+func Match(pattern, address string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			for len(pattern) > 0 && pattern[0] == '*' {
+				pattern = pattern[1:]
+			}
+			for i := 0; i <= len(address); i++ {
+				if Match(pattern, address[i:]) {
+					return true
+				}
+				if i < len(address) && address[i] == '/' {
+					break
+				}
+			}
+			return false
+		case '?':
+			if len(address) == 0 || address[0] == '/' {
+				return false
+			}
+			pattern, address = pattern[1:], address[1:]
+		case '[':
+			end := strings.IndexByte(pattern, ']')
+			if end < 0 || len(address) == 0 || address[0] == '/' {
+				return false
+			}
+			set, c := pattern[1:end], address[0]
+			negate := strings.HasPrefix(set, "!")
+			if negate {
+				set = set[1:]
+			}
+			in := false
+			for i := 0; i < len(set); i++ {
+				if i+2 < len(set) && set[i+1] == '-' {
+					in = in || set[i] <= c && c <= set[i+2]
+					i += 2
+				} else {
+					in = in || set[i] == c
+				}
+			}
+			if in == negate {
+				return false
+			}
+			pattern, address = pattern[end+1:], address[1:]
+		case '{':
+			end := strings.IndexByte(pattern, '}')
+			if end < 0 {
+				return false
+			}
+			for _, s := range strings.Split(pattern[1:end], ",") {
+				if strings.HasPrefix(address, s) && Match(pattern[end+1:], address[len(s):]) {
+					return true
+				}
+			}
+			return false
+		default:
+			if len(address) == 0 || address[0] != pattern[0] {
+				return false
+			}
+			pattern, address = pattern[1:], address[1:]
+		}
+	}
+	return len(address) == 0
+}
@@ -0,0 +1,152 @@
+package osc
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"code.google.com/p/gordon-go/audio"
+)
+
+func TestRoundTrip(t *testing.T) {
+	m := &Message{"/a/b", []interface{}{int32(-3), float32(1.5), "hello", []byte{1, 2, 3, 4, 5}, ""}}
+	b, err := m.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	msgs, err := Parse(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != 1 || !reflect.DeepEqual(msgs[0], m) {
+		t.Errorf("got %#v; want %#v", msgs, m)
+	}
+
+	bundle := &Bundle{1, []*Message{{"/x", nil}, m}}
+	b, err = bundle.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	msgs, err = Parse(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != 2 || msgs[0].Address != "/x" || !reflect.DeepEqual(msgs[1], m) {
+		t.Errorf("got %#v", msgs)
+	}
+
+	for _, p := range [][]byte{b[:len(b)-4], b[:3], []byte("/x\x00\x00,i\x00\x00")} {
+		if _, err := Parse(p); err == nil {
+			t.Errorf("no error parsing %q", p)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	for _, test := range []struct {
+		pattern, address string
+		match            bool
+	}{
+		{"/a/b", "/a/b", true},
+		{"/a/b", "/a/bc", false},
+		{"/a/?", "/a/b", true},
+		{"/?", "/a/b", false},
+		{"/*/b", "/abc/b", true},
+		{"/*", "/a/b", false},
+		{"/a*c", "/abbc", true},
+		{"/[a-c]x", "/bx", true},
+		{"/[!a-c]x", "/bx", false},
+		{"/[!a-c]x", "/dx", true},
+		{"/{foo,bar}/play", "/bar/play", true},
+		{"/{foo,bar}/play", "/baz/play", false},
+	} {
+		if m := Match(test.pattern, test.address); m != test.match {
+			t.Errorf("Match(%q, %q) = %v", test.pattern, test.address, m)
+		}
+	}
+}
+
+type testBand struct {
+	Sines *testInst
+}
+
+type testInst struct {
+	Gain  audio.Control
+	notes chan *audio.Note
+}
+
+func (i *testInst) Play(n struct{ Pitch, Amplitude []*audio.ControlPoint }) {
+	i.notes <- &audio.Note{Attributes: map[string][]*audio.ControlPoint{"Pitch": n.Pitch, "Amplitude": n.Amplitude}}
+}
+func (i *testInst) Sing() float64 { return 0 }
+func (i *testInst) Done() bool    { return true }
+func (i *testInst) Stop()         {}
+
+type testPlayer struct {
+	times chan float64
+}
+
+func (p *testPlayer) Sing() float64     { return 0 }
+func (p *testPlayer) Done() bool        { return true }
+func (p *testPlayer) Stop()             {}
+func (p *testPlayer) GetTime() float64  { return 0 }
+func (p *testPlayer) SetTime(t float64) { p.times <- t }
+
+func TestServer(t *testing.T) {
+	s, err := Listen("localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	inst := &testInst{notes: make(chan *audio.Note, 1)}
+	audio.Init(inst, audio.Params{SampleRate: 100})
+	done := make(chan bool, 1)
+	BindBand(s, &testBand{inst}, func(f func()) { f(); done <- true })
+	player := &testPlayer{make(chan float64, 1)}
+	BindTransport(s, audio.NewTransport(player))
+	go s.Serve()
+
+	c, err := Dial(s.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if err := c.Send("/Sines/play", "Pitch", 8.0, "Amplitude", 0, -1, 1, -8); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case n := <-inst.notes:
+		want := map[string][]*audio.ControlPoint{"Pitch": {{Time: 0, Value: 8}}, "Amplitude": {{Time: 0, Value: -1}, {Time: 1, Value: -8}}}
+		if !reflect.DeepEqual(n.Attributes, want) {
+			t.Errorf("played %v; want %v", n.Attributes, want)
+		}
+		<-done
+	case <-time.After(time.Second):
+		t.Fatal("note not played")
+	}
+
+	if err := c.Send("/Sines/Gain", float32(.5)); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-done:
+		if x := inst.Gain.Sing(); x != .5 {
+			t.Errorf("Gain is %v; want .5", x)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("control not set")
+	}
+
+	if err := c.Send("/transport/seek", 2.5); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case x := <-player.times:
+		if x != 2.5 {
+			t.Errorf("sought to %v; want 2.5", x)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("transport didn't seek")
+	}
+}
@@ -0,0 +1,144 @@
+package osc
+
+import (
+	"encoding"
+	"fmt"
+	"net"
+	"sync"
+)
+
+// A Server receives OSC packets on a UDP socket and calls the handlers whose
+// addresses match their messages' address patterns.
+type Server struct {
+	conn *net.UDPConn
+
+	mu       sync.Mutex
+	handlers []handler
+	closed   bool
+}
+
+type handler struct {
+	address string
+	f       func(*Message)
+}
+
+// Listen opens a Server on the given UDP address, such as ":9000" or
+// "localhost:0".
+func Listen(addr string) (*Server, error) {
+	a, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.ListenUDP("udp", a)
+	if err != nil {
+		return nil, err
+	}
+	return &Server{conn: conn}, nil
+}
+
+func (s *Server) Addr() net.Addr { return s.conn.LocalAddr() }
+
+// Handle calls f for each message whose address pattern matches address.  It
+// may be called while the Server is serving.
+func (s *Server) Handle(address string, f func(*Message)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handlers = append(s.handlers, handler{address, f})
+}
+
+// Addresses returns the addresses that have handlers.
+func (s *Server) Addresses() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a := []string{}
+	for _, h := range s.handlers {
+		a = append(a, h.address)
+	}
+	return a
+}
+
+// Serve receives and dispatches packets until the Server is closed, when it
+// returns nil.  Handlers are called on Serve's goroutine, one message at a
+// time.  Malformed packets and messages without a handler are reported and
+// skipped.
+func (s *Server) Serve() error {
+	buf := make([]byte, 65536)
+	for {
+		n, from, err := s.conn.ReadFromUDP(buf)
+		if err != nil {
+			s.mu.Lock()
+			closed := s.closed
+			s.mu.Unlock()
+			if closed {
+				return nil
+			}
+			return err
+		}
+		msgs, err := Parse(buf[:n])
+		if err != nil {
+			fmt.Printf("%s from %s\n", err, from)
+			continue
+		}
+		for _, m := range msgs {
+			s.dispatch(m)
+		}
+	}
+}
+
+func (s *Server) dispatch(m *Message) {
+	s.mu.Lock()
+	handlers := []handler{}
+	for _, h := range s.handlers {
+		if Match(m.Address, h.address) {
+			handlers = append(handlers, h)
+		}
+	}
+	s.mu.Unlock()
+	if len(handlers) == 0 {
+		fmt.Println("osc:  no handler for " + m.Address)
+	}
+	for _, h := range handlers {
+		h.f(m)
+	}
+}
+
+func (s *Server) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	s.mu.Unlock()
+	return s.conn.Close()
+}
+
+// A Client sends OSC packets to a Server.
+type Client struct {
+	conn *net.UDPConn
+}
+
+func Dial(addr string) (*Client, error) {
+	a, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.DialUDP("udp", nil, a)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{conn}, nil
+}
+
+// Send sends a message with the given address and arguments.
+func (c *Client) Send(address string, args ...interface{}) error {
+	return c.SendPacket(&Message{address, args})
+}
+
+// SendPacket sends a *Message or *Bundle.
+func (c *Client) SendPacket(p encoding.BinaryMarshaler) error {
+	b, err := p.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	_, err = c.conn.Write(b)
+	return err
+}
+
+func (c *Client) Close() error { return c.conn.Close() }
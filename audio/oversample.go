@@ -0,0 +1,184 @@
+package audio
+
+import "math"
+
+// An Oversampler runs a nonlinear process, such as distortion, at 2, 4 or 8
+// times the sample rate, so that the harmonics it generates above the
+// Nyquist frequency are filtered out instead of aliasing down into the audible
+// band.  Each doubling is a pair of half-band polyphase filters; the first
+// passes 86% of the Nyquist band, and aliases are suppressed by about 100 dB.
+type Oversampler struct {
+	factor   int
+	stages   []*oversampleStage
+	buf, tmp []float64
+}
+
+type oversampleStage struct {
+	up   *interpolator
+	down *decimator
+}
+
+// The half lengths, in samples at the lower rate, of the filters of each
+// doubling.  The first doubling needs the sharpest filter; the later ones
+// only need to pass the band that the earlier ones did.
+var halfBandLengths = []int{24, 10, 8}
+
+// The Kaiser window's beta, for about 100 dB of stopband attenuation.
+const halfBandBeta = 10
+
+func NewOversampler(factor int) *Oversampler {
+	o := &Oversampler{factor: factor, buf: make([]float64, factor), tmp: make([]float64, factor)}
+	for i, f := 0, 1; f < factor; i, f = i+1, 2*f {
+		if i == len(halfBandLengths) {
+			panic("NewOversampler:  factor must be 2, 4 or 8")
+		}
+		c := halfBand(halfBandLengths[i])
+		o.stages = append(o.stages, &oversampleStage{newInterpolator(c), newDecimator(c)})
+	}
+	if 1<<uint(len(o.stages)) != factor {
+		panic("NewOversampler:  factor must be 2, 4 or 8")
+	}
+	return o
+}
+
+func (o *Oversampler) Factor() int { return o.factor }
+
+// Process upsamples x, passes each sample at the higher rate through f, and
+// returns the downsampled result.
+func (o *Oversampler) Process(x float64, f func(float64) float64) float64 {
+	buf := o.upsample(x)
+	for i, x := range buf {
+		buf[i] = f(x)
+	}
+	return o.downsample(buf)
+}
+
+// upsample returns Factor samples at the higher rate.
+func (o *Oversampler) upsample(x float64) []float64 {
+	a, b := o.buf[:1], o.tmp
+	a[0] = x
+	for _, s := range o.stages {
+		b = b[:2*len(a)]
+		for i, x := range a {
+			b[2*i], b[2*i+1] = s.up.process(x)
+		}
+		a, b = b, a[:cap(a)]
+	}
+	return a
+}
+
+// downsample filters buf, of Factor samples at the higher rate, down to one
+// sample.
+func (o *Oversampler) downsample(buf []float64) float64 {
+	for i := len(o.stages) - 1; i >= 0; i-- {
+		s := o.stages[i]
+		n := len(buf) / 2
+		for j := 0; j < n; j++ {
+			buf[j] = s.down.process(buf[2*j], buf[2*j+1])
+		}
+		buf = buf[:n]
+	}
+	return buf[0]
+}
+
+// An OversampledVoice sings a Voice at a multiple of the sample rate and
+// downsamples the result.  The Voice is initialized with the higher rate.
+type OversampledVoice struct {
+	v Voice
+	o *Oversampler
+}
+
+func Oversample(v Voice, factor int) *OversampledVoice {
+	return &OversampledVoice{v, NewOversampler(factor)}
+}
+
+func (v *OversampledVoice) InitAudio(p Params) {
+	p.SampleRate *= float64(v.o.factor)
+	Init(v.v, p)
+}
+
+func (v *OversampledVoice) Sing() float64 {
+	buf := v.o.buf
+	for i := range buf {
+		buf[i] = v.v.Sing()
+	}
+	return v.o.downsample(buf)
+}
+
+func (v *OversampledVoice) Done() bool { return v.v.Done() }
+
+// halfBand returns the nonzero taps on one side of a Kaiser-windowed
+// half-band lowpass filter, at odd offsets 1, 3, 5, ... from its center tap,
+// which is 1/2.  The other taps are zero.
+func halfBand(n int) []float64 {
+	c := make([]float64, n)
+	sum := 0.0
+	for k := range c {
+		j := float64(2*k + 1)
+		r := j / float64(2*n)
+		c[k] = .5 * sinc(j/2) * bessel0(halfBandBeta*math.Sqrt(1-r*r)) / bessel0(halfBandBeta)
+		sum += c[k]
+	}
+	for k := range c {
+		c[k] *= .25 / sum // unity gain at DC
+	}
+	return c
+}
+
+// A halfBandWindow holds the last 2n samples of a signal, oldest first.
+type halfBandWindow struct {
+	buf []float64 // doubled, so that the window is always contiguous
+	i   int
+}
+
+func newHalfBandWindow(n int) *halfBandWindow {
+	return &halfBandWindow{buf: make([]float64, 4*n)}
+}
+
+func (w *halfBandWindow) push(x float64) []float64 {
+	n := len(w.buf) / 2
+	w.buf[w.i], w.buf[w.i+n] = x, x
+	w.i = (w.i + 1) % n
+	return w.buf[w.i : w.i+n]
+}
+
+// An interpolator doubles the sample rate.  Each input sample x[m] yields
+// x[m-n] and the point halfway between it and x[m-n+1].
+type interpolator struct {
+	c []float64
+	x *halfBandWindow
+}
+
+func newInterpolator(c []float64) *interpolator {
+	return &interpolator{c, newHalfBandWindow(len(c))}
+}
+
+func (p *interpolator) process(x float64) (a, b float64) {
+	n := len(p.c)
+	w := p.x.push(x)
+	for k, c := range p.c {
+		b += c * (w[n-1-k] + w[n+k])
+	}
+	return w[n-1], 2 * b
+}
+
+// A decimator halves the sample rate.  Each pair of input samples, the even
+// sample first, yields one output sample.
+type decimator struct {
+	c    []float64
+	e, o *halfBandWindow
+}
+
+func newDecimator(c []float64) *decimator {
+	return &decimator{c, newHalfBandWindow(len(c)), newHalfBandWindow(len(c))}
+}
+
+func (d *decimator) process(even, odd float64) float64 {
+	n := len(d.c)
+	e, o := d.e.push(even), d.o.push(odd)
+	y := .5 * o[n-1]
+	for k, c := range d.c {
+		y += c * (e[n-1-k] + e[n+k])
+	}
+	return y
+}
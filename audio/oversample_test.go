@@ -0,0 +1,112 @@
+package audio
+
+import (
+	"math"
+	"testing"
+)
+
+// aliasing returns the power in dB, relative to the total, of y's spectrum in
+// the band below .4 of the sample rate at frequencies other than multiples of
+// bin, the input's frequency.  y must be periodic in its length.
+func aliasing(y []float64, bin int) float64 {
+	n := len(y)
+	total, alias := 0.0, 0.0
+	for k := 1; k < 2*n/5; k++ {
+		re, im := 0.0, 0.0
+		for i, y := range y {
+			a := 2 * math.Pi * float64(k*i%n) / float64(n)
+			re += y * math.Cos(a)
+			im -= y * math.Sin(a)
+		}
+		p := re*re + im*im
+		total += p
+		if k%bin != 0 {
+			alias += p
+		}
+	}
+	return 10 * math.Log10(alias/total)
+}
+
+func TestOversampleAliasing(t *testing.T) {
+	const n, bin = 2048, 211 // about 4.9 kHz at 48 kHz
+	x := sineWave(bin, n, 4*n)
+	distort := func(x float64) float64 { return math.Tanh(2 * x) }
+	for _, test := range []struct {
+		factor int
+		max    float64 // dB
+	}{
+		{1, 0},
+		{2, -90},
+		{4, -120},
+		{8, -120},
+	} {
+		y := make([]float64, len(x))
+		if test.factor == 1 {
+			for i, x := range x {
+				y[i] = distort(x)
+			}
+		} else {
+			o := NewOversampler(test.factor)
+			for i, x := range x {
+				y[i] = o.Process(x, distort)
+			}
+		}
+		a := aliasing(y[len(y)-n:], bin)
+		t.Logf("%dx:  aliasing %.1f dB", test.factor, a)
+		if test.factor == 1 {
+			if a > -50 {
+				continue // the test can see aliasing
+			}
+			t.Fatalf("no aliasing detected without oversampling (%.1f dB)", a)
+		}
+		if a > test.max {
+			t.Errorf("%dx:  aliasing is %.1f dB; want below %v dB", test.factor, a, test.max)
+		}
+	}
+}
+
+func TestOversamplePassband(t *testing.T) {
+	for _, factor := range []int{2, 4, 8} {
+		for _, freq := range []float64{100, 5000, 15000, 19000} {
+			o := NewOversampler(factor)
+			x := sineWave(freq, 48000, 9600)
+			power := 0.0
+			for i, x := range x {
+				y := o.Process(x, func(x float64) float64 { return x })
+				if i >= 4800 {
+					power += y * y / 2400 // a whole number of periods
+				}
+			}
+			if db := 10 * math.Log10(power); math.Abs(db) > .01 {
+				t.Errorf("%dx:  gain at %v Hz is %.3f dB", factor, freq, db)
+			}
+		}
+	}
+}
+
+type rateVoice struct {
+	Params Params
+	n      int
+}
+
+func (v *rateVoice) Sing() float64 { v.n++; return 1 }
+func (v *rateVoice) Done() bool    { return false }
+
+func TestOversampledVoice(t *testing.T) {
+	v := &rateVoice{}
+	o := Oversample(v, 4)
+	Init(o, Params{48000})
+	if v.Params.SampleRate != 192000 {
+		t.Errorf("voice initialized at %v Hz; want 192000", v.Params.SampleRate)
+	}
+	y := 0.0
+	for i := 0; i < 100; i++ {
+		y = o.Sing()
+	}
+	if v.n != 400 {
+		t.Errorf("voice sang %d samples; want 400", v.n)
+	}
+	if math.Abs(y-1) > 1e-4 {
+		t.Errorf("DC gain is %v; want 1", y)
+	}
+}
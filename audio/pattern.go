@@ -4,12 +4,14 @@ import (
 	"fmt"
 	"reflect"
 	"sort"
+	"sync/atomic"
 )
 
 type Pattern struct {
 	Name       string
 	Notes      []*Note
 	Attributes map[string][]*ControlPoint
+	Modulation *Modulation // may be nil
 }
 
 type Note struct {
@@ -18,51 +20,127 @@ type Note struct {
 }
 
 type PatternPlayer struct {
-	pattern *Pattern
+	pattern *Pattern     // as edited by the user
+	next    atomic.Value // *Pattern, the latest snapshot of pattern
+	snap    *Pattern     // the snapshot being played
 	inst    Instrument
 	play    reflect.Value
+	params  Params
+	mod     *modMatrix
+	level   func(part string) float64 // for envelope followers
+	out     float64                   // the last output of inst, when played alone
 	i       int
 	t, dt   float64
 }
 
 func NewPatternPlayer(pattern *Pattern, inst Instrument) *PatternPlayer {
-	return &PatternPlayer{pattern: pattern, inst: inst, play: InstrumentPlayMethod(inst)}
+	p := newPatternPlayer(pattern.snapshot(), inst)
+	p.pattern = pattern
+	return p
+}
+
+func newPatternPlayer(snap *Pattern, inst Instrument) *PatternPlayer {
+	p := &PatternPlayer{snap: snap, inst: inst, play: InstrumentPlayMethod(inst)}
+	p.level = func(part string) float64 {
+		if part == "" {
+			return p.out
+		}
+		return 0
+	}
+	return p
 }
 
 func (p *PatternPlayer) InitAudio(params Params) {
 	Init(p.inst, params)
+	p.params = params
 	p.dt = 1 / params.SampleRate
 	p.SetTime(p.t)
 }
 
+// Commit hands the player a snapshot of its Pattern, which it starts playing at
+// the next buffer boundary.  Commit must be called after each edit, from the
+// goroutine that edits the Pattern; the player never reads the Pattern itself.
+func (p *PatternPlayer) Commit() {
+	p.next.Store(p.pattern.snapshot())
+}
+
+// StartBuffer picks up the latest committed snapshot.  It is called on the
+// audio thread by PlayAsync.
+func (p *PatternPlayer) StartBuffer() {
+	if s, ok := p.next.Load().(*Pattern); ok && s != p.snap {
+		p.setPattern(s, p.t)
+	}
+}
+
 func (p *PatternPlayer) GetTime() float64 { return p.t }
 func (p *PatternPlayer) SetTime(t float64) {
+	if s, ok := p.next.Load().(*Pattern); ok {
+		p.snap = s
+	}
 	noteType := p.play.Type().In(0)
-	for _, n := range p.pattern.Notes {
+	for _, n := range p.snap.Notes {
 		for name := range n.Attributes {
 			if _, ok := noteType.FieldByName(name); !ok {
-				panic(fmt.Sprintf("Pattern %s: Instrument %T has no attribute %s.", p.pattern.Name, p.inst, name))
+				panic(fmt.Sprintf("Pattern %s: Instrument %T has no attribute %s.", p.snap.Name, p.inst, name))
 			}
 		}
 		for i := 0; i < noteType.NumField(); i++ {
 			name := noteType.Field(i).Name
 			if _, ok := n.Attributes[name]; !ok {
-				panic(fmt.Sprintf("Pattern %s: Note has no attribute %s for instrument %T.", p.pattern.Name, name, p.inst))
+				panic(fmt.Sprintf("Pattern %s: Note has no attribute %s for instrument %T.", p.snap.Name, name, p.inst))
 			}
 		}
 	}
-	sort.Sort(notesByTime(p.pattern.Notes))
-	for p.i = 0; p.i < len(p.pattern.Notes) && p.pattern.Notes[p.i].Time < t; p.i++ {
+	p.setPattern(p.snap, t)
+}
+
+// setPattern switches to playing snap from time t without disturbing notes
+// already sounding:  notes before t are skipped and the controls are moved to
+// their values at t.
+func (p *PatternPlayer) setPattern(snap *Pattern, t float64) {
+	p.snap = snap
+	for p.i = 0; p.i < len(snap.Notes) && snap.Notes[p.i].Time < t; p.i++ {
 	}
 
 	for _, c := range InstrumentControls(p.inst) {
-		c.SetPoints(p.pattern.Attributes[c.Name])
+		c.SetPoints(snap.Attributes[c.Name])
 		c.SetTime(t)
 	}
+	p.mod.clear()
+	p.mod = newModMatrix(snap.Modulation, p.inst, p.params, t, p.level)
 
 	p.t = t
 }
 
+// snapshot returns a deep copy of p with its notes sorted by time.
+func (p *Pattern) snapshot() *Pattern {
+	s := &Pattern{Name: p.Name, Attributes: copyAttributes(p.Attributes), Modulation: p.Modulation.copy()}
+	for _, n := range p.Notes {
+		s.Notes = append(s.Notes, &Note{n.Time, copyAttributes(n.Attributes)})
+	}
+	sort.Stable(notesByTime(s.Notes))
+	return s
+}
+
+func copyAttributes(attrs map[string][]*ControlPoint) map[string][]*ControlPoint {
+	c := make(map[string][]*ControlPoint, len(attrs))
+	for name, points := range attrs {
+		c[name] = copyPoints(points)
+	}
+	return c
+}
+
+func copyPoints(points []*ControlPoint) []*ControlPoint {
+	if points == nil {
+		return nil
+	}
+	c := make([]*ControlPoint, len(points))
+	for i, p := range points {
+		c[i] = &ControlPoint{p.Time, p.Value}
+	}
+	return c
+}
+
 type notesByTime []*Note
 
 func (n notesByTime) Len() int           { return len(n) }
@@ -70,27 +148,47 @@ func (n notesByTime) Less(i, j int) bool { return n[i].Time < n[j].Time }
 func (n notesByTime) Swap(i, j int)      { n[i], n[j] = n[j], n[i] }
 
 func (p *PatternPlayer) Play() {
-	for ; p.i < len(p.pattern.Notes); p.i++ {
-		n := p.pattern.Notes[p.i]
+	p.mod.step()
+	for ; p.i < len(p.snap.Notes); p.i++ {
+		n := p.snap.Notes[p.i]
 		if n.Time > p.t {
 			break
 		}
-		note := reflect.New(p.play.Type().In(0)).Elem()
-		for name, val := range n.Attributes {
-			note.FieldByName(name).Set(reflect.ValueOf(val))
-		}
-		p.play.Call([]reflect.Value{note})
+		playNote(p.play, p.mod.note(n))
 	}
 	p.t += p.dt
 }
 
+// PlayNote plays n on inst, passing each of n's attributes to the field of
+// the same name in the parameter to inst's Play method.  Attributes that
+// have no such field are ignored.
+func PlayNote(inst Instrument, n *Note) {
+	playNote(InstrumentPlayMethod(inst), n)
+}
+
+func playNote(play reflect.Value, n *Note) {
+	note := reflect.New(play.Type().In(0)).Elem()
+	for name, val := range n.Attributes {
+		if f := note.FieldByName(name); f.IsValid() {
+			f.Set(reflect.ValueOf(val))
+		}
+	}
+	play.Call([]reflect.Value{note})
+}
+
 func (p *PatternPlayer) Sing() float64 {
 	p.Play()
-	return p.inst.Sing()
+	p.out = p.inst.Sing()
+	return p.out
+}
+
+func (p *PatternPlayer) Stop() {
+	p.mod.clear()
+	p.inst.Stop()
 }
 
 func (p *PatternPlayer) Done() bool {
-	return p.i == len(p.pattern.Notes) && p.inst.Done()
+	return p.i == len(p.snap.Notes) && p.inst.Done()
 }
 
 // For an Instrument to play notes, it must have a method Play(noteType) where noteType is a struct with exported fields of type []*ControlPoint.
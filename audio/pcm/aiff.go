@@ -0,0 +1,104 @@
+package pcm
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+)
+
+type aiffWriter struct {
+	w      io.WriteSeeker
+	buf    *bufio.Writer
+	f      Format
+	q      *quantizer
+	frames int64
+	b      []byte
+}
+
+// NewAIFFWriter writes an AIFF file with 16-, 24- or 32-bit integer samples.
+func NewAIFFWriter(w io.WriteSeeker, f Format) (Writer, error) {
+	if f.Float {
+		return nil, errors.New("pcm: AIFF doesn't support floating point samples")
+	}
+	if err := f.check(32); err != nil {
+		return nil, err
+	}
+	aw := &aiffWriter{w: w, buf: bufio.NewWriter(w), f: f, q: newQuantizer(f), b: make([]byte, 4)}
+	if err := aw.writeHeader(); err != nil {
+		return nil, err
+	}
+	return aw, nil
+}
+
+func (w *aiffWriter) dataSize() int64 { return w.frames * int64(w.f.Channels*w.f.Bits/8) }
+
+func (w *aiffWriter) writeHeader() error {
+	size := w.dataSize()
+	be := binary.BigEndian
+	h := make([]byte, 54)
+	copy(h[0:], "FORM")
+	be.PutUint32(h[4:], uint32(46+size+size%2))
+	copy(h[8:], "AIFFCOMM")
+	be.PutUint32(h[16:], 18)
+	be.PutUint16(h[20:], uint16(w.f.Channels))
+	be.PutUint32(h[22:], uint32(w.frames))
+	be.PutUint16(h[26:], uint16(w.f.Bits))
+	putExtended(h[28:38], float64(w.f.SampleRate))
+	copy(h[38:], "SSND")
+	be.PutUint32(h[42:], uint32(8+size))
+	// offset and block size are zero
+	_, err := w.buf.Write(h)
+	return err
+}
+
+// putExtended writes x to b as an 80-bit IEEE 754 extended precision number.
+func putExtended(b []byte, x float64) {
+	for i := range b {
+		b[i] = 0
+	}
+	if x == 0 {
+		return
+	}
+	frac, exp := math.Frexp(x) // x = frac * 2^exp, .5 <= frac < 1
+	binary.BigEndian.PutUint16(b, uint16(16383+exp-1))
+	binary.BigEndian.PutUint64(b[2:], uint64(math.Ldexp(frac, 64)))
+}
+
+func (w *aiffWriter) Write(samples []float64) error {
+	if len(samples)%w.f.Channels != 0 {
+		return errPartialFrame
+	}
+	n := w.f.Bits / 8
+	for i, x := range samples {
+		binary.BigEndian.PutUint32(w.b, uint32(w.q.quantize(x, i%w.f.Channels))<<uint(32-w.f.Bits))
+		if _, err := w.buf.Write(w.b[:n]); err != nil {
+			return err
+		}
+	}
+	w.frames += int64(len(samples) / w.f.Channels)
+	return nil
+}
+
+// Close pads the sound data to an even length and fills in the sizes in the
+// header.
+func (w *aiffWriter) Close() error {
+	if w.dataSize()%2 == 1 {
+		w.buf.WriteByte(0)
+	}
+	if err := w.buf.Flush(); err != nil {
+		return err
+	}
+	if _, err := w.w.Seek(0, 0); err != nil {
+		return err
+	}
+	if err := w.writeHeader(); err != nil {
+		return err
+	}
+	if err := w.buf.Flush(); err != nil {
+		return err
+	}
+	_, err := w.w.Seek(0, 2)
+	return err
+}
@@ -0,0 +1,406 @@
+package pcm
+
+import (
+	"crypto/md5"
+	"encoding/binary"
+	"errors"
+	"hash"
+	"io"
+)
+
+const flacBlockSize = 4096
+
+type flacWriter struct {
+	w       io.WriteSeeker
+	f       Format
+	q       *quantizer
+	md5     hash.Hash
+	block   [][]int64 // the samples of the current block, by channel
+	frames  int64
+	blocks  int
+	minSize int // frame sizes, in bytes
+	maxSize int
+	b       []byte
+}
+
+// NewFLACWriter writes a FLAC file with 16- or 24-bit samples, in fixed-size
+// blocks predicted by FLAC's fixed polynomial predictors.  Stereo is
+// decorrelated block by block.
+func NewFLACWriter(w io.WriteSeeker, f Format) (Writer, error) {
+	if f.Float {
+		return nil, errors.New("pcm: FLAC doesn't support floating point samples")
+	}
+	if err := f.check(24); err != nil {
+		return nil, err
+	}
+	if f.Channels > 8 {
+		return nil, errors.New("pcm: FLAC supports at most 8 channels")
+	}
+	fw := &flacWriter{w: w, f: f, q: newQuantizer(f), md5: md5.New(), block: make([][]int64, f.Channels), b: make([]byte, 4)}
+	if err := fw.writeHeader(); err != nil {
+		return nil, err
+	}
+	return fw, nil
+}
+
+func (w *flacWriter) writeHeader() error {
+	var b bitWriter
+	b.bytes = append(b.bytes, "fLaC"...)
+	b.write(1, 1) // last metadata block
+	b.write(0, 7) // STREAMINFO
+	b.write(34, 24)
+	b.write(flacBlockSize, 16)
+	b.write(flacBlockSize, 16)
+	b.write(uint64(w.minSize), 24)
+	b.write(uint64(w.maxSize), 24)
+	b.write(uint64(w.f.SampleRate), 20)
+	b.write(uint64(w.f.Channels-1), 3)
+	b.write(uint64(w.f.Bits-1), 5)
+	b.write(uint64(w.frames), 36)
+	if w.blocks == 0 {
+		b.bytes = append(b.bytes, make([]byte, 16)...) // unknown MD5
+	} else {
+		b.bytes = w.md5.Sum(b.bytes)
+	}
+	_, err := w.w.Write(b.bytes)
+	return err
+}
+
+func (w *flacWriter) Write(samples []float64) error {
+	if len(samples)%w.f.Channels != 0 {
+		return errPartialFrame
+	}
+	n := w.f.Bits / 8
+	for i, x := range samples {
+		c := i % w.f.Channels
+		s := w.q.quantize(x, c)
+		binary.LittleEndian.PutUint32(w.b, uint32(s))
+		w.md5.Write(w.b[:n])
+		w.block[c] = append(w.block[c], int64(s))
+		if c == w.f.Channels-1 && len(w.block[c]) == flacBlockSize {
+			if err := w.writeFrame(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Close writes the last, partial block and fills in the stream's length,
+// frame sizes and MD5 signature.
+func (w *flacWriter) Close() error {
+	if len(w.block[0]) > 0 {
+		if err := w.writeFrame(); err != nil {
+			return err
+		}
+	}
+	if _, err := w.w.Seek(0, 0); err != nil {
+		return err
+	}
+	if err := w.writeHeader(); err != nil {
+		return err
+	}
+	_, err := w.w.Seek(0, 2)
+	return err
+}
+
+// Channel assignments.
+const (
+	flacLeftSide  = 8
+	flacRightSide = 9
+	flacMidSide   = 10
+)
+
+func (w *flacWriter) writeFrame() error {
+	n := len(w.block[0])
+	var b bitWriter
+	b.write(0xFFF8, 16) // sync code, fixed block size
+	if n == flacBlockSize {
+		b.write(12, 4)
+	} else {
+		b.write(7, 4) // 16-bit block size at the end of the header
+	}
+	b.write(0, 4) // sample rate from STREAMINFO
+
+	// Encode the channels independently and, for stereo, as side and mid,
+	// then pick the cheapest combination.
+	bits := w.f.Bits
+	subframes := make([]*bitWriter, w.f.Channels)
+	for c, x := range w.block {
+		subframes[c] = encodeSubframe(x, bits)
+	}
+	assignment := w.f.Channels - 1
+	if w.f.Channels == 2 {
+		l, r := w.block[0], w.block[1]
+		mid, side := make([]int64, n), make([]int64, n)
+		for i := range l {
+			mid[i], side[i] = (l[i]+r[i])>>1, l[i]-r[i]
+		}
+		m, s := encodeSubframe(mid, bits), encodeSubframe(side, bits+1)
+		best := subframes[0].len() + subframes[1].len()
+		if size := subframes[0].len() + s.len(); size < best {
+			best, assignment = size, flacLeftSide
+		}
+		if size := s.len() + subframes[1].len(); size < best {
+			best, assignment = size, flacRightSide
+		}
+		if size := m.len() + s.len(); size < best {
+			best, assignment = size, flacMidSide
+		}
+		switch assignment {
+		case flacLeftSide:
+			subframes[1] = s
+		case flacRightSide:
+			subframes[0] = s
+		case flacMidSide:
+			subframes[0], subframes[1] = m, s
+		}
+	}
+	b.write(uint64(assignment), 4)
+	b.write(0, 3) // sample size from STREAMINFO
+	b.write(0, 1)
+	b.writeUTF8(uint64(w.blocks))
+	if n != flacBlockSize {
+		b.write(uint64(n-1), 16)
+	}
+	b.write(uint64(crc8(b.bytes)), 8)
+
+	for _, s := range subframes {
+		b.append(s)
+	}
+	b.align()
+	b.write(uint64(crc16(b.bytes)), 16)
+
+	if _, err := w.w.Write(b.bytes); err != nil {
+		return err
+	}
+	size := len(b.bytes)
+	if w.blocks == 0 || size < w.minSize {
+		w.minSize = size
+	}
+	if size > w.maxSize {
+		w.maxSize = size
+	}
+	w.blocks++
+	w.frames += int64(n)
+	for c := range w.block {
+		w.block[c] = w.block[c][:0]
+	}
+	return nil
+}
+
+// encodeSubframe returns the smallest encoding of x, of which each sample
+// has the given number of bits:  constant, verbatim, or the residual of a
+// fixed predictor of order 0 to 4.
+func encodeSubframe(x []int64, bits int) *bitWriter {
+	constant := true
+	for _, y := range x {
+		constant = constant && y == x[0]
+	}
+	if constant {
+		b := &bitWriter{}
+		b.write(0, 8) // padding, type 0, no wasted bits
+		b.writeSigned(x[0], bits)
+		return b
+	}
+
+	best := &bitWriter{}
+	best.write(1<<1, 8) // verbatim
+	for _, x := range x {
+		best.writeSigned(x, bits)
+	}
+	residual := append([]int64(nil), x...)
+	for order := 0; order <= 4 && order < len(x); order++ {
+		if order > 0 {
+			// Each order's residual is the difference of the previous order's.
+			for i := len(x) - 1; i >= order; i-- {
+				residual[i] -= residual[i-1]
+			}
+		}
+		b := &bitWriter{}
+		b.write(uint64(8|order)<<1, 8)
+		for _, x := range x[:order] {
+			b.writeSigned(x, bits)
+		}
+		writeResidual(b, residual[order:], len(x), order)
+		if b.len() < best.len() {
+			best = b
+		}
+	}
+	return best
+}
+
+// writeResidual Rice codes the residual of a block of n samples, choosing the
+// partition order and Rice parameters that minimize its size.
+func writeResidual(b *bitWriter, residual []int64, n, order int) {
+	u := make([]uint64, len(residual))
+	for i, r := range residual {
+		u[i] = uint64(r<<1 ^ r>>63)
+	}
+
+	bestCost := -1
+	var bestParams []int
+	bestOrder := 0
+	for p := 0; p <= 8 && n%(1<<uint(p)) == 0 && n>>uint(p) > order; p++ {
+		cost := 0
+		params := make([]int, 1<<uint(p))
+		start := 0
+		for i := range params {
+			end := (i + 1) * (n >> uint(p))
+			params[i] = riceParam(u[start : end-order])
+			cost += 5 + riceCost(u[start:end-order], params[i])
+			start = end - order
+		}
+		if bestCost < 0 || cost < bestCost {
+			bestCost, bestParams, bestOrder = cost, params, p
+		}
+	}
+
+	paramBits := 4
+	for _, k := range bestParams {
+		if k > 14 {
+			paramBits = 5 // RICE2
+		}
+	}
+	b.write(uint64(paramBits-4), 2)
+	b.write(uint64(bestOrder), 4)
+	start := 0
+	for i, k := range bestParams {
+		end := (i + 1) * (n >> uint(bestOrder))
+		b.write(uint64(k), paramBits)
+		for _, u := range u[start : end-order] {
+			b.writeRice(u, k)
+		}
+		start = end - order
+	}
+}
+
+// riceParam returns the Rice parameter that best codes u.
+func riceParam(u []uint64) int {
+	best, bestCost := 0, -1
+	for k := 0; k <= 30; k++ {
+		cost := riceCost(u, k)
+		if bestCost >= 0 && cost > bestCost {
+			break // the cost is convex in k
+		}
+		if bestCost < 0 || cost < bestCost {
+			best, bestCost = k, cost
+		}
+	}
+	return best
+}
+
+func riceCost(u []uint64, k int) int {
+	cost := len(u) * (k + 1)
+	for _, u := range u {
+		cost += int(u >> uint(k))
+	}
+	return cost
+}
+
+// A bitWriter accumulates bits, most significant first.
+type bitWriter struct {
+	bytes []byte
+	acc   uint64 // the bits after bytes
+	n     uint   // the number of bits in acc
+}
+
+func (b *bitWriter) len() int { return 8*len(b.bytes) + int(b.n) }
+
+func (b *bitWriter) write(x uint64, bits int) {
+	for bits > 0 {
+		m := 64 - int(b.n)
+		if m > bits {
+			m = bits
+		}
+		if m > 32 {
+			m = 32
+		}
+		bits -= m
+		b.acc = b.acc<<uint(m) | x>>uint(bits)&(1<<uint(m)-1)
+		b.n += uint(m)
+		for b.n >= 8 {
+			b.n -= 8
+			b.bytes = append(b.bytes, byte(b.acc>>b.n))
+		}
+	}
+}
+
+func (b *bitWriter) writeSigned(x int64, bits int) { b.write(uint64(x)&(1<<uint(bits)-1), bits) }
+
+func (b *bitWriter) writeRice(u uint64, k int) {
+	for q := u >> uint(k); q > 0; {
+		m := q
+		if m > 32 {
+			m = 32
+		}
+		b.write(0, int(m))
+		q -= m
+	}
+	b.write(1, 1)
+	b.write(u, k)
+}
+
+// writeUTF8 writes x in the UTF-8-like coding that FLAC uses for frame numbers.
+func (b *bitWriter) writeUTF8(x uint64) {
+	if x < 0x80 {
+		b.write(x, 8)
+		return
+	}
+	n := 2 // the number of bytes
+	for x >= 1<<uint(5*n+1) {
+		n++
+	}
+	b.write(1<<uint(n)-1, n) // n ones
+	b.write(0, 1)
+	b.write(x>>uint(6*(n-1)), 8-n-1)
+	for i := n - 2; i >= 0; i-- {
+		b.write(2, 2)
+		b.write(x>>uint(6*i)&0x3F, 6)
+	}
+}
+
+// append appends the bits of c.
+func (b *bitWriter) append(c *bitWriter) {
+	for _, x := range c.bytes {
+		b.write(uint64(x), 8)
+	}
+	b.write(c.acc, int(c.n))
+}
+
+// align pads the bits with zeros to a whole number of bytes.
+func (b *bitWriter) align() {
+	if b.n > 0 {
+		b.write(0, int(8-b.n))
+	}
+}
+
+func crc8(b []byte) byte {
+	crc := byte(0)
+	for _, x := range b {
+		crc ^= x
+		for i := 0; i < 8; i++ {
+			if crc&0x80 != 0 {
+				crc = crc<<1 ^ 0x07
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
+
+func crc16(b []byte) uint16 {
+	crc := uint16(0)
+	for _, x := range b {
+		crc ^= uint16(x) << 8
+		for i := 0; i < 8; i++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x8005
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
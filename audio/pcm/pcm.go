@@ -0,0 +1,141 @@
+// Package pcm writes audio to WAV, AIFF and FLAC files, quantizing it with
+// dither where needed, and reads it from WAV files.
+package pcm
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// A Format describes the samples in a file.
+type Format struct {
+	SampleRate int
+	Channels   int
+	Bits       int  // 16 or 24; or 32 if Float
+	Float      bool // IEEE floating point; WAV only
+	Dither     Dither
+}
+
+// A Dither is the noise added to a signal before it is rounded to integer
+// samples, to decorrelate the rounding error from the signal.
+type Dither int
+
+const (
+	NoDither Dither = iota
+
+	// TPDF adds white noise with a triangular distribution two steps wide,
+	// which makes the rounding error white and independent of the signal.
+	TPDF
+
+	// NoiseShaped is TPDF with the rounding error fed back through a filter
+	// that moves its power up to where hearing is least sensitive.  The filter
+	// is designed for a 44.1 kHz sample rate.
+	NoiseShaped
+)
+
+// A Writer writes interleaved samples, nominally between -1 and 1, to a
+// file.  Integer formats clip samples outside that range.  Close must be
+// called to complete the file; it does not close the underlying io.Writer.
+type Writer interface {
+	Write(samples []float64) error
+	Close() error
+}
+
+// Create creates the named file and returns a Writer for the format implied
+// by its extension:  .wav, .aif, .aiff or .flac.  Closing the Writer closes
+// the file.
+func Create(filename string, f Format) (Writer, error) {
+	var newWriter func(io.WriteSeeker, Format) (Writer, error)
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".wav":
+		newWriter = NewWAVWriter
+	case ".aif", ".aiff":
+		newWriter = NewAIFFWriter
+	case ".flac":
+		newWriter = NewFLACWriter
+	default:
+		return nil, fmt.Errorf("unknown audio file type: %s", filename)
+	}
+	file, err := os.Create(filename)
+	if err != nil {
+		return nil, err
+	}
+	w, err := newWriter(file, f)
+	if err != nil {
+		file.Close()
+		os.Remove(filename)
+		return nil, err
+	}
+	return fileWriter{w, file}, nil
+}
+
+type fileWriter struct {
+	Writer
+	file *os.File
+}
+
+func (w fileWriter) Close() error {
+	err := w.Writer.Close()
+	if err2 := w.file.Close(); err == nil {
+		err = err2
+	}
+	return err
+}
+
+var errPartialFrame = errors.New("pcm: samples don't fill a whole number of frames")
+
+func (f Format) check(maxBits int) error {
+	switch {
+	case f.SampleRate <= 0:
+		return fmt.Errorf("pcm: bad sample rate %d", f.SampleRate)
+	case f.Channels <= 0:
+		return fmt.Errorf("pcm: bad number of channels %d", f.Channels)
+	case f.Float && f.Bits != 32:
+		return fmt.Errorf("pcm: %d-bit floating point is not supported", f.Bits)
+	case !f.Float && (f.Bits != 16 && f.Bits != 24 && f.Bits != 32 || f.Bits > maxBits):
+		return fmt.Errorf("pcm: %d-bit integer samples are not supported", f.Bits)
+	}
+	return nil
+}
+
+// A quantizer converts samples to integers of a given number of bits.
+type quantizer struct {
+	scale, min, max float64
+	dither          Dither
+	rand            *rand.Rand
+	errs            [][5]float64 // the last rounding errors in each channel, most recent first
+}
+
+// Lipshitz et al.'s minimally audible error feedback filter for 44.1 kHz.
+var noiseShape = [5]float64{2.033, -2.165, 1.959, -1.590, .6149}
+
+func newQuantizer(f Format) *quantizer {
+	scale := math.Exp2(float64(f.Bits - 1))
+	return &quantizer{scale, -scale, scale - 1, f.Dither, rand.New(rand.NewSource(1)), make([][5]float64, f.Channels)}
+}
+
+func (q *quantizer) quantize(x float64, channel int) int32 {
+	x *= q.scale
+	e := &q.errs[channel]
+	if q.dither == NoiseShaped {
+		for i, c := range noiseShape {
+			x -= c * e[i]
+		}
+	}
+	y := x
+	if q.dither != NoDither {
+		y += q.rand.Float64() - q.rand.Float64()
+	}
+	y = math.Max(q.min, math.Min(q.max, math.Floor(y+.5)))
+	if q.dither == NoiseShaped {
+		copy(e[1:], e[:4])
+		e[0] = math.Max(-2, math.Min(2, y-x)) // limited, so that clipping can't make the filter unstable
+	}
+	return int32(y)
+}
@@ -0,0 +1,467 @@
+package pcm
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/binary"
+	"errors"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// A buffer is an in-memory io.WriteSeeker.
+type buffer struct {
+	b   []byte
+	pos int
+}
+
+func (b *buffer) Write(p []byte) (int, error) {
+	if n := b.pos + len(p); n > len(b.b) {
+		b.b = append(b.b, make([]byte, n-len(b.b))...)
+	}
+	copy(b.b[b.pos:], p)
+	b.pos += len(p)
+	return len(p), nil
+}
+
+func (b *buffer) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case 0:
+		b.pos = int(offset)
+	case 1:
+		b.pos += int(offset)
+	case 2:
+		b.pos = len(b.b) + int(offset)
+	}
+	return int64(b.pos), nil
+}
+
+// testSignal returns interleaved samples of a few tones and some noise, which
+// exercises all of the FLAC encoder's subframe types.
+func testSignal(channels, n int) []float64 {
+	x := make([]float64, channels*n)
+	r := rand.New(rand.NewSource(2))
+	for i := 0; i < n; i++ {
+		for c := 0; c < channels; c++ {
+			v := .5 * math.Sin(float64(i*(c+1))/30)
+			switch {
+			case i < 5000:
+			case i < 6000:
+				v = .25 // constant
+			case i < 9000:
+				v = r.Float64()*2 - 1 // noise
+			default:
+				v += .01 * r.NormFloat64()
+			}
+			x[i*channels+c] = v
+		}
+	}
+	return x
+}
+
+func quantized(x []float64, f Format) []int64 {
+	q := newQuantizer(f)
+	y := make([]int64, len(x))
+	for i, x := range x {
+		y[i] = int64(q.quantize(x, i%f.Channels))
+	}
+	return y
+}
+
+func TestWAV(t *testing.T) {
+	for _, f := range []Format{{44100, 2, 16, false, TPDF}, {96000, 1, 24, false, NoDither}, {48000, 2, 32, true, NoDither}} {
+		x := testSignal(f.Channels, 1001)
+		var b buffer
+		w, err := NewWAVWriter(&b, f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write(x[:f.Channels*500])
+		w.Write(x[f.Channels*500:])
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		le := binary.LittleEndian
+		size := len(x) * f.Bits / 8
+		if string(b.b[:4]) != "RIFF" || int(le.Uint32(b.b[4:])) != len(b.b)-8 || int(le.Uint32(b.b[40:])) != size || len(b.b) != 44+size+size%2 {
+			t.Fatalf("%v: bad header", f)
+		}
+		if int(le.Uint32(b.b[24:])) != f.SampleRate || int(le.Uint16(b.b[22:])) != f.Channels {
+			t.Fatalf("%v: wrong format in header", f)
+		}
+		want := quantized(x, f)
+		for i := range x {
+			s := b.b[44+i*f.Bits/8:]
+			var got int64
+			switch {
+			case f.Float:
+				if y := math.Float32frombits(le.Uint32(s)); y != float32(x[i]) {
+					t.Fatalf("%v: sample %d is %v; want %v", f, i, y, x[i])
+				}
+				continue
+			case f.Bits == 16:
+				got = int64(int16(le.Uint16(s)))
+			case f.Bits == 24:
+				got = int64(int32(le.Uint32(append(s[:3:3], 0))<<8) >> 8)
+			}
+			if got != want[i] {
+				t.Fatalf("%v: sample %d is %d; want %d", f, i, got, want[i])
+			}
+		}
+	}
+}
+
+func TestAIFF(t *testing.T) {
+	f := Format{44100, 2, 24, false, NoDither}
+	x := testSignal(f.Channels, 333)
+	var b buffer
+	w, err := NewAIFFWriter(&b, f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write(x)
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	be := binary.BigEndian
+	size := len(x) * 3
+	if string(b.b[:4]) != "FORM" || int(be.Uint32(b.b[4:])) != len(b.b)-8 || int(be.Uint32(b.b[22:])) != 333 || len(b.b) != 54+size {
+		t.Fatal("bad header")
+	}
+	if rate := b.b[28:38]; !bytes.Equal(rate, []byte{0x40, 0x0E, 0xAC, 0x44, 0, 0, 0, 0, 0, 0}) {
+		t.Fatalf("sample rate is % x; want 44100", rate)
+	}
+	want := quantized(x, f)
+	for i := range x {
+		s := b.b[54+3*i:]
+		if got := int64(int32(uint32(s[0])<<24|uint32(s[1])<<16|uint32(s[2])<<8) >> 8); got != want[i] {
+			t.Fatalf("sample %d is %d; want %d", i, got, want[i])
+		}
+	}
+}
+
+func TestFLAC(t *testing.T) {
+	for _, f := range []Format{{44100, 2, 16, false, NoiseShaped}, {96000, 1, 24, false, NoDither}, {48000, 3, 24, false, TPDF}} {
+		for _, n := range []int{0, 1, 4096, 10000} {
+			x := testSignal(f.Channels, n)
+			var b buffer
+			w, err := NewFLACWriter(&b, f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := 0; i < len(x); i += 999 * f.Channels {
+				end := i + 999*f.Channels
+				if end > len(x) {
+					end = len(x)
+				}
+				w.Write(x[i:end])
+			}
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+			got, err := decodeFLAC(b.b, f)
+			if err != nil {
+				t.Fatalf("%v, %d samples: %s", f, n, err)
+			}
+			want := quantized(x, f)
+			if len(got) != len(want) {
+				t.Fatalf("%v: decoded %d samples; want %d", f, len(got), len(want))
+			}
+			for i := range got {
+				if got[i] != want[i] {
+					t.Fatalf("%v: sample %d is %d; want %d", f, i, got[i], want[i])
+				}
+			}
+		}
+	}
+}
+
+func TestFLACCompresses(t *testing.T) {
+	f := Format{44100, 2, 16, false, TPDF}
+	x := make([]float64, 2*44100)
+	for i := range x {
+		x[i] = .5 * math.Sin(float64(i/2)/20)
+	}
+	var b buffer
+	w, _ := NewFLACWriter(&b, f)
+	w.Write(x)
+	w.Close()
+	if len(b.b) > len(x) { // half of the size of 16-bit PCM
+		t.Errorf("a stereo sine wave takes %d bytes; want at most %d", len(b.b), len(x))
+	}
+}
+
+func TestDither(t *testing.T) {
+	// Dither lets a signal smaller than one step through on average.
+	for _, d := range []Dither{TPDF, NoiseShaped} {
+		f := Format{44100, 1, 16, false, d}
+		q := newQuantizer(f)
+		x := .3 / 32768
+		sum := 0.0
+		const n = 100000
+		for i := 0; i < n; i++ {
+			sum += float64(q.quantize(x, 0))
+		}
+		if mean := sum / n; math.Abs(mean-.3) > .02 {
+			t.Errorf("dither %d: mean is %v; want .3", d, mean)
+		}
+	}
+}
+
+func TestNoiseShaping(t *testing.T) {
+	// Noise shaping moves quantization noise out of the low frequencies.
+	lowNoise := func(d Dither) float64 {
+		f := Format{44100, 1, 16, false, d}
+		x := sineSamples(1000, 44100, 1<<16)
+		y := quantized(x, f)
+		var lp [4]float64
+		power := 0.0
+		for i := range x {
+			e := float64(y[i]) - x[i]*32768
+			for j := range lp { // a lowpass below about 350 Hz
+				lp[j] += (e - lp[j]) * .05
+				e = lp[j]
+			}
+			power += e * e
+		}
+		return power
+	}
+	if shaped, flat := lowNoise(NoiseShaped), lowNoise(TPDF); shaped > flat/4 {
+		t.Errorf("low frequency noise is %v with noise shaping and %v without", shaped, flat)
+	}
+}
+
+func sineSamples(freq, rate float64, n int) []float64 {
+	x := make([]float64, n)
+	for i := range x {
+		x[i] = .5 * math.Sin(2*math.Pi*freq*float64(i)/rate)
+	}
+	return x
+}
+
+// decodeFLAC decodes the subset of FLAC that the encoder produces, checking
+// that the stream's header matches f, and returns interleaved samples.
+func decodeFLAC(data []byte, f Format) ([]int64, error) {
+	r := &bitReader{b: data}
+	if string(data[:4]) != "fLaC" {
+		return nil, errors.New("no fLaC marker")
+	}
+	r.pos = 32
+	if r.read(1) != 1 || r.read(7) != 0 || r.read(24) != 34 {
+		return nil, errors.New("bad STREAMINFO header")
+	}
+	r.read(16 + 16 + 24 + 24)
+	if int(r.read(20)) != f.SampleRate || int(r.read(3))+1 != f.Channels || int(r.read(5))+1 != f.Bits {
+		return nil, errors.New("wrong format in STREAMINFO")
+	}
+	total := int(r.read(36))
+	sum := data[26:42]
+	r.pos = 42 * 8
+
+	var out []int64
+	for frame := 0; r.pos/8 < len(data); frame++ {
+		start := r.pos / 8
+		if r.read(16) != 0xFFF8 {
+			return nil, errors.New("bad sync code")
+		}
+		bsCode, rateCode := r.read(4), r.read(4)
+		assignment, sizeCode := int(r.read(4)), r.read(3)
+		if rateCode != 0 || sizeCode != 0 || r.read(1) != 0 {
+			return nil, errors.New("unexpected frame header")
+		}
+		if n := r.readUTF8(); n != frame {
+			return nil, errors.New("bad frame number")
+		}
+		n := 4096
+		if bsCode == 7 {
+			n = int(r.read(16)) + 1
+		} else if bsCode != 12 {
+			return nil, errors.New("unexpected block size code")
+		}
+		if crc := byte(r.read(8)); crc != crc8(data[start:r.pos/8-1]) {
+			return nil, errors.New("bad header CRC")
+		}
+
+		channels := make([][]int64, f.Channels)
+		for c := range channels {
+			bits := f.Bits
+			if assignment == flacLeftSide && c == 1 || assignment == flacRightSide && c == 0 || assignment == flacMidSide && c == 1 {
+				bits++
+			}
+			x, err := r.readSubframe(n, bits)
+			if err != nil {
+				return nil, err
+			}
+			channels[c] = x
+		}
+		for i := 0; i < n; i++ {
+			switch assignment {
+			case flacLeftSide:
+				channels[1][i] = channels[0][i] - channels[1][i]
+			case flacRightSide:
+				channels[0][i] += channels[1][i]
+			case flacMidSide:
+				mid, side := channels[0][i]<<1|channels[1][i]&1, channels[1][i]
+				channels[0][i], channels[1][i] = (mid+side)>>1, (mid-side)>>1
+			}
+			for c := range channels {
+				out = append(out, channels[c][i])
+			}
+		}
+
+		r.pos = (r.pos + 7) &^ 7
+		end := r.pos / 8
+		if crc := uint16(r.read(16)); crc != crc16(data[start:end]) {
+			return nil, errors.New("bad frame CRC")
+		}
+	}
+	if len(out) != total*f.Channels {
+		return nil, errors.New("wrong total number of samples in STREAMINFO")
+	}
+	h := md5.New()
+	for _, x := range out {
+		b := make([]byte, 4)
+		binary.LittleEndian.PutUint32(b, uint32(x))
+		h.Write(b[:f.Bits/8])
+	}
+	if total > 0 && !bytes.Equal(h.Sum(nil), sum) {
+		return nil, errors.New("bad MD5 signature")
+	}
+	return out, nil
+}
+
+type bitReader struct {
+	b   []byte
+	pos int // in bits
+}
+
+func (r *bitReader) read(n int) uint64 {
+	x := uint64(0)
+	for ; n > 0; n-- {
+		bit := r.b[r.pos/8] >> uint(7-r.pos%8) & 1
+		x = x<<1 | uint64(bit)
+		r.pos++
+	}
+	return x
+}
+
+func (r *bitReader) readSigned(n int) int64 {
+	return int64(r.read(n)<<uint(64-n)) >> uint(64-n)
+}
+
+func (r *bitReader) readUTF8() int {
+	x := r.read(8)
+	n := 0
+	for x&(0x80>>uint(n)) != 0 {
+		n++
+	}
+	if n == 0 {
+		return int(x)
+	}
+	v := int(x & (0xFF >> uint(n+1)))
+	for i := 1; i < n; i++ {
+		v = v<<6 | int(r.read(8)&0x3F)
+	}
+	return v
+}
+
+func (r *bitReader) readSubframe(n, bits int) ([]int64, error) {
+	if r.read(1) != 0 {
+		return nil, errors.New("bad subframe padding")
+	}
+	typ := int(r.read(6))
+	if r.read(1) != 0 {
+		return nil, errors.New("unexpected wasted bits")
+	}
+	x := make([]int64, n)
+	switch {
+	case typ == 0:
+		v := r.readSigned(bits)
+		for i := range x {
+			x[i] = v
+		}
+	case typ == 1:
+		for i := range x {
+			x[i] = r.readSigned(bits)
+		}
+	case typ >= 8 && typ <= 12:
+		order := typ - 8
+		for i := 0; i < order; i++ {
+			x[i] = r.readSigned(bits)
+		}
+		method := r.read(2)
+		if method > 1 {
+			return nil, errors.New("bad residual coding method")
+		}
+		paramBits := 4 + int(method)
+		partitions := 1 << uint(r.read(4))
+		i := order
+		for p := 0; p < partitions; p++ {
+			k := int(r.read(paramBits))
+			if k == 1<<uint(paramBits)-1 {
+				return nil, errors.New("unexpected escape code")
+			}
+			for end := (p + 1) * n / partitions; i < end; i++ {
+				q := 0
+				for r.read(1) == 0 {
+					q++
+				}
+				u := uint64(q)<<uint(k) | r.read(k)
+				x[i] = int64(u>>1) ^ -int64(u&1)
+			}
+		}
+		coeffs := [][]int64{{}, {1}, {2, -1}, {3, -3, 1}, {4, -6, 4, -1}}[order]
+		for i := order; i < n; i++ {
+			for j, c := range coeffs {
+				x[i] += c * x[i-1-j]
+			}
+		}
+	default:
+		return nil, errors.New("unexpected subframe type")
+	}
+	return x, nil
+}
+
+func TestWAVRead(t *testing.T) {
+	for _, f := range []Format{{44100, 2, 16, false, NoDither}, {96000, 1, 24, false, NoDither}, {48000, 2, 32, true, NoDither}} {
+		x := testSignal(f.Channels, 1001)
+		var b buffer
+		w, err := NewWAVWriter(&b, f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write(x)
+		w.Close()
+
+		// Insert a chunk that the reader must skip.
+		b.b = append(b.b[:36:36], append([]byte("LIST\x03\x00\x00\x00abc\x00"), b.b[36:]...)...)
+
+		r, err := NewWAVReader(bytes.NewReader(b.b))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if g := r.Format(); g != f {
+			t.Fatalf("read format %v; want %v", g, f)
+		}
+		y, err := ReadAll(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(y) != len(x) {
+			t.Fatalf("%v: read %d samples; want %d", f, len(y), len(x))
+		}
+		want := quantized(x, f)
+		for i := range x {
+			if f.Float {
+				if y[i] != float64(float32(x[i])) {
+					t.Fatalf("%v: sample %d is %v; want %v", f, i, y[i], x[i])
+				}
+			} else if got := y[i] * math.Exp2(float64(f.Bits-1)); got != float64(want[i]) {
+				t.Fatalf("%v: sample %d is %v; want %d", f, i, got, want[i])
+			}
+		}
+	}
+}
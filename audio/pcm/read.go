@@ -0,0 +1,182 @@
+package pcm
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// A Reader reads interleaved samples, scaled to between -1 and 1 for integer
+// formats, from a file.
+type Reader interface {
+	Format() Format
+
+	// Read reads up to len(samples) samples, a whole number of frames.  At
+	// the end of the file it returns 0, io.EOF.
+	Read(samples []float64) (n int, err error)
+
+	Close() error
+}
+
+// Open opens the named file for reading.  Only WAV files are supported.
+// Closing the Reader closes the file.
+func Open(filename string) (Reader, error) {
+	if ext := strings.ToLower(filepath.Ext(filename)); ext != ".wav" {
+		return nil, fmt.Errorf("can't read audio file type: %s", filename)
+	}
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	r, err := NewWAVReader(file)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("%s: %s", filename, err)
+	}
+	return fileReader{r, file}, nil
+}
+
+type fileReader struct {
+	Reader
+	file *os.File
+}
+
+func (r fileReader) Close() error { return r.file.Close() }
+
+// ReadAll reads the rest of r's samples.
+func ReadAll(r Reader) ([]float64, error) {
+	x := []float64{}
+	buf := make([]float64, 4096*r.Format().Channels)
+	for {
+		n, err := r.Read(buf)
+		x = append(x, buf[:n]...)
+		if err == io.EOF {
+			return x, nil
+		}
+		if err != nil {
+			return x, err
+		}
+	}
+}
+
+type wavReader struct {
+	r     *bufio.Reader
+	f     Format
+	left  int64 // bytes of sample data
+	bytes int   // per sample
+	b     []byte
+}
+
+// NewWAVReader reads a WAV file with 8-, 16-, 24- or 32-bit integer or 32-
+// or 64-bit floating point samples.
+func NewWAVReader(r io.Reader) (Reader, error) {
+	wr := &wavReader{r: bufio.NewReader(r), b: make([]byte, 8)}
+	le := binary.LittleEndian
+	h := make([]byte, 12)
+	if _, err := io.ReadFull(wr.r, h); err != nil {
+		return nil, errors.New("pcm: not a WAV file")
+	}
+	if string(h[:4]) != "RIFF" || string(h[8:]) != "WAVE" {
+		return nil, errors.New("pcm: not a WAV file")
+	}
+	haveFormat := false
+	for {
+		if _, err := io.ReadFull(wr.r, h[:8]); err != nil {
+			return nil, errors.New("pcm: no data in WAV file")
+		}
+		id, size := string(h[:4]), int64(le.Uint32(h[4:]))
+		switch id {
+		case "fmt ":
+			if size < 16 {
+				return nil, errors.New("pcm: bad WAV format chunk")
+			}
+			c := make([]byte, size+size%2)
+			if _, err := io.ReadFull(wr.r, c); err != nil {
+				return nil, err
+			}
+			format := le.Uint16(c)
+			if format == 0xFFFE && size >= 26 { // WAVE_FORMAT_EXTENSIBLE
+				format = le.Uint16(c[24:])
+			}
+			wr.f = Format{
+				SampleRate: int(le.Uint32(c[4:])),
+				Channels:   int(le.Uint16(c[2:])),
+				Bits:       int(le.Uint16(c[14:])),
+				Float:      format == 3,
+			}
+			if format != 1 && format != 3 {
+				return nil, fmt.Errorf("pcm: unsupported WAV format %d", format)
+			}
+			switch {
+			case wr.f.Channels <= 0:
+				return nil, fmt.Errorf("pcm: bad number of channels %d", wr.f.Channels)
+			case wr.f.Float && wr.f.Bits != 32 && wr.f.Bits != 64:
+				return nil, fmt.Errorf("pcm: %d-bit floating point is not supported", wr.f.Bits)
+			case !wr.f.Float && (wr.f.Bits%8 != 0 || wr.f.Bits < 8 || wr.f.Bits > 32):
+				return nil, fmt.Errorf("pcm: %d-bit integer samples are not supported", wr.f.Bits)
+			}
+			wr.bytes = wr.f.Bits / 8
+			haveFormat = true
+		case "data":
+			if !haveFormat {
+				return nil, errors.New("pcm: WAV data before format")
+			}
+			wr.left = size - size%int64(wr.f.Channels*wr.bytes)
+			return wr, nil
+		default:
+			if _, err := io.CopyN(ioutil.Discard, wr.r, size+size%2); err != nil {
+				return nil, errors.New("pcm: no data in WAV file")
+			}
+		}
+	}
+}
+
+func (r *wavReader) Format() Format { return r.f }
+
+func (r *wavReader) Read(samples []float64) (int, error) {
+	if r.left == 0 {
+		return 0, io.EOF
+	}
+	n := len(samples) - len(samples)%r.f.Channels
+	if max := r.left / int64(r.bytes); int64(n) > max {
+		n = int(max)
+	}
+	le := binary.LittleEndian
+	b := r.b[:r.bytes]
+	for i := 0; i < n; i++ {
+		if _, err := io.ReadFull(r.r, b); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return i - i%r.f.Channels, err
+		}
+		var x float64
+		switch {
+		case r.f.Float && r.bytes == 4:
+			x = float64(math.Float32frombits(le.Uint32(b)))
+		case r.f.Float:
+			x = math.Float64frombits(le.Uint64(b))
+		case r.bytes == 1:
+			x = (float64(b[0]) - 128) / 128
+		default:
+			var u uint32
+			for j := r.bytes - 1; j >= 0; j-- {
+				u = u<<8 | uint32(b[j])
+			}
+			shift := uint(32 - r.f.Bits)
+			x = float64(int32(u<<shift)>>shift) / math.Exp2(float64(r.f.Bits-1))
+		}
+		samples[i] = x
+	}
+	r.left -= int64(n * r.bytes)
+	return n, nil
+}
+
+func (r *wavReader) Close() error { return nil }
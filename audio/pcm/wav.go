@@ -0,0 +1,97 @@
+package pcm
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"math"
+)
+
+type wavWriter struct {
+	w      io.WriteSeeker
+	buf    *bufio.Writer
+	f      Format
+	q      *quantizer
+	frames int64
+	b      []byte
+}
+
+// NewWAVWriter writes a WAV file with 16-, 24- or 32-bit integer or 32-bit
+// floating point samples.
+func NewWAVWriter(w io.WriteSeeker, f Format) (Writer, error) {
+	if err := f.check(32); err != nil {
+		return nil, err
+	}
+	ww := &wavWriter{w: w, buf: bufio.NewWriter(w), f: f, q: newQuantizer(f), b: make([]byte, 4)}
+	if err := ww.writeHeader(); err != nil {
+		return nil, err
+	}
+	return ww, nil
+}
+
+func (w *wavWriter) dataSize() int64 { return w.frames * int64(w.f.Channels*w.f.Bits/8) }
+
+func (w *wavWriter) writeHeader() error {
+	format := uint16(1) // PCM
+	if w.f.Float {
+		format = 3 // IEEE float
+	}
+	blockAlign := w.f.Channels * w.f.Bits / 8
+	size := w.dataSize()
+	le := binary.LittleEndian
+	h := make([]byte, 44)
+	copy(h[0:], "RIFF")
+	le.PutUint32(h[4:], uint32(36+size+size%2))
+	copy(h[8:], "WAVEfmt ")
+	le.PutUint32(h[16:], 16)
+	le.PutUint16(h[20:], format)
+	le.PutUint16(h[22:], uint16(w.f.Channels))
+	le.PutUint32(h[24:], uint32(w.f.SampleRate))
+	le.PutUint32(h[28:], uint32(w.f.SampleRate*blockAlign))
+	le.PutUint16(h[32:], uint16(blockAlign))
+	le.PutUint16(h[34:], uint16(w.f.Bits))
+	copy(h[36:], "data")
+	le.PutUint32(h[40:], uint32(size))
+	_, err := w.buf.Write(h)
+	return err
+}
+
+func (w *wavWriter) Write(samples []float64) error {
+	if len(samples)%w.f.Channels != 0 {
+		return errPartialFrame
+	}
+	n := w.f.Bits / 8
+	for i, x := range samples {
+		if w.f.Float {
+			binary.LittleEndian.PutUint32(w.b, math.Float32bits(float32(x)))
+		} else {
+			binary.LittleEndian.PutUint32(w.b, uint32(w.q.quantize(x, i%w.f.Channels)))
+		}
+		if _, err := w.buf.Write(w.b[:n]); err != nil {
+			return err
+		}
+	}
+	w.frames += int64(len(samples) / w.f.Channels)
+	return nil
+}
+
+// Close pads the data to an even length and fills in the sizes in the header.
+func (w *wavWriter) Close() error {
+	if w.dataSize()%2 == 1 {
+		w.buf.WriteByte(0)
+	}
+	if err := w.buf.Flush(); err != nil {
+		return err
+	}
+	if _, err := w.w.Seek(0, 0); err != nil {
+		return err
+	}
+	if err := w.writeHeader(); err != nil {
+		return err
+	}
+	if err := w.buf.Flush(); err != nil {
+		return err
+	}
+	_, err := w.w.Seek(0, 2)
+	return err
+}
@@ -2,7 +2,10 @@ package audio
 
 import (
 	"fmt"
+	"math"
 	"os"
+	"sync"
+	"time"
 )
 
 var playControls []PlayControl
@@ -11,15 +14,64 @@ func Play(v Voice) {
 	<-PlayAsync(v).Done
 }
 
+// A BufferStarter is a Voice that wants to know when each buffer of audio
+// begins.  PlayAsync calls StartBuffer on the audio thread before rendering
+// each buffer; it is the place to pick up changes made by other goroutines.
+type BufferStarter interface {
+	StartBuffer()
+}
+
 func PlayAsync(v Voice) PlayControl {
-	c := PlayControl{make(chan struct{}, 1), make(chan struct{}, 1)}
-	err := startPlaying(v, func(out []float32) {
+	return playAsync(v, nil)
+}
+
+// PlayDuplex plays v while capturing from the default input device into c,
+// so that Inputs reading from c process the live signal.
+func PlayDuplex(v Voice, c *LiveCapture) PlayControl {
+	return playAsync(v, c)
+}
+
+func playAsync(v Voice, capture *LiveCapture) PlayControl {
+	c := PlayControl{make(chan struct{}, 1), make(chan struct{}), &playStats{}}
+	startBuffer := func() {}
+	if b, ok := v.(BufferStarter); ok {
+		startBuffer = b.StartBuffer
+	}
+	channels := 1
+	sing := func(out []float32) {
 		for i := range out {
 			out[i] = float32(v.Sing())
 		}
+	}
+	if s, ok := v.(StereoVoice); ok && maxChannels >= 2 {
+		channels = 2
+		sing = func(out []float32) {
+			for i := 0; i < len(out); i += 2 {
+				l, r := s.SingStereo()
+				out[i], out[i+1] = float32(l), float32(r)
+			}
+		}
+	}
+	inChannels := 0
+	if capture != nil {
+		inChannels = capture.channels
+	}
+	var sampleRate float64
+	initAudio := func(p Params) {
+		Init(v, p)
+		sampleRate = p.SampleRate
+	}
+	err := startPlaying(initAudio, inChannels, channels, func(in, out []float32, flags int) {
+		start := time.Now()
+		if capture != nil {
+			capture.startBuffer(in)
+		}
+		startBuffer()
+		sing(out)
 		if v.Done() {
 			c.Stop()
 		}
+		c.stats.update(time.Since(start).Seconds(), float64(len(out)/channels)/sampleRate, flags)
 	})
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
@@ -32,14 +84,16 @@ func PlayAsync(v Voice) PlayControl {
 		if err := stopPlaying(); err != nil {
 			fmt.Fprintln(os.Stderr, err)
 		}
-		c.Done <- struct{}{}
+		close(c.Done)
 	}()
 	playControls = append(playControls, c)
 	return c
 }
 
+// Done is closed when playback has stopped.
 type PlayControl struct {
 	stop, Done chan struct{}
+	stats      *playStats
 }
 
 func (c PlayControl) Stop() {
@@ -48,3 +102,78 @@ func (c PlayControl) Stop() {
 	default:
 	}
 }
+
+// Problems that a backend reports with a buffer.
+const (
+	underflow = 1 << iota
+	overflow
+)
+
+// PlayStats describes how well playback is keeping up.
+type PlayStats struct {
+	// Load is the time spent rendering each buffer as a fraction of the
+	// buffer's duration, averaged over about the last second; PeakLoad is
+	// its recent maximum, decaying at the same rate.
+	Load, PeakLoad float64
+
+	// Late counts the buffers that took longer to render than to play.
+	// Underflows and Overflows count the buffers for which the device
+	// reported that output ran dry or input was lost.
+	Late, Underflows, Overflows int64
+}
+
+func (s PlayStats) String() string {
+	return fmt.Sprintf("DSP load %.0f%% (peak %.0f%%), %d late, %d underflows, %d overflows", 100*s.Load, 100*s.PeakLoad, s.Late, s.Underflows, s.Overflows)
+}
+
+type playStats struct {
+	mu    sync.Mutex
+	stats PlayStats
+}
+
+func (p *playStats) update(elapsed, duration float64, flags int) {
+	x := elapsed / duration
+	a := math.Exp(-duration)
+	p.mu.Lock()
+	s := &p.stats
+	s.Load = a*s.Load + (1-a)*x
+	s.PeakLoad = math.Max(x, a*s.PeakLoad)
+	if x > 1 {
+		s.Late++
+	}
+	if flags&underflow != 0 {
+		s.Underflows++
+	}
+	if flags&overflow != 0 {
+		s.Overflows++
+	}
+	p.mu.Unlock()
+}
+
+// Stats returns the current playback statistics.  It may be called from any
+// goroutine.
+func (c PlayControl) Stats() PlayStats {
+	if c.stats == nil {
+		return PlayStats{}
+	}
+	c.stats.mu.Lock()
+	defer c.stats.mu.Unlock()
+	return c.stats.stats
+}
+
+// PrintStats prints the playback statistics to standard error at the given
+// interval until playback stops.
+func (c PlayControl) PrintStats(interval time.Duration) {
+	go func() {
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-t.C:
+				fmt.Fprintln(os.Stderr, c.Stats())
+			case <-c.Done:
+				return
+			}
+		}
+	}()
+}
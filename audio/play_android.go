@@ -8,16 +8,24 @@ extern void start();
 extern void stop();
 */
 import "C"
-import "unsafe"
+import (
+	"errors"
+	"unsafe"
+)
+
+const maxChannels = 1 // corresponds with channels in play_android.c
 
 var (
 	started  bool
 	out      [64]float32
-	callback func(out []float32)
+	callback func(in, out []float32, flags int)
 )
 
-func startPlaying(v Voice, cb func(out []float32)) error {
-	Init(v, Params{SampleRate: 48000}) // corresponds with SL_SAMPLINGRATE_48 in play_android.c
+func startPlaying(initAudio func(Params), inChannels, channels int, cb func(in, out []float32, flags int)) error {
+	if inChannels > 0 {
+		return errors.New("audio input is not supported on Android")
+	}
+	initAudio(Params{SampleRate: 48000}) // corresponds with SL_SAMPLINGRATE_48 in play_android.c
 	if !started {
 		started = true
 		callback = cb
@@ -28,7 +36,7 @@ func startPlaying(v Voice, cb func(out []float32)) error {
 
 //export streamCallback
 func streamCallback(buf *int16) {
-	callback(out[:])
+	callback(nil, out[:], 0)
 	p := uintptr(unsafe.Pointer(buf))
 	for i := range out {
 		*(*int16)(unsafe.Pointer(p)) = int16(out[i] * 32767)
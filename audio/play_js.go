@@ -8,9 +8,14 @@ import (
 	"github.com/gopherjs/gopherjs/js"
 )
 
+const maxChannels = 2
+
 var node js.Object
 
-func startPlaying(v Voice, callback func(out []float32)) error {
+func startPlaying(initAudio func(Params), inChannels, channels int, callback func(in, out []float32, flags int)) error {
+	if inChannels > 0 {
+		return errors.New("audio input is not supported in the browser")
+	}
 	contextType := js.Global.Get("AudioContext")
 	if contextType == js.Undefined {
 		contextType = js.Global.Get("webkitAudioContext")
@@ -21,10 +26,26 @@ func startPlaying(v Voice, callback func(out []float32)) error {
 		return errors.New(s)
 	}
 	context := contextType.New()
-	Init(v, Params{SampleRate: context.Get("sampleRate").Float()})
-	node = context.Call("createScriptProcessor", 1024, 0, 1)
+	initAudio(Params{SampleRate: context.Get("sampleRate").Float()})
+	node = context.Call("createScriptProcessor", 1024, 0, channels)
+	var buf []float32
 	node.Set("onaudioprocess", func(e js.Object) {
-		callback(e.Get("outputBuffer").Call("getChannelData", 0).Interface().([]float32))
+		out := e.Get("outputBuffer")
+		if channels == 1 {
+			callback(nil, out.Call("getChannelData", 0).Interface().([]float32), 0)
+			return
+		}
+		n := out.Get("length").Int()
+		if len(buf) != n*channels {
+			buf = make([]float32, n*channels)
+		}
+		callback(nil, buf, 0)
+		for c := 0; c < channels; c++ {
+			data := out.Call("getChannelData", c)
+			for i := 0; i < n; i++ {
+				data.SetIndex(i, buf[i*channels+c])
+			}
+		}
 	})
 	node.Call("connect", context.Get("destination"))
 	return nil
@@ -17,7 +17,9 @@ func init() {
 	go func() {
 		defer os.Exit(0)
 		sig := make(chan os.Signal, 1)
-		signal.Notify(sig)
+		// only the signals that end the program; the runtime sends itself
+		// others (e.g. SIGURG to preempt goroutines) that must not stop it
+		signal.Notify(sig, os.Interrupt, syscall.SIGTERM, syscall.SIGQUIT)
 		if <-sig == syscall.SIGQUIT {
 			buf := make([]byte, 1<<10)
 			for runtime.Stack(buf, true) == len(buf) {
@@ -33,19 +35,40 @@ func init() {
 	}()
 }
 
+const maxChannels = 2
+
 var stream *portaudio.Stream
 
-func startPlaying(v Voice, callback func(out []float32)) error {
+func startPlaying(initAudio func(Params), inChannels, channels int, callback func(in, out []float32, flags int)) error {
 	const sampleRate = 96000
-	Init(v, Params{SampleRate: sampleRate})
+	initAudio(Params{SampleRate: sampleRate})
+	var cb interface{} = func(out []float32, _ portaudio.StreamCallbackTimeInfo, flags portaudio.StreamCallbackFlags) {
+		callback(nil, out, xrunFlags(flags))
+	}
+	if inChannels > 0 {
+		cb = func(in, out []float32, _ portaudio.StreamCallbackTimeInfo, flags portaudio.StreamCallbackFlags) {
+			callback(in, out, xrunFlags(flags))
+		}
+	}
 	var err error
-	stream, err = portaudio.OpenDefaultStream(0, 1, sampleRate, 1024, callback)
+	stream, err = portaudio.OpenDefaultStream(inChannels, channels, sampleRate, 1024, cb)
 	if err != nil {
 		return err
 	}
 	return stream.Start()
 }
 
+func xrunFlags(f portaudio.StreamCallbackFlags) int {
+	flags := 0
+	if f&(portaudio.InputUnderflow|portaudio.OutputUnderflow) != 0 {
+		flags |= underflow
+	}
+	if f&(portaudio.InputOverflow|portaudio.OutputOverflow) != 0 {
+		flags |= overflow
+	}
+	return flags
+}
+
 func stopPlaying() error {
 	return stream.Close()
 }
@@ -0,0 +1,30 @@
+package audio
+
+import (
+	"math"
+	"testing"
+)
+
+func TestPlayStats(t *testing.T) {
+	c := PlayControl{stats: &playStats{}}
+	const duration = 1024. / 96000
+	for i := 0; i < 1000; i++ {
+		c.stats.update(duration/4, duration, 0)
+	}
+	s := c.Stats()
+	if math.Abs(s.Load-.25) > 1e-3 || math.Abs(s.PeakLoad-.25) > 1e-3 {
+		t.Errorf("load %v, peak %v; want .25", s.Load, s.PeakLoad)
+	}
+	c.stats.update(2*duration, duration, underflow)
+	c.stats.update(duration/4, duration, overflow|underflow)
+	s = c.Stats()
+	if s.Late != 1 || s.Underflows != 2 || s.Overflows != 1 {
+		t.Errorf("got %d late, %d underflows, %d overflows; want 1, 2, 1", s.Late, s.Underflows, s.Overflows)
+	}
+	if s.PeakLoad < 1.9 || s.Load > .3 {
+		t.Errorf("after a late buffer, load %v, peak %v", s.Load, s.PeakLoad)
+	}
+	if (PlayControl{}).Stats() != (PlayStats{}) {
+		t.Error("zero PlayControl has stats")
+	}
+}
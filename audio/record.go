@@ -0,0 +1,132 @@
+package audio
+
+import (
+	"sync/atomic"
+
+	"code.google.com/p/gordon-go/audio/pcm"
+)
+
+// A Recorder is a Voice that passes another Voice through unchanged while
+// writing it to an audio file.  It can tap an Input or any other point in a
+// graph.  The file is written on another goroutine; if it falls behind, whole
+// buffers are dropped rather than holding up the audio thread.
+type Recorder struct {
+	v        Voice
+	filename string
+	f        pcm.Format
+	buf      []float64
+	bufs     chan []float64
+	errc     chan error
+	err      error
+	dropped  int64 // frames; accessed atomically
+}
+
+// NewRecorder records v to the named file.  The file is created when the
+// Recorder is initialized, at its sample rate.  If f.Channels is 0, a
+// StereoVoice is recorded in stereo and any other Voice in mono; if f.Bits is
+// 0, samples are recorded as 32-bit floating point.
+func NewRecorder(v Voice, filename string, f pcm.Format) *Recorder {
+	if f.Channels == 0 {
+		f.Channels = 1
+		if _, ok := v.(StereoVoice); ok {
+			f.Channels = 2
+		}
+	}
+	if f.Bits == 0 {
+		f.Bits, f.Float = 32, true
+	}
+	return &Recorder{v: v, filename: filename, f: f}
+}
+
+func (r *Recorder) InitAudio(p Params) {
+	Init(r.v, p)
+	if r.bufs != nil || r.err != nil {
+		return
+	}
+	r.f.SampleRate = int(p.SampleRate)
+	w, err := pcm.Create(r.filename, r.f)
+	if err != nil {
+		r.err = err
+		return
+	}
+	bufs, errc := make(chan []float64, 64), make(chan error, 1)
+	r.bufs, r.errc = bufs, errc
+	go func() {
+		var err error
+		for b := range bufs {
+			if err == nil {
+				err = w.Write(b)
+			}
+		}
+		if err2 := w.Close(); err == nil {
+			err = err2
+		}
+		errc <- err
+	}()
+}
+
+func (r *Recorder) Sing() float64 {
+	if r.f.Channels == 1 {
+		x := r.v.Sing()
+		r.record(x)
+		return x
+	}
+	left, right := r.SingStereo()
+	return (left + right) / 2
+}
+
+func (r *Recorder) SingStereo() (left, right float64) {
+	if s, ok := r.v.(StereoVoice); ok {
+		left, right = s.SingStereo()
+	} else {
+		left = r.v.Sing()
+		right = left
+	}
+	if r.f.Channels == 1 {
+		r.record((left + right) / 2)
+	} else {
+		r.record(left, right)
+	}
+	return
+}
+
+func (r *Recorder) record(x ...float64) {
+	if r.bufs == nil {
+		return
+	}
+	r.buf = append(r.buf, x...)
+	if len(r.buf) >= 4096*r.f.Channels {
+		r.flush()
+	}
+}
+
+func (r *Recorder) flush() {
+	select {
+	case r.bufs <- r.buf:
+	default:
+		atomic.AddInt64(&r.dropped, int64(len(r.buf)/r.f.Channels))
+	}
+	r.buf = nil
+}
+
+func (r *Recorder) Done() bool { return r.v.Done() }
+
+// Dropped returns the number of frames that were dropped because the file
+// couldn't be written fast enough.  It may be called from any goroutine.
+func (r *Recorder) Dropped() int64 { return atomic.LoadInt64(&r.dropped) }
+
+// Close writes the rest of the recording and completes the file.  It must
+// not be called while the Recorder is being played.
+func (r *Recorder) Close() error {
+	if r.bufs == nil {
+		return r.err
+	}
+	if len(r.buf) > 0 {
+		r.bufs <- r.buf
+		r.buf = nil
+	}
+	close(r.bufs)
+	r.bufs = nil
+	r.err = <-r.errc
+	return r.err
+}
@@ -0,0 +1,151 @@
+package audio
+
+import (
+	"fmt"
+	"math"
+)
+
+// The resampler's stopband attenuation in dB, and the fraction of the
+// narrower Nyquist band that it passes; the rest is its transition band.
+const (
+	resampleAttenuation = 110
+	resamplePassband    = .91
+)
+
+// A Resampler converts a stream of samples from one sample rate to another
+// with a polyphase windowed-sinc filter.  Frequencies above 91% of the lower
+// Nyquist frequency are rolled off, and aliases are suppressed by 110 dB.
+type Resampler struct {
+	up, down int
+	half     int         // half the filter length, in input samples
+	phases   [][]float64 // phases[p][j] weights input sample m-j for an output at input position m-half+p/up
+	in       []float64   // input samples from index base on
+	base     int
+	n        int // the number of input samples so far
+	k        int // the index of the next output sample
+}
+
+// NewResampler returns a Resampler from sample rate from to sample rate to.
+// The rates must be whole numbers.
+func NewResampler(from, to float64) *Resampler {
+	if from != math.Floor(from) || to != math.Floor(to) || from <= 0 || to <= 0 {
+		panic(fmt.Sprintf("can't resample from %v to %v; sample rates must be positive whole numbers", from, to))
+	}
+	g := gcd(int(from), int(to))
+	r := &Resampler{up: int(to) / g, down: int(from) / g}
+
+	ratio := math.Min(1, to/from)
+	fc := .5 * ratio * (1 + resamplePassband) / 2 // cutoff, in cycles per input sample
+	transition := .5 * ratio * (1 - resamplePassband)
+	r.half = int(math.Ceil((resampleAttenuation - 8) / (2.285 * 2 * math.Pi * transition) / 2))
+	beta := .1102 * (resampleAttenuation - 8.7)
+
+	c := r.half * r.up // the center of the prototype filter, at the upsampled rate
+	r.phases = make([][]float64, r.up)
+	for p := range r.phases {
+		h := make([]float64, 2*r.half+1)
+		sum := 0.0
+		for j := range h {
+			i := p + j*r.up
+			if i > 2*c {
+				break
+			}
+			t := float64(i-c) / float64(r.up)
+			w := t / float64(r.half)
+			h[j] = 2 * fc * sinc(2*fc*t) * bessel0(beta*math.Sqrt(math.Max(0, 1-w*w))) / bessel0(beta)
+			sum += h[j]
+		}
+		for j := range h {
+			h[j] /= sum // unity gain at DC in every phase
+		}
+		r.phases[p] = h
+	}
+	return r
+}
+
+// Process resamples the next samples of the input and returns the output
+// samples that they complete.  The output lags the input by the filter's
+// half length until Flush.
+func (r *Resampler) Process(x []float64) []float64 {
+	r.in = append(r.in, x...)
+	r.n += len(x)
+	return r.output(r.n - r.half - 1)
+}
+
+// Flush returns the rest of the output, up to the end of the input so far,
+// and resets the Resampler for a new stream.
+func (r *Resampler) Flush() []float64 {
+	n := r.n
+	r.in = append(r.in, make([]float64, r.half+1)...)
+	r.n += r.half + 1
+	var y []float64
+	for ; r.k*r.down < n*r.up; r.k++ {
+		y = append(y, r.sample())
+	}
+	*r = Resampler{up: r.up, down: r.down, half: r.half, phases: r.phases}
+	return y
+}
+
+// output computes the output samples whose input position is at or before
+// input sample last, whose filter window is then complete.
+func (r *Resampler) output(last int) []float64 {
+	var y []float64
+	for ; r.k*r.down <= last*r.up; r.k++ {
+		y = append(y, r.sample())
+	}
+	if drop := r.k*r.down/r.up - r.half - r.base; drop > 0 {
+		r.in = r.in[drop:]
+		r.base += drop
+	}
+	return y
+}
+
+func (r *Resampler) sample() float64 {
+	q := r.k*r.down + r.half*r.up
+	m := q / r.up
+	h := r.phases[q%r.up]
+	y := 0.0
+	for j, w := range h {
+		i := m - j - r.base
+		if i < 0 {
+			if m-j < 0 {
+				break // before the start of the input
+			}
+			panic("resampler history dropped too early")
+		}
+		if i < len(r.in) {
+			y += w * r.in[i]
+		}
+	}
+	return y
+}
+
+// Resample converts x from one sample rate to another.
+func Resample(x []float64, from, to float64) []float64 {
+	r := NewResampler(from, to)
+	return append(r.Process(x), r.Flush()...)
+}
+
+func gcd(a, b int) int {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
+
+func sinc(x float64) float64 {
+	if x == 0 {
+		return 1
+	}
+	return math.Sin(math.Pi*x) / (math.Pi * x)
+}
+
+// bessel0 is the zeroth-order modified Bessel function of the first kind.
+func bessel0(x float64) float64 {
+	sum, term := 1.0, 1.0
+	for k := 1; term > 1e-12*sum; k++ {
+		term *= x * x / 4 / float64(k*k)
+		sum += term
+	}
+	return sum
+}
@@ -0,0 +1,83 @@
+package audio
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func sineWave(freq, rate float64, n int) []float64 {
+	x := make([]float64, n)
+	for i := range x {
+		x[i] = math.Sin(2 * math.Pi * freq * float64(i) / rate)
+	}
+	return x
+}
+
+// snr returns the ratio in dB of the power of want to that of the difference
+// between got and want, ignoring the ends where the filter is ramping up.
+func snr(got, want []float64, skip int) float64 {
+	signal, noise := 0.0, 0.0
+	for i := skip; i < len(want)-skip; i++ {
+		signal += want[i] * want[i]
+		noise += (got[i] - want[i]) * (got[i] - want[i])
+	}
+	return 10 * math.Log10(signal/noise)
+}
+
+func TestResampleSNR(t *testing.T) {
+	for _, test := range []struct{ from, to, freq float64 }{
+		{96000, 44100, 1000},
+		{96000, 44100, 15000},
+		{44100, 96000, 1000},
+		{44100, 48000, 10000},
+		{48000, 48000, 5000},
+	} {
+		y := Resample(sineWave(test.freq, test.from, int(test.from/2)), test.from, test.to)
+		want := sineWave(test.freq, test.to, int(test.to/2))
+		if len(y) != len(want) {
+			t.Errorf("%v -> %v: got %d samples; want %d", test.from, test.to, len(y), len(want))
+			continue
+		}
+		if s := snr(y, want, 1000); s < 100 {
+			t.Errorf("%v -> %v, %v Hz: SNR is %.1f dB; want at least 100", test.from, test.to, test.freq, s)
+		}
+	}
+}
+
+func TestResampleAliasing(t *testing.T) {
+	// Tones between the new Nyquist frequency and the old one must be removed, not folded down.
+	for _, freq := range []float64{22500, 30000, 47000} {
+		y := Resample(sineWave(freq, 96000, 48000), 96000, 44100)
+		power := 0.0
+		for _, y := range y[1000 : len(y)-1000] {
+			power += y * y
+		}
+		db := 10 * math.Log10(2*power/float64(len(y)-2000)) // relative to the input sine
+		if db > -100 {
+			t.Errorf("%v Hz aliases at %.1f dB; want below -100", freq, db)
+		}
+	}
+}
+
+func TestResampleStreaming(t *testing.T) {
+	x := make([]float64, 20000)
+	for i := range x {
+		x[i] = rand.Float64()*2 - 1
+	}
+	want := Resample(x, 96000, 44100)
+	r := NewResampler(96000, 44100)
+	var y []float64
+	for i := 0; i < len(x); {
+		n := rand.Intn(1000)
+		if n > len(x)-i {
+			n = len(x) - i
+		}
+		y = append(y, r.Process(x[i:i+n])...)
+		i += n
+	}
+	y = append(y, r.Flush()...)
+	if !equal(y, want) {
+		t.Fatal("streaming output differs from one-shot output")
+	}
+}
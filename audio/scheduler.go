@@ -0,0 +1,246 @@
+package audio
+
+import (
+	"container/heap"
+	"math"
+	"sync"
+	"sync/atomic"
+	"unsafe"
+)
+
+// A Scheduler calls functions at given sample times.  Step must be called on
+// the audio thread once per sample; events due at or before the current
+// sample run during Step, in time order and, for equal times, in the order
+// they were scheduled.  Events may be scheduled, cancelled and rescheduled
+// from any goroutine, including from within an event.  Such changes are
+// passed to Step through a lock-free inbox, so Step never waits for a lock.
+type Scheduler struct {
+	now   int64          // the current sample
+	inbox unsafe.Pointer // *op, the changes not yet applied by Step, latest first
+
+	Params Params
+
+	mu      sync.Mutex // guards Params and pending
+	pending []func()   // sends the events scheduled in seconds before InitAudio
+
+	// owned by the audio thread
+	queue eventQueue
+	seq   uint64
+}
+
+// An op is a change to the queue, passed from any goroutine to Step.
+type op struct {
+	f    func()
+	next *op
+}
+
+// An Event is a handle to a scheduled function.
+type Event struct {
+	s     *Scheduler
+	f     func()
+	state uint64 // twice the number of times it was scheduled or cancelled, plus 1 if it is scheduled; accessed atomically
+
+	// owned by the audio thread
+	time   float64 // in samples; fractional for recurring events
+	period float64 // in samples, or 0 if the event doesn't recur
+	seq    uint64
+	gen    uint64 // the state in which it was queued
+	index  int    // in the queue, or -1
+}
+
+func (s *Scheduler) InitAudio(p Params) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Params = p
+	for _, send := range s.pending {
+		send()
+	}
+	s.pending = nil
+}
+
+// Now returns the current sample.
+func (s *Scheduler) Now() int64 { return atomic.LoadInt64(&s.now) }
+
+// At schedules f to be called at the given sample.
+func (s *Scheduler) At(sample int64, f func()) *Event {
+	e := &Event{s: s, f: f, index: -1}
+	s.schedule(e, e.request(true), float64(sample))
+	return e
+}
+
+// In schedules f to be called the given number of seconds from now.  If the
+// Scheduler's sample rate isn't known yet, the time is counted from when it
+// is initialized.
+func (s *Scheduler) In(seconds float64, f func()) *Event {
+	e := &Event{s: s, f: f, index: -1}
+	state := e.request(true)
+	send := func() { s.schedule(e, state, float64(s.Now())+seconds*s.Params.SampleRate) }
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Params.SampleRate == 0 {
+		s.pending = append(s.pending, send)
+		return e
+	}
+	send()
+	return e
+}
+
+// Every schedules f to be called at sample first and then every period
+// samples until cancelled.  The period need not be a whole number of samples,
+// so that events can keep in time with a tempo:  each call is rounded to the
+// nearest sample without accumulating error.
+func (s *Scheduler) Every(first int64, period float64, f func()) *Event {
+	if period <= 0 {
+		panic("Scheduler.Every:  period must be positive")
+	}
+	e := &Event{s: s, f: f, index: -1}
+	state := e.request(true)
+	s.send(func() { e.period = period })
+	s.schedule(e, state, float64(first))
+	return e
+}
+
+// request records that e is scheduled or cancelled and returns its new state.  Any
+// earlier request that Step hasn't yet applied is thereby superseded.
+func (e *Event) request(scheduled bool) uint64 {
+	for {
+		old := atomic.LoadUint64(&e.state)
+		state := old&^1 + 2
+		if scheduled {
+			state |= 1
+		}
+		if atomic.CompareAndSwapUint64(&e.state, old, state) {
+			return state
+		}
+	}
+}
+
+// schedule sends Step the request, made in the given state, to queue e at
+// time.
+func (s *Scheduler) schedule(e *Event, state uint64, time float64) {
+	s.send(func() {
+		if atomic.LoadUint64(&e.state) != state {
+			return // superseded
+		}
+		s.remove(e)
+		e.time, e.gen = time, state
+		s.push(e)
+	})
+}
+
+// send adds f to the inbox, for Step to call.
+func (s *Scheduler) send(f func()) {
+	o := &op{f: f}
+	for {
+		next := atomic.LoadPointer(&s.inbox)
+		o.next = (*op)(next)
+		if atomic.CompareAndSwapPointer(&s.inbox, next, unsafe.Pointer(o)) {
+			return
+		}
+	}
+}
+
+// receive applies the changes in the inbox, in the order they were sent.
+func (s *Scheduler) receive() {
+	if atomic.LoadPointer(&s.inbox) == nil {
+		return
+	}
+	var ops *op
+	for o := (*op)(atomic.SwapPointer(&s.inbox, nil)); o != nil; {
+		o.next, ops, o = ops, o, o.next
+	}
+	for ; ops != nil; ops = ops.next {
+		ops.f()
+	}
+}
+
+func (s *Scheduler) push(e *Event) {
+	s.seq++
+	e.seq = s.seq
+	heap.Push(&s.queue, e)
+}
+
+func (s *Scheduler) remove(e *Event) {
+	if e.index >= 0 {
+		heap.Remove(&s.queue, e.index)
+	}
+}
+
+// Cancel unschedules the event.  A recurring event stops recurring.
+func (e *Event) Cancel() {
+	e.request(false)
+	e.s.send(func() {
+		e.period = 0
+		e.s.remove(e)
+	})
+}
+
+// Reschedule moves the event to the given sample, scheduling it again if it
+// has already run or been cancelled.  It is ordered after other events at the
+// same time that are already scheduled.  A recurring event recurs from there.
+func (e *Event) Reschedule(sample int64) {
+	e.s.schedule(e, e.request(true), float64(sample))
+}
+
+// SetPeriod changes the period of a recurring event, taking effect after its
+// next call.
+func (e *Event) SetPeriod(period float64) {
+	if period <= 0 {
+		panic("Event.SetPeriod:  period must be positive")
+	}
+	e.s.send(func() { e.period = period })
+}
+
+// Scheduled reports whether the event is waiting to be called.
+func (e *Event) Scheduled() bool { return atomic.LoadUint64(&e.state)&1 != 0 }
+
+func (e *Event) sample() int64 { return int64(math.Floor(e.time + .5)) }
+
+// Step calls the events that are due and advances to the next sample.
+// Changes sent by the events themselves are applied before the next one is
+// chosen, so an event scheduled for now by another runs in the same Step.
+func (s *Scheduler) Step() {
+	now := s.Now()
+	for {
+		s.receive()
+		if len(s.queue) == 0 || s.queue[0].sample() > now {
+			break
+		}
+		e := heap.Pop(&s.queue).(*Event)
+		if e.period > 0 {
+			e.time += e.period
+			s.push(e)
+		} else {
+			atomic.CompareAndSwapUint64(&e.state, e.gen, e.gen&^1) // unless it has been rescheduled meanwhile
+		}
+		e.f()
+	}
+	atomic.StoreInt64(&s.now, now+1)
+}
+
+type eventQueue []*Event
+
+func (q eventQueue) Len() int { return len(q) }
+func (q eventQueue) Less(i, j int) bool {
+	if a, b := q[i].sample(), q[j].sample(); a != b {
+		return a < b
+	}
+	return q[i].seq < q[j].seq
+}
+func (q eventQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index, q[j].index = i, j
+}
+func (q *eventQueue) Push(x interface{}) {
+	e := x.(*Event)
+	e.index = len(*q)
+	*q = append(*q, e)
+}
+func (q *eventQueue) Pop() interface{} {
+	old := *q
+	e := old[len(old)-1]
+	old[len(old)-1] = nil
+	e.index = -1
+	*q = old[:len(old)-1]
+	return e
+}
@@ -0,0 +1,187 @@
+package audio
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestSchedulerOrder(t *testing.T) {
+	var s Scheduler
+	s.InitAudio(Params{100})
+	got := []int{}
+	record := func(i int) func() { return func() { got = append(got, i) } }
+	s.At(5, record(2))
+	s.At(3, record(1))
+	s.At(5, record(3)) // same time, after 2
+	e := s.At(4, record(-1))
+	s.At(7, record(5))
+	s.At(6, func() {
+		got = append(got, 4)
+		s.At(6, record(45)) // scheduled for now from within an event
+	})
+	e.Cancel()
+	for i := 0; i < 10; i++ {
+		s.Step()
+	}
+	if want := []int{1, 2, 3, 4, 45, 5}; !equalInts(got, want) {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+}
+
+func TestSchedulerReschedule(t *testing.T) {
+	var s Scheduler
+	var at []int64
+	f := func() { at = append(at, s.Now()) }
+	e := s.At(2, f)
+	s.Step()
+	e.Reschedule(5)
+	if !e.Scheduled() {
+		t.Error("rescheduled event isn't scheduled")
+	}
+	for i := 0; i < 10; i++ {
+		s.Step()
+	}
+	e.Reschedule(12)
+	for i := 0; i < 5; i++ {
+		s.Step()
+	}
+	if e.Scheduled() {
+		t.Error("event is still scheduled after running")
+	}
+	if !equalInt64s(at, []int64{5, 12}) {
+		t.Fatalf("ran at %v; want [5 12]", at)
+	}
+}
+
+// TestSchedulerSuperseded makes several changes to events between Steps; only
+// the last change to each event counts.
+func TestSchedulerSuperseded(t *testing.T) {
+	var s Scheduler
+	var got []int
+	record := func(i int) func() { return func() { got = append(got, i) } }
+	a := s.At(2, record(1))
+	a.Reschedule(4)
+	a.Cancel()
+	b := s.At(1, record(2))
+	b.Cancel()
+	b.Reschedule(3)
+	c := s.Every(1, 1, record(3))
+	c.Cancel()
+	if a.Scheduled() || !b.Scheduled() || c.Scheduled() {
+		t.Errorf("scheduled: %v %v %v; want false true false", a.Scheduled(), b.Scheduled(), c.Scheduled())
+	}
+	for i := 0; i < 10; i++ {
+		s.Step()
+	}
+	if !equalInts(got, []int{2}) {
+		t.Fatalf("got %v; want [2]", got)
+	}
+	if b.Scheduled() {
+		t.Error("event is still scheduled after running")
+	}
+}
+
+func TestSchedulerRecurring(t *testing.T) {
+	var s Scheduler
+	var at []int64
+	var e *Event
+	e = s.Every(1, 2.5, func() {
+		at = append(at, s.Now())
+		if len(at) == 5 {
+			e.Cancel()
+		}
+	})
+	for i := 0; i < 100; i++ {
+		s.Step()
+	}
+	// Times round to the nearest sample without drifting.
+	if want := []int64{1, 4, 6, 9, 11}; !equalInt64s(at, want) {
+		t.Fatalf("ran at %v; want %v", at, want)
+	}
+}
+
+func TestSchedulerIn(t *testing.T) {
+	var s Scheduler
+	ran := int64(-1)
+	s.In(.5, func() { ran = s.Now() })
+	for i := 0; i < 3; i++ {
+		s.Step()
+	}
+	s.InitAudio(Params{10}) // the delay counts from here
+	for i := 0; i < 20; i++ {
+		s.Step()
+	}
+	if ran != 8 {
+		t.Fatalf("ran at %d; want 8", ran)
+	}
+}
+
+// TestSchedulerConcurrent schedules events from other goroutines while the
+// scheduler runs.  Run it with -race.
+func TestSchedulerConcurrent(t *testing.T) {
+	var s Scheduler
+	var mu sync.Mutex
+	count := 0
+	done := make(chan bool)
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			default:
+				s.Step()
+			}
+		}
+	}()
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				e := s.At(s.Now()+int64(j%3), func() {
+					mu.Lock()
+					count++
+					mu.Unlock()
+				})
+				if j%10 == 0 {
+					e.Reschedule(s.Now() + 1)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	for {
+		mu.Lock()
+		n := count
+		mu.Unlock()
+		if n == 400 {
+			break
+		}
+	}
+	close(done)
+}
+
+func equalInts(x, y []int) bool {
+	if len(x) != len(y) {
+		return false
+	}
+	for i := range x {
+		if x[i] != y[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func equalInt64s(x, y []int64) bool {
+	if len(x) != len(y) {
+		return false
+	}
+	for i := range x {
+		if x[i] != y[i] {
+			return false
+		}
+	}
+	return true
+}
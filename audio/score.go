@@ -2,8 +2,10 @@ package audio
 
 import (
 	"fmt"
+	"math"
 	"reflect"
 	"sort"
+	"sync/atomic"
 )
 
 type Score struct {
@@ -13,6 +15,7 @@ type Score struct {
 type Part struct {
 	Name   string
 	Events []*PatternEvent
+	Strip  *Strip // nil for unity gain, centered, with no inserts or sends
 }
 
 type PatternEvent struct {
@@ -22,59 +25,177 @@ type PatternEvent struct {
 
 type ScorePlayer struct {
 	params      Params
-	score       *Score
+	score       *Score       // as edited by the user
+	next        atomic.Value // *scoreSnapshot, the latest snapshot of score
+	snap        *scoreSnapshot
 	band        Band
 	instruments map[string]Instrument
-	events      []*patternEvent
 	i, t        int
-	players     map[*PatternPlayer]struct{}
+	players     map[*PatternPlayer]*patternEvent
+	mixer       *mixer
+	stem        string
+
+	at   int64  // t, for Commit to read from other goroutines
+	rate uint64 // params.SampleRate's float64 bits, for Commit to read from other goroutines
 }
 
 func NewScorePlayer(score *Score, band Band) *ScorePlayer {
-	return &ScorePlayer{params: Params{96000 /* so Get/SetTime work before InitAudio */}, score: score, band: band, instruments: BandInstruments(band)}
+	p := &ScorePlayer{params: Params{96000 /* so Get/SetTime work before InitAudio */}, score: score, band: band, instruments: BandInstruments(band)}
+	p.rate = math.Float64bits(p.params.SampleRate)
+loop:
+	for name := range p.instruments {
+		for _, part := range score.Parts {
+			if part.Name == name {
+				continue loop
+			}
+		}
+		fmt.Println("no part for instrument " + name)
+	}
+	for _, part := range score.Parts {
+		if _, ok := p.instruments[part.Name]; !ok {
+			fmt.Println("no instrument for part " + part.Name)
+		}
+	}
+	p.snap = p.snapshot()
+	p.players = map[*PatternPlayer]*patternEvent{}
+	p.mixer = newMixer(p.snap, p.instruments, p.params, 0, p.stem)
+	return p
 }
 
 func (p *ScorePlayer) InitAudio(params Params) {
 	t := p.GetTime() // handle sample rate change
 	p.params = params
+	atomic.StoreUint64(&p.rate, math.Float64bits(params.SampleRate))
 	Init(p.band, params)
 	p.SetTime(t)
 }
 
-func (p *ScorePlayer) GetTime() float64 { return float64(p.t) / p.params.SampleRate }
-func (p *ScorePlayer) SetTime(t float64) {
-loop:
-	for name := range p.instruments {
-		for _, part := range p.score.Parts {
-			if part.Name == name {
-				continue loop
-			}
-		}
-		fmt.Println("no part for instrument " + name)
+// Commit hands the player a snapshot of its Score, which it starts playing at
+// the next buffer boundary.  Commit must be called after each edit to the
+// Score or its Patterns, from the goroutine that makes the edits; the player
+// never reads the Score itself.  The snapshot's mixer is built here too, at
+// the player's latest position, so that the audio thread need only catch it
+// up.
+func (p *ScorePlayer) Commit() {
+	s := p.snapshot()
+	s.params = Params{math.Float64frombits(atomic.LoadUint64(&p.rate))}
+	s.t = int(atomic.LoadInt64(&p.at))
+	s.mixer = newMixer(s, p.instruments, s.params, float64(s.t)/s.params.SampleRate, p.stem)
+	p.next.Store(s)
+}
+
+// StartBuffer picks up the latest committed snapshot.  Patterns that are
+// playing carry on from their current position in the new snapshot, or stop
+// if their event was removed.  It is called on the audio thread by PlayAsync.
+func (p *ScorePlayer) StartBuffer() {
+	s, ok := p.next.Load().(*scoreSnapshot)
+	if !ok || s == p.snap {
+		return
 	}
-	p.events = nil
-	for _, part := range p.score.Parts {
-		inst, ok := p.instruments[part.Name]
-		if !ok {
-			fmt.Println("no instrument for part " + part.Name)
+	p.snap = s
+	if s.mixer != nil && s.params == p.params && s.mixer.stem == p.stem && s.t <= p.t {
+		s.mixer.advance(p.t - s.t)
+		p.mixer = s.mixer
+	} else { // the player was moved back or reinitialized since the commit
+		p.mixer = newMixer(s, p.instruments, p.params, p.GetTime(), p.stem)
+	}
+	for p.i = 0; p.i < len(s.events) && p.sample(s.events[p.i].time) < p.t; p.i++ {
+	}
+	events := map[*PatternEvent]*patternEvent{}
+	for _, e := range s.events {
+		events[e.src] = e
+	}
+	playing := map[*PatternEvent]bool{}
+	for player, e := range p.players {
+		e, ok := events[e.src]
+		if !ok || p.sample(e.time) >= p.t {
+			player.mod.clear()
+			delete(p.players, player) // removed, or moved to start again later
 			continue
 		}
-		for _, e := range part.Events {
-			p.events = append(p.events, &patternEvent{int(e.Time * p.params.SampleRate), e.Pattern, inst})
+		p.players[player] = e
+		player.setPattern(e.pattern, p.localTime(e))
+		playing[e.src] = true
+	}
+	// Restart patterns that had finished but have gained notes after the current time.
+	for _, e := range s.events[:p.i] {
+		if n := len(e.pattern.Notes); !playing[e.src] && n > 0 && e.pattern.Notes[n-1].Time >= p.localTime(e) {
+			p.start(e)
 		}
 	}
-	sort.Sort(eventsByTime(p.events))
+}
+
+// localTime returns the current time relative to the start of e.
+func (p *ScorePlayer) localTime(e *patternEvent) float64 {
+	return float64(p.t-p.sample(e.time)) / p.params.SampleRate
+}
+
+// Stem makes the player output only the named part's channel.  The channel of
+// an aux bus carries the return of the sends of all the other parts, which in
+// turn leave it out of their own stems; an insert has no channel of its own.
+// So the stems of all the parts sum to the full mix.  Stem("") restores it.
+func (p *ScorePlayer) Stem(part string) {
+	p.stem = part
+	p.mixer = newMixer(p.snap, p.instruments, p.params, p.GetTime(), p.stem)
+}
+
+func (p *ScorePlayer) GetTime() float64 { return float64(p.t) / p.params.SampleRate }
+func (p *ScorePlayer) SetTime(t float64) {
+	if s, ok := p.next.Load().(*scoreSnapshot); ok {
+		p.snap = s
+	}
 	p.i = 0
 	p.t = int(t * p.params.SampleRate)
-	p.players = map[*PatternPlayer]struct{}{}
+	atomic.StoreInt64(&p.at, int64(p.t))
+	p.clearPlayers()
+	p.mixer = newMixer(p.snap, p.instruments, p.params, t, p.stem)
+}
+
+func (p *ScorePlayer) sample(t float64) int { return int(t * p.params.SampleRate) }
+
+// A scoreSnapshot is an immutable copy of a Score, ready for playing.
+type scoreSnapshot struct {
+	events []*patternEvent // sorted by time
+	strips map[string]*Strip
+
+	// The mixer built by Commit, at sample t of a clock at params; nil if the
+	// snapshot wasn't committed.  It is handed to the audio thread along with
+	// the snapshot and only used once.
+	mixer  *mixer
+	t      int
+	params Params
 }
 
 type patternEvent struct {
-	time    int
+	src     *PatternEvent
+	part    string
+	time    float64
 	pattern *Pattern
 	inst    Instrument
 }
 
+func (p *ScorePlayer) snapshot() *scoreSnapshot {
+	s := &scoreSnapshot{strips: map[string]*Strip{}}
+	patterns := map[*Pattern]*Pattern{}
+	for _, part := range p.score.Parts {
+		inst, ok := p.instruments[part.Name]
+		if !ok {
+			continue
+		}
+		s.strips[part.Name] = part.Strip.copy()
+		for _, e := range part.Events {
+			pattern, ok := patterns[e.Pattern]
+			if !ok {
+				pattern = e.Pattern.snapshot()
+				patterns[e.Pattern] = pattern
+			}
+			s.events = append(s.events, &patternEvent{e, part.Name, e.Time, pattern, inst})
+		}
+	}
+	sort.Stable(eventsByTime(s.events))
+	return s
+}
+
 type eventsByTime []*patternEvent
 
 func (e eventsByTime) Len() int           { return len(e) }
@@ -82,39 +203,90 @@ func (e eventsByTime) Less(i, j int) bool { return e[i].time < e[j].time }
 func (e eventsByTime) Swap(i, j int)      { e[i], e[j] = e[j], e[i] }
 
 func (p *ScorePlayer) Play() {
-	for ; p.i < len(p.events); p.i++ {
-		e := p.events[p.i]
-		if p.t < e.time {
+	for ; p.i < len(p.snap.events); p.i++ {
+		e := p.snap.events[p.i]
+		if p.t < p.sample(e.time) {
 			break
 		}
-		player := NewPatternPlayer(e.pattern, e.inst)
-		player.InitAudio(p.params)
-		player.SetTime(float64(p.t-e.time) / p.params.SampleRate)
-		p.players[player] = struct{}{}
+		p.start(e)
 	}
 	for player := range p.players {
 		player.Play()
-		if player.Done() {
+		if player.Done() && !player.mod.modulating() {
 			delete(p.players, player)
 		}
 	}
 	p.t++
+	atomic.StoreInt64(&p.at, int64(p.t))
+}
+
+func (p *ScorePlayer) start(e *patternEvent) {
+	// A finished pattern keeps modulating its instrument until the next one starts.
+	for player, e2 := range p.players {
+		if e2.part == e.part && player.Done() {
+			player.mod.clear()
+			delete(p.players, player)
+		}
+	}
+	player := newPatternPlayer(e.pattern, e.inst)
+	player.level = func(part string) float64 {
+		if part == "" {
+			part = e.part
+		}
+		return p.mixer.level(part)
+	}
+	player.InitAudio(p.params)
+	player.SetTime(p.localTime(e))
+	p.players[player] = e
 }
 
 func (p *ScorePlayer) Sing() float64 {
+	l, r := p.SingStereo()
+	return (l + r) / 2
+}
+
+// SingStereo mixes the band's instruments according to the parts' Strips.
+func (p *ScorePlayer) SingStereo() (l, r float64) {
 	p.Play()
-	return p.band.Sing()
+	return p.mixer.sing()
 }
 
-func (p *ScorePlayer) Done() bool {
-	return p.i == len(p.events) && len(p.players) == 0 && p.band.Done()
+func (p *ScorePlayer) Stop() {
+	for _, inst := range p.instruments {
+		inst.Stop()
+	}
+	p.clearPlayers()
 }
 
-// A Band must be a struct with exported fields of type Instrument.
-type Band interface {
-	Voice
+// clearPlayers stops playing patterns, removing their modulation.
+func (p *ScorePlayer) clearPlayers() {
+	for player := range p.players {
+		player.mod.clear()
+	}
+	p.players = map[*PatternPlayer]*patternEvent{}
 }
 
+func (p *ScorePlayer) Done() bool {
+	if p.i < len(p.snap.events) {
+		return false
+	}
+	for player := range p.players {
+		if !player.Done() {
+			return false
+		}
+	}
+	for _, inst := range p.instruments {
+		if !inst.Done() {
+			return false
+		}
+	}
+	return true
+}
+
+// A Band must be a struct with exported fields of type Instrument.  The
+// ScorePlayer mixes them according to the Strips of their parts.
+type Band interface{}
+
 func BandInstruments(b Band) map[string]Instrument {
 	insts := map[string]Instrument{}
 	v := reflect.Indirect(reflect.ValueOf(b))
@@ -0,0 +1,142 @@
+package audio
+
+import (
+	"sync"
+	"testing"
+)
+
+type testBand struct {
+	Inst testInst
+}
+
+type testInst struct {
+	MultiVoice
+	Gain   Control
+	played []float64
+}
+
+func (i *testInst) Play(n struct{ Amplitude []*ControlPoint }) {
+	i.played = append(i.played, n.Amplitude[0].Value)
+	i.Add(&testVoice{Amp: NewControl(n.Amplitude)})
+}
+
+func (i *testInst) Sing() float64 { return i.Gain.Sing() * i.MultiVoice.Sing() }
+
+type testVoice struct {
+	Amp *Control
+}
+
+func (v *testVoice) Sing() float64 { return v.Amp.Sing() }
+func (v *testVoice) Done() bool    { return v.Amp.Done() }
+
+func testNote(t, amp float64) *Note {
+	return &Note{t, map[string][]*ControlPoint{"Amplitude": {{0, amp}, {.01, amp}}}}
+}
+
+// render plays v for the given number of buffers, as PlayAsync would.
+func render(v Voice, buffers int) {
+	for i := 0; i < buffers; i++ {
+		v.(BufferStarter).StartBuffer()
+		for j := 0; j < 64; j++ {
+			v.Sing()
+		}
+	}
+}
+
+func TestScorePlayerCommit(t *testing.T) {
+	pattern := &Pattern{Name: "p", Attributes: map[string][]*ControlPoint{"Gain": {{0, 1}}}}
+	event := &PatternEvent{0, pattern}
+	score := &Score{[]*Part{{"Inst", []*PatternEvent{event}, nil}}}
+	band := &testBand{}
+	p := NewScorePlayer(score, band)
+	Init(p, Params{SampleRate: 6400})
+
+	render(p, 10) // .1s
+	pattern.Notes = append(pattern.Notes, testNote(.2, 1))
+	if len(band.Inst.played) != 0 {
+		t.Fatal("played an uncommitted note")
+	}
+	p.Commit()
+	render(p, 20)
+	if len(band.Inst.played) != 1 {
+		t.Fatalf("played %d notes; want 1", len(band.Inst.played))
+	}
+
+	// Notes added before the current time are not played, and a removed event stops playing.
+	pattern.Notes = append(pattern.Notes, testNote(.1, 2), testNote(.5, 3))
+	p.Commit()
+	render(p, 10)
+	score.Parts[0].Events = nil
+	p.Commit()
+	render(p, 30)
+	if len(band.Inst.played) != 1 {
+		t.Fatalf("played %v; want [1]", band.Inst.played)
+	}
+
+	// SetTime picks up the latest snapshot.
+	score.Parts[0].Events = []*PatternEvent{event}
+	p.Commit()
+	p.SetTime(0)
+	render(p, 100)
+	if want := []float64{1, 2, 1, 3}; !equal(band.Inst.played, want) {
+		t.Fatalf("played %v; want %v", band.Inst.played, want)
+	}
+}
+
+func TestPatternPlayerCommit(t *testing.T) {
+	pattern := &Pattern{Name: "p", Notes: []*Note{testNote(.3, 1), testNote(.1, 2)}, Attributes: map[string][]*ControlPoint{"Gain": {{0, 1}}}}
+	inst := &testInst{}
+	p := NewPatternPlayer(pattern, inst)
+	Init(p, Params{SampleRate: 6400})
+
+	render(p, 15)
+	pattern.Notes[0].Time = .2
+	pattern.Attributes["Gain"][0].Value = 0
+	p.Commit()
+	render(p, 1)
+	if g := inst.Gain.Sing(); g != 0 {
+		t.Errorf("gain is %v; want 0", g)
+	}
+	render(p, 10)
+	if want := []float64{2, 1}; !equal(inst.played, want) {
+		t.Fatalf("played %v; want %v", inst.played, want)
+	}
+}
+
+// TestLiveEdit edits a score while it plays on another goroutine.  Run it with -race.
+func TestLiveEdit(t *testing.T) {
+	pattern := &Pattern{Name: "p", Attributes: map[string][]*ControlPoint{"Gain": {{0, 1}}}}
+	score := &Score{[]*Part{{"Inst", []*PatternEvent{{0, pattern}}, nil}}}
+	p := NewScorePlayer(score, &testBand{})
+	Init(p, Params{SampleRate: 6400})
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		render(p, 1000)
+	}()
+	for i := 0; i < 200; i++ {
+		pattern.Notes = append(pattern.Notes, testNote(float64(i%20)/10, float64(i)))
+		pattern.Notes[i/2].Time += .01
+		pattern.Notes[i/3].Attributes["Amplitude"][0].Value = 0
+		pattern.Attributes["Gain"][0].Value = float64(i)
+		if i%10 == 0 {
+			score.Parts[0].Events = append(score.Parts[0].Events, &PatternEvent{float64(i) / 100, pattern})
+		}
+		p.Commit()
+	}
+	wg.Wait()
+}
+
+func equal(x, y []float64) bool {
+	if len(x) != len(y) {
+		return false
+	}
+	for i := range x {
+		if x[i] != y[i] {
+			return false
+		}
+	}
+	return true
+}
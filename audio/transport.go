@@ -0,0 +1,318 @@
+package audio
+
+import (
+	"math"
+	"sync"
+	"sync/atomic"
+)
+
+// A Player is a Voice whose position in time can be changed.  Stop must
+// silence any sound in progress; it is called before each SetTime so that
+// notes from the old position don't ring on at the new one.
+type Player interface {
+	Voice
+	Stop()
+	GetTime() float64
+	SetTime(t float64)
+}
+
+// How long the Transport fades in and out around a pause or jump.
+const declickTime = .005
+
+// A Transport plays a Player with PlayAsync and lets it be paused, resumed,
+// repositioned and looped while it plays.  Its methods may be called from any
+// goroutine; changes are handed to the audio thread and take effect between
+// samples, after a short fade to avoid clicks.
+type Transport struct {
+	player Player
+
+	rate        uint64     // the sample rate's float64 bits, read atomically as it is needed on the audio thread
+	clockMu     sync.Mutex // guards the tempo, which is read from every goroutine
+	tempo       float64
+	beatsPerBar int
+
+	mu       sync.Mutex
+	ctrl     PlayControl
+	cmds     chan func()
+	finished int32
+
+	// owned by the audio thread while playing
+	playing, pausing   bool
+	pos                int64
+	loopStart, loopEnd int64
+	fadeLen            int64
+	gain, target       float64
+	left               int64
+	pending            func()
+
+	time int64 // pos, for reading from other goroutines
+}
+
+func NewTransport(p Player) *Transport {
+	return &Transport{player: p, rate: math.Float64bits(96000 /* so Get/SetTime work before InitAudio */), tempo: 120, beatsPerBar: 4, cmds: make(chan func(), 64)}
+}
+
+func (t *Transport) InitAudio(params Params) {
+	time := t.GetTime()
+	atomic.StoreUint64(&t.rate, math.Float64bits(params.SampleRate))
+	Init(t.player, params)
+	t.fadeLen = int64(declickTime * params.SampleRate)
+	t.seek(t.samples(time))
+}
+
+func (t *Transport) sampleRate() float64 {
+	return math.Float64frombits(atomic.LoadUint64(&t.rate))
+}
+
+func (t *Transport) samples(time float64) int64 {
+	return int64(math.Floor(time*t.sampleRate() + .5))
+}
+
+// GetTime returns the position of the sample most recently played.
+func (t *Transport) GetTime() float64 {
+	return float64(atomic.LoadInt64(&t.time)) / t.sampleRate()
+}
+
+// SetTempo sets the tempo in beats per minute and the number of beats in a
+// bar, by which the Transport's beat positions are counted.  It doesn't
+// change the speed of the Player; beats are another way of naming times.  A
+// new Transport counts 120 BPM in 4.
+func (t *Transport) SetTempo(bpm float64, beatsPerBar int) {
+	if bpm <= 0 || beatsPerBar <= 0 {
+		panic("Transport.SetTempo:  tempo and beats per bar must be positive")
+	}
+	t.clockMu.Lock()
+	defer t.clockMu.Unlock()
+	t.tempo, t.beatsPerBar = bpm, beatsPerBar
+}
+
+func (t *Transport) Tempo() (bpm float64, beatsPerBar int) {
+	t.clockMu.Lock()
+	defer t.clockMu.Unlock()
+	return t.tempo, t.beatsPerBar
+}
+
+// BeatTime returns the time of a beat, counting from beat 0 at time 0.
+func (t *Transport) BeatTime(beat float64) float64 {
+	bpm, _ := t.Tempo()
+	return beat * 60 / bpm
+}
+
+// GetBeat returns the position of the sample most recently played, in beats.
+func (t *Transport) GetBeat() float64 {
+	bpm, _ := t.Tempo()
+	return t.GetTime() * bpm / 60
+}
+
+// GetBar returns the position of the sample most recently played as a bar
+// and a beat within it, both counting from 0.
+func (t *Transport) GetBar() (bar int, beat float64) {
+	_, n := t.Tempo()
+	b := t.GetBeat()
+	bar = int(math.Floor(b / float64(n)))
+	return bar, b - float64(bar*n)
+}
+
+// SeekBeat is Seek with the time given in beats.
+func (t *Transport) SeekBeat(beat float64) { t.Seek(t.BeatTime(beat)) }
+
+// SetLoopBeats is SetLoop with the times given in beats, e.g. SetLoopBeats(4,
+// 12) loops the second and third bars in 4.
+func (t *Transport) SetLoopBeats(start, end float64) {
+	t.SetLoop(t.BeatTime(start), t.BeatTime(end))
+}
+
+// Play starts or resumes playback at the current position.  The returned
+// PlayControl's Done channel is closed when playback ends, either because the
+// Player is done (and the Transport isn't looping) or because of Pause.
+func (t *Transport) Play() PlayControl {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.running() {
+		if atomic.LoadInt32(&t.finished) == 0 {
+			t.cmds <- t.resume
+			return t.ctrl
+		}
+		<-t.ctrl.Done
+	}
+	t.drain()
+	t.resume()
+	atomic.StoreInt32(&t.finished, 0)
+	t.ctrl = PlayAsync(t)
+	return t.ctrl
+}
+
+// Pause fades out and stops playback, keeping the current position.
+func (t *Transport) Pause() {
+	t.Do(func() {
+		t.pausing = true
+		t.fadeOut(func() {
+			if t.pausing {
+				t.playing, t.pausing = false, false
+			} else {
+				t.ramp(1, t.fadeLen)
+			}
+		})
+	})
+}
+
+// Seek moves playback to the given time.  Sounding notes are stopped and the
+// Player's controls are brought to their state at the new time.
+func (t *Transport) Seek(time float64) {
+	t.Do(func() {
+		pos := t.samples(time)
+		t.fadeOut(func() { t.seek(pos) })
+	})
+}
+
+// SetLoop makes playback jump back to start whenever it reaches end.  The
+// loop is cleared if end is not after start.
+func (t *Transport) SetLoop(start, end float64) {
+	t.Do(func() {
+		t.loopStart, t.loopEnd = t.samples(start), t.samples(end)
+	})
+}
+
+func (t *Transport) ClearLoop() { t.SetLoop(0, 0) }
+
+// Do runs f on the audio thread if playback is running, or right away if not,
+// so that f can safely change the Player and anything it plays.
+func (t *Transport) Do(f func()) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.running() {
+		t.cmds <- f
+		return
+	}
+	t.drain()
+	f()
+}
+
+func (t *Transport) running() bool {
+	if t.ctrl.Done == nil {
+		return false
+	}
+	select {
+	case <-t.ctrl.Done:
+		return false
+	default:
+		return true
+	}
+}
+
+// drain applies commands that were sent too late for the audio thread to
+// receive them.  It must only be called while playback isn't running.
+func (t *Transport) drain() {
+	t.gain, t.left = 0, 0
+	for len(t.cmds) > 0 {
+		(<-t.cmds)()
+	}
+}
+
+func (t *Transport) looping() bool { return t.loopEnd > t.loopStart }
+
+func (t *Transport) resume() {
+	t.playing, t.pausing = true, false
+	if t.pending == nil {
+		t.ramp(1, t.fadeLen)
+	}
+}
+
+// fadeOut calls f once the output has faded to silence.
+func (t *Transport) fadeOut(f func()) {
+	t.fadeOutIn(t.fadeLen, f)
+}
+
+func (t *Transport) fadeOutIn(n int64, f func()) {
+	if t.gain == 0 || n == 0 {
+		t.gain, t.left = 0, 0
+		f()
+		return
+	}
+	t.pending = f
+	t.ramp(0, n)
+}
+
+// ramp moves the gain linearly to target over n samples.
+func (t *Transport) ramp(target float64, n int64) {
+	t.target, t.left = target, n
+	if n == 0 {
+		t.gain = target
+	}
+}
+
+func (t *Transport) seek(pos int64) {
+	t.player.Stop()
+	t.player.SetTime(float64(pos) / t.sampleRate())
+	t.pos = pos
+	atomic.StoreInt64(&t.time, pos)
+	if t.playing {
+		t.ramp(1, t.fadeLen)
+	}
+}
+
+func (t *Transport) StartBuffer() {
+	if b, ok := t.player.(BufferStarter); ok {
+		b.StartBuffer()
+	}
+}
+
+func (t *Transport) Sing() float64 {
+	l, r := t.SingStereo()
+	return (l + r) / 2
+}
+
+func (t *Transport) SingStereo() (l, r float64) {
+	for len(t.cmds) > 0 {
+		(<-t.cmds)()
+	}
+	if t.looping() && t.pending == nil && t.playing {
+		n := t.loopEnd - t.pos
+		if n > t.fadeLen {
+			n = t.fadeLen
+		}
+		if n2 := (t.loopEnd - t.loopStart) / 2; n > n2 {
+			n = n2
+		}
+		if n < 0 {
+			n = 0
+		}
+		if t.pos >= t.loopEnd-n {
+			start := t.loopStart
+			t.fadeOutIn(n, func() { t.seek(start) })
+		}
+	}
+	if !t.playing && t.pending == nil {
+		return 0, 0
+	}
+
+	if s, ok := t.player.(StereoVoice); ok {
+		l, r = s.SingStereo()
+	} else {
+		l = t.player.Sing()
+		r = l
+	}
+	t.pos++
+	atomic.StoreInt64(&t.time, t.pos)
+
+	if t.left > 0 {
+		t.gain += (t.target - t.gain) / float64(t.left)
+		t.left--
+		if t.left == 0 && t.pending != nil {
+			f := t.pending
+			t.pending = nil
+			f()
+		}
+	}
+	return t.gain * l, t.gain * r
+}
+
+// Done reports whether playback should end:  after a Pause has faded out, or
+// when the Player is done and there is no loop to return to.
+func (t *Transport) Done() bool {
+	done := t.pending == nil && (!t.playing || !t.looping() && t.player.Done())
+	if done {
+		atomic.StoreInt32(&t.finished, 1)
+	}
+	return done
+}
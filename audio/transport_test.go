@@ -0,0 +1,138 @@
+package audio
+
+import (
+	"math"
+	"testing"
+)
+
+// clockPlayer sings its own time so that jumps can be seen in the output.
+type clockPlayer struct {
+	Params
+	t float64
+}
+
+func (p *clockPlayer) Sing() float64 {
+	x := p.t
+	p.t += 1 / p.SampleRate
+	return x
+}
+func (p *clockPlayer) Done() bool        { return false }
+func (p *clockPlayer) Stop()             {}
+func (p *clockPlayer) GetTime() float64  { return p.t }
+func (p *clockPlayer) SetTime(t float64) { p.t = t }
+
+func TestTransportBeats(t *testing.T) {
+	tr := NewTransport(&clockPlayer{})
+	tr.InitAudio(Params{1000})
+	tr.SetTempo(90, 3)
+	if x := tr.BeatTime(3); x != 2 {
+		t.Errorf("beat 3 at %v, want 2", x)
+	}
+
+	tr.SeekBeat(4.5)
+	if x := tr.GetTime(); x != 3 {
+		t.Errorf("after seeking to beat 4.5, time %v, want 3", x)
+	}
+	if bar, beat := tr.GetBar(); bar != 1 || beat != 1.5 {
+		t.Errorf("after seeking to beat 4.5, bar %d beat %v, want bar 1 beat 1.5", bar, beat)
+	}
+
+	// loop the first bar (2 seconds); the output must return to the start
+	tr.SetLoopBeats(0, 3)
+	tr.Seek(1.9)
+	tr.resume()
+	max := 0.0
+	for i := 0; i < 200; i++ {
+		tr.Sing()
+		max = math.Max(max, tr.GetTime())
+	}
+	if max > 2 {
+		t.Errorf("played to %v, past the loop end", max)
+	}
+	if x := tr.GetBeat(); x > 3*.2 {
+		t.Errorf("at beat %v after looping, want near the start", x)
+	}
+}
+
+// onePlayer sings 1 so that the Transport's gain can be seen in the output.
+type onePlayer struct{ clockPlayer }
+
+func (p *onePlayer) Sing() float64 {
+	p.clockPlayer.Sing()
+	return 1
+}
+
+func sing(tr *Transport, n int) []float64 {
+	x := make([]float64, n)
+	for i := range x {
+		x[i] = tr.Sing()
+	}
+	return x
+}
+
+func TestTransportFades(t *testing.T) {
+	tr := NewTransport(&onePlayer{})
+	tr.InitAudio(Params{1000})
+	fade := int(tr.fadeLen)
+	if fade != 5 {
+		t.Fatalf("fade of %d samples, want 5", fade)
+	}
+
+	// pretend that playback is running, so that commands wait for Sing as they would on the audio thread
+	tr.ctrl.Done = make(chan struct{})
+
+	// Play fades in
+	tr.resume()
+	x := sing(tr, fade)
+	for i := 1; i < fade-1; i++ {
+		if !(x[i] > x[i-1] && x[i] < 1) {
+			t.Errorf("fading in, got %v", x)
+			break
+		}
+	}
+	if x[fade-1] != 1 {
+		t.Errorf("after fading in, got %v, want 1", x)
+	}
+
+	// Seek fades out, jumps, and fades back in
+	tr.Seek(2)
+	x = sing(tr, fade)
+	for i := 1; i < fade; i++ {
+		if !(x[i] < x[i-1]) {
+			t.Errorf("fading out to seek, got %v", x)
+			break
+		}
+	}
+	if x := tr.GetTime(); x != 2 {
+		t.Errorf("after seeking, time %v, want 2", x)
+	}
+	x = sing(tr, fade)
+	if x[0] >= 1 || x[fade-1] != 1 {
+		t.Errorf("fading in after seeking, got %v", x)
+	}
+	if x := tr.GetTime(); x != 2+float64(fade)/1000 {
+		t.Errorf("after seeking and playing %d samples, time %v", fade, x)
+	}
+
+	// Pause fades out and stops
+	tr.Pause()
+	x = sing(tr, fade)
+	if x[0] >= 1 || x[fade-1] != 0 || tr.playing || !tr.Done() {
+		t.Errorf("pausing, got %v, playing %v", x, tr.playing)
+	}
+	paused := tr.GetTime()
+	if x := sing(tr, 10); x[9] != 0 || tr.GetTime() != paused {
+		t.Errorf("after pausing, got %v at time %v, want silence at %v", x, tr.GetTime(), paused)
+	}
+
+	// Play during a Pause's fade cancels it and fades back in
+	tr.resume()
+	sing(tr, fade)
+	tr.Pause()
+	sing(tr, 2)
+	tr.resume()
+	x = sing(tr, 2*fade)
+	if x[2*fade-1] != 1 || !tr.playing || tr.Done() {
+		t.Errorf("resuming during a pause, got %v, playing %v", x, tr.playing)
+	}
+}
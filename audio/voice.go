@@ -1,26 +1,178 @@
 package audio
 
+import (
+	"math"
+	"sync"
+)
+
 type Voice interface {
 	Sing() float64
 	Done() bool
 }
 
+// A StereoVoice is a Voice that can also sing separate left and right
+// channels.  Sing should return their average.
+type StereoVoice interface {
+	Voice
+	SingStereo() (left, right float64)
+}
+
+// A Releaser is a Voice that can be released, ending its note naturally
+// rather than being faded out.
+type Releaser interface {
+	Release()
+}
+
+// A Retuner is a Voice whose pitch (log2 frequency) can be changed while it
+// sings.
+type Retuner interface {
+	SetPitch(pitch float64)
+}
+
+// A MultiVoice sings the sum of its voices.  If Max is nonzero, adding a voice
+// beyond Max steals one that is already playing, chosen by Steal.  Stolen and
+// stopped voices are faded out quickly rather than cut off.
+//
+// A MultiVoice is safe to Add to and to control through its VoiceHandles from
+// other goroutines while it sings.
 type MultiVoice struct {
 	Params Params
-	voices []Voice
+	Max    int
+	Steal  StealPolicy
+
+	mu     sync.Mutex
+	voices []*VoiceHandle
+	time   int64
+	seq    uint64
+}
+
+// A StealPolicy chooses which voice to steal when a MultiVoice is full.
+type StealPolicy int
+
+const (
+	// StealOldest steals the voice that started first.
+	StealOldest StealPolicy = iota
+	// StealQuietest steals the voice with the lowest recent level.  Voices
+	// that have just started are passed over, because their attacks haven't
+	// risen yet.
+	StealQuietest
+	// StealSamePitch steals a voice with the same pitch as the new one, if
+	// there is one, and otherwise the oldest.
+	StealSamePitch
+)
+
+// The time over which a voice's level is averaged, for StealQuietest.
+const levelTime = .05
+
+// A VoiceHandle controls a voice playing in a MultiVoice.
+type VoiceHandle struct {
+	m     *MultiVoice
+	v     Voice
+	pitch float64
+	start int64
+	seq   uint64
+	level float64
+	gain  float64 // the declick fade, from 1 down to 0
+	fade  float64 // per sample, or 0 if not fading
+	done  bool
 }
 
-func (m *MultiVoice) Add(v Voice) {
+// Add starts v playing.  Its pitch is unknown, so it is never stolen by
+// StealSamePitch as a same-pitch voice.
+func (m *MultiVoice) Add(v Voice) *VoiceHandle {
+	return m.AddPitch(v, math.NaN())
+}
+
+// AddPitch starts v playing at the given pitch (log2 frequency).
+func (m *MultiVoice) AddPitch(v Voice, pitch float64) *VoiceHandle {
 	Init(v, m.Params)
-	m.voices = append(m.voices, v)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.Max > 0 {
+		for m.playing() >= m.Max {
+			m.steal(pitch).stop()
+		}
+	}
+	m.seq++
+	h := &VoiceHandle{m: m, v: v, pitch: pitch, start: m.time, seq: m.seq, gain: 1}
+	m.voices = append(m.voices, h)
+	return h
+}
+
+// playing returns the number of voices that aren't fading out.
+func (m *MultiVoice) playing() int {
+	n := 0
+	for _, h := range m.voices {
+		if h.fade == 0 {
+			n++
+		}
+	}
+	return n
+}
+
+func (m *MultiVoice) steal(pitch float64) *VoiceHandle {
+	var victim *VoiceHandle
+	young := int64(levelTime * m.Params.SampleRate)
+	for _, h := range m.voices {
+		if h.fade > 0 {
+			continue
+		}
+		if victim == nil {
+			victim = h
+			continue
+		}
+		switch m.Steal {
+		case StealQuietest:
+			hy, vy := m.time-h.start < young, m.time-victim.start < young
+			if hy != vy {
+				if vy {
+					victim = h
+				}
+				continue
+			}
+			if !hy && h.level != victim.level {
+				if h.level < victim.level {
+					victim = h
+				}
+				continue
+			}
+		case StealSamePitch:
+			hp, vp := h.pitch == pitch, victim.pitch == pitch
+			if hp != vp {
+				if hp {
+					victim = h
+				}
+				continue
+			}
+		}
+		if h.seq < victim.seq {
+			victim = h
+		}
+	}
+	return victim
 }
 
 func (m *MultiVoice) Sing() float64 {
+	m.mu.Lock()
+	k := 1.0
+	if m.Params.SampleRate > 0 {
+		k = 1 / (levelTime * m.Params.SampleRate)
+	}
 	x := 0.0
 	for i, n := 0, len(m.voices); i < n; {
-		v := m.voices[i]
-		x += v.Sing()
-		if v.Done() {
+		h := m.voices[i]
+		y := h.v.Sing()
+		if h.fade > 0 {
+			h.gain -= h.fade
+			if h.gain < h.fade/2 {
+				h.gain, h.done = 0, true
+			}
+			y *= h.gain
+		}
+		h.level += (math.Abs(y) - h.level) * k
+		x += y
+		if h.done || h.v.Done() {
+			h.done = true
 			n--
 			m.voices[i] = m.voices[n]
 			m.voices[n] = nil
@@ -29,13 +181,79 @@ func (m *MultiVoice) Sing() float64 {
 			i++
 		}
 	}
+	m.time++
+	m.mu.Unlock()
 	return x
 }
 
 func (m *MultiVoice) Done() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	return len(m.voices) == 0
 }
 
+// Stop fades out all voices.
 func (m *MultiVoice) Stop() {
-	m.voices = nil
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, h := range m.voices {
+		h.stop()
+	}
+}
+
+// Voice returns the handle's voice.
+func (h *VoiceHandle) Voice() Voice { return h.v }
+
+// Pitch returns the voice's pitch, or NaN if it is unknown.
+func (h *VoiceHandle) Pitch() float64 {
+	h.m.mu.Lock()
+	defer h.m.mu.Unlock()
+	return h.pitch
+}
+
+// SetPitch retunes the voice, if it is a Retuner.
+func (h *VoiceHandle) SetPitch(pitch float64) {
+	h.m.mu.Lock()
+	defer h.m.mu.Unlock()
+	h.pitch = pitch
+	if r, ok := h.v.(Retuner); ok && !h.done {
+		r.SetPitch(pitch)
+	}
+}
+
+// Release releases the voice if it is a Releaser, and otherwise stops it.
+func (h *VoiceHandle) Release() {
+	h.m.mu.Lock()
+	defer h.m.mu.Unlock()
+	if r, ok := h.v.(Releaser); ok {
+		if !h.done && h.fade == 0 {
+			r.Release()
+		}
+		return
+	}
+	h.stop()
+}
+
+// Stop fades the voice out quickly.
+func (h *VoiceHandle) Stop() {
+	h.m.mu.Lock()
+	defer h.m.mu.Unlock()
+	h.stop()
+}
+
+func (h *VoiceHandle) stop() {
+	if h.fade > 0 {
+		return
+	}
+	h.fade = 1
+	if n := declickTime * h.m.Params.SampleRate; n > 1 {
+		h.fade = 1 / n
+	}
+}
+
+// Done reports whether the voice has finished, been stopped or been stolen.
+func (h *VoiceHandle) Done() bool {
+	h.m.mu.Lock()
+	defer h.m.mu.Unlock()
+	return h.done
 }
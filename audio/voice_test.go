@@ -0,0 +1,104 @@
+package audio
+
+import (
+	"math"
+	"testing"
+)
+
+// handleVoice sings a constant until released.
+type handleVoice struct {
+	x, pitch float64
+	released bool
+}
+
+func (v *handleVoice) Sing() float64 {
+	if v.released {
+		return 0
+	}
+	return v.x
+}
+func (v *handleVoice) Done() bool             { return v.released }
+func (v *handleVoice) Release()               { v.released = true }
+func (v *handleVoice) SetPitch(pitch float64) { v.pitch = pitch }
+
+func TestMultiVoiceSteal(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		steal  StealPolicy
+		pitch  float64
+		stolen int
+	}{
+		{"oldest", StealOldest, 1, 0},
+		{"quietest", StealQuietest, 1, 1},
+		{"same pitch", StealSamePitch, 2, 2},
+		{"same pitch, none", StealSamePitch, 5, 0},
+	} {
+		m := &MultiVoice{Params: Params{1000}, Max: 3, Steal: test.steal}
+		var h []*VoiceHandle
+		for i, x := range []float64{1, .1, 1} {
+			h = append(h, m.AddPitch(&handleVoice{x: x}, float64(i)))
+		}
+		for i := 0; i < 100; i++ {
+			m.Sing()
+		}
+		h = append(h, m.AddPitch(&handleVoice{x: 1}, test.pitch))
+		for i := 0; i < 10; i++ {
+			m.Sing()
+		}
+		for i, h := range h {
+			if h.Done() != (i == test.stolen) {
+				t.Errorf("%s:  voice %d done = %v", test.name, i, h.Done())
+			}
+		}
+	}
+}
+
+func TestMultiVoiceDeclick(t *testing.T) {
+	m := &MultiVoice{Params: Params{1000}, Max: 1}
+	a := m.Add(&handleVoice{x: 1})
+	m.Sing()
+	b := m.Add(&handleVoice{x: 1})
+	last := 2.0
+	for i := 0; i < 5; i++ {
+		x := m.Sing()
+		if x >= last || x <= 1 && i < 4 {
+			t.Fatalf("sample %d:  got %v after %v; want a fade from 2 to 1", i, x, last)
+		}
+		last = x
+	}
+	if !a.Done() || b.Done() {
+		t.Fatal("the stolen voice should be done after the fade")
+	}
+	if x := m.Sing(); x != 1 {
+		t.Errorf("got %v after the fade; want 1", x)
+	}
+
+	b.Stop()
+	for i := 0; i < 5; i++ {
+		m.Sing()
+	}
+	if !m.Done() || !b.Done() {
+		t.Error("stopped voice isn't done")
+	}
+}
+
+func TestVoiceHandle(t *testing.T) {
+	m := &MultiVoice{Params: Params{1000}}
+	v := &handleVoice{x: 1}
+	h := m.AddPitch(v, 8)
+	h.SetPitch(9)
+	if v.pitch != 9 || h.Pitch() != 9 {
+		t.Errorf("retuned to %v, %v; want 9", v.pitch, h.Pitch())
+	}
+	if p := m.Add(&handleVoice{}).Pitch(); !math.IsNaN(p) {
+		t.Errorf("unknown pitch is %v; want NaN", p)
+	}
+	h.Release()
+	if !v.released {
+		t.Error("voice not released")
+	}
+	m.Sing()
+	if !h.Done() {
+		t.Error("released voice isn't done")
+	}
+}
@@ -0,0 +1,95 @@
+package wander
+
+import (
+	"math"
+	"math/rand"
+
+	"code.google.com/p/gordon-go/audio"
+)
+
+// A Generator makes notes from two Walks:  Melody walks through
+// frequencies, and Rhythm through the times between notes.  Generators with
+// the same seed and settings make the same notes, so a phrase heard live can
+// be captured by filling a Pattern from a new Generator.
+type Generator struct {
+	Melody, Rhythm *Walk
+
+	// Each note's Amplitude attribute starts at Amplitude, adjusted down for
+	// higher pitches, holds for Length seconds and then fades by 10 (log2
+	// units) over Release seconds.
+	Amplitude, Length, Release float64
+
+	time float64
+}
+
+func NewGenerator(seed int64) *Generator {
+	r := rand.New(rand.NewSource(seed))
+	rhythm := NewWalk(1./4, 8, SevenLimit, r)
+	rhythm.Zero = true
+	return &Generator{
+		Melody:  NewWalk(256, 8, SevenLimit, r),
+		Rhythm:  rhythm,
+		Length:  .1,
+		Release: 4,
+	}
+}
+
+// Next returns the next note.  Its Time is in seconds from the first note,
+// and it has the attributes Pitch, the log2 of its frequency, and Amplitude.
+func (g *Generator) Next() *audio.Note {
+	freq := g.Melody.Next()
+	pitch := math.Log2(freq)
+	amp := g.Amplitude - math.Log2(pitch/8)
+	n := &audio.Note{g.time, map[string][]*audio.ControlPoint{
+		"Pitch":     {{0, pitch}},
+		"Amplitude": {{0, amp}, {g.Length, amp}, {g.Length + g.Release, amp - 10}},
+	}}
+
+	dt := g.Rhythm.Next()
+	g.time += dt
+	g.Melody.Advance(dt)
+	g.Rhythm.Advance(dt)
+	return n
+}
+
+// Fill appends to p the notes that start within the next duration seconds,
+// offset to start at time t in the pattern.
+func (g *Generator) Fill(p *audio.Pattern, t, duration float64) {
+	start := g.time
+	for g.time < start+duration {
+		n := g.Next()
+		n.Time += t - start
+		p.Notes = append(p.Notes, n)
+	}
+}
+
+// A Player plays a Generator's notes live on an Instrument.
+type Player struct {
+	gen   *Generator
+	inst  audio.Instrument
+	sched audio.Scheduler
+}
+
+func NewPlayer(g *Generator, inst audio.Instrument) *Player {
+	p := &Player{gen: g, inst: inst}
+	p.sched.At(0, p.play)
+	return p
+}
+
+func (p *Player) InitAudio(params audio.Params) {
+	audio.Init(p.inst, params)
+	p.sched.InitAudio(params)
+}
+
+func (p *Player) play() {
+	audio.PlayNote(p.inst, p.gen.Next())
+	p.sched.At(int64(math.Floor(p.gen.time*p.sched.Params.SampleRate+.5)), p.play)
+}
+
+func (p *Player) Sing() float64 {
+	p.sched.Step()
+	return p.inst.Sing()
+}
+
+func (p *Player) Done() bool { return false }
+func (p *Player) Stop()      { p.inst.Stop() }
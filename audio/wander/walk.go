@@ -0,0 +1,216 @@
+// Package wander generates melodies and rhythms by random walks in just
+// intonation.  Each step multiplies the last value by a simple ratio, chosen
+// to keep the recent notes harmonically simple relative to one another.
+package wander
+
+import (
+	"math"
+	"math/rand"
+)
+
+// A Ratio is a just interval.
+type Ratio struct {
+	Num, Den int
+}
+
+func (r Ratio) Float() float64 { return float64(r.Num) / float64(r.Den) }
+
+// A Limit determines the ratios that a Walk steps by:  the products of
+// powers of Primes, each at most the corresponding power in Exponents, up or
+// down, whose complexity is less than MaxComplexity.
+type Limit struct {
+	Primes        []int
+	Exponents     []int
+	MaxComplexity int
+}
+
+var (
+	FiveLimit  = Limit{[]int{2, 3, 5}, []int{3, 2, 1}, 12}
+	SevenLimit = Limit{[]int{2, 3, 5, 7}, []int{3, 2, 1, 1}, 12}
+)
+
+func (l Limit) Ratios() []Ratio {
+	rats := []Ratio{{1, 1}}
+	for i, p := range l.Primes {
+		next := []Ratio{}
+		for _, r := range rats {
+			for x := -l.Exponents[i]; x <= l.Exponents[i]; x++ {
+				r := r
+				for y := x; y > 0; y-- {
+					r.Num *= p
+				}
+				for y := x; y < 0; y++ {
+					r.Den *= p
+				}
+				next = append(next, r)
+			}
+		}
+		rats = next
+	}
+	simple := []Ratio{}
+	for _, r := range rats {
+		if complexity(r.Num, r.Den) < l.MaxComplexity {
+			simple = append(simple, r)
+		}
+	}
+	return simple
+}
+
+// A Walk is a random walk through Ratios.  Each step is weighted by how
+// close it stays to Center and by how simple it keeps the history of recent
+// values.
+type Walk struct {
+	Ratios []Ratio
+
+	// Center is the value that the walk stays near, give or take Range
+	// octaves.  It drifts randomly by Drift octaves per square root second.
+	Center, Range, Drift float64
+
+	// Coherency weights the harmonic simplicity of the history against
+	// Center; at zero the walk ignores harmony.  Past values are forgotten
+	// over CoherencyTime seconds.
+	Coherency, CoherencyTime float64
+
+	// Zero lets Next return 0 instead of taking a step, with a probability
+	// that rises as the history gets more complex.  For a rhythm, a zero
+	// step is a chord.
+	Zero bool
+
+	rand    *rand.Rand
+	last    float64
+	time    float64
+	history []note
+}
+
+type note struct {
+	t float64
+	n int
+}
+
+func NewWalk(center, coherencyTime float64, limit Limit, rand *rand.Rand) *Walk {
+	return &Walk{
+		Ratios:        limit.Ratios(),
+		Center:        center,
+		Range:         1,
+		Coherency:     1,
+		CoherencyTime: coherencyTime,
+		rand:          rand,
+		last:          center,
+		history:       []note{{0, 1}},
+	}
+}
+
+// Advance moves the walk's clock forward by dt seconds.
+func (w *Walk) Advance(dt float64) {
+	w.time += dt
+	if w.Drift > 0 {
+		w.Center *= math.Exp2(w.Drift * math.Sqrt(dt) * w.rand.NormFloat64())
+	}
+}
+
+// Next takes a step and returns the new value.
+func (w *Walk) Next() float64 {
+	decay := math.Pow(.01, 1/w.CoherencyTime)
+	cSum, ampSum := w.historyComplexity(decay)
+
+	sum := 0.0
+	sums := make([]float64, len(w.Ratios))
+	for i, r := range w.Ratios {
+		p := math.Log2(w.last*r.Float()/w.Center) / w.Range
+		sum += math.Exp2(-p*p/2) * math.Exp2(-w.Coherency*w.complexity(decay, cSum, ampSum, r))
+		sums[i] = sum
+	}
+	if w.Zero {
+		sum += math.Exp2(-cSum / ampSum)
+		sums = append(sums, sum)
+	}
+	i := 0
+	x := sum * w.rand.Float64()
+	for i = range sums {
+		if x < sums[i] {
+			break
+		}
+	}
+	if i == len(w.Ratios) {
+		return 0
+	}
+	w.last *= w.Ratios[i].Float()
+	w.history = w.appendHistory(w.Ratios[i])
+
+	for i, n := range w.history {
+		if w.time-n.t < w.CoherencyTime {
+			w.history = w.history[i:]
+			d := w.history[0].n
+			for _, n := range w.history[1:] {
+				d = gcd(d, n.n)
+			}
+			for i := range w.history {
+				w.history[i].n /= d
+			}
+			break
+		}
+	}
+
+	return w.last
+}
+
+func (w *Walk) historyComplexity(decay float64) (cSum, ampSum float64) {
+	for i, n1 := range w.history {
+		a1 := math.Pow(decay, w.time-n1.t)
+		for _, n2 := range w.history[:i] {
+			a2 := math.Pow(decay, w.time-n2.t)
+			cSum += a1 * a2 * float64(complexity(n1.n, n2.n))
+		}
+		ampSum += a1
+	}
+	return
+}
+
+func (w *Walk) complexity(decay, cSum, ampSum float64, r Ratio) float64 {
+	const a1 = 1
+	n1n := r.Num * w.history[len(w.history)-1].n
+	for _, n2 := range w.history {
+		a2 := math.Pow(decay, w.time-n2.t)
+		cSum += a1 * a2 * float64(complexity(n1n, n2.n*r.Den))
+	}
+	return cSum / (ampSum + a1)
+}
+
+// complexity is the sum of p-1 for each prime factor p of a/b, with
+// multiplicity, after the ratio is reduced.
+func complexity(a, b int) int {
+	c := 0
+	for d := 2; a != b; {
+		d1 := a%d == 0
+		d2 := b%d == 0
+		if d1 != d2 {
+			c += d - 1
+		}
+		if d1 {
+			a /= d
+		}
+		if d2 {
+			b /= d
+		}
+		if !(d1 || d2) {
+			d++
+		}
+	}
+	return c
+}
+
+func (w *Walk) appendHistory(r Ratio) []note {
+	r.Num *= w.history[len(w.history)-1].n
+	history := make([]note, len(w.history), len(w.history)+1)
+	for i, n := range w.history {
+		history[i] = note{n.t, n.n * r.Den}
+	}
+	return append(history, note{w.time, r.Num})
+}
+
+func gcd(a, b int) int {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
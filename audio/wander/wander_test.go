@@ -0,0 +1,79 @@
+package wander
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"code.google.com/p/gordon-go/audio"
+)
+
+func TestLimit(t *testing.T) {
+	rats := FiveLimit.Ratios()
+	for _, r := range rats {
+		n, d := r.Num, r.Den
+		for _, p := range []int{2, 3, 5} {
+			for n%p == 0 {
+				n /= p
+			}
+			for d%p == 0 {
+				d /= p
+			}
+		}
+		if n != 1 || d != 1 {
+			t.Errorf("%d/%d is not 5-limit", r.Num, r.Den)
+		}
+		if c := complexity(r.Num, r.Den); c >= 12 {
+			t.Errorf("%d/%d has complexity %d", r.Num, r.Den, c)
+		}
+	}
+	if len(rats) == 0 || len(SevenLimit.Ratios()) <= len(rats) {
+		t.Errorf("got %d 5-limit and %d 7-limit ratios", len(rats), len(SevenLimit.Ratios()))
+	}
+}
+
+func TestSeed(t *testing.T) {
+	fill := func(seed int64) *audio.Pattern {
+		p := &audio.Pattern{}
+		NewGenerator(seed).Fill(p, 1, 10)
+		return p
+	}
+	p1, p2, p3 := fill(1), fill(1), fill(2)
+	if len(p1.Notes) == 0 {
+		t.Fatal("no notes")
+	}
+	same := func(p, q *audio.Pattern) bool {
+		if len(p.Notes) != len(q.Notes) {
+			return false
+		}
+		for i, n := range p.Notes {
+			m := q.Notes[i]
+			if n.Time != m.Time || n.Attributes["Pitch"][0].Value != m.Attributes["Pitch"][0].Value {
+				return false
+			}
+		}
+		return true
+	}
+	if !same(p1, p2) {
+		t.Error("the same seed made different notes")
+	}
+	if same(p1, p3) {
+		t.Error("different seeds made the same notes")
+	}
+	for _, n := range p1.Notes {
+		if n.Time < 1 || n.Time >= 11 {
+			t.Errorf("note at %v; want between 1 and 11", n.Time)
+		}
+	}
+}
+
+func TestCenter(t *testing.T) {
+	// The walk stays within a few octaves of its center.
+	w := NewWalk(440, 4, SevenLimit, rand.New(rand.NewSource(1)))
+	for i := 0; i < 1000; i++ {
+		if f := w.Next(); math.Abs(math.Log2(f/440)) > 4 {
+			t.Fatalf("step %d: %v Hz", i, f)
+		}
+		w.Advance(.25)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"time"
 )
 
 func Main(score *audio.Score, band audio.Band) {
@@ -32,7 +33,27 @@ func Main(score *audio.Score, band audio.Band) {
 				})
 			})
 		case "write":
-			Write(audio.NewScorePlayer(score, band), filepath.Join(path, name+".wav"))
+			Write(audio.NewScorePlayer(score, band), outputFile(path, name+".wav"))
+		case "master":
+			WriteMaster(audio.NewScorePlayer(score, band), outputFile(path, name+"_master.wav"))
+		case "stems":
+			stems(score, bandMaker(band), path, name, os.Args[2:])
+		case "load":
+			c := audio.PlayAsync(audio.NewScorePlayer(score, band))
+			c.PrintStats(time.Second)
+			<-c.Done
+		case "osc":
+			addr := ":9000"
+			if len(os.Args) > 2 {
+				addr = os.Args[2]
+			}
+			serveOSC(score, band, addr)
+		case "transcribe":
+			if len(os.Args) != 4 {
+				println("usage: transcribe file.wav pattern_name")
+				return
+			}
+			transcribe(path, os.Args[2], os.Args[3])
 		default:
 			println("unknown arg: " + os.Args[1])
 		}
@@ -40,3 +61,12 @@ func Main(score *audio.Score, band audio.Band) {
 		audio.Play(audio.NewScorePlayer(score, band))
 	}
 }
+
+// outputFile returns the file named on the command line after the command, or
+// else the named file in dir.
+func outputFile(dir, name string) string {
+	if len(os.Args) > 2 {
+		return os.Args[2]
+	}
+	return filepath.Join(dir, name)
+}
@@ -0,0 +1,205 @@
+package audiogui
+
+import (
+	"fmt"
+	"io"
+	"math"
+
+	"code.google.com/p/gordon-go/audio"
+	. "code.google.com/p/gordon-go/gui"
+)
+
+// newRouteAttributeView returns a view of the depth of a modulation route,
+// which is automated on the pattern's timeline like a Control.
+func newRouteAttributeView(p *PatternView, r *audio.Route) *attributeView {
+	a := newAttributeView(p, r.Target)
+	a.isPatternAttribute = true
+	a.route = r
+	a.nameText.SetText(routeName(r))
+	note := &audio.Note{0, map[string][]*audio.ControlPoint{}}
+	n := newNoteView(a, note)
+	a.notes[note] = n
+	a.Add(n)
+	return a
+}
+
+func routeName(r *audio.Route) string {
+	s := ""
+	switch src := r.Source.(type) {
+	case *audio.LFO:
+		unit := "Hz"
+		if src.Sync {
+			unit = "/beat"
+		}
+		s = fmt.Sprintf("%s LFO %g%s", src.Shape, src.Rate, unit)
+		if src.Phase != 0 {
+			s += fmt.Sprintf(" +%g", src.Phase)
+		}
+		if src.PerVoice {
+			s += " per voice"
+		}
+	case *audio.EnvFollower:
+		part := src.Part
+		if part == "" {
+			part = "self"
+		}
+		s = fmt.Sprintf("follow %s %gs/%gs", part, src.Attack, src.Release)
+	}
+	if r.Curve != 0 {
+		s += fmt.Sprintf(" curve %g", r.Curve)
+	}
+	return s + " -> " + r.Target
+}
+
+// addRoute adds a route from src to the attribute a and focuses its depth.
+func (a *attributeView) addRoute(src audio.ModSource) {
+	p := a.pattern
+	if p.pattern.Modulation == nil {
+		p.pattern.Modulation = &audio.Modulation{Tempo: 120}
+	}
+	m := p.pattern.Modulation
+	r := &audio.Route{src, a.name, []*audio.ControlPoint{{}}, 0}
+	m.Routes = append(m.Routes, r)
+	r2 := newRouteAttributeView(p, r)
+	for i, a2 := range p.attrs {
+		if a2 == a {
+			p.attrs = append(p.attrs[:i+1], append([]*attributeView{r2}, p.attrs[i+1:]...)...)
+			break
+		}
+	}
+	p.Add(r2)
+	p.reform()
+	p.edited()
+	SetKeyFocus(r2)
+}
+
+func (a *attributeView) removeRoute() {
+	p := a.pattern
+	m := p.pattern.Modulation
+	for i, r := range m.Routes {
+		if r == a.route {
+			m.Routes = append(m.Routes[:i], m.Routes[i+1:]...)
+			break
+		}
+	}
+	if len(m.Routes) == 0 {
+		p.pattern.Modulation = nil
+	}
+	SetKeyFocus(a.next(true))
+	for i, a2 := range p.attrs {
+		if a2 == a {
+			p.attrs = append(p.attrs[:i], p.attrs[i+1:]...)
+			break
+		}
+	}
+	p.Remove(a)
+	a.stop <- true
+	p.reform()
+	p.edited()
+}
+
+// modKeyPress handles the keys that add and edit modulation routes:
+//
+//	L	add an LFO (per voice, on a note attribute)
+//	E	add an envelope follower of this instrument
+//
+// and, on a route's depth:
+//
+//	S	next LFO shape (Shift: previous)
+//	R	double the LFO rate (Shift: halve)
+//	Y	toggle tempo sync
+//	P	advance the LFO phase by a quarter cycle
+//	B	raise the tempo by 5 BPM (Shift: lower)
+//	A	double the follower's attack time (Shift: halve)
+//	D	double the follower's release time (Shift: halve)
+//	C	raise the curve by 1/2 (Shift: lower)
+//	Backspace	remove the route
+//
+// It reports whether it handled k.
+func (a *attributeView) modKeyPress(k KeyEvent) bool {
+	if k.Command || k.Alt {
+		return false
+	}
+	r := a.route
+	switch {
+	case k.Key == KeyL && r == nil:
+		a.addRoute(&audio.LFO{audio.Sine, 1, false, 0, !a.isPatternAttribute})
+		return true
+	case k.Key == KeyE && r == nil && a.isPatternAttribute:
+		a.addRoute(&audio.EnvFollower{"", .01, .1})
+		return true
+	}
+	if r == nil {
+		return false
+	}
+	scale := 2.0
+	if k.Shift {
+		scale = .5
+	}
+	lfo, _ := r.Source.(*audio.LFO)
+	env, _ := r.Source.(*audio.EnvFollower)
+	switch {
+	case k.Key == KeyS && lfo != nil:
+		n := len(audio.LFOShapes)
+		d := 1
+		if k.Shift {
+			d = n - 1
+		}
+		lfo.Shape = audio.LFOShape((int(lfo.Shape) + d) % n)
+	case k.Key == KeyR && lfo != nil:
+		lfo.Rate *= scale
+	case k.Key == KeyY && lfo != nil:
+		lfo.Sync = !lfo.Sync
+	case k.Key == KeyP && lfo != nil:
+		lfo.Phase = math.Mod(lfo.Phase+.25, 1)
+	case k.Key == KeyB && lfo != nil:
+		m := a.pattern.pattern.Modulation
+		if k.Shift {
+			m.Tempo = math.Max(5, m.Tempo-5)
+		} else {
+			m.Tempo += 5
+		}
+		fmt.Printf("tempo %g BPM\n", m.Tempo)
+	case k.Key == KeyA && env != nil:
+		env.Attack *= scale
+	case k.Key == KeyD && env != nil:
+		env.Release *= scale
+	case k.Key == KeyC:
+		if k.Shift {
+			r.Curve -= .5
+		} else {
+			r.Curve += .5
+		}
+	case k.Key == KeyBackspace || k.Key == KeyDelete:
+		a.removeRoute()
+		return true
+	default:
+		return false
+	}
+	a.nameText.SetText(routeName(r))
+	a.nameText.Move(InnerRect(a).Max.Sub(Pt(Size(a.nameText))))
+	a.pattern.edited()
+	return true
+}
+
+// writeModulation writes m as an argument to NewPattern.
+func writeModulation(w io.Writer, m *audio.Modulation) {
+	fmt.Fprintf(w, ", &audio.Modulation{%v, []*audio.Route{\n", m.Tempo)
+	for _, r := range m.Routes {
+		switch src := r.Source.(type) {
+		case *audio.LFO:
+			fmt.Fprintf(w, "\t{&audio.LFO{%d, %v, %v, %v, %v}", src.Shape, src.Rate, src.Sync, src.Phase, src.PerVoice)
+		case *audio.EnvFollower:
+			fmt.Fprintf(w, "\t{&audio.EnvFollower{%q, %v, %v}", src.Part, src.Attack, src.Release)
+		}
+		fmt.Fprintf(w, ", %q, []*audio.ControlPoint{", r.Target)
+		for i, p := range r.Depth {
+			if i > 0 {
+				fmt.Fprint(w, ", ")
+			}
+			fmt.Fprintf(w, "{%v, %v}", p.Time, p.Value)
+		}
+		fmt.Fprintf(w, "}, %v},\n", r.Curve)
+	}
+	fmt.Fprint(w, "}}")
+}
@@ -0,0 +1,26 @@
+package audiogui
+
+import (
+	"fmt"
+
+	"code.google.com/p/gordon-go/audio"
+	"code.google.com/p/gordon-go/audio/osc"
+)
+
+// serveOSC plays the score and lets OSC messages on addr play its
+// instruments, set their controls and drive the transport.
+func serveOSC(score *audio.Score, band audio.Band, addr string) {
+	s, err := osc.Listen(addr)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	t := audio.NewTransport(audio.NewScorePlayer(score, band))
+	osc.BindBand(s, band, t.Do)
+	osc.BindTransport(s, t)
+	fmt.Printf("listening for OSC on %s\n", s.Addr())
+	t.Play()
+	if err := s.Serve(); err != nil {
+		fmt.Println(err)
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"code.google.com/p/gordon-go/audio"
+	"code.google.com/p/gordon-go/audio/wander"
 	. "code.google.com/p/gordon-go/gui"
 )
 
@@ -22,11 +23,14 @@ type PatternView struct {
 	cursorTime float64
 	tPressed   bool
 
+	loopStart, loopEnd float64
+
 	player     *audio.PatternPlayer
+	transport  *audio.Transport
 	play, stop chan bool
 	oldFocus   View
 
-	closed func()
+	changed, closed func()
 }
 
 func NewPatternView(pattern *audio.Pattern, inst audio.Instrument) *PatternView {
@@ -47,9 +51,17 @@ func NewPatternView(pattern *audio.Pattern, inst audio.Instrument) *PatternView
 		p.attrs = append(p.attrs, a)
 		p.Add(a)
 	}
+	if pattern.Modulation != nil {
+		for _, r := range pattern.Modulation.Routes {
+			a := newRouteAttributeView(p, r)
+			p.attrs = append(p.attrs, a)
+			p.Add(a)
+		}
+	}
 	p.timeGrid = &uniformGrid{0, 1}
 
 	p.player = audio.NewPatternPlayer(pattern, inst)
+	p.transport = audio.NewTransport(p.player)
 	p.play = make(chan bool, 1)
 	p.stop = make(chan bool)
 	go p.animate()
@@ -61,6 +73,7 @@ func (p *PatternView) InitFocus() { SetKeyFocus(p.attrs[0]) }
 
 func (p *PatternView) Close() {
 	p.ViewBase.Close()
+	p.transport.Pause()
 	p.stop <- true
 	for _, a := range p.attrs {
 		a.stop <- true
@@ -77,19 +90,17 @@ func (p *PatternView) animate() {
 	var ctrl audio.PlayControl
 	for {
 		select {
-		case <-p.play:
-			if next != nil {
-				next = nil
-				ctrl.Stop()
+		case play := <-p.play:
+			if !play {
+				p.transport.Pause()
 				break
 			}
-			p.inst.Stop()
-			ctrl = audio.PlayAsync(p.player)
+			ctrl = p.transport.Play()
 			next = time.After(time.Second / fps)
 		case <-next:
 			next = time.After(time.Second / fps)
 			Do(p, func() {
-				p.cursorTime = p.player.GetTime()
+				p.cursorTime = p.transport.GetTime()
 				Repaint(p)
 			})
 		case <-ctrl.Done:
@@ -103,6 +114,33 @@ func (p *PatternView) animate() {
 	}
 }
 
+// audition plays the pattern from the cursor.  If loop is set, it repeats the
+// loop region, or the whole pattern if no region has been marked.
+func (p *PatternView) audition(from View, loop bool) {
+	p.oldFocus = from
+	SetKeyFocus(p)
+	switch {
+	case !loop:
+		p.transport.ClearLoop()
+	case p.loopEnd > p.loopStart:
+		p.transport.SetLoop(p.loopStart, p.loopEnd)
+	default:
+		p.transport.SetLoop(0, patternDuration(p.pattern))
+	}
+	p.transport.Seek(p.cursorTime)
+	p.play <- true
+}
+
+// setLoopPoint moves the start or end of the loop region to the cursor.
+func (p *PatternView) setLoopPoint(end bool) {
+	if end {
+		p.loopEnd = p.cursorTime
+	} else {
+		p.loopStart = p.cursorTime
+	}
+	Repaint(p)
+}
+
 func (p *PatternView) reform() {
 	r := InnerRect(p)
 	x1, y1 := r.Min.XY()
@@ -123,9 +161,59 @@ func (p *PatternView) newNote() *audio.Note {
 		}
 	}
 	p.pattern.Notes = append(p.pattern.Notes, n)
+	p.edited()
 	return n
 }
 
+// addNoteViews adds views of note to the note attribute views.
+func (p *PatternView) addNoteViews(note *audio.Note) map[*attributeView]*noteView {
+	views := map[*attributeView]*noteView{}
+	for _, a := range p.attrs {
+		if !a.isPatternAttribute {
+			n := newNoteView(a, note)
+			a.notes[note] = n
+			a.Add(n)
+			views[a] = n
+		}
+	}
+	return views
+}
+
+// wander fills the loop region, or the 8 seconds after the cursor, with notes
+// from a wander.Generator.  Attributes that the generator doesn't set are
+// taken from the attribute cursors, as for a new note.
+func (p *PatternView) wander() {
+	start, end := p.cursorTime, p.cursorTime+8
+	if p.loopEnd > p.loopStart {
+		start, end = p.loopStart, p.loopEnd
+	}
+	n := len(p.pattern.Notes)
+	wander.NewGenerator(time.Now().UnixNano()).Fill(p.pattern, start, end-start)
+	for _, note := range p.pattern.Notes[n:] {
+		attrs := map[string][]*audio.ControlPoint{}
+		for _, a := range p.attrs {
+			if !a.isPatternAttribute {
+				attrs[a.name] = note.Attributes[a.name]
+				if attrs[a.name] == nil {
+					attrs[a.name] = []*audio.ControlPoint{{0, a.cursorVal}}
+				}
+			}
+		}
+		note.Attributes = attrs
+		p.addNoteViews(note)
+	}
+	p.edited()
+}
+
+// edited must be called after each change to the pattern so that the players
+// pick it up.
+func (p *PatternView) edited() {
+	p.player.Commit()
+	if p.changed != nil {
+		p.changed()
+	}
+}
+
 func (p *PatternView) KeyPress(k KeyEvent) {
 	switch k.Key {
 	case KeySpace:
@@ -188,12 +276,17 @@ func savePattern(p *audio.Pattern) {
 		}
 		fmt.Fprint(f, "\t},\n")
 	}
-	fmt.Fprint(f, "})\n")
+	fmt.Fprint(f, "}")
+	if p.Modulation != nil {
+		writeModulation(f, p.Modulation)
+	}
+	fmt.Fprint(f, ")\n")
 }
 
 type attributeView struct {
 	*ViewBase
 	isPatternAttribute bool
+	route              *audio.Route // if this is the depth of a modulation route
 
 	pattern   *PatternView
 	name      string
@@ -302,6 +395,9 @@ func (a *attributeView) KeyPress(k KeyEvent) {
 		a.pattern.save()
 		return
 	}
+	if a.modKeyPress(k) {
+		return
+	}
 
 	if k.Alt {
 		switch k.Key {
@@ -333,25 +429,15 @@ func (a *attributeView) KeyPress(k KeyEvent) {
 			break
 		}
 		note := a.pattern.newNote()
-		var n *noteView
-		for _, a2 := range a.pattern.attrs {
-			if !a2.isPatternAttribute {
-				n2 := newNoteView(a2, note)
-				a2.notes[note] = n2
-				a2.Add(n2)
-				if a2 == a {
-					n = n2
-				}
-			}
-		}
-		SetKeyFocus(n)
+		SetKeyFocus(a.pattern.addNoteViews(note)[a])
 	case KeySpace:
-		a.pattern.oldFocus = a
-		SetKeyFocus(a.pattern)
-		a.pattern.player.SetTime(a.pattern.cursorTime)
-		a.pattern.play <- true
+		a.pattern.audition(a, k.Shift)
+	case KeyLeftBracket, KeyRightBracket:
+		a.pattern.setLoopPoint(k.Key == KeyRightBracket)
 	case KeyT:
 		a.pattern.tPressed = true
+	case KeyW:
+		a.pattern.wander()
 	case KeyG:
 		if k.Shift {
 			a.valueGrid = nil
@@ -493,6 +579,13 @@ func (a *attributeView) Paint() {
 		}
 	}
 
+	if p := a.pattern; p.loopEnd > p.loopStart {
+		SetLineWidth(2)
+		SetColor(Color{.2, .4, .2, 1})
+		DrawLine(a.to(Pt(p.loopStart, min.Y)), a.to(Pt(p.loopStart, max.Y)))
+		DrawLine(a.to(Pt(p.loopEnd, min.Y)), a.to(Pt(p.loopEnd, max.Y)))
+	}
+
 	if p, ok := KeyFocus(a).(*controlPointView); !ok || p.note.attr == a {
 		SetLineWidth(3)
 		SetColor(Color{.2, .2, .35, 1})
@@ -521,7 +614,7 @@ type noteView struct {
 func newNoteView(attr *attributeView, note *audio.Note) *noteView {
 	n := &noteView{attr: attr, note: note}
 	n.ViewBase = NewView(n)
-	for _, point := range note.Attributes[attr.name] {
+	for _, point := range n.getpts() {
 		p := newControlPointView(n, point)
 		n.points = append(n.points, p)
 		n.Add(p)
@@ -531,8 +624,19 @@ func newNoteView(attr *attributeView, note *audio.Note) *noteView {
 	return n
 }
 
-func (n *noteView) getpts() []*audio.ControlPoint    { return n.note.Attributes[n.attr.name] }
-func (n *noteView) setpts(pts []*audio.ControlPoint) { n.note.Attributes[n.attr.name] = pts }
+func (n *noteView) getpts() []*audio.ControlPoint {
+	if r := n.attr.route; r != nil {
+		return r.Depth
+	}
+	return n.note.Attributes[n.attr.name]
+}
+func (n *noteView) setpts(pts []*audio.ControlPoint) {
+	if r := n.attr.route; r != nil {
+		r.Depth = pts
+		return
+	}
+	n.note.Attributes[n.attr.name] = pts
+}
 
 func (n *noteView) TookKeyFocus() {
 	for _, a := range n.attr.pattern.attrs {
@@ -627,6 +731,7 @@ func (n *noteView) KeyPress(k KeyEvent) {
 				break
 			}
 		}
+		n.attr.pattern.edited()
 		SetKeyFocus(n.attr)
 		for _, a := range n.attr.pattern.attrs {
 			if n, ok := a.notes[n.note]; ok {
@@ -671,6 +776,7 @@ func (n *noteView) setTime(t float64) {
 			}
 		}
 	}
+	n.attr.pattern.edited()
 }
 
 func (n *noteView) reform() {
@@ -819,6 +925,7 @@ func (p *controlPointView) setTime(t float64) {
 func (p *controlPointView) setValue(v float64) {
 	p.point.Value = v
 	p.reform()
+	p.note.attr.pattern.edited()
 }
 
 func (p *controlPointView) reform() {
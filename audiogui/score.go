@@ -25,9 +25,13 @@ type ScoreView struct {
 	timeGrid   *uniformGrid
 	cursorTime float64
 
+	loopStart, loopEnd float64
+
 	player      *audio.ScorePlayer
+	transport   *audio.Transport
 	play, close chan bool
 	oldFocus    View
+	load        *Text
 
 	pattern *PatternView
 }
@@ -53,8 +57,12 @@ loop:
 		s.Add(p)
 	}
 	s.timeGrid = &uniformGrid{0, 1}
+	s.load = NewText("")
+	s.load.SetBackgroundColor(Color{})
+	s.Add(s.load)
 
 	s.player = audio.NewScorePlayer(score, band)
+	s.transport = audio.NewTransport(s.player)
 	s.play = make(chan bool, 1)
 	s.close = make(chan bool)
 	go s.animate()
@@ -66,34 +74,47 @@ func (s *ScoreView) InitFocus() { SetKeyFocus(s.parts[0]) }
 
 func (s *ScoreView) Close() {
 	s.ViewBase.Close()
+	s.transport.Pause()
 	s.close <- true
 }
 
+// audition plays the score from the cursor, repeating the loop region if loop is set.
+func (s *ScoreView) audition(from View, loop bool) {
+	s.oldFocus = from
+	SetKeyFocus(s)
+	if loop {
+		s.transport.SetLoop(s.loopStart, s.loopEnd)
+	} else {
+		s.transport.ClearLoop()
+	}
+	s.transport.Seek(s.cursorTime)
+	s.play <- true
+}
+
 func (s *ScoreView) animate() {
 	var next <-chan time.Time
 	var ctrl audio.PlayControl
 	for {
 		select {
-		case <-s.play:
-			if next != nil {
-				next = nil
-				ctrl.Stop()
+		case play := <-s.play:
+			if !play {
+				s.transport.Pause()
 				break
 			}
-			for _, inst := range audio.BandInstruments(s.band) {
-				inst.Stop()
-			}
-			ctrl = audio.PlayAsync(s.player)
+			ctrl = s.transport.Play()
 			next = time.After(time.Second / 60)
 		case <-next:
 			next = time.After(time.Second / 60)
+			stats := ctrl.Stats()
 			Do(s, func() {
-				s.cursorTime = s.player.GetTime()
+				s.cursorTime = s.transport.GetTime()
+				s.showLoad(stats.String())
 				Repaint(s)
 			})
 		case <-ctrl.Done:
 			next = nil
 			Do(s, func() {
+				s.showLoad("")
 				SetKeyFocus(s.oldFocus)
 			})
 		case <-s.close:
@@ -102,14 +123,22 @@ func (s *ScoreView) animate() {
 	}
 }
 
+// showLoad shows the DSP load while the score plays.
+func (s *ScoreView) showLoad(text string) {
+	s.load.SetText(text)
+	s.load.Move(Pt(Width(s)-Width(s.load), 0))
+}
+
 func (s *ScoreView) reform() {
 	w, h := Size(s)
+	s.load.Move(Pt(w-Width(s.load), 0))
 	for _, p := range s.parts {
 		ph := Height(p)
 		h -= ph
 		p.Resize(w, ph)
 		p.Move(Pt(0, h))
 		p.name.Move(Pt(w-Width(p.name), ph-Height(p.name)))
+		p.updateStrip()
 		for _, e := range p.events {
 			e.reform()
 		}
@@ -133,13 +162,62 @@ func (s *ScoreView) save() {
 		for _, e := range p.Events {
 			fmt.Fprintf(f, "\t\t{%v, %s_pattern},\n", e.Time, e.Pattern.Name)
 		}
-		fmt.Fprint(f, "\t}},\n")
+		fmt.Fprint(f, "\t}, ")
+		writeStrip(f, p.Strip)
+		fmt.Fprint(f, "},\n")
 	}
 	fmt.Fprint(f, "}}\n")
 }
 
+func writeStrip(f *os.File, s *audio.Strip) {
+	if s == nil {
+		fmt.Fprint(f, "nil")
+		return
+	}
+	writePoints := func(indent string, points []*audio.ControlPoint) {
+		fmt.Fprint(f, "{\n")
+		for _, p := range points {
+			fmt.Fprintf(f, "%s\t{%v, %v},\n", indent, p.Time, p.Value)
+		}
+		fmt.Fprintf(f, "%s},\n", indent)
+	}
+	fmt.Fprint(f, "&audio.Strip{\n")
+	if len(s.Gain) > 0 {
+		fmt.Fprint(f, "\t\tGain: ")
+		writePoints("\t\t", s.Gain)
+	}
+	if len(s.Pan) > 0 {
+		fmt.Fprint(f, "\t\tPan: ")
+		writePoints("\t\t", s.Pan)
+	}
+	if s.Mute {
+		fmt.Fprint(f, "\t\tMute: true,\n")
+	}
+	if s.Solo {
+		fmt.Fprint(f, "\t\tSolo: true,\n")
+	}
+	if len(s.Inserts) > 0 {
+		fmt.Fprintf(f, "\t\tInserts: %#v,\n", s.Inserts)
+	}
+	if len(s.Sends) > 0 {
+		fmt.Fprint(f, "\t\tSends: map[string][]*audio.ControlPoint{\n")
+		names := []string{}
+		for name := range s.Sends {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Fprintf(f, "\t\t\t%q: ", name)
+			writePoints("\t\t\t", s.Sends[name])
+		}
+		fmt.Fprint(f, "\t\t},\n")
+	}
+	fmt.Fprint(f, "\t}")
+}
+
 func (s *ScoreView) editPattern(e *patternEventView) {
 	p := NewPatternView(e.event.Pattern, audio.BandInstruments(s.band)[e.part.part.Name])
+	p.changed = s.player.Commit
 	p.closed = func() {
 		s.pattern = nil
 		s.reform()
@@ -151,6 +229,16 @@ func (s *ScoreView) editPattern(e *patternEventView) {
 	p.InitFocus()
 }
 
+// setLoopPoint moves the start or end of the loop region to the cursor.
+func (s *ScoreView) setLoopPoint(end bool) {
+	if end {
+		s.loopEnd = s.cursorTime
+	} else {
+		s.loopStart = s.cursorTime
+	}
+	Repaint(s)
+}
+
 func (s *ScoreView) Resize(width, height float64) {
 	s.transTime += (width - Width(s)) / 2
 	s.ViewBase.Resize(width, height)
@@ -186,6 +274,8 @@ type partView struct {
 	score   *ScoreView
 	part    *audio.Part
 	name    *Text
+	strip   *Text
+	param   int // index into stripParams of the parameter edited by the + and - keys
 	events  []*patternEventView
 	focused bool
 }
@@ -196,6 +286,9 @@ func newPartView(score *ScoreView, part *audio.Part) *partView {
 	p.name = NewText(part.Name)
 	p.name.SetBackgroundColor(Color{})
 	p.Add(p.name)
+	p.strip = NewText("")
+	p.strip.SetBackgroundColor(Color{})
+	p.Add(p.strip)
 	for _, event := range part.Events {
 		e := newPatternEventView(p, event)
 		p.events = append(p.events, e)
@@ -232,9 +325,12 @@ func (p *partView) KeyPress(k KeyEvent) {
 	switch k.Key {
 	case KeyLeft, KeyRight:
 		p.score.cursorTime = math.Max(0, p.score.timeGrid.next(p.score.cursorTime, k.Key == KeyRight))
+		p.updateStrip()
 		Repaint(p.score)
 	case KeyDown, KeyUp:
 		SetKeyFocus(p.next(k.Key == KeyUp))
+	case KeyLeftBracket, KeyRightBracket:
+		p.score.setLoopPoint(k.Key == KeyRightBracket)
 	case KeyEnter:
 		event := &audio.PatternEvent{p.score.cursorTime, &audio.Pattern{Attributes: map[string][]*audio.ControlPoint{}}}
 		e := newPatternEventView(p, event)
@@ -249,6 +345,7 @@ func (p *partView) KeyPress(k KeyEvent) {
 				Patterns[name] = patternInfo{event.Pattern, filepath.Join(filepath.Dir(p.score.path), name) + "_pattern.go"}
 				savePattern(event.Pattern)
 			}
+			p.score.player.Commit()
 			e.reform()
 			SetKeyFocus(e)
 		}
@@ -258,11 +355,160 @@ func (p *partView) KeyPress(k KeyEvent) {
 		}
 		SetKeyFocus(e.name)
 	case KeySpace:
-		p.score.oldFocus = p
-		SetKeyFocus(p.score)
-		p.score.player.SetTime(p.score.cursorTime)
-		p.score.play <- true
+		p.score.audition(p, k.Shift)
+	case KeyTab:
+		n := len(p.stripParams())
+		if k.Shift {
+			p.param = (p.param - 1 + n) % n
+		} else {
+			p.param = (p.param + 1) % n
+		}
+		p.updateStrip()
+	case KeyEqual, KeyMinus:
+		dv := .1
+		if k.Key == KeyMinus {
+			dv = -dv
+		}
+		p.adjustStrip(dv)
+	case KeyM:
+		p.editStrip(func(s *audio.Strip) { s.Mute = !s.Mute })
+	case KeyS:
+		p.editStrip(func(s *audio.Strip) { s.Solo = !s.Solo })
+	}
+}
+
+func (p *partView) inst() audio.Instrument {
+	return audio.BandInstruments(p.score.band)[p.part.Name]
+}
+
+// stripParams lists the automated parameters of the part's Strip:  Gain, Pan
+// and, unless the part is itself an Effect, a send to each Effect part.
+func (p *partView) stripParams() []string {
+	params := []string{"Gain", "Pan"}
+	if _, ok := p.inst().(audio.Effect); ok {
+		return params
+	}
+	for _, p2 := range p.score.parts {
+		if _, ok := p2.inst().(audio.Effect); ok {
+			params = append(params, p2.part.Name)
+		}
+	}
+	return params
+}
+
+func (p *partView) stripPoints(param string) []*audio.ControlPoint {
+	s := p.part.Strip
+	if s == nil {
+		return nil
+	}
+	switch param {
+	case "Gain":
+		return s.Gain
+	case "Pan":
+		return s.Pan
+	}
+	return s.Sends[param]
+}
+
+func (p *partView) editStrip(f func(s *audio.Strip)) {
+	if p.part.Strip == nil {
+		p.part.Strip = &audio.Strip{}
+	}
+	f(p.part.Strip)
+	p.score.player.Commit()
+	p.updateStrip()
+	Repaint(p)
+}
+
+// adjustStrip changes the selected parameter at the cursor by dv, adding a
+// control point there if there isn't one.
+func (p *partView) adjustStrip(dv float64) {
+	param := p.stripParams()[p.param]
+	t := p.score.cursorTime
+	points := p.stripPoints(param)
+	v := valueAt(points, t) + dv
+	if param == "Pan" {
+		v = math.Max(-1, math.Min(1, v))
+	}
+	points = setValueAt(points, t, v)
+	p.editStrip(func(s *audio.Strip) {
+		switch param {
+		case "Gain":
+			s.Gain = points
+		case "Pan":
+			s.Pan = points
+		default:
+			if s.Sends == nil {
+				s.Sends = map[string][]*audio.ControlPoint{}
+			}
+			s.Sends[param] = points
+		}
+	})
+}
+
+func (p *partView) updateStrip() {
+	params := p.stripParams()
+	if p.param >= len(params) {
+		p.param = 0
+	}
+	text := ""
+	for i, param := range params {
+		points := p.stripPoints(param)
+		if i > 1 {
+			if len(points) == 0 && i != p.param {
+				continue // unused send
+			}
+			param = "> " + param
+		}
+		param = fmt.Sprintf("%s %.1f", param, valueAt(points, p.score.cursorTime))
+		if i == p.param {
+			param = "[" + param + "]"
+		}
+		text += param + "  "
 	}
+	if s := p.part.Strip; s != nil {
+		if s.Mute {
+			text += "M "
+		}
+		if s.Solo {
+			text += "S "
+		}
+		for _, name := range s.Inserts {
+			text += "+" + name + " "
+		}
+	}
+	p.strip.SetText(strings.TrimSpace(text))
+	p.strip.Move(Pt(Pos(p.name).X-Width(p.strip)-8, Height(p)-Height(p.strip)))
+}
+
+// valueAt returns the value of an automation curve at time t, interpolating
+// linearly between points as audio.Control does.
+func valueAt(points []*audio.ControlPoint, t float64) float64 {
+	prev := &audio.ControlPoint{}
+	for _, p := range points {
+		if t < p.Time {
+			return prev.Value + (p.Value-prev.Value)*(t-prev.Time)/(p.Time-prev.Time)
+		}
+		prev = p
+	}
+	return prev.Value
+}
+
+// setValueAt returns points with the value at time t set to v.  An empty
+// curve gets a point at time 0 so that the value before t is unchanged.
+func setValueAt(points []*audio.ControlPoint, t, v float64) []*audio.ControlPoint {
+	if len(points) == 0 {
+		points = []*audio.ControlPoint{{0, 0}}
+	}
+	i := sort.Search(len(points), func(i int) bool { return points[i].Time >= t })
+	if i < len(points) && points[i].Time == t {
+		points[i].Value = v
+		return points
+	}
+	points = append(points, nil)
+	copy(points[i+1:], points[i:])
+	points[i] = &audio.ControlPoint{t, v}
+	return points
 }
 
 func (p *partView) focusNearest(pt Point, dirKey int) {
@@ -326,11 +572,45 @@ func (p *partView) Paint() {
 	}
 	DrawLine(Pt(p.to(p.score.cursorTime), r.Min.Y), Pt(p.to(p.score.cursorTime), r.Max.Y))
 
+	if p.focused {
+		p.paintStripParam()
+	}
+
+	if s := p.score; s.loopEnd > s.loopStart {
+		SetLineWidth(2)
+		SetColor(Color{.2, .4, .2, 1})
+		DrawLine(Pt(p.to(s.loopStart), r.Min.Y), Pt(p.to(s.loopStart), r.Max.Y))
+		DrawLine(Pt(p.to(s.loopEnd), r.Min.Y), Pt(p.to(s.loopEnd), r.Max.Y))
+	}
+
 	SetColor(Color{1, 1, 1, 1})
 	SetLineWidth(1)
 	DrawLine(ZP, Pt(Width(p), 0))
 }
 
+// paintStripParam draws the automation curve of the selected Strip parameter.
+func (p *partView) paintStripParam() {
+	param := p.stripParams()[p.param]
+	points := p.stripPoints(param)
+	if len(points) == 0 {
+		return
+	}
+	scale := 4.0 // log2 gain
+	if param == "Pan" {
+		scale = 1
+	}
+	h := Height(p)
+	y := func(v float64) float64 { return h/2 + math.Max(-1, math.Min(1, v/scale))*h/2 }
+	pts := []Point{Pt(p.to(0), y(0))}
+	for _, pt := range points {
+		pts = append(pts, Pt(p.to(pt.Time), y(pt.Value)))
+	}
+	pts = append(pts, Pt(Width(p), y(points[len(points)-1].Value)))
+	SetLineWidth(1)
+	SetColor(Color{.5, .4, .2, 1})
+	DrawLineStrip(pts...)
+}
+
 type patternEventView struct {
 	*ViewBase
 	part    *partView
@@ -350,18 +630,23 @@ func newPatternEventView(part *partView, event *audio.PatternEvent) *patternEven
 }
 
 func (e *patternEventView) reform() {
+	t := patternDuration(e.event.Pattern)
+	max := OuterRect(e.name).Max
+	e.Resize(math.Max(max.X+3, t*e.part.score.scaleTime), max.Y)
+	e.Move(Pt(e.part.to(e.event.Time), 4))
+}
+
+func patternDuration(p *audio.Pattern) float64 {
 	t := 0.0
-	for _, n := range e.event.Pattern.Notes {
+	for _, n := range p.Notes {
 		for _, a := range n.Attributes {
-			t = math.Max(t, n.Time + a[len(a)-1].Time)
+			t = math.Max(t, n.Time+a[len(a)-1].Time)
 		}
 	}
-	for _, a := range e.event.Pattern.Attributes {
+	for _, a := range p.Attributes {
 		t = math.Max(t, a[len(a)-1].Time)
 	}
-	max := OuterRect(e.name).Max
-	e.Resize(math.Max(max.X+3, t*e.part.score.scaleTime), max.Y)
-	e.Move(Pt(e.part.to(e.event.Time), 4))
+	return t
 }
 
 func (e *patternEventView) TookKeyFocus() {
@@ -389,7 +674,13 @@ func (e *patternEventView) KeyPress(k KeyEvent) {
 		switch k.Key {
 		case KeyLeft, KeyRight:
 			e.event.Time = e.part.score.timeGrid.next(e.event.Time, k.Key == KeyRight)
+			e.part.score.player.Commit()
 			e.reform()
+		case KeySpace:
+			s := e.part.score
+			s.loopStart, s.loopEnd = e.event.Time, e.event.Time+patternDuration(e.event.Pattern)
+			s.cursorTime = s.loopStart
+			s.audition(e, true)
 		}
 		return
 	}
@@ -400,6 +691,10 @@ func (e *patternEventView) KeyPress(k KeyEvent) {
 		SetKeyFocus(e.part)
 	case KeyDown, KeyUp:
 		SetKeyFocus(e.part.next(k.Key == KeyUp))
+	case KeySpace:
+		e.part.score.audition(e, false)
+	case KeyLeftBracket, KeyRightBracket:
+		e.part.score.setLoopPoint(k.Key == KeyRightBracket)
 	case KeyEnter:
 		e.part.score.editPattern(e)
 	case KeyEscape:
@@ -412,6 +707,7 @@ func (e *patternEventView) KeyPress(k KeyEvent) {
 				break
 			}
 		}
+		e.part.score.player.Commit()
 		for i, e2 := range e.part.events {
 			if e2 == e {
 				e.part.events = append(e.part.events[:i], e.part.events[i+1:]...)
@@ -557,10 +853,15 @@ type patternInfo struct {
 	path string
 }
 
-func NewPattern(notes []*audio.Note, attributes map[string][]*audio.ControlPoint) *audio.Pattern {
+// NewPattern is called by the files that savePattern writes.  The modulation,
+// if any, follows the attributes.
+func NewPattern(notes []*audio.Note, attributes map[string][]*audio.ControlPoint, mod ...*audio.Modulation) *audio.Pattern {
 	_, path, _, _ := runtime.Caller(1)
 	name := strings.TrimSuffix(filepath.Base(path), "_pattern.go")
-	p := &audio.Pattern{name, notes, attributes}
+	p := &audio.Pattern{name, notes, attributes, nil}
+	if len(mod) > 0 {
+		p.Modulation = mod[0]
+	}
 	Patterns[name] = patternInfo{p, path}
 	return p
 }
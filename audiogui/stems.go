@@ -0,0 +1,165 @@
+package audiogui
+
+import (
+	"code.google.com/p/gordon-go/audio"
+	"code.google.com/p/gordon-go/audio/pcm"
+
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sync"
+)
+
+type stemManifest struct {
+	Score      string
+	SampleRate float64
+	BitDepth   int
+	From, To   float64
+	Stems      []*stemInfo
+}
+
+type stemInfo struct {
+	Part   string
+	File   string
+	Length float64 // seconds
+	Peak   float64
+}
+
+// stems renders each part of score to its own WAV file, through its own
+// ScorePlayer and band made by newBand so that parts can be rendered in
+// parallel, and writes a manifest of the files as JSON.
+func stems(score *audio.Score, newBand func() audio.Band, path, name string, args []string) {
+	dir := filepath.Join(path, name+"_stems")
+	flags := flag.NewFlagSet("stems", flag.ExitOnError)
+	from := flags.Float64("from", 0, "start time in seconds")
+	to := flags.Float64("to", 0, "end time in seconds; notes after it are not played (default: end of score)")
+	rate := flags.Float64("rate", 96000, "sample rate")
+	bits := flags.Int("bits", 32, "bits per sample: 16 or 24 for integer PCM, 32 for floating point")
+	format := flags.String("format", "wav", "file format: wav, aiff or flac")
+	tail := flags.Float64("tail", 10, "maximum time in seconds to render after the end, while sound dies away")
+	flags.StringVar(&dir, "dir", dir, "output directory")
+	flags.Parse(args)
+	if *bits != 16 && *bits != 24 && *bits != 32 {
+		fmt.Fprintln(os.Stderr, "bits must be 16, 24 or 32")
+		os.Exit(2)
+	}
+	end := *to
+	if end == 0 {
+		end = scoreDuration(score)
+	} else {
+		score = trimScore(score, end)
+	}
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	runtime.GOMAXPROCS(runtime.NumCPU())
+
+	inserts := map[string]bool{}
+	for _, part := range score.Parts {
+		if part.Strip != nil {
+			for _, name := range part.Strip.Inserts {
+				inserts[name] = true
+			}
+		}
+	}
+	instruments := audio.BandInstruments(newBand())
+	m := &stemManifest{Score: name, SampleRate: *rate, BitDepth: *bits, From: *from, To: end}
+	for _, part := range score.Parts {
+		if _, ok := instruments[part.Name]; ok && !inserts[part.Name] { // inserts are heard in the parts they process
+			m.Stems = append(m.Stems, &stemInfo{Part: part.Name, File: name + "_" + part.Name + "." + *format})
+		}
+	}
+
+	failed := false
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	for _, s := range m.Stems {
+		wg.Add(1)
+		go func(s *stemInfo) {
+			defer wg.Done()
+			p := audio.NewScorePlayer(score, newBand())
+			p.Stem(s.Part)
+			p.SetTime(*from)
+			f := pcm.Format{SampleRate: int(*rate), Bits: *bits, Float: *bits == 32, Dither: pcm.TPDF}
+			n, peak, err := render(p, filepath.Join(dir, s.File), audio.Params{*rate}, f, end-*from+*tail)
+			s.Length, s.Peak = float64(n) / *rate, peak
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "error writing stem for part %s: %s\n", s.Part, err)
+				failed = true
+				return
+			}
+			fmt.Printf("wrote %s (%.1fs, peak %.3f)\n", s.File, s.Length, s.Peak)
+		}(s)
+	}
+	wg.Wait()
+
+	b, err := json.MarshalIndent(m, "", "\t")
+	if err == nil {
+		err = ioutil.WriteFile(filepath.Join(dir, "manifest.json"), append(b, '\n'), 0666)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error writing manifest:", err)
+		failed = true
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// bandMaker returns a func that makes new bands of the same type as band,
+// each from the zero value, so that they share no voices, buffers or locks.
+// Bands are built this way (e.g., &band{}) and their instruments set
+// themselves up when they are first played; a band configured otherwise
+// can't be rendered in parallel.
+func bandMaker(band audio.Band) func() audio.Band {
+	t := reflect.TypeOf(band)
+	if t.Kind() != reflect.Ptr || t.Elem().Kind() != reflect.Struct {
+		panic(fmt.Sprintf("%T must be a pointer to a struct.", band))
+	}
+	if !reflect.DeepEqual(band, reflect.New(t.Elem()).Interface()) {
+		fmt.Printf("warning: %T is not its zero value; stems are rendered from new, zero bands\n", band)
+	}
+	return func() audio.Band { return reflect.New(t.Elem()).Interface() }
+}
+
+// trimScore returns a copy of score without the notes that start at or after
+// time end.
+func trimScore(score *audio.Score, end float64) *audio.Score {
+	trimmed := &audio.Score{}
+	for _, part := range score.Parts {
+		p := &audio.Part{part.Name, nil, part.Strip}
+		for _, e := range part.Events {
+			if e.Time >= end {
+				continue
+			}
+			pattern := &audio.Pattern{e.Pattern.Name, nil, e.Pattern.Attributes, e.Pattern.Modulation}
+			for _, n := range e.Pattern.Notes {
+				if e.Time+n.Time < end {
+					pattern.Notes = append(pattern.Notes, n)
+				}
+			}
+			p.Events = append(p.Events, &audio.PatternEvent{e.Time, pattern})
+		}
+		trimmed.Parts = append(trimmed.Parts, p)
+	}
+	return trimmed
+}
+
+func scoreDuration(score *audio.Score) float64 {
+	t := 0.0
+	for _, part := range score.Parts {
+		for _, e := range part.Events {
+			t = math.Max(t, e.Time+patternDuration(e.Pattern))
+		}
+	}
+	return t
+}
@@ -0,0 +1,44 @@
+package audiogui
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"code.google.com/p/gordon-go/audio"
+	"code.google.com/p/gordon-go/audio/analysis"
+	"code.google.com/p/gordon-go/audio/pcm"
+)
+
+// transcribe writes the notes sung or played in an audio file to a new
+// pattern file, name_pattern.go in dir.
+func transcribe(dir, filename, name string) {
+	path := filepath.Join(dir, name+"_pattern.go")
+	if _, err := os.Stat(path); err == nil {
+		fmt.Printf("%s already exists\n", path)
+		return
+	}
+	r, err := pcm.Open(filename)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer r.Close()
+	x, err := pcm.ReadAll(r)
+	if err != nil {
+		fmt.Printf("%s:  %s\n", filename, err)
+		return
+	}
+	f := r.Format()
+	mono := make([]float64, len(x)/f.Channels)
+	for i := range mono {
+		for ch := 0; ch < f.Channels; ch++ {
+			mono[i] += x[i*f.Channels+ch] / float64(f.Channels)
+		}
+	}
+
+	p := &audio.Pattern{name, analysis.Transcribe(mono, float64(f.SampleRate)), map[string][]*audio.ControlPoint{}, nil}
+	Patterns[name] = patternInfo{p, path}
+	savePattern(p)
+	fmt.Printf("transcribed %d notes to %s\n", len(p.Notes), path)
+}
@@ -2,42 +2,94 @@ package audiogui
 
 import (
 	"code.google.com/p/gordon-go/audio"
-	"github.com/oov/audio/wave"
+	"code.google.com/p/gordon-go/audio/pcm"
 
-	"os"
+	"math"
 )
 
+// Write renders v to a 32-bit floating point file at 96 kHz.  The file's
+// extension chooses the format; see pcm.Create.
 func Write(v audio.Voice, filename string) {
-	f, err := os.Create(filename)
-	if err != nil {
+	if _, _, err := render(v, filename, audio.Params{96000}, pcm.Format{SampleRate: 96000, Bits: 32, Float: true}, -1); err != nil {
 		panic(err)
 	}
-	defer f.Close()
+}
 
-	params := audio.Params{96000}
-	const channels = 1
-	const bitsPerSample = 32
-	w, err := wave.NewWriter(f, &wave.WaveFormatExtensible{Format: wave.WaveFormatEx{
-		FormatTag:      wave.WAVE_FORMAT_IEEE_FLOAT,
-		Channels:       channels,
-		SamplesPerSec:  uint32(params.SampleRate),
-		BitsPerSample:  bitsPerSample,
-		AvgBytesPerSec: uint32(params.SampleRate * channels * bitsPerSample / 8),
-		BlockAlign:     uint16(channels * bitsPerSample / 8),
-	}})
-	if err != nil {
+// WriteMaster renders v at 96 kHz and writes it to a 44.1 kHz, 16-bit file
+// with noise-shaped dither, ready for CD.
+func WriteMaster(v audio.Voice, filename string) {
+	if _, _, err := render(v, filename, audio.Params{96000}, pcm.Format{SampleRate: 44100, Bits: 16, Dither: pcm.NoiseShaped}, -1); err != nil {
 		panic(err)
 	}
-	defer w.Close()
+}
+
+// render plays v at params' sample rate and writes it to a file in format f,
+// resampling it to f's sample rate if need be.  A StereoVoice is written in
+// stereo, otherwise mono, whatever f.Channels says.  It stops when v is Done
+// or, if maxLen is not negative, after maxLen seconds, and returns the number
+// of frames written and their peak level.
+func render(v audio.Voice, filename string, params audio.Params, f pcm.Format, maxLen float64) (n int, peak float64, err error) {
+	stereo, isStereo := v.(audio.StereoVoice)
+	f.Channels = 1
+	if isStereo {
+		f.Channels = 2
+	}
+	w, err := pcm.Create(filename, f)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer func() {
+		if err2 := w.Close(); err == nil {
+			err = err2
+		}
+	}()
+
+	resamplers := make([]*audio.Resampler, f.Channels)
+	if float64(f.SampleRate) != params.SampleRate {
+		for i := range resamplers {
+			resamplers[i] = audio.NewResampler(params.SampleRate, float64(f.SampleRate))
+		}
+	}
+	chunk := make([][]float64, f.Channels)
+	write := func(flush bool) error {
+		out := chunk
+		if resamplers[0] != nil {
+			out = make([][]float64, f.Channels)
+			for i, r := range resamplers {
+				out[i] = r.Process(chunk[i])
+				if flush {
+					out[i] = append(out[i], r.Flush()...)
+				}
+			}
+		}
+		samples := make([]float64, 0, len(out[0])*f.Channels)
+		for j := range out[0] {
+			for i := range out {
+				samples = append(samples, out[i][j])
+				peak = math.Max(peak, math.Abs(out[i][j]))
+			}
+		}
+		n += len(out[0])
+		for i := range chunk {
+			chunk[i] = chunk[i][:0]
+		}
+		return w.Write(samples)
+	}
 
 	audio.Init(v, params)
-	for !v.Done() {
-		n, err := w.WriteFloat64Interleaved([][]float64{{v.Sing()}})
-		if err != nil {
-			panic(err)
+	max := int(maxLen * params.SampleRate)
+	for i := 0; !v.Done() && (maxLen < 0 || i < max); i++ {
+		if isStereo {
+			l, r := stereo.SingStereo()
+			chunk[0], chunk[1] = append(chunk[0], l), append(chunk[1], r)
+		} else {
+			chunk[0] = append(chunk[0], v.Sing())
 		}
-		if n != 1 {
-			panic("short write")
+		if len(chunk[0]) == 4096 {
+			if err := write(false); err != nil {
+				return n, peak, err
+			}
 		}
 	}
+	return n, peak, write(true)
 }
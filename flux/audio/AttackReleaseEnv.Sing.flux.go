@@ -5,88 +5,88 @@ package audio
 func (x2 *AttackReleaseEnv) Sing() (x Audio, done bool) {
 	var v *AttackReleaseEnv
 	var v2 *AttackReleaseEnv
-	var v3 *AttackReleaseEnv
+	var v3 bool
 	var v4 *AttackReleaseEnv
-	var v5 *AttackReleaseEnv
-	var v6 Audio
-	var v7 bool
-	var v8 *AttackReleaseEnv
+	var v5 Audio
+	var v6 *AttackReleaseEnv
+	var v7 *AttackReleaseEnv
+	var v8 Audio
 	var v9 *AttackReleaseEnv
-	var v10 Audio
-	v8 = x2//env
+	var v10 *AttackReleaseEnv
 	v = x2//env
-	v9 = x2//env
+	v10 = x2//env
 	v2 = x2//env
-	v3 = x2//env
 	v4 = x2//env
-	v5 = x2//env
-	x22 := &v3.Out
-	v6 = *x22
+	v6 = x2//env
+	v7 = x2//env
+	v9 = x2//env
+	x22 := &v.Out
+	v5 = *x22
+	v8 = *x22
 	x = *x22
-	v10 = *x22
-	x3 := &v4.release
-	v7 = *x3
-	if v7 {
+	x3 := &v2.release
+	v3 = *x3
+	if v3 {
 		var v11 float64
 		var v12 float64
-		x4 := &v5.x
+		x4 := &v4.x
 		v11 = *x4
 		const x5 = 0.0001
 		v12 = x5
 		x6 := v11 < v12
 		done = x6
-		for i := range v10 {
-			var v13 = &v10[i]
-			var v14 *float64
-			var v15 *float64
-			var v16 float64
+		for i := range v5 {
+			var v13 = &v5[i]
+			var v14 float64
+			var v15 float64
+			var v16 *float64
 			var v17 float64
-			var v18 float64
+			var v18 *float64
 			var v19 float64
-			v14 = v13
-			x7 := &v8.x
-			v15 = x7
-			v16 = *x7
-			x8 := &v.down
-			v17 = *x8
-			x9 := v16 * v17
-			v18 = x9
+			v16 = v13
+			x7 := &v6.x
+			v14 = *x7
+			v18 = x7
+			x8 := &v7.down
+			v15 = *x8
+			x9 := v14 * v15
+			v17 = x9
 			v19 = x9
-			*v14 = v19
-			*v15 = v18
+			*v16 = v17
+			*v18 = v19
 		}
 	} else  {
-		for i2 := range v6 {
-			var v20 = &v6[i2]
-			var v21 *float64
+		for i2 := range v8 {
+			var v20 = &v8[i2]
+			var v21 float64
 			var v22 float64
 			var v23 float64
 			var v24 float64
-			var v25 *float64
+			var v25 float64
 			var v26 float64
-			var v27 float64
+			var v27 *float64
 			var v28 float64
-			var v29 float64
+			var v29 *float64
 			var v30 float64
-			v25 = v20
+			v27 = v20
 			const x10 = 1
-			v28 = x10
-			x11 := &v2.up
-			v26 = *x11
+			v25 = x10
+			x11 := &v9.up
+			v23 = *x11
 			const x12 = 1
-			v29 = x12
-			x13 := &v9.x
-			v27 = *x13
-			v21 = x13
-			x14 := v29 - v27
-			v22 = x14
-			x15 := v26 * v22
-			v30 = x15
-			x16 := v28 - v30
-			v24 = x16
-			v23 = x16
-			*v25 = v24
-			*v21 = v23
+			v21 = x12
+			x13 := &v10.x
+			v22 = *x13
+			v29 = x13
+			x14 := v21 - v22
+			v24 = x14
+			x15 := v23 * v24
+			v26 = x15
+			x16 := v25 - v26
+			v28 = x16
+			v30 = x16
+			*v27 = v28
+			*v29 = v30
 		}
 	}
 	return
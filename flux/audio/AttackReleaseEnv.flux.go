@@ -2,4 +2,4 @@
 
 package audio
 
-type AttackReleaseEnv struct{Params Params; attackTime float64; releaseTime float64; up float64; down float64; release bool; x float64; Out Audio}
\ No newline at end of file
+type AttackReleaseEnv struct{Params Params; attackTime float64; releaseTime float64; up float64; down float64; release bool; x float64; Out Audio}
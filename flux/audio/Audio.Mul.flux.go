@@ -6,28 +6,28 @@ func (x Audio) Mul(x2 Audio, x3 Audio) (x4 Audio) {
 	var v Audio
 	var v2 Audio
 	var v3 Audio
-	v3 = x3
 	v = x
-	x4 = x
 	v2 = x2
+	v3 = x3
+	x4 = x
 	for k := range v {
 		var v4 = &v[k]
 		var v5 int
-		var v6 *float64
+		var v6 int
 		var v7 float64
 		var v8 float64
-		var v9 float64
-		var v10 int
-		v10 = k
+		var v9 *float64
+		var v10 float64
 		v5 = k
-		v6 = v4
-		x22 := &v2[v10]
+		v6 = k
+		v9 = v4
+		x22 := &v2[v5]
 		v7 = *x22
-		x32 := &v3[v5]
+		x32 := &v3[v6]
 		v8 = *x32
 		x42 := v7 * v8
-		v9 = x42
-		*v6 = v9
+		v10 = x42
+		*v9 = v10
 	}
 	return
 }
@@ -1,3 +1,5 @@
+// Generated by Flux, not meant for human consumption.  Editing may make it unreadable by Flux.
+
 package audio
 
-type Audio []float64
\ No newline at end of file
+type Audio []float64
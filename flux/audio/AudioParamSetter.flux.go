@@ -2,4 +2,4 @@
 
 package audio
 
-type AudioParamSetter interface{SetAudioParams(p Params)}
\ No newline at end of file
+type AudioParamSetter interface{SetAudioParams(p Params)}
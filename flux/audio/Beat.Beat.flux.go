@@ -3,50 +3,50 @@
 package audio
 
 func (x *Beat) Beat() () {
-	var v int
-	var v2 int
-	var v3 *Beat
-	var v4 *int
+	var v *Beat
+	var v2 *Beat
+	var v3 *Params
+	var v4 int
 	var v5 int
-	var v6 int
+	var v6 bool
 	var v7 *Beat
-	var v8 *Beat
-	var v9 int
-	var v10 bool
+	var v8 int
+	var v9 *Beat
+	var v10 int
 	var v11 int
-	var v12 *Beat
-	var v13 int
-	var v14 *Params
-	v8 = x
-	v3 = x
+	var v12 int
+	var v13 *int
+	var v14 int
+	v = x
+	v2 = x
 	v7 = x
-	v12 = x
-	x2 := &v3.i
+	v9 = x
+	x2 := &v.i
+	v10 = *x2
 	v11 = *x2
-	v4 = x2
-	v5 = *x2
-	v6 = *x2
-	v13 = *x2
-	x3 := &v7.Params
-	v14 = x3
-	x4 := &v14.BufferSize
-	v9 = *x4
-	v = *x4
-	x5 := v5 < v9
-	v10 = x5
-	if v10 {
+	v13 = x2
+	v4 = *x2
+	v8 = *x2
+	x3 := &v2.Params
+	v3 = x3
+	x4 := &v3.BufferSize
+	v12 = *x4
+	v5 = *x4
+	x5 := v4 < v5
+	v6 = x5
+	if v6 {
 		var v15 func(offset int)
 		var v16 int
-		x6 := &v8.f
+		x6 := &v7.f
 		v15 = *x6
-		v15(v13)
-		x7 := &v12.n
+		v15(v8)
+		x7 := &v9.n
 		v16 = *x7
-		x8 := v6 + v16
+		x8 := v10 + v16
 		v11 = x8
 	}
-	x9 := v11 - v
-	v2 = x9
-	*v4 = v2
+	x9 := v11 - v12
+	v14 = x9
+	*v13 = v14
 	return
 }
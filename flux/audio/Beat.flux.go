@@ -1,3 +1,5 @@
+// Generated by Flux, not meant for human consumption.  Editing may make it unreadable by Flux.
+
 package audio
 
-type Beat struct{Params; i int; n int; f func(offset int)}
\ No newline at end of file
+type Beat struct{Params; i int; n int; f func(offset int)}
@@ -2,4 +2,4 @@
 
 package audio
 
-type Control struct{Params Params; Periods []ControlPeriod; elapsed []float64; i int; t float64; Out Audio}
\ No newline at end of file
+type Control struct{Params Params; Periods []ControlPeriod; elapsed []float64; i int; t float64; Out Audio}
@@ -2,4 +2,4 @@
 
 package audio
 
-type ControlConst struct{Duration_ float64; Value float64}
\ No newline at end of file
+type ControlConst struct{Duration_ float64; Value float64}
@@ -2,4 +2,4 @@
 
 package audio
 
-type ControlExp struct{Duration_ float64; Value1 float64; Value2 float64; x float64; a float64}
\ No newline at end of file
+type ControlExp struct{Duration_ float64; Value1 float64; Value2 float64; x float64; a float64}
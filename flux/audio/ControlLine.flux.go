@@ -2,4 +2,4 @@
 
 package audio
 
-type ControlLine struct{Duration_ float64; Value1 float64; Value2 float64; x float64; step float64}
\ No newline at end of file
+type ControlLine struct{Duration_ float64; Value1 float64; Value2 float64; x float64; step float64}
@@ -2,4 +2,4 @@
 
 package audio
 
-type ControlPeriod interface{Control(_ Audio); Duration() float64}
\ No newline at end of file
+type ControlPeriod interface{Control(_ Audio); Duration() float64}
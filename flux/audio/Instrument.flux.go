@@ -2,4 +2,4 @@
 
 package audio
 
-type Instrument interface{Play(_ Note); Sing() (_ Audio, done bool)}
\ No newline at end of file
+type Instrument interface{Play(_ Note); Sing() (_ Audio, done bool)}
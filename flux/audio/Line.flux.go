@@ -1,3 +1,5 @@
+// Generated by Flux, not meant for human consumption.  Editing may make it unreadable by Flux.
+
 package audio
 
-type Line struct{x float64; Params Params; Out Audio}
\ No newline at end of file
+type Line struct{x float64; Params Params; Out Audio}
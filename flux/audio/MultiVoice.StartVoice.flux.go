@@ -7,30 +7,31 @@ import (
 )
 
 func (x *MultiVoice) StartVoice(v Voice) () {
-	var v2 Voice
-	var v3 Params
-	var v4 *sync.Mutex
-	var v5 *MultiVoice
-	var v6 map[Voice]struct{}
+	var v2 *MultiVoice
+	var v3 *MultiVoice
+	var v4 Params
+	var v5 interface{}
+	var v6 *MultiVoice
 	var v7 *sync.Mutex
-	var v8 *MultiVoice
-	var v9 *MultiVoice
-	var v10 interface{}
-	v2 = v
-	v8 = x
-	v9 = x
-	v5 = x
-	v10 = v
-	x2 := &v9.voices
-	v6 = *x2
-	x3 := &v5.Params
-	v3 = *x3
-	v3.Set(v10)//;0
-	x4 := &v8.mu
+	var v8 map[Voice]struct{}
+	var v9 Voice
+	var v10 *sync.Mutex
+	v2 = x
+	v3 = x
+	v5 = v
+	v6 = x
+	v9 = v
+	x2 := &v2.voices
+	v8 = *x2
+	x3 := &v3.Params
+	v4 = *x3
+	v4.Set(v5)//;0
+	x4 := &v6.mu
+	v10 = x4
 	v7 = x4
-	v4 = x4
 	v7.Lock()//0;1
 	var v11 struct{}
-	v6[v2] = v11//1;2
-	v4.Unlock()//2;
+	v8[v9] = v11//1;2
+	v10.Unlock()//2;
+	return
 }
@@ -1,7 +1,9 @@
+// Generated by Flux, not meant for human consumption.  Editing may make it unreadable by Flux.
+
 package audio
 
 import (
 	"sync"
 )
 
-type MultiVoice struct{Params Params; voices map[Voice]struct{}; mu sync.Mutex; Out Audio}
\ No newline at end of file
+type MultiVoice struct{Params Params; voices map[Voice]struct{}; mu sync.Mutex; Out Audio}
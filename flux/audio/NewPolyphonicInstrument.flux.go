@@ -3,12 +3,12 @@
 package audio
 
 func NewPolyphonicInstrument(x func(_ Note) Voice) (i Instrument) {
-	var v func(_ Note) Voice
-	var v2 *MultiVoice
-	v = x
+	var v *MultiVoice
+	var v2 func(_ Note) Voice
+	v2 = x
 	x2 := NewMultiVoice()
-	v2 = x2
-	x3 := &PolyphonicInstrument{MultiVoice: v2, newVoice: v}
+	v = x2
+	x3 := &PolyphonicInstrument{MultiVoice: v, newVoice: v2}
 	i = x3
 	return
 }
@@ -1,3 +1,5 @@
+// Generated by Flux, not meant for human consumption.  Editing may make it unreadable by Flux.
+
 package audio
 
-type NormalOsc struct{Sine SineOsc}
\ No newline at end of file
+type NormalOsc struct{Sine SineOsc}
@@ -2,7 +2,4 @@
 
 package audio
 
-type Note struct {
-	Start		float64
-	Attributes	map[string][]ControlPeriod
-}
+type Note struct{Start float64; Attributes map[string][]ControlPeriod}
@@ -1,3 +1,5 @@
+// Generated by Flux, not meant for human consumption.  Editing may make it unreadable by Flux.
+
 package audio
 
-type Params struct{SampleRate float64; BufferSize int; visited map[interface{}]struct{}}
\ No newline at end of file
+type Params struct{SampleRate float64; BufferSize int; visited map[interface{}]struct{}}
@@ -2,4 +2,4 @@
 
 package audio
 
-type Pattern struct{Params Params; Notes []Note; i int; t float64; Instrument Instrument}
\ No newline at end of file
+type Pattern struct{Params Params; Notes []Note; i int; t float64; Instrument Instrument}
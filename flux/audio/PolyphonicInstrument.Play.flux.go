@@ -3,20 +3,21 @@
 package audio
 
 func (x *PolyphonicInstrument) Play(note Note) () {
-	var v Note
-	var v2 *MultiVoice
+	var v *PolyphonicInstrument
+	var v2 *PolyphonicInstrument
 	var v3 func(_ Note) Voice
-	var v4 Voice
-	var v5 *PolyphonicInstrument
-	var v6 *PolyphonicInstrument
-	v5 = x
-	v6 = x
-	v = note
-	x2 := &v6.MultiVoice
-	v2 = *x2
-	x3 := &v5.newVoice
+	var v4 Note
+	var v5 *MultiVoice
+	var v6 Voice
+	v = x
+	v2 = x
+	v4 = note
+	x2 := &v.MultiVoice
+	v5 = *x2
+	x3 := &v2.newVoice
 	v3 = *x3
-	x4 := v3(v)
-	v4 = x4
-	v2.StartVoice(v4)
+	x4 := v3(v4)
+	v6 = x4
+	v5.StartVoice(v6)
+	return
 }
@@ -2,4 +2,4 @@
 
 package audio
 
-type PolyphonicInstrument struct{*MultiVoice; newVoice func(_ Note) Voice}
\ No newline at end of file
+type PolyphonicInstrument struct{*MultiVoice; newVoice func(_ Note) Voice}
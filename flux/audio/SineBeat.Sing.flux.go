@@ -5,44 +5,44 @@ package audio
 func (x *SineBeat) Sing() (x2 Audio) {
 	var v *SineBeat
 	var v2 *SineBeat
-	var v3 float64
+	var v3 *SineOsc
 	var v4 float64
-	var v5 Audio
-	var v6 float64
+	var v5 *SineBeat
+	var v6 *SineBeat
 	var v7 *SineBeat
-	var v8 *SineBeat
-	var v9 *NormalOsc
+	var v8 *NormalOsc
+	var v9 float64
 	var v10 float64
-	var v11 *SineBeat
-	var v12 *SineBeat
-	var v13 *SineOsc
+	var v11 Audio
+	var v12 Audio
+	var v13 *SineBeat
 	var v14 Audio
-	var v15 Audio
-	v11 = x
-	v12 = x
-	v7 = x
-	v8 = x
+	var v15 float64
 	v = x
+	v13 = x
 	v2 = x
-	x22 := &v11.Sine
-	v13 = x22
+	v5 = x
+	v6 = x
+	v7 = x
+	x22 := &v.Sine
+	v3 = x22
 	x3 := &v2.sineFreq
-	v3 = *x3
-	x4 := v13.SineFreq(v3)
-	v14 = x4
-	x5 := &v.Env
-	v9 = x5
-	x6 := &v8.beatFreq
-	v10 = *x6
+	v4 = *x3
+	x4 := v3.SineFreq(v4)
+	v11 = x4
+	x5 := &v5.Env
+	v8 = x5
+	x6 := &v6.beatFreq
+	v9 = *x6
 	x7 := &v7.beatWidth
-	v4 = *x7
-	x23 := v9.Osc(v10, v4)
-	v5 = x23
-	x8 := Mul(v14, v5)
-	v15 = x8
-	x9 := &v12.amp
-	v6 = *x9
-	x32 := v15.MulX(v6)
+	v10 = *x7
+	x23 := v8.Osc(v9, v10)
+	v12 = x23
+	x8 := Mul(v11, v12)
+	v14 = x8
+	x9 := &v13.amp
+	v15 = *x9
+	x32 := v14.MulX(v15)
 	x2 = x32
 	return
 }
@@ -1,3 +1,5 @@
+// Generated by Flux, not meant for human consumption.  Editing may make it unreadable by Flux.
+
 package audio
 
-type SineBeat struct{amp float64; Sine SineOsc; sineFreq float64; Env NormalOsc; beatFreq float64; beatWidth float64}
\ No newline at end of file
+type SineBeat struct{amp float64; Sine SineOsc; sineFreq float64; Env NormalOsc; beatFreq float64; beatWidth float64}
@@ -3,24 +3,24 @@
 package audio
 
 func (x *SineBeats) Sing() (x2 Audio) {
-	var v []*SineBeat
-	var v2 *SineBeats
+	var v *SineBeats
+	var v2 Audio
 	var v3 *SineBeats
-	var v4 Audio
+	var v4 []*SineBeat
 	var v5 Audio
 	var v6 Audio
-	v2 = x
+	v = x
 	v3 = x
-	x22 := &v2.Out
-	v4 = *x22
+	x22 := &v.Out
+	v2 = *x22
 	v5 = *x22
 	v6 = *x22
 	x2 = *x22
-	v4.Zero()//;0
+	v2.Zero()//;0
 	x3 := &v3.Beats//0;
-	v = *x3
-	for i := range v {
-		var v7 = &v[i]
+	v4 = *x3
+	for i := range v4 {
+		var v7 = &v4[i]
 		var v8 *SineBeat
 		var v9 Audio
 		v8 = *v7
@@ -1,3 +1,5 @@
+// Generated by Flux, not meant for human consumption.  Editing may make it unreadable by Flux.
+
 package audio
 
-type SineOsc struct{Params Params; phase float64; Out Audio}
\ No newline at end of file
+type SineOsc struct{Params Params; phase float64; Out Audio}
@@ -8,32 +8,32 @@ func Sub(x2 Audio, x3 Audio) (x Audio) {
 	var v3 Audio
 	var v4 Audio
 	var v5 Audio
+	v = x2
 	v4 = x2
-	v5 = x2
-	v = x3
-	x22 := len(v4)
+	v5 = x3
+	x22 := len(v)
 	v2 = x22
 	x32 := make(Audio, v2)
 	v3 = x32
 	x = x32
 	for k := range v3 {
 		var v6 = &v3[k]
-		var v7 float64
-		var v8 float64
-		var v9 int
-		var v10 int
+		var v7 int
+		var v8 int
+		var v9 float64
+		var v10 float64
 		var v11 *float64
 		var v12 float64
-		v10 = k
 		v11 = v6
-		v9 = k
-		x4 := &v5[v9]
-		v12 = *x4
-		x5 := &v[v10]
-		v7 = *x5
-		x6 := v12 - v7
-		v8 = x6
-		*v11 = v8
+		v7 = k
+		v8 = k
+		x4 := &v4[v7]
+		v9 = *x4
+		x5 := &v5[v8]
+		v10 = *x5
+		x6 := v9 - v10
+		v12 = x6
+		*v11 = v12
 	}
 	return
 }
@@ -2,4 +2,4 @@
 
 package audio
 
-type Voice interface{Sing() (_ Audio, done bool)}
\ No newline at end of file
+type Voice interface{Sing() (_ Audio, done bool)}
@@ -0,0 +1,95 @@
+// Copyright 2014 Gordon Klaus. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"code.google.com/p/gordon-go/flux/go/types"
+	"code.google.com/p/gordon-go/refactor"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"time"
+)
+
+// Unsaved changes to funcs are periodically written to a recovery journal, a directory holding the would-be source of each file, named by the file's escaped path.  Saving a func removes its entry; entries that remain (e.g., after a crash) are offered for recovery on the next launch.
+
+const autosaveInterval = 30 * time.Second
+
+func autosaveDir() string {
+	home := os.Getenv("HOME")
+	if home == "" {
+		home = os.TempDir()
+	}
+	return filepath.Join(home, ".flux", "autosave")
+}
+
+func autosavePath(path string) string {
+	return filepath.Join(autosaveDir(), url.QueryEscape(path))
+}
+
+// autosave writes src() to obj's entry in the journal.  As with save, a panic is reported rather than propagated.
+func autosave(obj types.Object, src func() []byte) {
+	defer func() {
+		if x := recover(); x != nil {
+			fmt.Printf("error autosaving %s: %v\n%s", obj.GetName(), x, debug.Stack())
+		}
+	}()
+	path := autosavePath(fluxPath(obj))
+	err := os.MkdirAll(filepath.Dir(path), 0777)
+	if err == nil {
+		err = refactor.WriteFile(path, src())
+	}
+	if err != nil {
+		fmt.Printf("error autosaving %s: %s\n", obj.GetName(), err)
+	}
+}
+
+func removeAutosave(obj types.Object) {
+	os.Remove(autosavePath(fluxPath(obj)))
+}
+
+// An autosaved is an entry in the recovery journal.
+type autosaved struct {
+	path string // the file it would replace
+	src  []byte
+	time time.Time
+}
+
+func autosaves() (a []autosaved) {
+	dir := autosaveDir()
+	fis, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	for _, fi := range fis {
+		path, err := url.QueryUnescape(fi.Name())
+		if err != nil || fi.IsDir() {
+			continue
+		}
+		src, err := ioutil.ReadFile(filepath.Join(dir, fi.Name()))
+		if err != nil {
+			fmt.Printf("error reading autosaved %s: %s\n", path, err)
+			continue
+		}
+		a = append(a, autosaved{path, src, fi.ModTime()})
+	}
+	return
+}
+
+// restore replaces the file with the autosaved source and removes the entry from the journal.
+func (a autosaved) restore() {
+	if err := refactor.WriteFile(a.path, a.src); err != nil {
+		fmt.Printf("error recovering %s: %s\n", a.path, err)
+		return
+	}
+	a.discard()
+}
+
+func (a autosaved) discard() {
+	os.Remove(autosavePath(a.path))
+}
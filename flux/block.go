@@ -7,7 +7,6 @@ package main
 import (
 	"code.google.com/p/gordon-go/flux/go/types"
 	. "code.google.com/p/gordon-go/flux/gui"
-	. "code.google.com/p/gordon-go/util"
 	"fmt"
 	"go/token"
 	"math/rand"
@@ -18,10 +17,14 @@ const blockRadius = 8
 type block struct {
 	*ViewBase
 	node    node
-	nodes   map[node]bool
+	nodes   map[node]int // the value is the order in which the node was added, which determines the order in which nodes are written
 	conns   map[*connection]bool
 	focused bool
 
+	selection map[node]bool
+	band      Rectangle // dragged from Min to Max
+	banding   bool
+
 	arrange, childArranged blockchan
 	stop                   stopchan
 }
@@ -30,8 +33,9 @@ func newBlock(n node, arranged blockchan) *block {
 	b := &block{}
 	b.ViewBase = NewView(b)
 	b.node = n
-	b.nodes = map[node]bool{}
+	b.nodes = map[node]int{}
 	b.conns = map[*connection]bool{}
+	b.selection = map[node]bool{}
 
 	b.arrange = make(blockchan)
 	b.childArranged = make(blockchan)
@@ -70,11 +74,15 @@ func func_(n node) *funcNode {
 	return fn
 }
 
+// nodesAdded counts the nodes ever added to any block.
+var nodesAdded int
+
 func (b *block) addNode(n node) {
-	if !b.nodes[n] {
+	if _, ok := b.nodes[n]; !ok {
 		b.Add(n)
 		n.Move(Pt(rand.NormFloat64(), rand.NormFloat64()))
-		b.nodes[n] = true
+		nodesAdded++
+		b.nodes[n] = nodesAdded
 		n.setBlock(b)
 		switch n := n.(type) {
 		case *callNode:
@@ -98,12 +106,13 @@ func (b *block) addNode(n node) {
 }
 
 func (b *block) removeNode(n node) {
-	if b.nodes[n] {
+	if _, ok := b.nodes[n]; ok {
 		for _, c := range append(n.inConns(), n.outConns()...) {
 			c.blk.removeConn(c)
 		}
 		b.Remove(n)
 		delete(b.nodes, n)
+		delete(b.selection, n)
 		switch n := n.(type) {
 		case *callNode:
 			if n.obj != nil && !isMethod(n.obj) {
@@ -219,46 +228,54 @@ func (b block) outConns() (conns []*connection) {
 	return
 }
 
+// nodeOrder returns the nodes of b in a topological order.  Of the nodes that are ready, those added earlier come first, except that branches come last so that the values leaving the block are assigned before it is left.  The reader adds nodes in the order they were written, so reading and writing reproduces the order.
 func (b *block) nodeOrder() []node {
 	order := []node{}
 	var inputsNode *portsNode
 
-	visited := Set{}
-	var insertInOrder func(n node, visitedThisCall Set)
-	insertInOrder = func(n node, visitedThisCall Set) {
-		if visitedThisCall[n] {
-			fmt.Println("cyclic")
-			return
-		}
-		visitedThisCall[n] = true
-
-		if !visited[n] {
-			visited[n] = true
-			for _, src := range srcsInBlock(n) {
-				insertInOrder(src, visitedThisCall.Copy())
-			}
-			if pn, ok := n.(*portsNode); ok {
-				if !pn.out {
-					inputsNode = pn
-				}
-			} else {
-				order = append(order, n)
+	remaining := map[node]bool{}
+	for n := range b.nodes {
+		if pn, ok := n.(*portsNode); ok {
+			if !pn.out {
+				inputsNode = pn
 			}
+			continue
 		}
+		remaining[n] = true
 	}
-
-	endNodes := []node{}
-	for n := range b.nodes {
-		if len(dstsInBlock(n)) == 0 {
-			endNodes = append(endNodes, n)
+	ready := func(n node) bool {
+		for _, src := range srcsInBlock(n) {
+			if remaining[src] {
+				return false
+			}
 		}
+		return true
 	}
-	if len(endNodes) == 0 && len(b.nodes) > 0 {
-		fmt.Println("cyclic")
+	before := func(n1, n2 node) bool {
+		_, br1 := n1.(*branchNode)
+		_, br2 := n2.(*branchNode)
+		if br1 != br2 {
+			return br2
+		}
+		return b.nodes[n1] < b.nodes[n2]
 	}
-
-	for _, n := range endNodes {
-		insertInOrder(n, Set{})
+	for len(remaining) > 0 {
+		var next node
+		for n := range remaining {
+			if ready(n) && (next == nil || before(n, next)) {
+				next = n
+			}
+		}
+		if next == nil {
+			fmt.Println("cyclic")
+			for n := range remaining {
+				if next == nil || before(n, next) {
+					next = n
+				}
+			}
+		}
+		order = append(order, next)
+		delete(remaining, next)
 	}
 	if inputsNode != nil {
 		order = append([]node{inputsNode}, order...)
@@ -379,9 +396,31 @@ func (b *block) LostKeyFocus() {
 }
 
 func (b *block) KeyPress(event KeyEvent) {
+	if event.Command {
+		switch event.Key {
+		case KeyC, KeyX:
+			if nodes := b.selectedNodes(); len(nodes) > 0 {
+				b.copyNodes(nodes)
+				if event.Key == KeyX {
+					for n := range nodes {
+						b.removeNode(n)
+					}
+					recordEdit(b)
+					SetKeyFocus(b)
+				}
+			}
+			return
+		case KeyV:
+			b.paste()
+			return
+		}
+	}
+
 	switch k := event.Key; k {
 	case KeyLeft, KeyRight, KeyUp, KeyDown:
-		if event.Alt && !event.Shift {
+		if n, ok := KeyFocus(b).(node); ok && selecting(event) && len(b.selectedNodes()) > 0 {
+			b.extendSelection(n, k)
+		} else if event.Alt && !event.Shift {
 			b.focusNearestView(KeyFocus(b), k)
 		} else if n, ok := KeyFocus(b).(node); ok {
 			focseq := event.Alt && event.Shift
@@ -414,6 +453,14 @@ func (b *block) KeyPress(event KeyEvent) {
 			SetKeyFocus(v.node)
 		case *portsNode:
 		case node:
+			if nodes := b.selectedNodes(); len(nodes) > 1 {
+				for n := range nodes {
+					b.removeNode(n)
+				}
+				recordEdit(b)
+				SetKeyFocus(b)
+				break
+			}
 			foc := View(b)
 			in, out := v.inConns(), v.outConns()
 			if len(in) > 0 {
@@ -423,11 +470,14 @@ func (b *block) KeyPress(event KeyEvent) {
 				foc = out[len(out)-1].dst.node
 			}
 			b.removeNode(v)
+			recordEdit(b)
 			SetKeyFocus(foc)
 		}
 	case KeyEscape:
-		if f, ok := b.node.(*funcNode); ok && !f.literal {
-			f.Close()
+		if len(b.selection) > 0 {
+			b.selectNodes(map[node]bool{})
+		} else if f, ok := b.node.(*funcNode); ok && !f.literal {
+			f.confirmClose(func() {})
 		} else if f, ok := b.node.(focuserFrom); ok {
 			f.focusFrom(b)
 		} else {
@@ -437,7 +487,11 @@ func (b *block) KeyPress(event KeyEvent) {
 		if !(event.Ctrl || event.Alt || event.Super) {
 			switch event.Text {
 			default:
-				browser := newBrowser(browserOptions{enterTypes: true, canFuncAsVal: true}, b)
+				opts := browserOptions{enterTypes: true, canFuncAsVal: true}
+				if b.func_().isConst() {
+					opts = browserOptions{objFilter: isConstOperand, enterTypes: true}
+				}
+				browser := newBrowser(opts, b)
 				b.Add(browser)
 				w := window(b)
 				browser.Move(Map(Center(w), w, b))
@@ -471,6 +525,9 @@ func (b *block) KeyPress(event KeyEvent) {
 				}
 				SetKeyFocus(n.text)
 			case "{":
+				if b.func_().isConst() {
+					break
+				}
 				n := newCompositeLiteralNode(b.func_().pkg())
 				b.addNode(n)
 				MoveCenter(n, Center(b))
@@ -556,6 +613,7 @@ func (b *block) newNode(obj types.Object, funcAsVal bool, godefer string) node {
 		nn.editType()
 	} else {
 		SetKeyFocus(n)
+		recordEdit(b)
 	}
 	return n
 }
@@ -566,6 +624,13 @@ func (b *block) Paint() {
 		SetColor(focusColor)
 		DrawPoint(Center(b))
 	}
+	SetColor(selectionColor)
+	for n := range b.selection {
+		FillRect(RectInParent(n))
+	}
+	if b.banding {
+		FillRect(b.band.Canon())
+	}
 	{
 		SetColor(lineColor)
 		SetLineWidth(1.5)
@@ -709,6 +774,7 @@ func (n *portsNode) KeyPress(event KeyEvent) {
 			if f.obj == nil {
 				f.output.setType(sig)
 			}
+			recordEdit(n)
 		}
 	} else {
 		n.nodeBase.KeyPress(event)
@@ -7,7 +7,6 @@ package main
 import (
 	"code.google.com/p/gordon-go/flux/go/types"
 	. "code.google.com/p/gordon-go/flux/gui"
-	"code.google.com/p/gordon-go/refactor"
 	"code.google.com/p/gordon-go/trash"
 	"fmt"
 	"go/build"
@@ -340,6 +339,14 @@ func (b browser) filteredObjs() (objs objects) {
 			}
 			addSubPkgs(obj.importPath)
 		case *types.TypeName:
+			if t, ok := obj.Type.(*types.Named).UnderlyingT.(*types.Struct); ok && b.options.mutable && fluxObjs[obj] {
+				for _, f := range t.Fields {
+					if f2, ok := b.newObj.(field); ok && f2.Var == f {
+						continue // being renamed; added as newObj
+					}
+					add(field{f, obj.Type, false})
+				}
+			}
 			for _, m := range intuitiveMethodSet(obj.Type) {
 				if types.IsIdentical(m.Obj.(*types.Func).Type.(*types.Signature).Recv.Type, m.Recv) {
 					// preserve Object identity for non-inherited methods so that fluxObjs works
@@ -390,6 +397,51 @@ func isFluxObj(obj types.Object) bool {
 	return fluxObjs[obj]
 }
 
+// isEditable reports whether obj was created in Flux (or is a field of a Flux type) or may be imported into Flux:  a hand-written func or (non-inherited) method in a package under GOPATH, or a type whose methods might be.
+func isEditable(obj types.Object) bool {
+	if fluxObjs[obj] {
+		return true
+	}
+	switch obj := obj.(type) {
+	case field:
+		t, _ := indirect(obj.recv)
+		n, ok := t.(*types.Named)
+		return ok && fluxObjs[n.Obj]
+	case *types.Func:
+		if recv := obj.Type.(*types.Signature).Recv; recv != nil {
+			t, _ := indirect(recv.Type)
+			n, ok := t.(*types.Named)
+			if !ok {
+				return false
+			}
+			declared := false
+			for _, m := range n.Methods {
+				declared = declared || m == obj
+			}
+			if !declared {
+				return false
+			}
+		}
+	case *types.TypeName:
+	default:
+		return false
+	}
+	p := obj.GetPkg()
+	return p != nil && inGopath(p.Path)
+}
+
+var gopathPkgs = map[string]bool{}
+
+func inGopath(importPath string) bool {
+	in, ok := gopathPkgs[importPath]
+	if !ok {
+		p, err := build.Import(importPath, "", build.FindOnly)
+		in = err == nil && !p.Goroot
+		gopathPkgs[importPath] = in
+	}
+	return in
+}
+
 func isType(obj types.Object) bool {
 	_, ok := obj.(*types.TypeName)
 	return ok
@@ -499,8 +551,11 @@ func (b *browser) KeyPress(event KeyEvent) {
 			Show(b.pkgName)
 			b.pkgName.Accept = func(name string) {
 				if pkg.pkgName != name {
-					pkg.pkgName = name
-					savePackageName(pkg.importPath, name)
+					if err := savePackageName(pkg.importPath, name); err != nil {
+						fmt.Printf("error renaming package %s:\n%s\n", pkg.importPath, err)
+					} else {
+						pkg.pkgName = name
+					}
 				}
 				b.refresh()
 				SetKeyFocus(b)
@@ -516,21 +571,9 @@ func (b *browser) KeyPress(event KeyEvent) {
 			b.newObj = obj
 			b.oldName = obj.GetName()
 			if p, ok := obj.(*pkgObject); ok {
-				delete(pkgs, p.importPath)
 				delete(pkgObjects, p.importPath)
 			} else {
-				if objs := obj.GetPkg().Scope().Objects; objs[obj.GetName()] == obj {
-					delete(objs, obj.GetName())
-				} else {
-					t, _ := indirect(obj.(*types.Func).Type.(*types.Signature).Recv.Type)
-					n := t.(*types.Named)
-					for i, f2 := range n.Methods {
-						if f2 == obj {
-							n.Methods = append(n.Methods[:i], n.Methods[i+1:]...)
-							break
-						}
-					}
-				}
+				removeObj(obj)
 			}
 			b.text.SetText(obj.GetName())
 			return
@@ -542,7 +585,7 @@ func (b *browser) KeyPress(event KeyEvent) {
 			}
 			b.newObj = nil
 			if p, ok := obj.(*pkgObject); ok {
-				oldImportPath := p.importPath
+				oldSrcDir, oldImportPath := p.srcDir, p.importPath
 				if len(b.path) > 0 {
 					parent := b.path[0].(*pkgObject)
 					p.srcDir = parent.srcDir
@@ -555,8 +598,11 @@ func (b *browser) KeyPress(event KeyEvent) {
 				pkgObjects[p.importPath] = p
 				if b.oldName != "" {
 					b.oldName = ""
-					if err := refactor.MovePackage(oldImportPath, p.importPath); err != nil {
+					if err := movePackage(oldImportPath, p.importPath); err != nil {
 						fmt.Printf("error moving package %s: %s\n", oldImportPath, err)
+						delete(pkgObjects, p.importPath)
+						p.name, p.srcDir, p.importPath = path.Base(oldImportPath), oldSrcDir, oldImportPath
+						pkgObjects[p.importPath] = p
 					}
 					b.clearText()
 					return
@@ -569,48 +615,18 @@ func (b *browser) KeyPress(event KeyEvent) {
 					return
 				}
 			} else {
-				if isMethod(obj) {
-					recv := b.path[0].(*types.TypeName).Type.(*types.Named)
-					recv.Methods = append(recv.Methods, obj.(*types.Func))
-				} else {
-					pkgs[b.path[0].(*pkgObject).importPath].Scope().Insert(obj)
-				}
 				if b.oldName != "" {
-					newName := obj.GetName()
+					name := obj.GetName()
 					setObjectName(obj, b.oldName)
-					oldPaths := []string{fluxPath(obj)}
-					if t, ok := obj.(*types.TypeName); ok {
-						for _, m := range t.Type.(*types.Named).Methods {
-							oldPaths = append(oldPaths, fluxPath(m))
-						}
-					}
-					recv := ""
-					if isMethod(obj) {
-						t, _ := indirect(obj.(*types.Func).Type.(*types.Signature).Recv.Type)
-						recv = t.(*types.Named).Obj.Name
-					}
-					if err := refactor.Rename(obj.GetPkg().Path, recv, b.oldName, newName); err != nil {
-						fmt.Printf("error renaming %v to %v: %v\n", b.oldName, newName, err)
-						b.oldName = ""
-						b.clearText()
-						return
-					}
-					setObjectName(obj, newName)
-					newPaths := []string{fluxPath(obj)}
-					if t, ok := obj.(*types.TypeName); ok {
-						for _, m := range t.Type.(*types.Named).Methods {
-							newPaths = append(newPaths, fluxPath(m))
-						}
-					}
-					for i := range oldPaths {
-						if err := os.Rename(oldPaths[i], newPaths[i]); err != nil {
-							fmt.Println("error renaming files: ", err)
-						}
-					}
+					insertObj(obj)
 					b.oldName = ""
+					if err := renameObj(obj, name); err != nil {
+						fmt.Printf("error renaming %s to %s:\n%s\n", obj.GetName(), name, err)
+					}
 					b.clearText()
 					return
 				}
+				insertObj(obj)
 			}
 			b.makeCurrent(obj)
 		}
@@ -657,11 +673,10 @@ func (b *browser) KeyPress(event KeyEvent) {
 			if b.oldName != "" {
 				setObjectName(b.newObj, b.oldName)
 				b.oldName = ""
-				if isMethod(b.newObj) {
-					recv := b.path[0].(*types.TypeName).Type.(*types.Named)
-					recv.Methods = append(recv.Methods, b.newObj.(*types.Func))
+				if p, ok := b.newObj.(*pkgObject); ok {
+					pkgObjects[p.importPath] = p
 				} else {
-					pkgs[b.path[0].(*pkgObject).importPath].Scope().Insert(b.newObj)
+					insertObj(b.newObj)
 				}
 			} else if b.i < len(b.objs)-1 {
 				b.i++
@@ -758,7 +773,7 @@ func (b *browser) unique(name string) bool {
 		t := t.Type.(*types.Named)
 		if t, ok := t.UnderlyingT.(*types.Struct); ok {
 			for _, f := range t.Fields {
-				if f.Name == name {
+				if f2, ok := b.newObj.(field); f.Name == name && !(ok && f2.Var == f) {
 					return false
 				}
 			}
@@ -812,6 +827,30 @@ func deleteObj(obj types.Object) bool {
 	if trash.Trash(fluxPath(obj)) != nil {
 		return false
 	}
+	removeObj(obj)
+	delete(fluxObjs, obj)
+	return true
+}
+
+// insertObj adds obj to its receiver's methods, if it is a method, or else to its package's scope.  Fields are left to their struct.
+func insertObj(obj types.Object) {
+	if _, ok := obj.(field); ok {
+		return
+	}
+	if isMethod(obj) {
+		t, _ := indirect(obj.(*types.Func).Type.(*types.Signature).Recv.Type)
+		n := t.(*types.Named)
+		n.Methods = append(n.Methods, obj.(*types.Func))
+	} else {
+		obj.GetPkg().Scope().Insert(obj)
+	}
+}
+
+// removeObj undoes insertObj.
+func removeObj(obj types.Object) {
+	if _, ok := obj.(field); ok {
+		return
+	}
 	if objs := obj.GetPkg().Scope().Objects; objs[obj.GetName()] == obj {
 		delete(objs, obj.GetName())
 	} else {
@@ -824,8 +863,6 @@ func deleteObj(obj types.Object) bool {
 			}
 		}
 	}
-	delete(fluxObjs, obj)
-	return true
 }
 
 func (b *browser) Paint() {
@@ -909,6 +946,8 @@ func setObjectName(obj types.Object, name string) {
 		obj.Name = name
 	case *types.Const:
 		obj.Name = name
+	case field:
+		obj.Name = name
 	}
 }
 
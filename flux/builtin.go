@@ -59,6 +59,7 @@ func (n *appendNode) KeyPress(event KeyEvent) {
 			n.removePortBase(ins[1])
 		}
 		SetKeyFocus(n.newInput(newVar("", t.Elem)))
+		recordEdit(n)
 	} else if ok && event.Key == KeyPeriod && event.Ctrl {
 		if n.ellipsis() {
 			n.removePortBase(ins[1])
@@ -70,6 +71,7 @@ func (n *appendNode) KeyPress(event KeyEvent) {
 			p.valView.setEllipsis()
 			SetKeyFocus(p)
 		}
+		recordEdit(n)
 	} else {
 		n.ViewBase.KeyPress(event)
 	}
@@ -119,6 +119,7 @@ func (n *callNode) KeyPress(event KeyEvent) {
 				n.removePortBase(ins[i])
 			}
 			SetKeyFocus(n.newInput(newVar(v.Name, v.Type.(*types.Slice).Elem)))
+			recordEdit(n)
 		} else if event.Key == KeyPeriod && event.Ctrl {
 			if n.ellipsis() {
 				n.removePortBase(ins[i])
@@ -130,6 +131,7 @@ func (n *callNode) KeyPress(event KeyEvent) {
 				in.valView.setEllipsis()
 				SetKeyFocus(in)
 			}
+			recordEdit(n)
 		} else {
 			n.ViewBase.KeyPress(event)
 		}
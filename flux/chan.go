@@ -87,6 +87,7 @@ func (n *chanNode) KeyPress(event KeyEvent) {
 			n.send = !n.send
 			n.connsChanged()
 			SetKeyFocus(n)
+			recordEdit(n)
 		}
 	} else {
 		n.nodeBase.KeyPress(event)
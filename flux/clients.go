@@ -0,0 +1,774 @@
+// Copyright 2014 Gordon Klaus. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"code.google.com/p/gordon-go/flux/go/types"
+	"code.google.com/p/gordon-go/refactor"
+	. "code.google.com/p/gordon-go/flux/gui"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// When the signature of a func or the field names of a struct type change, the code that depends on them (its clients) is updated to match:  calls in Flux funcs are rewired, and calls and field references in hand-written Go are rewritten.  Clients are sought in the changed package and in the packages under GOPATH that import it.  They are read against the old declaration, which is temporarily reinstated for the purpose.  Sites that can't be updated automatically (e.g., a Go call whose results are used in an expression) are reported, to be fixed by hand.
+
+// A change is a change to the declaration of obj since it was last saved.
+type change struct {
+	obj types.Object
+
+	// for a func, the old and new signatures and the index in the old signature of each new param and result, or -1 if it is new
+	old, new        *types.Signature
+	params, results []int
+
+	// for a struct type, the old and new names of renamed fields
+	names map[*types.Var][2]string
+}
+
+// A savedSig is a func's signature as it was last saved.  The vars are kept along with a copy so that they can be followed through edits, which modify them in place.
+type savedSig struct {
+	sig             *types.Signature
+	params, results []*types.Var
+}
+
+func saveSig(sig *types.Signature) savedSig {
+	return savedSig{copySignature(sig), append([]*types.Var(nil), sig.Params...), append([]*types.Var(nil), sig.Results...)}
+}
+
+// sigChange returns the change to the signature of f since it was last saved, or nil if there is none.
+func sigChange(f *funcNode) *change {
+	sig, saved := f.sig(), f.savedSig
+	if _, ok := f.obj.(*types.Func); !ok || saved.sig == nil {
+		return nil
+	}
+	c := &change{obj: f.obj, old: saved.sig, new: &types.Signature{Recv: sig.Recv, Params: sig.Params, Results: sig.Results, IsVariadic: sig.IsVariadic}}
+	c.params = varIndices(sig.Params, saved.params, saved.sig.Params)
+	c.results = varIndices(sig.Results, saved.results, saved.sig.Results)
+	if sig.IsVariadic != c.old.IsVariadic || changed(c.params, sig.Params, c.old.Params) || changed(c.results, sig.Results, c.old.Results) {
+		return c
+	}
+	return nil
+}
+
+// varIndices returns the index of each of vars among old, matching by identity or, failing that (e.g., after an undo, which copies vars), by name.
+func varIndices(vars, old, oldCopies []*types.Var) []int {
+	x := make([]int, len(vars))
+	used := map[int]bool{}
+	for i, v := range vars {
+		x[i] = -1
+		for j, o := range old {
+			if o == v {
+				x[i] = j
+				used[j] = true
+			}
+		}
+	}
+	for i, v := range vars {
+		if x[i] >= 0 || v.Name == "" || v.Name == "_" {
+			continue
+		}
+		for j, o := range oldCopies {
+			if !used[j] && o.Name == v.Name {
+				x[i] = j
+				used[j] = true
+				break
+			}
+		}
+	}
+	return x
+}
+
+func reordered(x []int, n int) bool {
+	if len(x) != n {
+		return true
+	}
+	for i, j := range x {
+		if j != i {
+			return true
+		}
+	}
+	return false
+}
+
+func changed(x []int, vars, old []*types.Var) bool {
+	if len(vars) != len(old) {
+		return true
+	}
+	for i, j := range x {
+		if j != i || !types.IsIdentical(vars[i].Type, old[j].Type) {
+			return true
+		}
+	}
+	return false
+}
+
+// fieldNames returns the names of the fields of t, if it is a struct.
+func fieldNames(t *types.Named) map[*types.Var]string {
+	names := map[*types.Var]string{}
+	if s, ok := t.UnderlyingT.(*types.Struct); ok {
+		for _, f := range s.Fields {
+			names[f] = f.Name
+		}
+	}
+	return names
+}
+
+// fieldChange returns the renaming of the fields of t since their names were recorded by fieldNames, or nil if there is none.
+func fieldChange(t *types.Named, names map[*types.Var]string) *change {
+	c := &change{obj: t.Obj, names: map[*types.Var][2]string{}}
+	if s, ok := t.UnderlyingT.(*types.Struct); ok {
+		for _, f := range s.Fields {
+			if old, ok := names[f]; ok && old != f.Name && !f.Anonymous {
+				c.names[f] = [2]string{old, f.Name}
+			}
+		}
+	}
+	if len(c.names) == 0 {
+		return nil
+	}
+	return c
+}
+
+// declare declares c.obj as it was before the change if old is true, and as it is after if not.
+func (c *change) declare(old bool) {
+	if c.old != nil {
+		sig, d := c.obj.GetType().(*types.Signature), c.new
+		if old {
+			d = c.old
+		}
+		sig.Params, sig.Results, sig.IsVariadic = d.Params, d.Results, d.IsVariadic
+	}
+	for f, names := range c.names {
+		f.Name = names[1]
+		if old {
+			f.Name = names[0]
+		}
+	}
+}
+
+func (c *change) String() string {
+	if c.old != nil {
+		return "the callers of " + objName(c.obj)
+	}
+	return "the users of the fields of " + objName(c.obj)
+}
+
+// A client is a Flux func or a Go file that depends on a changed declaration.
+type client struct {
+	name   string
+	sites  int
+	manual []string // sites to be fixed by hand
+	update func() error
+}
+
+// findClients returns the clients of c.obj, excluding funcs that are open for editing; see updateOpen.
+func findClients(c *change) (clients []*client) {
+	c.declare(true)
+	defer c.declare(false)
+	for _, path := range importers(c.obj.GetPkg().Path) {
+		pkg, err := getPackage(path)
+		if err != nil {
+			fmt.Printf("error loading %s: %s\n", path, err)
+			continue
+		}
+		for _, obj := range pkgFluxObjs(pkg) {
+			if _, ok := obj.(*types.TypeName); ok || obj == c.obj || openFuncs[obj] != nil {
+				continue
+			}
+			if src, err := ioutil.ReadFile(fluxPath(obj)); err != nil || !bytes.Contains(src, []byte(c.obj.GetName())) && !c.mentions(src) {
+				continue
+			}
+			f, err := readFuncObj(obj)
+			if err != nil {
+				fmt.Println(err)
+				continue
+			}
+			if n := len(c.nodes(f)); n > 0 {
+				clients = append(clients, &client{objName(obj), n, nil, func() error {
+					for _, n := range c.nodes(f) {
+						c.fix(n)
+					}
+					return saveFunc(f)
+				}})
+			}
+		}
+		clients = append(clients, c.goClients(path)...)
+	}
+	return
+}
+
+// mentions reports whether src mentions the old name of a renamed field.
+func (c *change) mentions(src []byte) bool {
+	for _, names := range c.names {
+		if bytes.Contains(src, []byte(names[0])) {
+			return true
+		}
+	}
+	return false
+}
+
+// importers returns the import paths of the packages under GOPATH that import the package at path, preceded by path itself.
+func importers(path string) []string {
+	paths := []string{path}
+	for _, gopath := range filepath.SplitList(build.Default.GOPATH) {
+		src := filepath.Join(gopath, "src")
+		filepath.Walk(src, func(dir string, info os.FileInfo, err error) error {
+			if err != nil || !info.IsDir() {
+				return nil
+			}
+			if name := info.Name(); dir != src && (name[0] == '.' || name[0] == '_' || name == "testdata") {
+				return filepath.SkipDir
+			}
+			if p, err := build.ImportDir(dir, 0); err == nil {
+				for _, imp := range p.Imports {
+					if imp == path && p.ImportPath != path {
+						paths = append(paths, p.ImportPath)
+					}
+				}
+			}
+			return nil
+		})
+	}
+	return paths
+}
+
+// nodes returns the nodes of f that depend on c.obj.
+func (c *change) nodes(f *funcNode) (nodes []node) {
+	f.funcblk.walk(nil, func(n node) {
+		switch n := n.(type) {
+		case *callNode:
+			if c.old != nil && c.calls(n) {
+				nodes = append(nodes, n)
+			}
+		case *valueNode:
+			if f, ok := n.obj.(field); ok && c.names[f.Var] != [2]string{} {
+				nodes = append(nodes, n)
+			}
+		case *compositeLiteralNode:
+			for _, p := range n.ins {
+				if c.names[p.obj] != [2]string{} {
+					nodes = append(nodes, n)
+					break
+				}
+			}
+		}
+	}, nil)
+	return
+}
+
+// calls reports whether n calls c.obj.  The reader makes a new object for each method call, so methods are matched by lookup.
+func (c *change) calls(n *callNode) bool {
+	if n.obj == c.obj {
+		return true
+	}
+	if !isMethod(n.obj) || n.obj.GetName() != c.obj.GetName() {
+		return false
+	}
+	recv := n.obj.GetType().(*types.Signature).Recv.Type
+	m, _, _ := types.LookupFieldOrMethod(recv, n.obj.GetPkg(), n.obj.GetName())
+	return m == c.obj
+}
+
+// fix updates n, which was read against the old declaration of c.obj, to the new one.
+func (c *change) fix(n node) {
+	switch n := n.(type) {
+	case *callNode:
+		c.rewire(n)
+	case *valueNode:
+		n.text.SetText("." + n.obj.GetName())
+		n.reform()
+	case *compositeLiteralNode:
+		for _, p := range n.ins {
+			p.valView.name.SetText(p.obj.Name)
+		}
+	}
+}
+
+// rewire moves the connections of a call from the ports of the old signature to those of the new one.  Connections to removed ports are deleted.
+func (c *change) rewire(n *callNode) {
+	if n.obj != c.obj {
+		sig := n.obj.GetType().(*types.Signature)
+		sig.Params, sig.Results, sig.IsVariadic = c.new.Params, c.new.Results, c.new.IsVariadic
+	}
+	oldIns, oldOuts := ins(n), outs(n)
+	if c.new.Recv != nil {
+		oldIns = oldIns[1:]
+	}
+	fixed := len(c.old.Params)
+	if c.old.IsVariadic {
+		fixed--
+	}
+	move := func(c *connection, p *port) {
+		if p.out {
+			c.setSrc(p)
+		} else {
+			c.setDst(p)
+		}
+		c.bad = !c.connectable(c.src, c.dst)
+	}
+	for i, v := range c.new.Params {
+		j := c.params[i]
+		if c.new.IsVariadic && i == len(c.new.Params)-1 {
+			if j == len(c.old.Params)-1 && c.old.IsVariadic && fixed <= len(oldIns) {
+				for _, p := range oldIns[fixed:] {
+					var in *port
+					if p.obj == c.old.Params[j] || p.valView.ellipsis {
+						in = n.newInput(v)
+						in.valView.setEllipsis()
+					} else {
+						in = n.newInput(newVar(v.Name, v.Type.(*types.Slice).Elem))
+					}
+					for _, conn := range append([]*connection(nil), p.conns...) {
+						move(conn, in)
+					}
+				}
+			}
+			break
+		}
+		in := n.newInput(v)
+		if j >= 0 && j < fixed && j < len(oldIns) {
+			for _, conn := range append([]*connection(nil), oldIns[j].conns...) {
+				move(conn, in)
+			}
+		}
+	}
+	for i, v := range c.new.Results {
+		out := n.newOutput(v)
+		if j := c.results[i]; j >= 0 && j < len(oldOuts) {
+			for _, conn := range append([]*connection(nil), oldOuts[j].conns...) {
+				move(conn, out)
+			}
+		}
+	}
+	for _, p := range append(oldIns, oldOuts...) {
+		n.removePortBase(p)
+	}
+}
+
+// goClients returns the hand-written files of the package at path that depend on c.obj, with their updates already made to the syntax trees but not yet written.
+func (c *change) goClients(path string) (clients []*client) {
+	buildPkg, err := build.Import(path, "", 0)
+	if err != nil {
+		return nil
+	}
+	fset := token.NewFileSet()
+	files := []*ast.File{}
+	for _, name := range append(buildPkg.GoFiles, buildPkg.CgoFiles...) {
+		file, err := parser.ParseFile(fset, filepath.Join(buildPkg.Dir, name), nil, parser.ParseComments)
+		if err != nil {
+			fmt.Println(err)
+			return nil
+		}
+		files = append(files, file)
+	}
+	info := &types.Info{Types: map[ast.Expr]types.Type{}, Objects: map[*ast.Ident]types.Object{}, Selections: map[*ast.SelectorExpr]*types.Selection{}}
+	cfg := types.Config{FakeImportC: true, Import: srcImport, Error: func(error) {}}
+	cfg.Check(path, fset, files, info)
+
+	for _, file := range files {
+		name := fset.Position(file.Package).Filename
+		if strings.HasSuffix(name, ".flux.go") {
+			continue
+		}
+		cl := &client{name: path + "/" + filepath.Base(name)}
+		report := func(pos token.Pos, problem string) {
+			p := fset.Position(pos)
+			cl.manual = append(cl.manual, fmt.Sprintf("%s:%d: %s", cl.name, p.Line, problem))
+		}
+		if c.old != nil {
+			c.rewriteCalls(file, info, path, report, &cl.sites)
+		} else {
+			c.renameFields(file, info, &cl.sites)
+		}
+		if cl.sites == 0 && len(cl.manual) == 0 {
+			continue
+		}
+		file := file
+		cl.update = func() error {
+			buf := &bytes.Buffer{}
+			if err := format.Node(buf, fset, file); err != nil {
+				return err
+			}
+			return refactor.WriteFile(name, buf.Bytes())
+		}
+		clients = append(clients, cl)
+	}
+	return
+}
+
+// rewriteCalls rewrites the calls to c.obj in file to match its new signature, reporting those that it can't.  Calls need no rewriting if only the types of params and results changed.
+func (c *change) rewriteCalls(file *ast.File, info *types.Info, pkg string, report func(token.Pos, string), sites *int) {
+	if c.new.IsVariadic == c.old.IsVariadic && !reordered(c.params, len(c.old.Params)) && !reordered(c.results, len(c.old.Results)) {
+		return
+	}
+	calls := map[ast.Expr]*ast.CallExpr{}
+	stmts := map[*ast.CallExpr]ast.Stmt{}
+	decls := map[*ast.Ident]bool{}
+	ast.Inspect(file, func(x ast.Node) bool {
+		switch x := x.(type) {
+		case *ast.CallExpr:
+			calls[x.Fun] = x
+			if s, ok := x.Fun.(*ast.SelectorExpr); ok {
+				calls[s.Sel] = x
+			}
+		case *ast.FuncDecl:
+			decls[x.Name] = true
+		case *ast.AssignStmt:
+			if call, ok := x.Rhs[0].(*ast.CallExpr); ok && len(x.Rhs) == 1 {
+				stmts[call] = x
+			}
+		case *ast.ExprStmt:
+			if call, ok := x.X.(*ast.CallExpr); ok {
+				stmts[call] = x
+			}
+		case *ast.GoStmt:
+			stmts[x.Call] = x
+		case *ast.DeferStmt:
+			stmts[x.Call] = x
+		}
+		return true
+	})
+	for id, obj := range info.Objects {
+		if id.Pos() < file.Pos() || id.Pos() >= file.End() || decls[id] || !c.sameFunc(obj) {
+			continue
+		}
+		call := calls[id]
+		if call == nil {
+			report(id.Pos(), "refers to "+c.obj.GetName()+" without calling it")
+			continue
+		}
+		if problem := c.rewriteCall(call, stmts[call], file, pkg); problem != "" {
+			report(call.Pos(), problem)
+			continue
+		}
+		*sites++
+	}
+}
+
+// rewriteCall rewrites call, the whole of stmt if stmt is non-nil, or returns the reason it can't.
+func (c *change) rewriteCall(call *ast.CallExpr, stmt ast.Stmt, file *ast.File, pkg string) string {
+	var lhs []ast.Expr
+	if reordered(c.results, len(c.old.Results)) {
+		switch s := stmt.(type) {
+		case *ast.ExprStmt, *ast.GoStmt, *ast.DeferStmt:
+		case *ast.AssignStmt:
+			if len(c.new.Results) == 0 {
+				return "assigns results that " + c.obj.GetName() + " no longer has"
+			}
+			kept := map[int]bool{}
+			for _, j := range c.results {
+				if j >= 0 {
+					lhs = append(lhs, s.Lhs[j])
+					kept[j] = true
+				} else {
+					lhs = append(lhs, ast.NewIdent("_"))
+				}
+			}
+			for j, x := range s.Lhs {
+				if id, ok := x.(*ast.Ident); !kept[j] && !(ok && id.Name == "_") {
+					return "uses a result that was removed"
+				}
+			}
+		default:
+			return "uses the results of " + c.obj.GetName() + " in an expression"
+		}
+	}
+
+	var args []ast.Expr
+	if c.new.IsVariadic != c.old.IsVariadic || reordered(c.params, len(c.old.Params)) {
+		fixed := len(c.old.Params)
+		if c.old.IsVariadic {
+			fixed--
+		}
+		if len(call.Args) < fixed || !c.old.IsVariadic && len(call.Args) != fixed {
+			return "passes the results of a call as arguments"
+		}
+		kept := map[int]bool{}
+		for i, v := range c.new.Params {
+			j := c.params[i]
+			if c.new.IsVariadic && i == len(c.new.Params)-1 {
+				if j == len(c.old.Params)-1 && c.old.IsVariadic {
+					args = append(args, call.Args[fixed:]...)
+					kept[j] = true
+				} else if j >= 0 || call.Ellipsis.IsValid() {
+					return "passes variadic arguments that changed"
+				}
+				break
+			}
+			switch {
+			case j >= fixed:
+				return "passes variadic arguments that changed"
+			case j >= 0:
+				args = append(args, call.Args[j])
+				kept[j] = true
+			default:
+				zero, ok := zeroValue(v.Type, file, pkg)
+				if !ok {
+					return "needs a value for the new parameter " + v.Name
+				}
+				x, _ := parser.ParseExpr(zero)
+				args = append(args, x)
+			}
+		}
+		for j, x := range call.Args {
+			if j >= fixed && kept[len(c.old.Params)-1] {
+				break
+			}
+			if !kept[j] && sideEffects(x) {
+				return "passes an argument with side effects to a removed parameter"
+			}
+		}
+		if !c.new.IsVariadic || !kept[len(c.old.Params)-1] {
+			call.Ellipsis = token.NoPos
+		}
+	} else {
+		args = call.Args
+	}
+
+	call.Args = args
+	if s, ok := stmt.(*ast.AssignStmt); ok && lhs != nil {
+		s.Lhs = lhs
+		if s.Tok == token.DEFINE {
+			s.Tok = token.ASSIGN
+			for _, x := range lhs {
+				if id, ok := x.(*ast.Ident); ok && id.Name != "_" {
+					s.Tok = token.DEFINE
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// sameFunc reports whether obj is c.obj.  The same package type-checked anew has new objects, so funcs are compared by name.
+func (c *change) sameFunc(obj types.Object) bool {
+	f, ok := obj.(*types.Func)
+	if !ok || f.Name != c.obj.GetName() || f.Pkg == nil || f.Pkg.Path != c.obj.GetPkg().Path || isMethod(f) != isMethod(c.obj) {
+		return false
+	}
+	if !isMethod(f) {
+		return true
+	}
+	t, _ := indirect(f.Type.(*types.Signature).Recv.Type)
+	recv, _ := indirect(c.obj.GetType().(*types.Signature).Recv.Type)
+	return sameType(t, recv.(*types.Named).Obj)
+}
+
+func sideEffects(x ast.Expr) (effects bool) {
+	ast.Inspect(x, func(x ast.Node) bool {
+		switch x := x.(type) {
+		case *ast.CallExpr:
+			effects = true
+		case *ast.UnaryExpr:
+			effects = effects || x.Op == token.ARROW
+		}
+		return !effects
+	})
+	return
+}
+
+// zeroValue returns an expression for the zero value of t in file, which belongs to the package at path, or false if writing it would need a new import.
+func zeroValue(t types.Type, file *ast.File, path string) (string, bool) {
+	switch u := underlying(t).(type) {
+	case *types.Basic:
+		switch {
+		case u.Info&types.IsBoolean != 0:
+			return "false", true
+		case u.Info&types.IsNumeric != 0:
+			return "0", true
+		case u.Info&types.IsString != 0:
+			return `""`, true
+		}
+		return "nil", true
+	case *types.Pointer, *types.Slice, *types.Map, *types.Chan, *types.Signature, *types.Interface:
+		return "nil", true
+	case *types.Struct, *types.Array:
+		t, ok := t.(*types.Named)
+		if !ok {
+			return "", false
+		}
+		if t.Obj.Pkg == nil || t.Obj.Pkg.Path == path {
+			return t.Obj.Name + "{}", true
+		}
+		for _, imp := range file.Imports {
+			if imp.Path.Value != `"`+t.Obj.Pkg.Path+`"` {
+				continue
+			}
+			switch {
+			case imp.Name == nil:
+				return t.Obj.Pkg.Name + "." + t.Obj.Name + "{}", true
+			case imp.Name.Name == ".":
+				return t.Obj.Name + "{}", true
+			case imp.Name.Name != "_":
+				return imp.Name.Name + "." + t.Obj.Name + "{}", true
+			}
+		}
+	}
+	return "", false
+}
+
+// renameFields renames the references in file to the renamed fields of c.obj.
+func (c *change) renameFields(file *ast.File, info *types.Info, sites *int) {
+	t := c.obj.(*types.TypeName)
+	rename := func(id *ast.Ident) {
+		for _, names := range c.names {
+			if id.Name == names[0] {
+				id.Name = names[1]
+				*sites++
+				return
+			}
+		}
+	}
+	ast.Inspect(file, func(x ast.Node) bool {
+		switch x := x.(type) {
+		case *ast.SelectorExpr:
+			if s := info.Selections[x]; s != nil && s.Kind == types.FieldVal && sameType(fieldOwner(s.Recv, s.Index), t) {
+				rename(x.Sel)
+			}
+		case *ast.CompositeLit:
+			if lt, _ := indirect(info.Types[x]); sameType(lt, t) {
+				for _, elt := range x.Elts {
+					if kv, ok := elt.(*ast.KeyValueExpr); ok {
+						if id, ok := kv.Key.(*ast.Ident); ok {
+							rename(id)
+						}
+					}
+				}
+			}
+		}
+		return true
+	})
+}
+
+// fieldOwner returns the type declaring the field reached from recv by index.
+func fieldOwner(recv types.Type, index []int) types.Type {
+	t, _ := indirect(recv)
+	for _, i := range index[:len(index)-1] {
+		s, ok := underlying(t).(*types.Struct)
+		if !ok {
+			return nil
+		}
+		t, _ = indirect(s.Fields[i].Type)
+	}
+	return t
+}
+
+// sameType reports whether t is the named type of obj.  The same package type-checked anew has new objects, so they are compared by name.
+func sameType(t types.Type, obj *types.TypeName) bool {
+	n, ok := t.(*types.Named)
+	return ok && n.Obj.Name == obj.Name && n.Obj.Pkg != nil && n.Obj.Pkg.Path == obj.Pkg.Path
+}
+
+// openFuncs are the funcs open in windows.
+var openFuncs = map[types.Object]*funcNode{}
+
+// updateOpen updates the open funcs that depend on c.obj.  Those without unsaved changes are then saved.  Funcs in other windows are updated on their windows' goroutines; v is a view in the current window.
+func (c *change) updateOpen(v View) {
+	for _, f := range openFuncs {
+		f := f
+		update := func() {
+			nodes := c.nodes(f)
+			if len(nodes) == 0 {
+				return
+			}
+			dirty := f.history.dirty()
+			focus := KeyFocus(f)
+			for _, n := range nodes {
+				c.fix(n)
+			}
+			if focus != nil && Parent(focus) != nil {
+				SetKeyFocus(focus) // removing ports moves the focus
+			}
+			f.history.record()
+			if !dirty && f.obj != c.obj {
+				saveFunc(f)
+			}
+		}
+		if window(f) == window(v) {
+			update()
+		} else {
+			go Do(f, update)
+		}
+	}
+}
+
+// updateClients offers to update the clients of c.obj before calling save, which saves c.obj.  If there are clients, the user is shown them and asked whether to update them; done is called unless the user cancels (if cancel is true).
+func updateClients(c *change, v View, cancel bool, save func() error, done func()) {
+	if c == nil {
+		if save() == nil {
+			done()
+		}
+		return
+	}
+	clients := findClients(c)
+	open := 0
+	for _, f := range openFuncs {
+		if len(c.nodes(f)) > 0 && f.obj != c.obj {
+			open++
+		}
+	}
+	if len(clients) == 0 && open == 0 {
+		if save() == nil {
+			done()
+		}
+		return
+	}
+	q := fmt.Sprintf("Update %s?", c)
+	for _, cl := range clients {
+		if cl.sites > 0 {
+			q += fmt.Sprintf("\n    %s (%d)", cl.name, cl.sites)
+		}
+	}
+	if open > 0 {
+		q += fmt.Sprintf("\n    %d open func(s)", open)
+	}
+	manual := []string{}
+	for _, cl := range clients {
+		manual = append(manual, cl.manual...)
+	}
+	if len(manual) > 0 {
+		q += "\nTo be fixed by hand:"
+		for _, m := range manual {
+			q += "\n    " + m
+		}
+	}
+	answers := []string{"Update", "Don't Update"}
+	if cancel {
+		answers = append(answers, "Cancel")
+	}
+	newPrompt(q, answers...).show(window(v), func(answer int) {
+		switch answer {
+		case 0:
+			c.updateOpen(v)
+			if save() != nil {
+				return
+			}
+			for _, cl := range clients {
+				if cl.sites == 0 {
+					continue
+				}
+				if err := cl.update(); err != nil {
+					fmt.Printf("error updating %s: %s\n", cl.name, err)
+				}
+			}
+			for _, m := range manual {
+				fmt.Println(m)
+			}
+			done()
+		case 1:
+			if save() == nil {
+				done()
+			}
+		}
+	})
+}
@@ -0,0 +1,298 @@
+// Copyright 2014 Gordon Klaus. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	. "code.google.com/p/gordon-go/flux/gui"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io"
+)
+
+// The clipboard holds the nodes last cut or copied, as the source of a func whose body is those nodes.  It is shared by all func editors, so nodes can be pasted into another func, even one in another package.
+var clipboard []byte
+
+// selectedNodes returns the nodes that an edit of b applies to:  the selection, if the focused node is part of it; otherwise, the focused node.
+func (b *block) selectedNodes() map[node]bool {
+	n, ok := KeyFocus(b).(node)
+	if !ok || n.block() != b {
+		return nil
+	}
+	if _, ok := n.(*portsNode); ok {
+		return nil
+	}
+	if b.selection[n] {
+		return b.selection
+	}
+	return map[node]bool{n: true}
+}
+
+// selectNodes makes nodes the selection.  Only one block in a func has a selection.
+func (b *block) selectNodes(nodes map[node]bool) {
+	b.outermost().walk(func(b *block) {
+		if len(b.selection) > 0 {
+			b.selection = map[node]bool{}
+			Repaint(b)
+		}
+	}, nil, nil)
+	b.selection = nodes
+	Repaint(b)
+}
+
+// extendSelection adds to the selection the nearest node in the direction of dirKey from the focused node n, and focuses it.
+func (b *block) extendSelection(n node, dirKey int) {
+	if !b.selection[n] {
+		b.selectNodes(map[node]bool{n: true})
+	}
+	views := []View{}
+	for m := range b.nodes {
+		if _, ok := m.(*portsNode); !ok && m != n {
+			views = append(views, m)
+		}
+	}
+	if next, ok := nearestView(b, views, Map(ZP, n, b), dirKey).(node); ok {
+		b.selection[next] = true
+		SetKeyFocus(next)
+	}
+	Repaint(b)
+}
+
+// selecting reports whether event extends the selection (Shift-arrow), so that nodes which handle the arrow keys can pass it to their block.
+func selecting(event KeyEvent) bool {
+	switch event.Key {
+	case KeyLeft, KeyRight, KeyUp, KeyDown:
+		return event.Shift && !event.Alt
+	}
+	return false
+}
+
+// Mouse drags a band with the left button to select the nodes it touches.  Other buttons pan the view.
+func (b *block) Mouse(m MouseEvent) {
+	if m.Button != 0 {
+		MouseParent(b, m)
+		return
+	}
+	switch {
+	case m.Press:
+		b.band = Rectangle{m.Pos, m.Pos}
+		b.banding = true
+		b.selectNodes(map[node]bool{})
+	case m.Drag:
+		b.band.Max = m.Pos
+		Repaint(b)
+	case m.Release:
+		b.banding = false
+		sel := map[node]bool{}
+		var foc node
+		for n := range b.nodes {
+			if _, ok := n.(*portsNode); !ok && RectInParent(n).Overlaps(b.band.Canon()) {
+				sel[n] = true
+				foc = n
+			}
+		}
+		b.selectNodes(sel)
+		if foc != nil {
+			SetKeyFocus(foc)
+		}
+	}
+}
+
+// nodesSource returns the source of a func whose body is the nodes of b.  Connections to the rest of the func are cut:  a cut input is left unconnected, and a cut output is assigned to a var that is never used.  Every object is qualified by its package, so that the nodes can be pasted into another package.
+func nodesSource(b *block, nodes map[node]bool) []byte {
+	f := b.func_()
+	buf := &bytes.Buffer{}
+	w := newWriterTo(f.obj, struct {
+		*bytes.Buffer
+		io.Closer
+	}{buf, nil})
+	w.top, w.sel = b, nodes
+	w.pkgNames[w.pkg] = w.name(w.pkg.Name)
+	for p := range f.pkgRefs {
+		w.pkgNames[p] = w.name(p.Name)
+	}
+	w.importing(func() {
+		w.write("func _() {\n")
+		vars := map[*port]string{}
+		b.walk(nil, func(n node) {
+			if !w.inside(n) {
+				return
+			}
+			for _, c := range n.outConns() {
+				if _, ok := vars[c.dst]; ok || c.dst == nil || w.inside(c.dst.node) || c.dst.obj.Type == seqType {
+					continue
+				}
+				t := c.dst.obj.Type
+				if t == nil {
+					t = c.src.obj.Type
+				}
+				w.collectPkgs(t)
+				name := w.name("v")
+				w.write("\tvar %s %s\n", name, w.typ(t))
+				vars[c.dst] = name
+			}
+		}, nil)
+		w.block(b, vars)
+		w.write("}\n")
+	})
+	return buf.Bytes()
+}
+
+func (b *block) copyNodes(nodes map[node]bool) {
+	clipboard = nodesSource(b, nodes)
+}
+
+// paste reads the clipboard into b and selects the pasted nodes.  Types and package references are resolved anew in b's func.
+func (b *block) paste() {
+	if clipboard == nil {
+		return
+	}
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", clipboard, parser.ParseComments)
+	if err != nil {
+		fmt.Printf("error pasting: %s\n", err)
+		return
+	}
+	old := map[node]bool{}
+	for n := range b.nodes {
+		old[n] = true
+	}
+	r := newReader(fset, file, b.func_().pkg())
+	r.block(b, file.Decls[len(file.Decls)-1].(*ast.FuncDecl).Body.List)
+	r.removeDangling()
+
+	pasted := map[node]bool{}
+	var foc node
+	for n := range b.nodes {
+		if !old[n] {
+			pasted[n] = true
+			foc = n
+		}
+	}
+	b.selectNodes(pasted)
+	if foc != nil {
+		SetKeyFocus(foc)
+	}
+	recordEdit(b)
+}
+
+// newDropper returns a Mouser that, when n is dropped into another block, moves it there along with the rest of its selection.
+func newDropper(n node) Clicker {
+	return func(m MouseEvent) {
+		if m.Release {
+			drop(n)
+		}
+	}
+}
+
+func drop(n node) {
+	b := n.block()
+	if b == nil {
+		return
+	}
+	if _, ok := n.(*portsNode); ok {
+		return
+	}
+	nodes := map[node]bool{n: true}
+	if b.selection[n] {
+		for n := range b.selection {
+			nodes[n] = true
+		}
+	}
+
+	// the innermost block under n that isn't part of a moving node
+	top := b.outermost()
+	p := Map(Center(n), n, top)
+	var target *block
+	depth := -1
+	top.walk(func(b *block) {
+		d := 0
+		for b := b; b.outer() != nil; b = b.outer() {
+			if nodes[b.node] {
+				return
+			}
+			d++
+		}
+		if d > depth && Map(p, top, b).In(Rect(b)) {
+			target, depth = b, d
+		}
+	}, nil, nil)
+	if target != nil && target != b {
+		target.moveNodes(nodes)
+	}
+}
+
+// moveNodes moves nodes from another block into b.  Their connections follow them, except those that would no longer be valid, which are removed.
+func (b *block) moveNodes(nodes map[node]bool) {
+	for n := range nodes {
+		old := n.block()
+		c := Map(Center(n), n, b)
+		old.Remove(n)
+		delete(old.nodes, n)
+		delete(old.selection, n)
+		rearrange(old)
+		b.Add(n)
+		MoveCenter(n, c)
+		nodesAdded++
+		b.nodes[n] = nodesAdded
+		n.setBlock(b)
+		setArranged(n, b.childArranged)
+	}
+
+	conns := map[*connection]bool{}
+	for _, n := range b.allNodes() {
+		if nodes[b.find(n)] {
+			for _, c := range append(n.inConns(), n.outConns()...) {
+				conns[c] = true
+			}
+		}
+	}
+	for c := range conns {
+		if !c.feedback { // a feedback connection may no longer be in a loop
+			c.reblock()
+		}
+	}
+	for c := range conns {
+		if c.src != nil && c.dst != nil && c.connectable(c.src, c.dst) {
+			c.reblock()
+			c.reform()
+		} else {
+			c.blk.removeConn(c)
+		}
+	}
+	b.selectNodes(nodes)
+	rearrange(b)
+	recordEdit(b)
+}
+
+// setArranged tells the blocks of n to report their arrangement on a new channel, after n has moved to another block.
+func setArranged(n node, arranged blockchan) {
+	switch n := n.(type) {
+	case *ifNode:
+		n.arranged = arranged
+		for _, b := range n.blocks {
+			b.setArranged(arranged)
+		}
+	case *selectNode:
+		n.arranged = arranged
+		for _, c := range n.cases {
+			c.blk.setArranged(arranged)
+		}
+	case *loopNode:
+		n.loopblk.setArranged(arranged)
+	case *funcNode:
+		n.funcblk.setArranged(arranged)
+	}
+}
+
+func (b *block) setArranged(arranged blockchan) {
+	close(b.stop)
+	b.arrange = make(blockchan)
+	b.stop = make(stopchan)
+	go arrange(b.arrange, b.childArranged, arranged, b.stop)
+	rearrange(b)
+}
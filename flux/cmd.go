@@ -0,0 +1,320 @@
+// Copyright 2014 Gordon Klaus. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"code.google.com/p/gordon-go/flux/go/types"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const usage = `usage: flux [command [packages]]
+
+With no command, flux opens the editor.  The commands are:
+
+	check	report invalid nodes, ports, and connections and type-check the generated code
+	fmt	rewrite Flux files in canonical form and print the names of those that changed
+	list	print the Flux functions, methods, types, variables, and constants
+
+Packages are import paths or directories, optionally ending in "/..."; the default is the current directory.
+The exit status is 0 on success, 1 if problems were found, and 2 if the packages could not be loaded.
+`
+
+// command runs the command-line tool on args and returns its exit status.  It needs no window, so it may be built with -tags headless where OpenGL is unavailable.
+func command(args []string) int {
+	var run func(pkg *types.Package, objs []types.Object) bool
+	switch args[0] {
+	case "check":
+		run = checkPkg
+	case "fmt":
+		run = fmtPkg
+	case "list":
+		run = listPkg
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		return 2
+	}
+	paths, err := importPaths(args[1:])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	status := 0
+	for _, path := range paths {
+		pkg, err := getPackage(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error loading %s: %s\n", path, err)
+			return 2
+		}
+		if !run(pkg, pkgFluxObjs(pkg)) {
+			status = 1
+		}
+	}
+	return status
+}
+
+// importPaths resolves the package arguments to import paths.
+func importPaths(args []string) ([]string, error) {
+	if len(args) == 0 {
+		args = []string{"."}
+	}
+	paths := []string{}
+	for _, arg := range args {
+		all := false
+		if arg == "..." || strings.HasSuffix(arg, "/...") {
+			all = true
+			arg = strings.TrimSuffix(strings.TrimSuffix(arg, "..."), "/")
+			if arg == "" {
+				arg = "."
+			}
+		}
+		var p *build.Package
+		var err error
+		if build.IsLocalImport(arg) || filepath.IsAbs(arg) {
+			var dir string
+			if dir, err = filepath.Abs(arg); err != nil {
+				return nil, err
+			}
+			p, err = build.ImportDir(dir, build.FindOnly)
+		} else {
+			p, err = build.Import(arg, "", build.FindOnly)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if p.ImportPath == "" || build.IsLocalImport(p.ImportPath) {
+			return nil, fmt.Errorf("%s is not in GOPATH", arg)
+		}
+		if !all {
+			paths = append(paths, p.ImportPath)
+			continue
+		}
+		err = filepath.Walk(p.Dir, func(dir string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if !info.IsDir() {
+				return nil
+			}
+			if name := info.Name(); dir != p.Dir && (name[0] == '.' || name[0] == '_' || name == "testdata") {
+				return filepath.SkipDir
+			}
+			rel, _ := filepath.Rel(p.Dir, dir)
+			path := filepath.ToSlash(filepath.Join(p.ImportPath, rel))
+			if _, err := build.Import(path, "", 0); err == nil {
+				paths = append(paths, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return paths, nil
+}
+
+// pkgFluxObjs returns the Flux functions, methods, types, variables, and constants of pkg, ordered by name.
+func pkgFluxObjs(pkg *types.Package) []types.Object {
+	objs := []types.Object{}
+	for obj := range fluxObjs {
+		if obj != nil && obj.GetPkg() == pkg {
+			objs = append(objs, obj)
+		}
+	}
+	sort.Sort(objsByName(objs))
+	return objs
+}
+
+type objsByName []types.Object
+
+func (o objsByName) Len() int           { return len(o) }
+func (o objsByName) Less(i, j int) bool { return objName(o[i]) < objName(o[j]) }
+func (o objsByName) Swap(i, j int)      { o[i], o[j] = o[j], o[i] }
+
+// objName returns the qualified name of obj, including the receiver type name of a method.
+func objName(obj types.Object) string {
+	name := obj.GetName()
+	if isMethod(obj) {
+		t, _ := indirect(obj.GetType().(*types.Signature).Recv.Type)
+		name = t.(*types.Named).Obj.Name + "." + name
+	}
+	return obj.GetPkg().Path + "." + name
+}
+
+func listPkg(pkg *types.Package, objs []types.Object) bool {
+	for _, obj := range objs {
+		fmt.Println(objName(obj))
+	}
+	return true
+}
+
+func fmtPkg(pkg *types.Package, objs []types.Object) bool {
+	ok := true
+	for _, obj := range objs {
+		path := fluxPath(obj)
+		old, err := ioutil.ReadFile(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			ok = false
+			continue
+		}
+		switch obj := obj.(type) {
+		case *types.TypeName:
+			err = saveType(obj.Type.(*types.Named))
+		case *types.Func, *types.Var, *types.Const:
+			var f *funcNode
+			if f, err = readFuncObj(obj); err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %s\n", objName(obj), err)
+			} else {
+				err = saveFunc(f)
+			}
+		}
+		if err != nil {
+			ok = false
+			continue
+		}
+		if src, err := ioutil.ReadFile(path); err != nil || string(src) != string(old) {
+			fmt.Println(path)
+		}
+	}
+	return ok
+}
+
+func checkPkg(pkg *types.Package, objs []types.Object) bool {
+	ok := true
+	for _, obj := range objs {
+		problems := []string{}
+		switch obj := obj.(type) {
+		case *types.TypeName:
+			walkType(obj.Type.(*types.Named).UnderlyingT, func(t *types.Named) {
+				if unknown(t.Obj) {
+					problems = append(problems, "unknown type "+t.Obj.Name)
+				}
+			})
+		case *types.Func, *types.Var, *types.Const:
+			f, err := readFuncObj(obj)
+			if err != nil {
+				problems = append(problems, err.Error())
+				break
+			}
+			problems = graphProblems(f)
+		}
+		sort.Strings(problems)
+		for _, p := range problems {
+			fmt.Printf("%s: %s\n", objName(obj), p)
+			ok = false
+		}
+	}
+
+	// the graph may be valid while the code it was saved as is not, e.g. if a file was edited by hand
+	p, err := build.Import(pkg.Path, "", 0)
+	if err != nil {
+		fmt.Println(err)
+		return false
+	}
+	fset := token.NewFileSet()
+	files := []*ast.File{}
+	for _, name := range append(p.GoFiles, p.CgoFiles...) {
+		file, err := parser.ParseFile(fset, filepath.Join(p.Dir, name), nil, 0)
+		if err != nil {
+			fmt.Println(err)
+			return false
+		}
+		files = append(files, file)
+	}
+	cfg := types.Config{FakeImportC: true, Import: srcImport, Error: func(err error) {
+		fmt.Println(err)
+		ok = false
+	}}
+	cfg.Check(pkg.Path, fset, files, nil)
+	return ok
+}
+
+// readFuncObj reads the Flux func obj, reporting a panic on unreadable input as an error.
+func readFuncObj(obj types.Object) (f *funcNode, err error) {
+	defer func() {
+		if x := recover(); x != nil {
+			f, err = nil, fmt.Errorf("cannot read %s: %v", fluxPath(obj), x)
+		}
+	}()
+	f = newFuncNode(obj, nil)
+	err = readFunc(f, nil)
+	return
+}
+
+// graphProblems describes the invalidities of f that the editor marks with a red X.
+func graphProblems(f *funcNode) (problems []string) {
+	report := func(format string, a ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, a...))
+	}
+	checkPorts := func(n node, ports []*port, kind string) {
+		for _, p := range ports {
+			if p.bad {
+				report("invalid %s %s of %s", kind, p.obj.Name, nodeName(n))
+			}
+			if p.obj.Type != nil {
+				walkType(p.obj.Type, func(t *types.Named) {
+					if unknown(t.Obj) {
+						report("unknown type %s at %s %s of %s", t.Obj.Name, kind, p.obj.Name, nodeName(n))
+					}
+				})
+			}
+		}
+	}
+	f.funcblk.walk(nil, func(n node) {
+		switch n := n.(type) {
+		case *callNode:
+			if n.obj != nil && unknown(n.obj) {
+				report("unknown %s", n.obj.GetName())
+			}
+		case *valueNode:
+			if n.obj != nil && unknown(n.obj) {
+				report("unknown %s", n.obj.GetName())
+			}
+		}
+		checkPorts(n, n.inputs(), "input")
+		checkPorts(n, n.outputs(), "output")
+	}, func(c *connection) {
+		if c.bad {
+			report("invalid connection from %s to %s", nodeName(c.src.node), nodeName(c.dst.node))
+		}
+	})
+	return
+}
+
+func nodeName(n node) string {
+	switch n := n.(type) {
+	case *funcNode:
+		return "func literal"
+	case *ifNode:
+		return "if"
+	case *loopNode:
+		return "loop"
+	case *selectNode:
+		return "select"
+	case *portsNode:
+		if n.out {
+			return "outputs"
+		}
+		return "inputs"
+	case interface {
+		nodeText() string
+	}:
+		if s := n.nodeText(); s != "" {
+			return s
+		}
+	}
+	return strings.TrimSuffix(strings.TrimPrefix(fmt.Sprintf("%T", n), "*main."), "Node")
+}
+
+func (n *nodeBase) nodeText() string { return n.text.Text() }
@@ -7,9 +7,10 @@ import (
 )
 
 var (
-	lineColor  = Color{.5, .5, .5, 1}
-	focusColor = Color{1, 1, 1, .5}
-	noColor    = Color{}
+	lineColor      = Color{.5, .5, .5, 1}
+	focusColor     = Color{1, 1, 1, .5}
+	selectionColor = Color{.6, .8, 1, .25}
+	noColor        = Color{}
 )
 
 func color(obj types.Object, bright, funcAsVal bool) Color {
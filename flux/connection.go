@@ -7,7 +7,6 @@ package main
 import (
 	"code.google.com/p/gordon-go/flux/go/types"
 	. "code.google.com/p/gordon-go/flux/gui"
-	. "github.com/chsc/gogl/gl21"
 	"math"
 	"sort"
 	"strings"
@@ -69,7 +68,12 @@ func (c *connection) connectable(src, dst *port) bool {
 	}
 
 	f := func(t types.Type) bool { return assignable(t, u) }
-	if n, ok := dst.node.(connectable); ok {
+	if isConstValue(dst) {
+		f = func(t types.Type) bool {
+			_, ok := underlying(t).(*types.Basic)
+			return ok
+		}
+	} else if n, ok := dst.node.(connectable); ok {
 		f = func(t types.Type) bool { return n.connectable(t, dst) && assignableToAll(t, dst) }
 	}
 	if !maybeIndirect(t, f) {
@@ -105,12 +109,21 @@ func (c *connection) connectable(src, dst *port) bool {
 }
 
 func precedes(n1, n2 node) bool {
-	for _, dst := range dstsInBlock(n1) {
-		if dst == n2 || precedes(dst, n2) {
-			return true
+	visited := map[node]bool{} // in case moving nodes between blocks has made a cycle
+	var p func(n node) bool
+	p = func(n node) bool {
+		if visited[n] {
+			return false
+		}
+		visited[n] = true
+		for _, dst := range dstsInBlock(n) {
+			if dst == n2 || p(dst) {
+				return true
+			}
 		}
+		return false
 	}
-	return false
+	return p(n1)
 }
 
 func assignable(t, u types.Type) bool {
@@ -341,6 +354,7 @@ func (c *connection) cancelEditing() {
 func (c *connection) stopEditing() {
 	if c.editing {
 		c.editing = false
+		b := c.blk
 		if c.connected() {
 			c.reform()
 		} else {
@@ -351,6 +365,7 @@ func (c *connection) stopEditing() {
 			c.blk.removeConn(c)
 			SetKeyFocus(p)
 		}
+		recordEdit(b)
 	}
 }
 
@@ -377,6 +392,7 @@ func (c *connection) toggleHidden() {
 					SetKeyFocus(srctxt)
 				} else {
 					c.focus(c.focusSrc)
+					recordEdit(c)
 				}
 				srctxt.TextChanged = nil
 			}
@@ -524,11 +540,15 @@ func (c *connection) KeyPress(event KeyEvent) {
 		}
 		p.focusNextConn(c.dstPt.Sub(c.srcPt).Angle(), event.Key)
 	case KeyBackspace:
+		b := c.blk
 		SetKeyFocus(c.src)
-		c.blk.removeConn(c)
+		b.removeConn(c)
+		recordEdit(b)
 	case KeyDelete:
+		b := c.blk
 		SetKeyFocus(c.dst)
-		c.blk.removeConn(c)
+		b.removeConn(c)
+		recordEdit(b)
 	case KeyEnter:
 		c.startEditing()
 	case KeyEscape:
@@ -541,6 +561,9 @@ func (c *connection) KeyPress(event KeyEvent) {
 		if event.Text == "_" {
 			if c.src.obj.Type != seqType {
 				c.toggleHidden()
+				if !c.hidden || c.src.conntxt.Text() != "" {
+					recordEdit(c)
+				}
 			}
 		} else {
 			c.ViewBase.KeyPress(event)
@@ -629,12 +652,8 @@ func (c *connection) Paint() {
 		if c.focusSrc {
 			c1, c2 = c2, c1
 		}
-		ctrlPts := []Double{Double(c1.R), Double(c1.G), Double(c1.B), Double(c1.A), Double(c2.R), Double(c2.G), Double(c2.B), Double(c2.A)}
-		Map1d(MAP1_COLOR_4, 0, 1, 4, 2, &ctrlPts[0])
-		Enable(MAP1_COLOR_4)
 		SetLineWidth(7)
-		DrawBezier(pts...)
-		Disable(MAP1_COLOR_4)
+		DrawGradientBezier(c1, c2, pts...)
 	}
 	if c.bad {
 		SetColor(Color{1, 0, 0, 1})
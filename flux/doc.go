@@ -21,11 +21,13 @@ To create a new item, hold Command and press 1 (package or directory), 2 (type),
 
 To delete an item (and its children, if it has any), press Command-Delete.  Only items created in Flux can be deleted.
 
-To change the name of an item (or the import path of a package), press Command-Enter, then edit the name and press Enter.
+To change the name of an item (or the import path of a package), press Command-Enter, then edit the name and press Enter.  The fields of a type created in Flux are listed along with its methods so that they can be renamed too.  Every use of the item in the packages under GOPATH, hand-written or Flux, is updated to the new name.  If the new name would conflict with another declaration or change the meaning of existing code (e.g., if a use would be shadowed by a local variable of the same name, or a type would no longer implement an interface it is used as), the conflicts are printed and nothing is changed.
 
-To change the name of a package, press Shift-Enter, then edit the name and press Enter.  The package name is displayed only if it different from the final path element, or while editing it.
+To change the name of a package, press Shift-Enter, then edit the name and press Enter.  The files that import the package are updated to refer to it by its new name.  The package name is displayed only if it different from the final path element, or while editing it.
 
-The browser behaves differently depending on the context in which it is opened.  In the context of program start, it displays only objects created in Flux and it allows you to create, delete, or open them for editing.  When opened in the context of editing a type or function, a relevant subset of objects is displayed from which one can be selected.
+The browser behaves differently depending on the context in which it is opened.  In the context of program start, it displays only objects created in Flux, along with the hand-written functions, methods, and types of packages under GOPATH, and it allows you to create, delete, or open them for editing.  When opened in the context of editing a type or function, a relevant subset of objects is displayed from which one can be selected.
+
+Opening a hand-written function or method offers to convert it to Flux.  If you choose Convert, its declaration is removed from its file (along with any imports that are no longer used) and it is saved as Flux code; if the file cannot be rewritten, the Flux code is discarded.  Functions using goto, fallthrough, labeled branches out of nested statements, method expressions, print or println, local type declarations, or named results within function literals cannot be converted; the reasons are printed and the function is left as it was.  Hand-written types cannot be opened, only browsed for their methods.
 
 
 Function editor
@@ -36,7 +38,7 @@ Every node belongs to a block.  Outermost is the function block, which is run wh
 
 The execution order of nodes is determined as follows:  Node A runs before node B if there is a connection with A as its source and B as its destination.  A connection that exits or enters a block has that block's containing node as a source or destination, respectively.
 
-The arrow keys are used to navigate the graph.  On their own, they move the focus between nodes, ports, and connections following the topology of the graph.  While holding Alt, they move the focus between nodes with no regard for connectivity.  Pressing Escape moves the focus from a connection end to its port, from a port to its node, and from a node to its containing node.  Pressing Escape when a top-level node is focused saves changes and exits the function editor.
+The arrow keys are used to navigate the graph.  On their own, they move the focus between nodes, ports, and connections following the topology of the graph.  While holding Alt, they move the focus between nodes with no regard for connectivity.  Pressing Escape moves the focus from a connection end to its port, from a port to its node, and from a node to its containing node.  Pressing Escape when a top-level node is focused exits the function editor.
 
 To create a named node (function or method, variable, constant, struct field, operator, special node), simply start typing its name; the browser will open, allowing you to select the desired item.  Hold Shift in the browser to treat functions and methods as values; otherwise they are treated as calls.
 
@@ -60,7 +62,11 @@ To control the execution order of two nodes that are ambiguously ordered, a sequ
 
 Press Backspace or Delete to delete a node or connection.
 
-To save changes, press Command-S.
+To select several nodes, hold Shift while pressing the arrow keys, or drag a band around them with the left mouse button (drag with another button to pan).  Press Escape to clear the selection.  Backspace or Delete deletes every selected node.  Press Command-X or Command-C to cut or copy the selection (or the focused node), and Command-V to paste it into the focused block, even in another function.  Connections to nodes that were not copied are cut.  To move a node, or the selection it is part of, into another block (e.g., into a loop), drag it there with the mouse.
+
+To undo an edit, press Command-Z; to redo it, press Shift-Command-Z.  An edit made in several steps, such as creating a node and selecting its type, is undone in one.  The history of edits is kept until Flux quits, even if the function is saved or closed, unless its file is changed outside of Flux.
+
+To save changes, press Command-S.  A file is written in full or not at all:  if saving fails, the error is printed and the file is left as it was.  When a function with unsaved changes is closed (by pressing Escape, or by closing its window or quitting), Flux asks whether to save them; choose Save, Don't Save, or Cancel with the arrow keys and Enter, or by typing an answer's first letter.  Unsaved changes are also written every 30 seconds to a recovery journal in $HOME/.flux/autosave.  If Flux quits without saving them (e.g., because it crashed), it offers to recover them the next time it starts.
 
 
 Type editor
@@ -71,9 +77,30 @@ Press Enter to move the focus from a composite type to one of its children.  Use
 
 To replace the focused item, press Backspace.  For a named item (struct field, function parameter or result, or interface method), first type the name and Enter.  Otherwise just select the type from the browser.  After a composite type is created, each of its children is edited in turn.  Press Escape to stop entering new named items.  Press Comma to insert a new named item (hold Shift to insert before the focused item); to delete one, press Delete.
 
+Press Command-Z to undo an edit and Shift-Command-Z to redo it.
+
+
+Variable and constant editors
+
+The value of a package-level variable or constant is edited in the function editor, as the body of a function without parameters whose single result is the value.  When a new variable is opened, its type is first selected in the type editor.  A variable's graph is its initializer; if the graph is empty, the variable is initialized to the zero value of its type.
+
+A constant's graph is restricted to constants, literals, operators, and conversions.  The value computed by the graph is displayed beside the result, and the constant takes its type.  A constant is saved only when its graph computes a valid constant; a new constant that is closed before then is discarded.
+
 
 Invalid code
 
 It is impossible to write invalid (uncompilable) code in Flux.  However, it is possible for code to become invalid when its dependencies change.  For example, when a variable is renamed or removed or when a function signature changes, any code that referred to those objects will no longer work.  In the case of a name change, the referred-to object is simply unknown; while in the case of a type change, some connections or ports may become invalid.  Such invalidities are indicated by a red X drawn over the offending name, port, or connection.  Replace invalid nodes, adjust invalid connections, and remove invalid ports to make the code valid again.
+
+Some changes are propagated automatically.  When a function whose parameters or results have changed is saved, or when the fields of a struct type are renamed, Flux looks for the code that depends on it (its clients) in its package and in the packages under GOPATH that import it, and lists them for review.  Choose Update to update them:  calls in Flux functions are rewired, connecting each argument and result to the port of the same parameter or result (connections to removed ports are deleted); calls in hand-written Go have their arguments and assigned results reordered, removed, or added as zero values; and references to renamed fields are renamed.  Functions open in other windows are updated too, and saved unless they have unsaved changes.  Go code that cannot be updated automatically (e.g., a call whose results are used in an expression, or that would discard an argument with side effects) is listed to be fixed by hand.  Choose Don't Update to save without updating the clients.
+
+Command line
+
+Flux code can also be checked and maintained without opening a window, e.g. on a build server:
+
+	flux check [packages]
+	flux fmt [packages]
+	flux list [packages]
+
+check reports the invalidities described above and type-checks the generated code; fmt rewrites Flux files in canonical form, printing the names of those that changed; list prints the Flux functions, methods, types, variables, and constants.  Packages are import paths or directories, optionally ending in "/...", and default to the current directory.  The exit status is 1 if problems were found and 2 if the packages could not be loaded.  Build with -tags headless to run Flux (and its tests) where OpenGL is unavailable.
 */
 package main
@@ -10,12 +10,17 @@ import (
 	"code.google.com/p/gordon-go/refactor"
 	"fmt"
 	"math"
+	"os"
 	"runtime"
+	"sync"
 	"time"
 )
 
 func main() {
 	runtime.GOMAXPROCS(runtime.NumCPU())
+	if len(os.Args) > 1 {
+		os.Exit(command(os.Args[1:]))
+	}
 	go refactor.ReportShadowedPackages()
 	if err := Run(newFluxWindow); err != nil {
 		fmt.Println(err)
@@ -26,9 +31,11 @@ type fluxWindow struct {
 	*Window
 	*Panner
 	browser *browser
+	f       *funcNode // the func being edited, if any
 
 	target chan Point
 	pause  chan bool
+	closed chan bool // closed when the window closes, to stop its goroutines
 }
 
 func newFluxWindow() {
@@ -36,16 +43,21 @@ func newFluxWindow() {
 	NewWindow(w, "Flux", func(win *Window) {
 		w.Window = win
 		w.Panner = NewPanner(w)
-		w.browser = newBrowser(browserOptions{objFilter: isFluxObj, acceptTypes: true, enterTypes: true, mutable: true}, nil)
+		w.browser = newBrowser(browserOptions{objFilter: isEditable, acceptTypes: true, enterTypes: true, mutable: true}, nil)
 		w.Add(w.browser)
 		w.SetRect(Rect(w))
 		w.browser.accepted = func(obj types.Object) {
 			switch obj := obj.(type) {
 			case *types.TypeName:
+				if !isFluxObj(obj) {
+					return // hand-written types are only browsed for their methods
+				}
 				w.SetTitle(obj.Pkg.Path + "." + obj.Name)
 				typ := obj.Type.(*types.Named)
+				names := fieldNames(typ)
 				Hide(w.browser)
 				v := newTypeView(&typ.UnderlyingT, obj.Pkg)
+				v.history = loadHistory(obj, v.snapshot, v.restore)
 				w.Add(v)
 				MoveCenter(v, Center(w))
 				reset := func() {
@@ -66,8 +78,7 @@ func newFluxWindow() {
 					})
 				} else {
 					v.done = func() {
-						saveType(typ)
-						reset()
+						updateClients(fieldChange(typ, names), v, false, func() error { return saveType(typ) }, reset)
 					}
 					SetKeyFocus(v)
 				}
@@ -77,30 +88,146 @@ func newFluxWindow() {
 					t, _ := indirect(recv.Type)
 					prefix += t.(*types.Named).Obj.Name + "."
 				}
-				w.SetTitle(prefix + obj.Name)
+				if fluxObjs[obj] || !obj.Pos().IsValid() { // a Flux func or a new one
+					w.openFunc(obj, prefix+obj.Name)
+					return
+				}
 				Hide(w.browser)
-				f := loadFunc(obj)
-				w.Add(f)
-				go animate(f.animate, f.stop)
-				f.Move(Center(w))
-				f.done = func() {
+				q := fmt.Sprintf("Convert %s to Flux?  Its declaration will be removed from its file.", prefix+obj.Name)
+				newPrompt(q, "Convert", "Cancel").show(w, func(answer int) {
+					if answer == 0 {
+						w.openFunc(obj, prefix+obj.Name)
+						return
+					}
 					Show(w.browser)
-					w.browser.clearText()
 					SetKeyFocus(w.browser)
-					w.SetTitle("Flux")
+				})
+			case *types.Var:
+				if obj.Type != nil {
+					w.openFunc(obj, "var "+obj.Pkg.Path+"."+obj.Name)
+					return
 				}
-				SetKeyFocus(f.inputsNode)
+				Hide(w.browser)
+				v := newTypeView(&obj.Type, obj.Pkg)
+				w.Add(v)
+				MoveCenter(v, Center(w))
+				v.edit(func() {
+					w.Remove(v)
+					if obj.Type == nil {
+						delete(obj.Pkg.Scope().Objects, obj.Name)
+						Show(w.browser)
+						w.browser.clearText()
+						SetKeyFocus(w.browser)
+						return
+					}
+					w.openFunc(obj, "var "+obj.Pkg.Path+"."+obj.Name)
+				})
+			case *types.Const:
+				w.openFunc(obj, "const "+obj.Pkg.Path+"."+obj.Name)
 			}
 		}
 		w.browser.canceled = func() {}
 		SetKeyFocus(w.browser)
+		win.ConfirmClose = func(destroy func()) {
+			closeWin := func() {
+				select {
+				case <-w.closed:
+				default:
+					close(w.closed)
+				}
+				destroy()
+			}
+			if w.f != nil {
+				w.f.confirmClose(closeWin)
+			} else {
+				closeWin()
+			}
+		}
+		recoveryOffered.Do(func() {
+			if a := autosaves(); len(a) > 0 {
+				Hide(w.browser)
+				w.recover(a)
+			}
+		})
 
 		w.target = make(chan Point)
 		w.pause = make(chan bool)
+		w.closed = make(chan bool)
 		go w.animate()
+		go w.autosave()
 	})
 }
 
+// openFunc opens the editor of obj, a func or the value of a var or const.  A new const that is closed without having been saved (which it is only once it has a value) is forgotten.
+func (w *fluxWindow) openFunc(obj types.Object, title string) {
+	f := loadFunc(obj)
+	if f == nil {
+		Show(w.browser)
+		SetKeyFocus(w.browser)
+		return
+	}
+	w.SetTitle(title)
+	Hide(w.browser)
+	w.Add(f)
+	go animate(f.animate, f.stop)
+	f.Move(Center(w))
+	w.f = f
+	openFuncs[obj] = f
+	f.done = func() {
+		w.f = nil
+		delete(openFuncs, obj)
+		if _, ok := obj.(*types.Const); ok && !fluxObjs[obj] {
+			delete(obj.GetPkg().Scope().Objects, obj.GetName())
+		}
+		Show(w.browser)
+		w.browser.clearText()
+		SetKeyFocus(w.browser)
+		w.SetTitle("Flux")
+	}
+	SetKeyFocus(f.inputsNode)
+}
+
+var recoveryOffered sync.Once
+
+// recover asks, one at a time, whether to recover the entries of the autosave journal.  An entry that is neither recovered nor discarded is offered again on the next launch.
+func (w *fluxWindow) recover(a []autosaved) {
+	if len(a) == 0 {
+		Show(w.browser)
+		SetKeyFocus(w.browser)
+		return
+	}
+	q := fmt.Sprintf("Recover unsaved changes to %s from %s?", a[0].path, a[0].time.Format("Jan 2 15:04"))
+	newPrompt(q, "Recover", "Discard", "Not Now").show(w, func(answer int) {
+		switch answer {
+		case 0:
+			a[0].restore()
+		case 1:
+			a[0].discard()
+		}
+		w.recover(a[1:])
+	})
+}
+
+// autosave periodically writes the unsaved changes of the func being edited to the autosave journal.
+func (w *fluxWindow) autosave() {
+	for {
+		select {
+		case <-time.After(autosaveInterval):
+		case <-w.closed:
+			return
+		}
+		select {
+		case DoChan(w) <- func() {
+			if f := w.f; f != nil && f.history.dirty() {
+				autosave(f.obj, func() []byte { return funcSource(f) })
+			}
+		}:
+		case <-w.closed:
+			return
+		}
+	}
+}
+
 func panTo(v View, p Point) {
 	w := window(v)
 	if w == nil {
@@ -121,15 +248,27 @@ func window(v View) *fluxWindow {
 }
 
 func (w *fluxWindow) animate() {
-	target := <-w.target
+	var target Point
+	select {
+	case target = <-w.target:
+	case <-w.closed:
+		return
+	}
 	vel := ZP
 	for {
 		next := time.After(time.Second / fps)
 		r := ZR
-		Do(w, func() {
+		done := make(chan bool)
+		select {
+		case DoChan(w) <- func() {
 			Pan(w, Rect(w).Min.Add(vel.Div(fps)))
 			r = Rect(w)
-		})
+			done <- true
+		}:
+			<-done
+		case <-w.closed:
+			return
+		}
 		d := target.Sub(r.Center())
 		d.X = math.Copysign(math.Max(0, math.Abs(d.X)-r.Dx()/3), d.X)
 		d.Y = math.Copysign(math.Max(0, math.Abs(d.Y)-r.Dy()/3), d.Y)
@@ -141,7 +280,13 @@ func (w *fluxWindow) animate() {
 		case <-next:
 		case target = <-w.target:
 		case <-w.pause:
-			target = <-w.target
+			select {
+			case target = <-w.target:
+			case <-w.closed:
+				return
+			}
+		case <-w.closed:
+			return
 		}
 	}
 }
@@ -0,0 +1,1048 @@
+// Copyright 2014 Gordon Klaus. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"code.google.com/p/gordon-go/flux/go/types"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// These tests load and save funcs without a window; run them with -tags headless where OpenGL is unavailable.  They are in package main because the graph model is not yet separate from its views (see tasks.txt).
+
+// testPackage writes a package with the given files into a temporary GOPATH and returns its directory and a func that removes it.
+func testPackage(t *testing.T, path string, files map[string]string) (string, func()) {
+	gopath, err := ioutil.TempDir("", "flux")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := filepath.Join(gopath, "src", path)
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		t.Fatal(err)
+	}
+	for name, src := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(src), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	oldGopath := build.Default.GOPATH
+	build.Default.GOPATH = gopath
+	return dir, func() {
+		build.Default.GOPATH = oldGopath
+		os.RemoveAll(gopath)
+	}
+}
+
+// checkPackage type-checks the package in dir, including its func bodies.
+func checkPackage(t *testing.T, path, dir string) {
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, dir, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := []*ast.File{}
+	for _, f := range pkgs[filepath.Base(path)].Files {
+		files = append(files, f)
+	}
+	if _, err := (&types.Config{Import: srcImport}).Check(path, fset, files, nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func lookup(t *testing.T, path, name string) types.Object {
+	pkg, err := getPackage(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj := pkg.Scope().Lookup(name)
+	if obj == nil {
+		t.Fatalf("%s.%s not found", path, name)
+	}
+	return obj
+}
+
+func countNodes(b *block) (n int) {
+	b.walk(nil, func(node) { n++ }, nil)
+	return
+}
+
+func TestLoadSaveFunc(t *testing.T) {
+	dir, cleanup := testPackage(t, "fluxtest/a", map[string]string{
+		"a.go": `package a
+
+func Sum(x []int) (y int) {
+	for _, x := range x {
+		y += x
+	}
+	return
+}
+`,
+	})
+	defer cleanup()
+
+	f := loadFunc(lookup(t, "fluxtest/a", "Sum"))
+	if f == nil {
+		t.Fatal("loadFunc failed")
+	}
+	n := countNodes(f.funcblk)
+	checkPackage(t, "fluxtest/a", dir)
+	src, err := ioutil.ReadFile(fluxPath(f.obj))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f = newFuncNode(f.obj, nil)
+	if err := readFunc(f, nil); err != nil {
+		t.Fatal(err)
+	}
+	if n2 := countNodes(f.funcblk); n2 != n {
+		t.Errorf("read func has %d nodes, want %d", n2, n)
+	}
+	if src2 := funcSource(f); !bytes.Equal(src2, src) {
+		t.Errorf("writing a read func changed it from\n%s\nto\n%s", src, src2)
+	}
+}
+
+func TestImportFunc(t *testing.T) {
+	dir, cleanup := testPackage(t, "fluxtest/b", map[string]string{
+		"b.go": `package b
+
+type T struct{ n int }
+
+// Collatz returns the number of steps it takes n to reach 1.
+func Collatz(n int) (steps int) {
+	for n != 1 {
+		switch {
+		case n%2 == 0:
+			n /= 2
+		default:
+			n = 3*n + 1
+		}
+		steps++
+	}
+	return
+}
+
+func (t *T) Inc() { t.n++ }
+`,
+	})
+	defer cleanup()
+
+	for _, obj := range []types.Object{
+		lookup(t, "fluxtest/b", "Collatz"),
+		lookup(t, "fluxtest/b", "T").GetType().(*types.Named).Methods[0],
+	} {
+		if loadFunc(obj) == nil {
+			t.Fatalf("loadFunc(%s) failed", obj.GetName())
+		}
+	}
+	src, err := ioutil.ReadFile(filepath.Join(dir, "b.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(src), "func") {
+		t.Errorf("imported funcs remain in b.go:\n%s", src)
+	}
+	for _, name := range []string{"Collatz.flux.go", "T.Inc.flux.go"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Error(err)
+		}
+	}
+	checkPackage(t, "fluxtest/b", dir)
+}
+
+// importTests are hand-written funcs, each with calls of it whose results are printed by a program that is run after the funcs are converted to Flux.
+var importTests = []struct{ decl, calls, want string }{
+	// if/else chains
+	{`func Sign(x int) int {
+	if x < 0 {
+		return -1
+	} else if x == 0 {
+		return 0
+	} else {
+		return 1
+	}
+}`, "i.Sign(-5), i.Sign(0), i.Sign(7)", "-1 0 1"},
+	{`func Abs(x int) int {
+	if x < 0 {
+		x = -x
+	}
+	return x
+}`, "i.Abs(-3), i.Abs(4)", "3 4"},
+
+	// for
+	{`func Log2(n int) (i int) {
+	for {
+		if n <= 1 {
+			break
+		}
+		n /= 2
+		i++
+	}
+	return
+}`, "i.Log2(1), i.Log2(8), i.Log2(9)", "0 3 3"},
+	{`func Digits(n int) int {
+	d := 1
+	for n >= 10 {
+		n /= 10
+		d++
+	}
+	return d
+}`, "i.Digits(0), i.Digits(12345)", "1 5"},
+	{`func SumOdd(n int) (s int) {
+	for i := 0; i < n; i++ {
+		if i%2 == 0 {
+			continue
+		}
+		s += i
+	}
+	return
+}`, "i.SumOdd(0), i.SumOdd(10)", "0 25"},
+	{`func Max(x []int) int {
+	m := x[0]
+	for _, v := range x {
+		if v > m {
+			m = v
+		}
+	}
+	return m
+}`, "i.Max([]int{3, 9, 2})", "9"},
+	{`func Total(m map[string]int) (t int) {
+	for _, v := range m {
+		t += v
+	}
+	return
+}`, `i.Total(map[string]int{"a": 1, "b": 2, "c": 3})`, "6"},
+	{`func Runes(s string) (n int) {
+	for _ = range s {
+		n++
+	}
+	return
+}`, `i.Runes("héllo")`, "5"},
+	{`func Drain(c chan int) (n int) {
+	for x := range c {
+		n += x
+	}
+	return
+}`, "i.Drain(ch(1, 2, 3))", "6"},
+
+	// switch
+	{`func Kind(x int) string {
+	switch x {
+	case 0:
+		return "zero"
+	case 1, 3, 5:
+		return "odd"
+	default:
+		return "other"
+	}
+}`, "i.Kind(0), i.Kind(3), i.Kind(4)", "zero odd other"},
+	{`func Vowels(s string) (n int) {
+	for _, r := range s {
+		switch r {
+		case 'a', 'e', 'i', 'o', 'u':
+			n++
+		}
+	}
+	return
+}`, `i.Vowels("education")`, "5"},
+	{`func Grade(score int) string {
+	g := "C"
+	switch {
+	case score >= 90:
+		g = "A"
+	case score >= 80:
+		g = "B"
+	}
+	return g
+}`, "i.Grade(95), i.Grade(85), i.Grade(10)", "A B C"},
+
+	// multi-assignment
+	{`func Swap(a, b int) (int, int) {
+	a, b = b, a
+	return a, b
+}`, "i.Swap(1, 2)", "2 1"},
+	{`func Fib(n int) int {
+	a, b := 0, 1
+	for i := 0; i < n; i++ {
+		a, b = b, a+b
+	}
+	return a
+}`, "i.Fib(0), i.Fib(10)", "0 55"},
+
+	// compound operators
+	{`func Ops(x int) int {
+	x += 3
+	x *= 4
+	x -= 2
+	x /= 3
+	x %= 5
+	x <<= 2
+	x |= 1
+	x &^= 4
+	x ^= 8
+	x++
+	x--
+	return x
+}`, "i.Ops(2)", "9"},
+
+	// closures over variables
+	{`func Count(k int) int {
+	n := 0
+	inc := func() { n++ }
+	for i := 0; i < k; i++ {
+		inc()
+	}
+	return n
+}`, "i.Count(3)", "3"},
+	{`func Adder(x int) func(int) int {
+	return func(y int) int {
+		x += y
+		return x
+	}
+}`, "i.Adder(1)(2)", "3"},
+
+	// named results
+	{`func DivMod(a, b int) (q, r int) {
+	q = a / b
+	r = a % b
+	return
+}`, "i.DivMod(7, 2)", "3 1"},
+	{`func Clamp(x, lo, hi int) (y int) {
+	y = x
+	if y < lo {
+		y = lo
+		return
+	}
+	if y > hi {
+		return hi
+	}
+	return
+}`, "i.Clamp(-1, 0, 9), i.Clamp(5, 0, 9), i.Clamp(12, 0, 9)", "0 5 9"},
+
+	// early returns
+	{`func Index(x []string, s string) int {
+	for i, v := range x {
+		if v == s {
+			return i
+		}
+	}
+	return -1
+}`, `i.Index([]string{"a", "b"}, "b"), i.Index(nil, "c")`, "1 -1"},
+	{`func Pair(x []int, sum int) (int, int) {
+	for i := range x {
+		for j := i + 1; j < len(x); j++ {
+			if x[i]+x[j] == sum {
+				return i, j
+			}
+		}
+	}
+	return -1, -1
+}`, "i.Pair([]int{1, 4, 6}, 10)", "1 2"},
+}
+
+func TestTranslateFuncs(t *testing.T) {
+	goTool, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("the go tool is needed to run the converted funcs")
+	}
+	src := "package i\n"
+	main := "package main\n\nimport (\n\t\"fluxtest/i\"\n\t\"fmt\"\n)\n\nfunc ch(x ...int) chan int {\n\tc := make(chan int, len(x))\n\tfor _, x := range x {\n\t\tc <- x\n\t}\n\tclose(c)\n\treturn c\n}\n\nfunc main() {\n"
+	for _, test := range importTests {
+		src += "\n" + test.decl + "\n"
+		main += "\tfmt.Println(" + test.calls + ")\n"
+	}
+	main += "}\n"
+	dir, cleanup := testPackage(t, "fluxtest/i", map[string]string{"i.go": src})
+	defer cleanup()
+	mainDir := filepath.Join(dir, "..", "imain")
+	if err := os.MkdirAll(mainDir, 0777); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(mainDir, "main.go"), []byte(main), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	pkg, err := getPackage("fluxtest/i")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range pkg.Scope().Names() {
+		obj := pkg.Scope().Lookup(name)
+		if err := importFunc(newFuncNode(obj, nil)); err != nil {
+			t.Fatalf("importing %s: %s", name, err)
+		}
+	}
+	if src, _ := ioutil.ReadFile(filepath.Join(dir, "i.go")); strings.Contains(string(src), "func") {
+		t.Errorf("imported funcs remain in i.go:\n%s", src)
+	}
+	checkPackage(t, "fluxtest/i", dir)
+
+	// run with the test's GOPATH, and let the go tool find its own GOROOT
+	cmd := exec.Command(goTool, "run", "main.go")
+	cmd.Dir = mainDir
+	for _, e := range os.Environ() {
+		if !strings.HasPrefix(e, "GOPATH=") && !strings.HasPrefix(e, "GOROOT=") {
+			cmd.Env = append(cmd.Env, e)
+		}
+	}
+	cmd.Env = append(cmd.Env, "GOPATH="+build.Default.GOPATH)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("%s\n%s", err, out)
+	}
+	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+	if len(lines) != len(importTests) {
+		t.Fatalf("got %d lines of output, want %d:\n%s", len(lines), len(importTests), out)
+	}
+	for i, test := range importTests {
+		if lines[i] != test.want {
+			t.Errorf("%s = %s, want %s\n%s", test.calls, lines[i], test.want, test.decl)
+		}
+	}
+}
+
+func TestImportFuncErrors(t *testing.T) {
+	src := `package n
+
+func Goto(x int) int {
+	if x < 0 {
+		goto neg
+	}
+	return x
+neg:
+	return -x
+}
+
+func Fallthrough(x int) (n int) {
+	switch x {
+	case 0:
+		n++
+		fallthrough
+	case 1:
+		n++
+	}
+	return
+}
+
+func LocalType() int {
+	type t struct{ x int }
+	return t{1}.x
+}
+
+func Several(x int) int {
+	type t int
+	if x < 0 {
+		goto neg
+	}
+	return int(t(x))
+neg:
+	return 0
+}
+`
+	dir, cleanup := testPackage(t, "fluxtest/n", map[string]string{"n.go": src})
+	defer cleanup()
+
+	for _, test := range []struct {
+		name string
+		want []string
+	}{
+		{"Goto", []string{"n.go:5:3: cannot translate goto statement"}},
+		{"Fallthrough", []string{"n.go:16:3: cannot translate fallthrough statement"}},
+		{"LocalType", []string{"n.go:24:2: cannot translate local type declaration"}},
+		{"Several", []string{"n.go:29:2: cannot translate local type declaration", "n.go:31:3: cannot translate goto statement"}},
+	} {
+		err := importFunc(newFuncNode(lookup(t, "fluxtest/n", test.name), nil))
+		if err == nil {
+			t.Errorf("importing %s succeeded", test.name)
+			continue
+		}
+		errs := strings.Split(err.Error(), "\n")
+		if len(errs) != len(test.want) {
+			t.Errorf("importing %s: got errors\n%s\nwant\n%s", test.name, err, strings.Join(test.want, "\n"))
+			continue
+		}
+		for i, want := range test.want {
+			if !strings.HasSuffix(errs[i], want) {
+				t.Errorf("importing %s: got error %q, want %q", test.name, errs[i], want)
+			}
+		}
+	}
+	if b, err := ioutil.ReadFile(filepath.Join(dir, "n.go")); err != nil || string(b) != src {
+		t.Errorf("n.go changed:\n%s", b)
+	}
+	if files, _ := filepath.Glob(filepath.Join(dir, "*.flux.go")); len(files) > 0 {
+		t.Errorf("Flux files written: %v", files)
+	}
+}
+
+func TestCommand(t *testing.T) {
+	dir, cleanup := testPackage(t, "fluxtest/c", map[string]string{
+		"c.go": `package c
+
+func Twice(x int) int { return Helper(x) * 2 }
+
+func Helper(x int) int { return x }
+`,
+	})
+	defer cleanup()
+
+	if loadFunc(lookup(t, "fluxtest/c", "Twice")) == nil {
+		t.Fatal("loadFunc failed")
+	}
+	if s := command([]string{"check", "fluxtest/c"}); s != 0 {
+		t.Errorf("check of valid package exited with %d", s)
+	}
+	if s := command([]string{"list", "fluxtest/c"}); s != 0 {
+		t.Errorf("list exited with %d", s)
+	}
+
+	src := "package c\n\nfunc Helper(x string) int { return len(x) }\n"
+	if err := ioutil.WriteFile(filepath.Join(dir, "c.go"), []byte(src), 0666); err != nil {
+		t.Fatal(err)
+	}
+	delete(pkgs, "fluxtest/c")
+	if s := command([]string{"check", "fluxtest/c"}); s != 1 {
+		t.Errorf("check of invalid package exited with %d, want 1", s)
+	}
+	if s := command([]string{"check", "fluxtest/missing"}); s != 2 {
+		t.Errorf("check of missing package exited with %d, want 2", s)
+	}
+	if s := command([]string{"bogus"}); s != 2 {
+		t.Errorf("unknown command exited with %d, want 2", s)
+	}
+}
+
+// TestRoundTrip checks that reading and writing each Flux file of the audio package reproduces it exactly.
+func TestRoundTrip(t *testing.T) {
+	pkg, err := getPackage("code.google.com/p/gordon-go/flux/audio")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, obj := range pkgFluxObjs(pkg) {
+		path := fluxPath(obj)
+		src, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var src2 []byte
+		switch obj := obj.(type) {
+		case *types.TypeName:
+			src2 = typeSource(obj.Type.(*types.Named))
+		case *types.Func, *types.Var, *types.Const:
+			f := newFuncNode(obj, nil)
+			if err := readFunc(f, nil); err != nil {
+				t.Errorf("%s: %s", path, err)
+				continue
+			}
+			src2 = funcSource(f)
+		}
+		if !bytes.Equal(src2, src) {
+			lines, lines2 := strings.Split(string(src), "\n"), strings.Split(string(src2), "\n")
+			i := 0
+			for i < len(lines) && i < len(lines2) && lines[i] == lines2[i] {
+				i++
+			}
+			line, line2 := "", ""
+			if i < len(lines) {
+				line = lines[i]
+			}
+			if i < len(lines2) {
+				line2 = lines2[i]
+			}
+			t.Errorf("%s:%d: reading and writing changed\n\t%s\nto\n\t%s", path, i+1, line, line2)
+		}
+	}
+}
+
+func TestSaveAndAutosave(t *testing.T) {
+	dir, cleanup := testPackage(t, "fluxtest/s", map[string]string{
+		"s.go": `package s
+
+func Neg(x int) int { return -x }
+`,
+	})
+	defer cleanup()
+	oldHome := os.Getenv("HOME")
+	os.Setenv("HOME", dir)
+	defer os.Setenv("HOME", oldHome)
+
+	f := loadFunc(lookup(t, "fluxtest/s", "Neg"))
+	if f == nil {
+		t.Fatal("loadFunc failed")
+	}
+	if f.history.dirty() {
+		t.Error("loaded func is dirty")
+	}
+	path := fluxPath(f.obj)
+	src, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := save(f.obj, func() []byte { panic("oops") }); err == nil {
+		t.Error("save did not report a panic")
+	}
+	if src2, _ := ioutil.ReadFile(path); !bytes.Equal(src2, src) {
+		t.Errorf("failed save changed the file to\n%s", src2)
+	}
+	fis, _ := ioutil.ReadDir(dir)
+	for _, fi := range fis {
+		if strings.HasPrefix(fi.Name(), ".") && !fi.IsDir() {
+			t.Errorf("failed save left %s behind", fi.Name())
+		}
+	}
+
+	autosave(f.obj, func() []byte { return []byte("changed") })
+	a := autosaves()
+	if len(a) != 1 || a[0].path != path || string(a[0].src) != "changed" {
+		t.Fatalf("autosaves() = %v, want the entry for %s", a, path)
+	}
+	a[0].restore()
+	if src2, _ := ioutil.ReadFile(path); string(src2) != "changed" {
+		t.Errorf("restore wrote\n%s", src2)
+	}
+	if a := autosaves(); len(a) != 0 {
+		t.Errorf("restored entry was not removed from the journal")
+	}
+}
+
+// TestUndoUnrecordedEdit checks that an edit that was not recorded is recorded by undo, so that it can be redone.
+func TestUndoUnrecordedEdit(t *testing.T) {
+	_, cleanup := testPackage(t, "fluxtest/u", map[string]string{
+		"u.go": `package u
+
+func Neg(x int) int { return -x }
+`,
+	})
+	defer cleanup()
+
+	f := loadFunc(lookup(t, "fluxtest/u", "Neg"))
+	if f == nil {
+		t.Fatal("loadFunc failed")
+	}
+	src := funcSource(f)
+	c := f.inputsNode.outs[0].conns[0]
+	c.blk.removeConn(c)
+	edited := funcSource(f)
+	if bytes.Equal(edited, src) {
+		t.Fatal("disconnecting the parameter changed nothing")
+	}
+
+	f.history.undo()
+	if src2 := funcSource(f); !bytes.Equal(src2, src) {
+		t.Errorf("undo gave\n%s\nwant\n%s", src2, src)
+	}
+	f.history.redo()
+	if src2 := funcSource(f); !bytes.Equal(src2, edited) {
+		t.Errorf("redo gave\n%s\nwant\n%s", src2, edited)
+	}
+}
+
+// TestUndoKeepsViews checks that redo restores the views that undo left, rather than reading the func again, and that they are dropped when a new edit makes them unreachable.
+func TestUndoKeepsViews(t *testing.T) {
+	_, cleanup := testPackage(t, "fluxtest/h", map[string]string{
+		"h.go": `package h
+
+func Sub(x, y int) int { return x - y }
+`,
+	})
+	defer cleanup()
+
+	f := loadFunc(lookup(t, "fluxtest/h", "Sub"))
+	if f == nil {
+		t.Fatal("loadFunc failed")
+	}
+	src := funcSource(f)
+	c := f.inputsNode.outs[0].conns[0]
+	c.blk.removeConn(c)
+	f.history.record()
+	edited, editedblk := funcSource(f), f.funcblk
+
+	f.history.undo()
+	if f.funcblk == editedblk {
+		t.Fatal("undo did not replace the funcblk")
+	}
+	if src2 := funcSource(f); !bytes.Equal(src2, src) {
+		t.Errorf("undo gave\n%s\nwant\n%s", src2, src)
+	}
+	f.history.redo()
+	if f.funcblk != editedblk {
+		t.Error("redo did not reuse the funcblk that undo left")
+	}
+	if src2 := funcSource(f); !bytes.Equal(src2, edited) {
+		t.Errorf("redo gave\n%s\nwant\n%s", src2, edited)
+	}
+
+	f.history.undo()
+	c = f.inputsNode.outs[1].conns[0]
+	c.blk.removeConn(c)
+	f.history.record()
+	if len(f.history.states) != 2 {
+		t.Fatalf("%d states after a new edit, want 2", len(f.history.states))
+	}
+	for _, s := range f.history.states {
+		if s.(*funcState).view != nil {
+			t.Error("a new edit left views cached")
+		}
+	}
+	if len(f.pkgRefs) != 0 {
+		t.Errorf("dropping the cached views changed the package references: %v", f.pkgRefs)
+	}
+}
+
+func TestHistoryLimit(t *testing.T) {
+	n := 0
+	h := &history{keys: []string{"0"}, states: []interface{}{0}}
+	h.snapshot = func() (string, interface{}) { return fmt.Sprint(n), n }
+	h.restore = func(_, state interface{}) { n = state.(int) }
+	for i := 1; i <= 2*maxHistory; i++ {
+		n = i
+		h.record()
+	}
+	if len(h.states) != maxHistory || h.i != maxHistory-1 {
+		t.Fatalf("%d states, at %d; want %d, at the last", len(h.states), h.i, maxHistory)
+	}
+	for i := 0; i < 2*maxHistory; i++ {
+		h.undo()
+	}
+	if n != maxHistory+1 {
+		t.Errorf("undid back to %d, want %d", n, maxHistory+1)
+	}
+}
+
+// TestPaste copies nodes with connections among them and to the rest of their func, and pastes them into a func in another package.
+func TestPaste(t *testing.T) {
+	dir, cleanup := testPackage(t, "fluxtest/cp", map[string]string{
+		"p.go": `package cp
+
+import "fluxtest/cq"
+
+func F(x int) int { return cq.G(x*2) + 1 }
+`,
+	})
+	defer cleanup()
+	for path, src := range map[string]string{
+		"fluxtest/cq": "package cq\n\nfunc G(x int) int { return x }\n",
+		"fluxtest/cr": "package cr\n\nfunc H() {}\n",
+	} {
+		d := filepath.Join(filepath.Dir(filepath.Dir(dir)), path)
+		if err := os.MkdirAll(d, 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(d, filepath.Base(path)+".go"), []byte(src), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	f := loadFunc(lookup(t, "fluxtest/cp", "F"))
+	if f == nil {
+		t.Fatal("loadFunc failed")
+	}
+	nodes := map[node]bool{}
+	for n := range f.funcblk.nodes {
+		switch n := n.(type) {
+		case *operatorNode:
+			if n.op == "*" {
+				nodes[n] = true
+			}
+		case *callNode:
+			nodes[n] = true
+		case *basicLiteralNode:
+			if n.text.Text() == "2" {
+				nodes[n] = true
+			}
+		}
+	}
+	if len(nodes) != 3 {
+		t.Fatalf("selected %d nodes, want x*2 and cq.G", len(nodes))
+	}
+	src := funcSource(f)
+	f.funcblk.copyNodes(nodes)
+	defer func() { clipboard = nil }()
+	if !bytes.Contains(clipboard, []byte("cq.G(")) {
+		t.Errorf("the clipboard doesn't qualify G by its package:\n%s", clipboard)
+	}
+	if src2 := funcSource(f); !bytes.Equal(src2, src) {
+		t.Errorf("copying changed the func to\n%s", src2)
+	}
+
+	h := loadFunc(lookup(t, "fluxtest/cr", "H"))
+	if h == nil {
+		t.Fatal("loadFunc failed")
+	}
+	h.funcblk.paste()
+	var lit *basicLiteralNode
+	var op *operatorNode
+	var call *callNode
+	for n := range h.funcblk.nodes {
+		switch n := n.(type) {
+		case *basicLiteralNode:
+			lit = n
+		case *operatorNode:
+			op = n
+		case *callNode:
+			call = n
+		case *portsNode:
+		default:
+			t.Errorf("pasted an unexpected %T", n)
+		}
+	}
+	if lit == nil || op == nil || call == nil {
+		t.Fatalf("pasted %d nodes, want 2, x*2 and cq.G", len(h.funcblk.nodes)-2)
+	}
+	if len(h.funcblk.selection) != 3 {
+		t.Errorf("%d nodes selected after pasting, want 3", len(h.funcblk.selection))
+	}
+	if call.obj.GetPkg().Path != "fluxtest/cq" || call.obj.GetName() != "G" {
+		t.Errorf("pasted a call of %s.%s, want fluxtest/cq.G", call.obj.GetPkg().Path, call.obj.GetName())
+	}
+
+	// the connections among the nodes are kept; those to the rest of F are cut
+	if c := ins(op)[1].conns; len(c) != 1 || c[0].src.node != lit {
+		t.Error("the literal is not connected to the operator")
+	}
+	if c := ins(call)[0].conns; len(c) != 1 || c[0].src.node != op {
+		t.Error("the operator is not connected to the call")
+	}
+	if len(ins(op)[0].conns) != 0 || len(outs(call)[0].conns) != 0 {
+		t.Error("a connection to the rest of F was pasted")
+	}
+
+	if h.pkgRefs[call.obj.GetPkg()] != 1 {
+		t.Errorf("package references %v, want fluxtest/cq once", h.pkgRefs)
+	}
+	if src := funcSource(h); !bytes.Contains(src, []byte(`"fluxtest/cq"`)) {
+		t.Errorf("H doesn't import fluxtest/cq:\n%s", src)
+	}
+}
+
+// TestMoveNodes moves a node into a loop:  its connection from outside is kept, and its connection to the loop itself, which would make a cycle, is removed.
+func TestMoveNodes(t *testing.T) {
+	_, cleanup := testPackage(t, "fluxtest/m", map[string]string{
+		"m.go": `package m
+
+func M(x int) {
+	n := x * 2
+	for i := 0; i < n; i++ {
+	}
+}
+`,
+	})
+	defer cleanup()
+
+	f := loadFunc(lookup(t, "fluxtest/m", "M"))
+	if f == nil {
+		t.Fatal("loadFunc failed")
+	}
+	var op *operatorNode
+	var loop *loopNode
+	for n := range f.funcblk.nodes {
+		switch n := n.(type) {
+		case *operatorNode:
+			op = n
+		case *loopNode:
+			loop = n
+		}
+	}
+	if op == nil || loop == nil {
+		t.Fatal("M has no operator or loop")
+	}
+	if c := outs(op)[0].conns; len(c) != 1 || c[0].dst.node != loop {
+		t.Fatal("the operator doesn't feed the loop")
+	}
+	loop.loopblk.moveNodes(map[node]bool{op: true})
+	if op.block() != loop.loopblk || f.funcblk.nodes[op] != 0 {
+		t.Fatal("the operator was not moved into the loop")
+	}
+	if c := ins(op)[0].conns; len(c) != 1 || c[0].src.node != f.inputsNode {
+		t.Error("the connection from x was not kept")
+	}
+	if len(outs(op)[0].conns) != 0 {
+		t.Error("the connection to the loop was not removed")
+	}
+}
+
+// An unfinished connection, such as one being dragged, is not written.
+func TestWriteUnfinishedConn(t *testing.T) {
+	_, cleanup := testPackage(t, "fluxtest/w", map[string]string{
+		"w.go": `package w
+
+func Add(x, y int) int { return x + y }
+`,
+	})
+	defer cleanup()
+
+	f := loadFunc(lookup(t, "fluxtest/w", "Add"))
+	if f == nil {
+		t.Fatal("loadFunc failed")
+	}
+	src := funcSource(f)
+	var op *operatorNode
+	f.funcblk.walk(nil, func(n node) {
+		if n, ok := n.(*operatorNode); ok {
+			op = n
+		}
+	}, nil)
+	c := newConnection()
+	c.setDst(op.ins[0])
+	if src2 := funcSource(f); !bytes.Equal(src2, src) {
+		t.Errorf("with a connection without a source, got\n%s\nwant\n%s", src2, src)
+	}
+	c.setDst(nil)
+
+	c = newConnection()
+	c.setSrc(f.inputsNode.outs[0])
+	if f.history.dirty() {
+		t.Error("a connection without a destination made the func dirty")
+	}
+}
+
+func TestUpdateClients(t *testing.T) {
+	dir, cleanup := testPackage(t, "fluxtest/d", map[string]string{
+		"d.go": `package d
+
+func F(x, y int) int { return x + y }
+
+func G(a, b int) int { return F(a, b) * 2 }
+
+func V(t T) int { return t.A }
+`,
+		"h.go": `package d
+
+func H() int { return F(1, 2) }
+
+func U() int { return T{A: 1}.A }
+`,
+		"T.flux.go": `// Generated by Flux, not meant for human consumption.  Editing may make it unreadable by Flux.
+
+package d
+
+type T struct {
+	A int
+}
+`,
+	})
+	defer cleanup()
+
+	f := loadFunc(lookup(t, "fluxtest/d", "F"))
+	for _, name := range []string{"G", "V"} {
+		if loadFunc(lookup(t, "fluxtest/d", name)) == nil {
+			t.Fatalf("loadFunc(%s) failed", name)
+		}
+	}
+
+	f.inputsNode.removePort(f.inputsNode.outs[0])
+	c := sigChange(f)
+	if c == nil {
+		t.Fatal("no change to signature")
+	}
+	clients := findClients(c)
+	if len(clients) != 2 {
+		t.Fatalf("found %d clients of F, want 2", len(clients))
+	}
+	if err := saveFunc(f); err != nil {
+		t.Fatal(err)
+	}
+	for _, cl := range clients {
+		if len(cl.manual) > 0 {
+			t.Errorf("%s: %s", cl.name, cl.manual)
+		}
+		if err := cl.update(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	checkPackage(t, "fluxtest/d", dir)
+	if src, _ := ioutil.ReadFile(filepath.Join(dir, "h.go")); !strings.Contains(string(src), "F(2)") {
+		t.Errorf("call in h.go not updated:\n%s", src)
+	}
+
+	typ := lookup(t, "fluxtest/d", "T").(*types.TypeName).Type.(*types.Named)
+	names := fieldNames(typ)
+	typ.UnderlyingT.(*types.Struct).Fields[0].Name = "B"
+	c = fieldChange(typ, names)
+	if c == nil {
+		t.Fatal("no change to fields")
+	}
+	clients = findClients(c)
+	if len(clients) != 2 {
+		t.Fatalf("found %d clients of T, want 2", len(clients))
+	}
+	if err := saveType(typ); err != nil {
+		t.Fatal(err)
+	}
+	for _, cl := range clients {
+		if err := cl.update(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	checkPackage(t, "fluxtest/d", dir)
+}
+
+func TestVarConst(t *testing.T) {
+	const header = "// Generated by Flux, not meant for human consumption.  Editing may make it unreadable by Flux.\n\npackage e\n\n"
+	dir, cleanup := testPackage(t, "fluxtest/e", map[string]string{
+		"e.go": `package e
+
+const B = 2
+`,
+		"K.flux.go": header + "const K = -1 + (B * (3 - 4))\n",
+		"S.flux.go": header + "const S = (string)(\"a\" + \"b\")\n",
+		"V.flux.go": header + "var V int\n",
+		"W.flux.go": header + `var W int = func () (x int) {
+	const x2 = 3
+	x = x2
+	return
+}()
+`,
+	})
+	defer cleanup()
+
+	for _, name := range []string{"K", "S", "V", "W"} {
+		obj := lookup(t, "fluxtest/e", name)
+		src, err := ioutil.ReadFile(fluxPath(obj))
+		if err != nil {
+			t.Fatal(err)
+		}
+		f, err := readFuncObj(obj)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if src2 := funcSource(f); !bytes.Equal(src2, src) {
+			t.Errorf("writing a read %s changed it from\n%s\nto\n%s", name, src, src2)
+		}
+		if err := saveFunc(f); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for name, want := range map[string]string{"K": "-3", "S": `"ab"`} {
+		if val := lookup(t, "fluxtest/e", name).(*types.Const).Val(); val == nil || val.String() != want {
+			t.Errorf("%s = %v, want %s", name, val, want)
+		}
+	}
+	checkPackage(t, "fluxtest/e", dir)
+
+	// a char literal whose text is still empty has no value
+	f, err := readFuncObj(lookup(t, "fluxtest/e", "K"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := newBasicLiteralNode(token.CHAR)
+	f.funcblk.addNode(n)
+	f.outputsNode.ins[0].conns[0].setSrc(n.outs[0])
+	if src := string(funcSource(f)); !strings.HasSuffix(src, "const K\n") {
+		t.Errorf("an empty char literal was written as a value:\n%s", src)
+	}
+}
@@ -19,11 +19,17 @@ type funcNode struct {
 	inputsNode, outputsNode *portsNode
 	focused                 bool
 
-	obj     types.Object
+	obj     types.Object // a *types.Func, or the *types.Var or *types.Const whose value this is
 	literal bool
 	pkgRefs map[*types.Package]int
+	history *history
 	done    func()
 
+	savedSig savedSig // to tell how clients must be updated when the signature changes
+
+	valueSig *types.Signature // for a var or const, func() T, the signature of its value
+	value    *Text            // for a const, its folded value
+
 	animate blockchan
 	stop    stopchan
 }
@@ -31,7 +37,13 @@ type funcNode struct {
 func newFuncNode(obj types.Object, arranged blockchan) *funcNode {
 	n := &funcNode{obj: obj, literal: obj == nil}
 	n.ViewBase = NewView(n)
-	n.AggregateMouser = AggregateMouser{NewClickFocuser(n), NewMover(n)}
+	switch obj := obj.(type) {
+	case *types.Var:
+		n.valueSig = &types.Signature{Results: []*types.Var{newVar("", obj.Type)}}
+	case *types.Const:
+		n.valueSig = &types.Signature{Results: []*types.Var{newVar("", nil)}}
+	}
+	n.AggregateMouser = AggregateMouser{NewClickFocuser(n), NewMover(n), newDropper(n)}
 	if n.literal {
 		n.output = newOutput(n, newVar("", &types.Signature{}))
 		MoveCenter(n.output, Pt(0, -portSize))
@@ -42,19 +54,29 @@ func newFuncNode(obj types.Object, arranged blockchan) *funcNode {
 		n.stop = make(stopchan)
 		arranged = n.animate
 	}
+	n.newFuncblk(arranged)
+	return n
+}
+
+func (n *funcNode) newFuncblk(arranged blockchan) {
 	n.funcblk = newBlock(n, arranged)
 	n.inputsNode = newInputsNode()
-	n.inputsNode.editable = true
+	n.inputsNode.editable = n.valueSig == nil
 	n.funcblk.addNode(n.inputsNode)
 	n.outputsNode = newOutputsNode()
-	n.outputsNode.editable = true
+	n.outputsNode.editable = n.valueSig == nil
 	n.funcblk.addNode(n.outputsNode)
-	return n
 }
 
+// Close closes n without saving it; unsaved changes are kept in the autosave journal.  See confirmClose.
 func (n *funcNode) Close() {
 	if !n.literal {
-		saveFunc(n)
+		if n.history != nil {
+			if n.history.dirty() {
+				autosave(n.obj, func() []byte { return funcSource(n) })
+			}
+			dropViews(n.history.states)
+		}
 		n.funcblk.close()
 		n.stop.stop()
 		n.done()
@@ -62,12 +84,45 @@ func (n *funcNode) Close() {
 	n.ViewBase.Close()
 }
 
+// confirmClose closes n and calls closed, first asking whether to save unsaved changes.  If the user cancels, n stays open and closed is not called.
+func (n *funcNode) confirmClose(closed func()) {
+	if !n.history.dirty() {
+		n.Close()
+		closed()
+		return
+	}
+	if _, ok := KeyFocus(n).(*prompt); ok {
+		return
+	}
+	p := newPrompt(fmt.Sprintf("Save changes to %s?", n.obj.GetName()), "Save", "Don't Save", "Cancel")
+	p.show(Parent(n), func(answer int) {
+		switch answer {
+		case 0:
+			n.save(func() {
+				n.Close()
+				closed()
+			})
+		case 1:
+			n.Close()
+			removeAutosave(n.obj)
+			closed()
+		}
+	})
+}
+
+// save saves n and calls saved.  If the signature of n has changed, the user is first offered to update its clients.
+func (n *funcNode) save(saved func()) {
+	updateClients(sigChange(n), n, true, func() error { return saveFunc(n) }, saved)
+}
+
 func (n *funcNode) sig() *types.Signature {
-	obj := n.obj
-	if obj == nil {
-		obj = n.output.obj
+	switch obj := n.obj.(type) {
+	case nil:
+		return n.output.obj.Type.(*types.Signature)
+	case *types.Func:
+		return obj.Type.(*types.Signature)
 	}
-	return obj.GetType().(*types.Signature)
+	return n.valueSig
 }
 
 func (n funcNode) pkg() *types.Package {
@@ -144,7 +199,13 @@ func (n *funcNode) LostKeyFocus() {
 
 func (n *funcNode) KeyPress(event KeyEvent) {
 	if event.Command && event.Key == KeyS && !n.literal {
-		saveFunc(n)
+		n.save(func() {})
+	} else if event.Command && event.Key == KeyZ && !n.literal {
+		if event.Shift {
+			n.history.redo()
+		} else {
+			n.history.undo()
+		}
 	} else if event.Key == KeyUp && n.literal {
 		SetKeyFocus(n.outputsNode)
 	} else {
@@ -134,6 +134,8 @@ func NewConst(pos token.Pos, pkg *Package, name string, typ Type, val exact.Valu
 
 func (obj *Const) Val() exact.Value { return obj.val }
 
+func (obj *Const) SetVal(val exact.Value) { obj.val = val }
+
 // A TypeName represents a declared type.
 type TypeName struct {
 	object
@@ -1,9 +1,9 @@
+// +build !headless
+
 package gui
 
 import . "github.com/chsc/gogl/gl21"
 
-type Color struct{ R, G, B, A float64 }
-
 func SetColor(c Color)       { Color4d(Double(c.R), Double(c.G), Double(c.B), Double(c.A)) }
 func SetPointSize(x float64) { PointSize(Float(x)) }
 func SetLineWidth(x float64) { LineWidth(Float(x)) }
@@ -62,6 +62,22 @@ func DrawBezier(ctrlPts ...Point) {
 	EvalMesh1(LINE, 0, Int(steps))
 }
 
+// DrawGradientBezier draws a bezier curve whose color fades from c1 to c2.
+func DrawGradientBezier(c1, c2 Color, ctrlPts ...Point) {
+	colors := []Double{Double(c1.R), Double(c1.G), Double(c1.B), Double(c1.A), Double(c2.R), Double(c2.G), Double(c2.B), Double(c2.A)}
+	Map1d(MAP1_COLOR_4, 0, 1, 4, 2, &colors[0])
+	Enable(MAP1_COLOR_4)
+	defer Disable(MAP1_COLOR_4)
+	DrawBezier(ctrlPts...)
+}
+
 func Rotate(rot float64) {
 	Rotated(Double(rot*360), 0, 0, 1)
 }
+
+func pushMatrix() { PushMatrix() }
+func popMatrix()  { PopMatrix() }
+
+func translate(d Point) {
+	Translated(Double(d.X), Double(d.Y), 0)
+}
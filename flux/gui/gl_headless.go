@@ -0,0 +1,22 @@
+// +build headless
+
+package gui
+
+// Without OpenGL, nothing is drawn.
+
+func SetColor(c Color)                                  {}
+func SetPointSize(x float64)                            {}
+func SetLineWidth(x float64)                            {}
+func DrawPoint(p Point)                                 {}
+func DrawLine(p1, p2 Point)                             {}
+func DrawRect(r Rectangle)                              {}
+func FillRect(r Rectangle)                              {}
+func DrawPolygon(pts ...Point)                          {}
+func FillPolygon(pts ...Point)                          {}
+func DrawBezier(ctrlPts ...Point)                       {}
+func DrawGradientBezier(c1, c2 Color, ctrlPts ...Point) {}
+func Rotate(rot float64)                                {}
+
+func pushMatrix()       {}
+func popMatrix()        {}
+func translate(d Point) {}
@@ -0,0 +1,223 @@
+// +build !headless
+
+package gui
+
+import (
+	"code.google.com/p/gordon-go/ftgl"
+	"code.google.com/p/gordon-go/glfw"
+	gl "github.com/chsc/gogl/gl21"
+	"go/build"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+)
+
+var do = make(chan func(), 1)
+
+// must be called on the main goroutine, which will run on the main thread because glfw calls runtime.LockOSThread()
+func Run(init func()) error {
+	glfw.OnError(func(err error) {
+		log.Println("GLFW: ", err)
+	})
+	if err := glfw.Init(); err != nil {
+		return err
+	}
+	defer glfw.Terminate()
+	if err := gl.Init(); err != nil {
+		return err
+	}
+	go init()
+	for len(windows) == 0 {
+		(<-do)()
+	}
+	for len(windows) > 0 {
+		glfw.WaitEvents()
+		select {
+		case f := <-do:
+			f()
+		default:
+		}
+	}
+	return nil
+}
+
+func doMain(f func()) {
+	done := make(chan bool)
+	do <- func() {
+		f()
+		done <- true
+	}
+	glfw.PostEmptyEvent()
+	<-done
+}
+
+type nativeWindow struct{ *glfw.Window }
+
+func newNativeWindow(title string) nativeWindow {
+	return nativeWindow{glfw.NewWindow(960, 520, title)}
+}
+
+func (w *Window) run() {
+	runtime.LockOSThread()
+	glfw.MakeContextCurrent(w.w.Window)
+	defer glfw.MakeContextCurrent(nil)
+
+	// glfw should fire initial resize events to avoid this duplication (https://github.com/glfw/glfw/issues/62)
+	width, height := w.w.Size()
+	gl.MatrixMode(gl.PROJECTION)
+	gl.LoadIdentity()
+	gl.Ortho(0, gl.Double(width), 0, gl.Double(height), -1, 1)
+	Resize(w, Pt(float64(width), float64(height)))
+	width, height = w.w.FramebufferSize()
+	gl.Viewport(0, 0, gl.Sizei(width), gl.Sizei(height))
+
+	gl.Enable(gl.BLEND)
+	gl.Enable(gl.POINT_SMOOTH)
+	gl.Enable(gl.LINE_SMOOTH)
+	gl.BlendFunc(gl.SRC_ALPHA, gl.ONE_MINUS_SRC_ALPHA)
+
+	for !w.close {
+		select {
+		case f := <-w.do:
+			f()
+		case <-w.paint:
+			gl.MatrixMode(gl.MODELVIEW)
+			gl.LoadIdentity()
+
+			gl.Clear(gl.COLOR_BUFFER_BIT | gl.DEPTH_BUFFER_BIT)
+			w.base().paint()
+			w.w.SwapBuffers()
+		}
+	}
+}
+
+func (w *Window) registerCallbacks() {
+	w.w.OnFocus(func(focused bool) {
+		if focused {
+			go doMain(func() {
+				for i, w2 := range windows {
+					if w2 == w {
+						windows = append(append([]*Window{w}, windows[:i]...), windows[i+1:]...)
+						break
+					}
+				}
+			})
+		}
+	})
+	w.w.OnClose(w.Close)
+	w.w.OnResize(func(width, height int) {
+		w.Do(func() {
+			s := Pt(float64(width), float64(height))
+			gl.MatrixMode(gl.PROJECTION)
+			gl.LoadIdentity()
+			gl.Ortho(0, gl.Double(s.X), 0, gl.Double(s.Y), -1, 1)
+			Resize(w.Self, s)
+			if w.centralView != nil {
+				Resize(w.centralView, s)
+			}
+		})
+	})
+	w.w.OnFramebufferResize(func(width, height int) {
+		w.Do(func() {
+			gl.Viewport(0, 0, gl.Sizei(width), gl.Sizei(height))
+		})
+	})
+
+	k := KeyEvent{}
+	w.w.OnKey(func(key, scancode, action, mods int) {
+		w.Do(func() {
+			k.Key = key
+			k.action = action
+			k.Repeat = action == glfw.Repeat
+			k.Shift = mods&glfw.ModShift != 0
+			k.Ctrl = mods&glfw.ModControl != 0
+			k.Alt = mods&glfw.ModAlt != 0
+			k.Super = mods&glfw.ModSuper != 0
+			k.Command = commandKey(k)
+			if key >= KeyEscape || action == glfw.Release {
+				k.Text = ""
+				if w.keyFocus != nil {
+					if k.action != glfw.Release {
+						w.keyFocus.KeyPress(k)
+					} else {
+						w.keyFocus.KeyRelease(k)
+					}
+				}
+			}
+		})
+	})
+	w.w.OnChar(func(char rune) {
+		w.Do(func() {
+			if char < KeyEscape {
+				k.Text = string(char)
+				if w.keyFocus != nil {
+					if k.action != glfw.Release {
+						w.keyFocus.KeyPress(k)
+					} else {
+						w.keyFocus.KeyRelease(k)
+					}
+				}
+			}
+		})
+	})
+
+	m := MouseEvent{}
+	w.w.OnMouseMove(func(x, y float64) {
+		m.Pos = Pt(x, y)
+		m.Move, m.Press, m.Release, m.Drag = true, false, false, false
+		w.mouse(m)
+	})
+	w.w.OnMouseButton(func(button, action, mods int) {
+		m.Button = button
+		m.Move, m.Press, m.Release, m.Drag = false, action == glfw.Press, action == glfw.Release, false
+		w.mouse(m)
+	})
+	w.w.OnScroll(func(dx, dy float64) {
+		w.Do(func() {
+			s := ScrollEvent{m.Pos, Pt(dx, -dy)}
+			s.Pos = w.mapToWindow(s.Pos)
+			v, _ := viewAtFunc(w.Self, s.Pos, func(v View) View {
+				v, _ = v.(ScrollerView)
+				return v
+			}).(ScrollerView)
+			if v != nil {
+				s.Pos = Map(s.Pos, w.Self, v)
+				v.Scroll(s)
+			}
+		})
+	})
+}
+
+var fontCache = struct {
+	sync.Mutex
+	m map[*glfw.Window]ftgl.Font
+}{m: map[*glfw.Window]ftgl.Font{}}
+
+// getFont returns the font of the current OpenGL context or, if there is none (as when views are made without a window), a font that only approximates its metrics.
+func getFont() font {
+	w := glfw.GetCurrentContext()
+	if w == nil {
+		return nullFont{}
+	}
+	fontCache.Lock()
+	defer fontCache.Unlock()
+	f := fontCache.m[w]
+	if f.Nil() {
+		f = ftgl.NewTextureFont(filepath.Join(pkgDir(), "Times New Roman.ttf"))
+		f.SetFaceSize(18, 1)
+		fontCache.m[w] = f
+	}
+	return f
+}
+
+func pkgDir() string {
+	for _, dir := range build.Default.SrcDirs() {
+		dir := filepath.Join(dir, "code.google.com/p/gordon-go/flux/gui")
+		if _, err := os.Stat(dir); err == nil {
+			return dir
+		}
+	}
+	panic("unreachable")
+}
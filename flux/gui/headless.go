@@ -0,0 +1,58 @@
+// +build headless
+
+package gui
+
+import "sync"
+
+// Without a windowing system, windows are never shown and receive no input, but views may be made, laid out, and given key focus as usual.
+
+var (
+	mainMu    sync.Mutex
+	destroyed = make(chan bool, 1)
+)
+
+func Run(init func()) error {
+	go init()
+	for {
+		<-destroyed
+		n := 0
+		doMain(func() { n = len(windows) })
+		if n == 0 {
+			return nil
+		}
+	}
+}
+
+func doMain(f func()) {
+	mainMu.Lock()
+	defer mainMu.Unlock()
+	f()
+}
+
+type nativeWindow struct{}
+
+func newNativeWindow(title string) nativeWindow { return nativeWindow{} }
+func (nativeWindow) SetTitle(title string)      {}
+func (nativeWindow) Show()                      {}
+func (nativeWindow) Destroy() {
+	select {
+	case destroyed <- true:
+	default:
+	}
+}
+
+func (w *Window) run() {
+	Resize(w, Pt(960, 520))
+	for !w.close {
+		select {
+		case f := <-w.do:
+			f()
+		case <-w.paint:
+			w.base().paint()
+		}
+	}
+}
+
+func (w *Window) registerCallbacks() {}
+
+func getFont() font { return nullFont{} }
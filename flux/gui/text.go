@@ -1,20 +1,14 @@
 package gui
 
 import (
-	"code.google.com/p/gordon-go/ftgl"
-	"code.google.com/p/gordon-go/glfw"
-	gl "github.com/chsc/gogl/gl21"
-	"go/build"
-	"os"
-	"path/filepath"
-	"sync"
 	"time"
+	"unicode/utf8"
 )
 
 type Text struct {
 	*ViewBase
 	text                string
-	font                ftgl.Font
+	font                font
 	textColor           Color
 	frameSize           float64
 	frameColor          Color
@@ -38,37 +32,20 @@ func NewText(text string) *Text {
 	return t
 }
 
-var fontCache = struct {
-	sync.Mutex
-	m map[*glfw.Window]ftgl.Font
-}{m: map[*glfw.Window]ftgl.Font{}}
-
-// Must be called from a thread holding an OpenGL context, i.e., a window callback thread.
-func getFont() ftgl.Font {
-	w := glfw.GetCurrentContext()
-	if w == nil {
-		panic("no current context")
-	}
-	fontCache.Lock()
-	defer fontCache.Unlock()
-	font := fontCache.m[w]
-	if font.Nil() {
-		font = ftgl.NewTextureFont(filepath.Join(pkgDir(), "Times New Roman.ttf"))
-		font.SetFaceSize(18, 1)
-		fontCache.m[w] = font
-	}
-	return font
+type font interface {
+	Advance(text string) float64
+	Ascender() float64
+	Descender() float64
+	Render(text string)
 }
 
-func pkgDir() string {
-	for _, dir := range build.Default.SrcDirs() {
-		dir := filepath.Join(dir, "code.google.com/p/gordon-go/flux/gui")
-		if _, err := os.Stat(dir); err == nil {
-			return dir
-		}
-	}
-	panic("unreachable")
-}
+// nullFont approximates the metrics of the default font, and renders nothing.
+type nullFont struct{}
+
+func (nullFont) Advance(text string) float64 { return 8 * float64(utf8.RuneCountInString(text)) }
+func (nullFont) Ascender() float64           { return 16 }
+func (nullFont) Descender() float64          { return -4 }
+func (nullFont) Render(text string)          {}
 
 func (t Text) Text() string { return t.text }
 func (t *Text) SetText(text string) {
@@ -176,6 +153,6 @@ func (t *Text) Paint() {
 	}
 
 	SetColor(t.textColor)
-	gl.Translated(gl.Double(t.frameSize), gl.Double(t.frameSize-t.font.Descender()), 0)
+	translate(Pt(t.frameSize, t.frameSize-t.font.Descender()))
 	t.font.Render(t.text)
 }
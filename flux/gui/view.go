@@ -1,9 +1,6 @@
 package gui
 
-import (
-	. "code.google.com/p/gordon-go/util"
-	gl "github.com/chsc/gogl/gl21"
-)
+import . "code.google.com/p/gordon-go/util"
 
 type View interface {
 	base() *ViewBase
@@ -220,10 +217,9 @@ func (v *ViewBase) paint() {
 	if v.hidden {
 		return
 	}
-	gl.PushMatrix()
-	defer gl.PopMatrix()
-	d := MapToParent(ZP, v)
-	gl.Translated(gl.Double(d.X), gl.Double(d.Y), 0)
+	pushMatrix()
+	defer popMatrix()
+	translate(MapToParent(ZP, v))
 	v.Self.Paint()
 	for _, child := range v.children {
 		child.base().paint()
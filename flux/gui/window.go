@@ -1,13 +1,7 @@
 package gui
 
-import (
-	"code.google.com/p/gordon-go/glfw"
-	gl "github.com/chsc/gogl/gl21"
-	"runtime"
-)
-
 type Window struct {
-	w *glfw.Window
+	w nativeWindow
 	*ViewBase
 	centralView View
 	keyFocus    View
@@ -16,6 +10,9 @@ type Window struct {
 	close       bool
 	paint       chan bool
 	do          chan func()
+
+	// ConfirmClose, if set, is called (on the window's goroutine) instead of closing the window; it should call close if and when the window may be closed.
+	ConfirmClose func(close func())
 }
 
 func NewWindow(self View, title string, init func(w *Window)) {
@@ -24,7 +21,7 @@ func NewWindow(self View, title string, init func(w *Window)) {
 		self = w
 	}
 	doMain(func() {
-		w.w = glfw.NewWindow(960, 520, title)
+		w.w = newNativeWindow(title)
 		windows = append([]*Window{w}, windows...)
 	})
 	w.ViewBase = NewView(self)
@@ -36,137 +33,6 @@ func NewWindow(self View, title string, init func(w *Window)) {
 	go doMain(w.registerCallbacks)
 }
 
-func (w *Window) run() {
-	runtime.LockOSThread()
-	glfw.MakeContextCurrent(w.w)
-	defer glfw.MakeContextCurrent(nil)
-
-	// glfw should fire initial resize events to avoid this duplication (https://github.com/glfw/glfw/issues/62)
-	width, height := w.w.Size()
-	gl.MatrixMode(gl.PROJECTION)
-	gl.LoadIdentity()
-	gl.Ortho(0, gl.Double(width), 0, gl.Double(height), -1, 1)
-	Resize(w, Pt(float64(width), float64(height)))
-	width, height = w.w.FramebufferSize()
-	gl.Viewport(0, 0, gl.Sizei(width), gl.Sizei(height))
-
-	gl.Enable(gl.BLEND)
-	gl.Enable(gl.POINT_SMOOTH)
-	gl.Enable(gl.LINE_SMOOTH)
-	gl.BlendFunc(gl.SRC_ALPHA, gl.ONE_MINUS_SRC_ALPHA)
-
-	for !w.close {
-		select {
-		case f := <-w.do:
-			f()
-		case <-w.paint:
-			gl.MatrixMode(gl.MODELVIEW)
-			gl.LoadIdentity()
-
-			gl.Clear(gl.COLOR_BUFFER_BIT | gl.DEPTH_BUFFER_BIT)
-			w.base().paint()
-			w.w.SwapBuffers()
-		}
-	}
-}
-
-func (w *Window) registerCallbacks() {
-	w.w.OnFocus(func(focused bool) {
-		if focused {
-			go doMain(func() {
-				for i, w2 := range windows {
-					if w2 == w {
-						windows = append(append([]*Window{w}, windows[:i]...), windows[i+1:]...)
-						break
-					}
-				}
-			})
-		}
-	})
-	w.w.OnClose(w.Close)
-	w.w.OnResize(func(width, height int) {
-		w.Do(func() {
-			s := Pt(float64(width), float64(height))
-			gl.MatrixMode(gl.PROJECTION)
-			gl.LoadIdentity()
-			gl.Ortho(0, gl.Double(s.X), 0, gl.Double(s.Y), -1, 1)
-			Resize(w.Self, s)
-			if w.centralView != nil {
-				Resize(w.centralView, s)
-			}
-		})
-	})
-	w.w.OnFramebufferResize(func(width, height int) {
-		w.Do(func() {
-			gl.Viewport(0, 0, gl.Sizei(width), gl.Sizei(height))
-		})
-	})
-
-	k := KeyEvent{}
-	w.w.OnKey(func(key, scancode, action, mods int) {
-		w.Do(func() {
-			k.Key = key
-			k.action = action
-			k.Repeat = action == glfw.Repeat
-			k.Shift = mods&glfw.ModShift != 0
-			k.Ctrl = mods&glfw.ModControl != 0
-			k.Alt = mods&glfw.ModAlt != 0
-			k.Super = mods&glfw.ModSuper != 0
-			k.Command = commandKey(k)
-			if key >= KeyEscape || action == glfw.Release {
-				k.Text = ""
-				if w.keyFocus != nil {
-					if k.action != glfw.Release {
-						w.keyFocus.KeyPress(k)
-					} else {
-						w.keyFocus.KeyRelease(k)
-					}
-				}
-			}
-		})
-	})
-	w.w.OnChar(func(char rune) {
-		w.Do(func() {
-			if char < KeyEscape {
-				k.Text = string(char)
-				if w.keyFocus != nil {
-					if k.action != glfw.Release {
-						w.keyFocus.KeyPress(k)
-					} else {
-						w.keyFocus.KeyRelease(k)
-					}
-				}
-			}
-		})
-	})
-
-	m := MouseEvent{}
-	w.w.OnMouseMove(func(x, y float64) {
-		m.Pos = Pt(x, y)
-		m.Move, m.Press, m.Release, m.Drag = true, false, false, false
-		w.mouse(m)
-	})
-	w.w.OnMouseButton(func(button, action, mods int) {
-		m.Button = button
-		m.Move, m.Press, m.Release, m.Drag = false, action == glfw.Press, action == glfw.Release, false
-		w.mouse(m)
-	})
-	w.w.OnScroll(func(dx, dy float64) {
-		w.Do(func() {
-			s := ScrollEvent{m.Pos, Pt(dx, -dy)}
-			s.Pos = w.mapToWindow(s.Pos)
-			v, _ := viewAtFunc(w.Self, s.Pos, func(v View) View {
-				v, _ = v.(ScrollerView)
-				return v
-			}).(ScrollerView)
-			if v != nil {
-				s.Pos = Map(s.Pos, w.Self, v)
-				v.Scroll(s)
-			}
-		})
-	})
-}
-
 func (w *Window) mouse(m MouseEvent) {
 	w.Do(func() {
 		switch {
@@ -241,9 +107,16 @@ func (w *Window) Do(f func()) {
 }
 
 func (w *Window) Close() {
-	go doMain(func() {
-		closeWindow(w)
-	})
+	destroy := func() {
+		go doMain(func() {
+			closeWindow(w)
+		})
+	}
+	if w.ConfirmClose != nil {
+		go w.Do(func() { w.ConfirmClose(destroy) })
+	} else {
+		destroy()
+	}
 }
 
 func (w *Window) SetTitle(s string) { w.w.SetTitle(s) }
@@ -0,0 +1,300 @@
+// Copyright 2014 Gordon Klaus. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"code.google.com/p/gordon-go/flux/go/types"
+	. "code.google.com/p/gordon-go/flux/gui"
+	"fmt"
+)
+
+// A history is a list of the states of a func or type, recorded as it is edited so that edits can be undone and redone.
+// A state is recorded when an edit is finished.  An edit of several steps (e.g., creating a node and then choosing its type) is a transaction; its state is recorded when its last step ends.
+// Only the last maxHistory states are kept.
+type history struct {
+	snapshot func() (key string, state interface{})
+	restore  func(from, to interface{}) // from is the state being left, which may keep the views showing it for when it is restored
+	recorded func() // if not nil, called when an edit is recorded
+	keys     []string // to tell whether an edit changed anything
+	states   []interface{}
+	i        int    // the current state
+	editing  int    // the depth of the transactions in progress
+	saved    string // the key of the state last saved, to tell whether there are unsaved changes
+}
+
+const maxHistory = 100
+
+// A viewCache is a state that may hold on to the views last showing it.  dropView releases them.
+type viewCache interface {
+	dropView()
+}
+
+func dropViews(states []interface{}) {
+	for _, s := range states {
+		if c, ok := s.(viewCache); ok {
+			c.dropView()
+		}
+	}
+}
+
+// histories outlive the views that edit them, so that a func or type reopened within a session keeps its history.
+var histories = map[types.Object]*history{}
+
+// loadHistory returns the history of obj, bound to a new view by snapshot and restore.
+// If obj has changed since its history was last recorded (e.g., it was edited outside of Flux), a new history is begun.
+func loadHistory(obj types.Object, snapshot func() (string, interface{}), restore func(from, to interface{})) *history {
+	key, state := snapshot()
+	h := histories[obj]
+	if h == nil || h.keys[h.i] != key {
+		h = &history{keys: []string{key}, states: []interface{}{state}, saved: key}
+		histories[obj] = h
+	}
+	h.snapshot, h.restore, h.recorded = snapshot, restore, nil
+	h.editing = 0
+	return h
+}
+
+func (h *history) begin() { h.editing++ }
+
+// markSaved records that the current state has been saved.
+func (h *history) markSaved() {
+	h.saved, _ = h.snapshot()
+}
+
+// dirty reports whether the state differs from the saved one.  It is called on a timer by autosave and when closing, so a panic taking a snapshot is reported rather than propagated, and the state is then assumed to be dirty.
+func (h *history) dirty() (dirty bool) {
+	defer func() {
+		if x := recover(); x != nil {
+			fmt.Printf("error comparing with the saved state: %v\n", x)
+			dirty = true
+		}
+	}()
+	key, _ := h.snapshot()
+	return key != h.saved
+}
+
+func (h *history) end() {
+	h.editing--
+	h.record()
+}
+
+func (h *history) record() {
+	if h.editing > 0 {
+		return
+	}
+	key, state := h.snapshot()
+	if key == h.keys[h.i] {
+		return
+	}
+	h.i++
+	dropViews(h.states[h.i:])
+	h.keys = append(h.keys[:h.i], key)
+	h.states = append(h.states[:h.i], state)
+	if n := len(h.states) - maxHistory; n > 0 {
+		dropViews(h.states[:n])
+		h.keys = append([]string(nil), h.keys[n:]...)
+		h.states = append([]interface{}(nil), h.states[n:]...)
+		h.i -= n
+	}
+	if h.recorded != nil {
+		h.recorded()
+	}
+}
+
+func (h *history) undo() {
+	if h.editing > 0 {
+		return
+	}
+	h.record() // in case an edit went unrecorded
+	if h.i > 0 {
+		h.i--
+		h.restore(h.states[h.i+1], h.states[h.i])
+	}
+}
+
+func (h *history) redo() {
+	if h.editing > 0 {
+		return
+	}
+	h.record()
+	if h.i < len(h.states)-1 {
+		h.i++
+		h.restore(h.states[h.i-1], h.states[h.i])
+	}
+}
+
+// historyOf returns the history of the func or type that v is a part of, or nil if there is none.
+func historyOf(v View) *history {
+	for ; v != nil; v = Parent(v) {
+		switch v := v.(type) {
+		case *funcNode:
+			if v.history != nil {
+				return v.history
+			}
+		case *typeView:
+			if v.history != nil {
+				return v.history
+			}
+		}
+	}
+	return nil
+}
+
+// beginEdit begins a transaction in the history of the func or type that v is a part of.  Nothing is recorded until the returned func ends it.
+func beginEdit(v View) (end func()) {
+	h := historyOf(v)
+	if h == nil {
+		return func() {}
+	}
+	h.begin()
+	return h.end
+}
+
+// recordEdit records the state of the func or type that v is a part of, unless a transaction is in progress.
+func recordEdit(v View) {
+	if h := historyOf(v); h != nil {
+		h.record()
+	}
+}
+
+// A funcState is a recorded state of a funcNode.  The reader takes the signature as given, so it is recorded alongside the source.
+// Reading the source rebuilds the whole func, so the views showing a state are kept when undo or redo leaves it, and reused when it is restored.
+type funcState struct {
+	src  []byte
+	sig  *types.Signature
+	view *funcView
+}
+
+// A funcView is a funcblk that has been taken out of its funcNode, along with the parts of the funcNode and its signature that go with it.
+type funcView struct {
+	f                       *funcNode
+	funcblk                 *block
+	inputsNode, outputsNode *portsNode
+	pkgRefs                 map[*types.Package]int
+	params, results         []*types.Var
+	isVariadic              bool
+	recvName                string
+	recvType                types.Type
+}
+
+func (f *funcNode) snapshot() (string, interface{}) {
+	src := funcSource(f)
+	return string(src), &funcState{src: src, sig: copySignature(f.sig())}
+}
+
+func (f *funcNode) restore(from, to interface{}) {
+	s := to.(*funcState)
+	sig := f.sig()
+	left := &funcView{f: f, funcblk: f.funcblk, inputsNode: f.inputsNode, outputsNode: f.outputsNode, pkgRefs: f.pkgRefs, params: sig.Params, results: sig.Results, isVariadic: sig.IsVariadic}
+	if sig.Recv != nil {
+		left.recvName, left.recvType = sig.Recv.Name, sig.Recv.Type
+	}
+	f.Remove(f.funcblk)
+	f.funcblk.setArranged(nil) // so that it doesn't disturb the animation of the funcblk that replaces it
+	from.(*funcState).dropView()
+	from.(*funcState).view = left
+
+	if v := s.view; v != nil {
+		s.view = nil
+		sig.Params, sig.Results, sig.IsVariadic = v.params, v.results, v.isVariadic
+		if sig.Recv != nil {
+			sig.Recv.Name, sig.Recv.Type = v.recvName, v.recvType
+		}
+		f.funcblk, f.inputsNode, f.outputsNode, f.pkgRefs = v.funcblk, v.inputsNode, v.outputsNode, v.pkgRefs
+		f.Add(f.funcblk)
+		f.funcblk.setArranged(f.animate)
+	} else {
+		saved := copySignature(s.sig)
+		sig.Params, sig.Results, sig.IsVariadic = saved.Params, saved.Results, saved.IsVariadic
+		if sig.Recv != nil {
+			sig.Recv.Name, sig.Recv.Type = saved.Recv.Name, saved.Recv.Type
+		}
+		f.pkgRefs = map[*types.Package]int{}
+		f.newFuncblk(f.animate)
+		if err := readFunc(f, s.src); err != nil {
+			fmt.Printf("error restoring %s: %s\n", f.obj.GetName(), err)
+		}
+	}
+	if f.isConst() {
+		f.showValue()
+	}
+	SetKeyFocus(f.inputsNode)
+}
+
+// dropView closes the views kept for s, if any.
+func (s *funcState) dropView() {
+	v := s.view
+	if v == nil {
+		return
+	}
+	s.view = nil
+	f := v.f
+	pkgRefs := f.pkgRefs
+	f.pkgRefs = v.pkgRefs // closing the funcblk removes its references
+	v.funcblk.close()
+	f.pkgRefs = pkgRefs
+}
+
+func (v *typeView) snapshot() (string, interface{}) {
+	t := copyType(*v.typ)
+	return types.TypeString(nil, t), t
+}
+
+func (v *typeView) restore(_, state interface{}) {
+	t, _ := state.(types.Type)
+	v.setType(copyType(t))
+	SetKeyFocus(v)
+}
+
+// copySignature copies s, including its receiver.
+func copySignature(s *types.Signature) *types.Signature {
+	c := copyType(s).(*types.Signature)
+	if s.Recv != nil {
+		c.Recv = copyVar(s.Recv)
+	}
+	return c
+}
+
+// copyType returns a copy of t, down to its named types.  Editing modifies types in place, so a recorded type must not share any part of them.
+// The receivers of signatures are shared, as an interface method's receiver is the interface itself.
+func copyType(t types.Type) types.Type {
+	switch t := t.(type) {
+	case *types.Pointer:
+		return &types.Pointer{Elem: copyType(t.Elem)}
+	case *types.Array:
+		return &types.Array{Len: t.Len, Elem: copyType(t.Elem)}
+	case *types.Slice:
+		return &types.Slice{Elem: copyType(t.Elem)}
+	case *types.Chan:
+		return &types.Chan{Dir: t.Dir, Elem: copyType(t.Elem)}
+	case *types.Map:
+		return &types.Map{Key: copyType(t.Key), Elem: copyType(t.Elem)}
+	case *types.Struct:
+		return &types.Struct{Fields: copyVars(t.Fields)}
+	case *types.Signature:
+		return &types.Signature{Recv: t.Recv, Params: copyVars(t.Params), Results: copyVars(t.Results), IsVariadic: t.IsVariadic}
+	case *types.Interface:
+		c := &types.Interface{Embeddeds: append([]*types.Named(nil), t.Embeddeds...)}
+		for _, m := range t.Methods {
+			sig, _ := copyType(m.Type).(*types.Signature)
+			c.Methods = append(c.Methods, types.NewFunc(0, m.Pkg, m.Name, sig))
+		}
+		return c
+	}
+	return t
+}
+
+func copyVars(vars []*types.Var) (c []*types.Var) {
+	for _, v := range vars {
+		c = append(c, copyVar(v))
+	}
+	return
+}
+
+func copyVar(v *types.Var) *types.Var {
+	c := types.NewVar(0, v.Pkg, v.Name, copyType(v.Type))
+	c.Anonymous, c.IsField = v.Anonymous, v.IsField
+	return c
+}
@@ -25,7 +25,7 @@ type ifNode struct {
 func newIfNode(arranged blockchan) *ifNode {
 	n := &ifNode{focused: -1, arranged: arranged}
 	n.ViewBase = NewView(n)
-	n.AggregateMouser = AggregateMouser{NewClickFocuser(n), NewMover(n)}
+	n.AggregateMouser = AggregateMouser{NewClickFocuser(n), NewMover(n), newDropper(n)}
 
 	n.seqIn = newInput(n, newVar("seq", seqType))
 	MoveCenter(n.seqIn, Pt(0, -portSize))
@@ -115,6 +115,10 @@ func (n *ifNode) LostKeyFocus() {
 }
 
 func (n *ifNode) KeyPress(event KeyEvent) {
+	if selecting(event) {
+		n.ViewBase.KeyPress(event)
+		return
+	}
 	switch event.Key {
 	case KeyUp:
 		if event.Alt && event.Shift {
@@ -139,6 +143,7 @@ func (n *ifNode) KeyPress(event KeyEvent) {
 	case KeyComma:
 		n.newBlock()
 		n.focus(len(n.blocks) - 1)
+		recordEdit(n)
 	case KeyBackspace, KeyDelete:
 		if len(n.blocks) == 1 {
 			n.ViewBase.KeyPress(event)
@@ -158,6 +163,7 @@ func (n *ifNode) KeyPress(event KeyEvent) {
 		}
 		n.focus(i)
 		rearrange(n.blk)
+		recordEdit(n)
 	default:
 		n.ViewBase.KeyPress(event)
 	}
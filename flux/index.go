@@ -109,6 +109,7 @@ func (n *indexNode) KeyPress(event KeyEvent) {
 			n.set = !n.set
 			n.connsChanged()
 			SetKeyFocus(n)
+			recordEdit(n)
 		}
 	} else {
 		n.nodeBase.KeyPress(event)
@@ -23,7 +23,7 @@ type loopNode struct {
 func newLoopNode(arranged blockchan) *loopNode {
 	n := &loopNode{}
 	n.ViewBase = NewView(n)
-	n.AggregateMouser = AggregateMouser{NewClickFocuser(n), NewMover(n)}
+	n.AggregateMouser = AggregateMouser{NewClickFocuser(n), NewMover(n), newDropper(n)}
 	n.input = newInput(n, nil)
 	n.input.connsChanged = n.connsChanged
 	MoveCenter(n.input, Pt(0, portSize))
@@ -68,7 +68,11 @@ func (n *loopNode) connectable(t types.Type, dst *port) bool {
 }
 
 func (n *loopNode) connsChanged() {
-	t := inputType(n.input)
+	n.setInputType(inputType(n.input))
+}
+
+// setInputType sets the type of n's input and of the key and element ports that depend on it.
+func (n *loopNode) setInputType(t types.Type) {
 	var key, elem types.Type
 	key = types.Typ[types.Int]
 	elemPort := true
@@ -127,6 +131,10 @@ func (n *loopNode) LostKeyFocus() {
 }
 
 func (n *loopNode) KeyPress(event KeyEvent) {
+	if selecting(event) {
+		n.ViewBase.KeyPress(event)
+		return
+	}
 	switch event.Key {
 	case KeyUp:
 		if event.Alt && event.Shift {
@@ -51,7 +51,7 @@ func newNodeBase(self node) *nodeBase {
 func newGoDeferNodeBase(self node, godefer string) *nodeBase {
 	n := &nodeBase{self: self, godefer: godefer}
 	n.ViewBase = NewView(n)
-	n.AggregateMouser = AggregateMouser{NewClickFocuser(self), NewMover(self)}
+	n.AggregateMouser = AggregateMouser{NewClickFocuser(self), NewMover(self), newDropper(self)}
 	n.pkg = newPkgText()
 	n.Add(n.pkg)
 	n.text = NewText("")
@@ -392,7 +392,10 @@ func newBasicLiteralNode(kind token.Token) *basicLiteralNode {
 			return true
 		}
 	}
-	n.text.Accept = func(string) { SetKeyFocus(n) }
+	n.text.Accept = func(string) {
+		SetKeyFocus(n)
+		recordEdit(n)
+	}
 	return n
 }
 
@@ -234,10 +234,12 @@ func (p *port) KeyPress(event KeyEvent) {
 		p.Add(b)
 		b.accepted = func(obj types.Object) {
 			b.Close()
+			end := beginEdit(p)
 			n := p.node.block().newNode(obj, b.funcAsVal, "")
 			c := newConnection()
 			c.setSrc(p)
 			c.setDst(ins(n)[0])
+			end()
 		}
 		b.canceled = func() {
 			b.Close()
@@ -259,6 +261,7 @@ func (p *port) KeyPress(event KeyEvent) {
 			removePort(*port)
 		}); ok {
 			n.removePort(p)
+			recordEdit(p.node)
 		} else {
 			SetKeyFocus(p.node)
 		}
@@ -275,6 +278,7 @@ func (p *port) KeyPress(event KeyEvent) {
 			} else {
 				p.setType(&types.Pointer{Elem: p.obj.Type})
 			}
+			recordEdit(p)
 		} else {
 			p.ViewBase.KeyPress(event)
 		}
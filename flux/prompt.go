@@ -0,0 +1,107 @@
+// Copyright 2014 Gordon Klaus. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	. "code.google.com/p/gordon-go/flux/gui"
+	"strings"
+)
+
+// A prompt asks a question, which may span several lines, and waits for one of several answers.  Left and Right select an answer and Enter chooses it; an answer can also be chosen by typing its first letter.  Escape chooses the last answer, which should be the one that changes nothing.
+type prompt struct {
+	*ViewBase
+	answers  []*Text
+	selected int
+	chose    func(answer int)
+	oldFocus View
+}
+
+func newPrompt(question string, answers ...string) *prompt {
+	p := &prompt{}
+	p.ViewBase = NewView(p)
+	x := 0.0
+	for _, a := range answers {
+		t := NewText(a)
+		t.SetBackgroundColor(noColor)
+		t.SetFrameSize(3)
+		t.Move(Pt(x, 0))
+		x += Width(t) + 8
+		p.Add(t)
+		p.answers = append(p.answers, t)
+	}
+	y := Height(p.answers[0]) + 8
+	lines := strings.Split(question, "\n")
+	for i := len(lines) - 1; i >= 0; i-- {
+		t := NewText(lines[i])
+		t.SetBackgroundColor(noColor)
+		t.Move(Pt(0, y))
+		y += Height(t)
+		p.Add(t)
+	}
+	ResizeToFit(p, 8)
+	p.selectAnswer(0)
+	return p
+}
+
+// show adds p to v and gives it the key focus.  Once an answer is chosen, p is closed, the key focus is returned, and chose is called with the index of the answer.
+func (p *prompt) show(v View, chose func(answer int)) {
+	p.oldFocus = KeyFocus(v)
+	p.chose = chose
+	v.Add(p)
+	MoveCenter(p, Center(v))
+	SetKeyFocus(p)
+}
+
+func (p *prompt) selectAnswer(i int) {
+	p.selected = i
+	for j, a := range p.answers {
+		if j == i {
+			a.SetFrameColor(focusColor)
+		} else {
+			a.SetFrameColor(noColor)
+		}
+	}
+}
+
+func (p *prompt) choose(i int) {
+	p.Close()
+	SetKeyFocus(p.oldFocus)
+	p.chose(i)
+}
+
+func (p *prompt) KeyPress(k KeyEvent) {
+	switch k.Key {
+	case KeyLeft:
+		if p.selected > 0 {
+			p.selectAnswer(p.selected - 1)
+		}
+	case KeyRight:
+		if p.selected < len(p.answers)-1 {
+			p.selectAnswer(p.selected + 1)
+		}
+	case KeyEnter:
+		p.choose(p.selected)
+	case KeyEscape:
+		p.choose(len(p.answers) - 1)
+	default:
+		if k.Command || k.Text == "" {
+			return
+		}
+		for i, a := range p.answers {
+			if strings.HasPrefix(strings.ToLower(a.Text()), strings.ToLower(k.Text)) {
+				p.choose(i)
+				return
+			}
+		}
+	}
+}
+
+func (p *prompt) Paint() {
+	SetColor(Color{0, 0, 0, .85})
+	FillRect(Rect(p))
+	SetColor(lineColor)
+	SetLineWidth(1)
+	DrawRect(Rect(p))
+}
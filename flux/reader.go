@@ -10,6 +10,7 @@ import (
 	"go/ast"
 	"go/parser"
 	"go/token"
+	"os"
 	"strconv"
 	"strings"
 )
@@ -20,36 +21,61 @@ import (
 
 func loadFunc(obj types.Object) *funcNode {
 	f := newFuncNode(obj, nil)
-	fset := token.NewFileSet()
-	file, err := parser.ParseFile(fset, fluxPath(obj), nil, parser.ParseComments)
-	if err == nil {
-		r := &reader{fset, obj.GetPkg(), types.NewScope(obj.GetPkg().Scope()), map[string]*port{}, map[string][]*connection{}, ast.NewCommentMap(fset, file, file.Comments), map[int]node{}}
-		for _, i := range file.Imports {
-			path, _ := strconv.Unquote(i.Path.Value)
-			pkg, err := getPackage(path)
-			if err != nil {
-				fmt.Printf("error importing %s: %s\n", i.Path.Value, err)
-				continue
+	if err := readFunc(f, nil); err != nil {
+		if _, ok := obj.(*types.Func); !ok {
+			if !os.IsNotExist(err) {
+				fmt.Printf("error reading %s: %s\n", obj.GetName(), err)
+				return nil
+			}
+			// this is a new var or const.  a const is saved once it has a value
+			f.newValuePort()
+			if _, ok := obj.(*types.Var); ok {
+				saveFunc(f)
 			}
-			name := pkg.Name
-			if i.Name != nil {
-				name = i.Name.Name
+		} else {
+			switch err := importFunc(f); err {
+			case nil:
+			case errNoDecl:
+				// this is a new func; save it
+				if isMethod(obj) {
+					f.inputsNode.newOutput(obj.GetType().(*types.Signature).Recv)
+				}
+				saveFunc(f)
+			default:
+				fmt.Printf("error importing %s:\n%s\n", obj.GetName(), err)
+				return nil
 			}
-			r.scope.Insert(types.NewPkgName(0, pkg, name))
 		}
-		decl := file.Decls[len(file.Decls)-1].(*ast.FuncDecl) // get param and result var names from the source, as the obj names might not match
+	}
+	f.history = loadHistory(obj, f.snapshot, f.restore)
+	if f.isConst() {
+		f.history.recorded = f.showValue
+		f.showValue()
+	}
+	f.savedSig = saveSig(f.sig())
+	return f
+}
+
+// readFunc reads the body of f (or the value of a var or const) from src, or from f's file if src is nil.
+func readFunc(f *funcNode, src interface{}) error {
+	obj := f.obj
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, fluxPath(obj), src, parser.ParseComments)
+	if err != nil {
+		return err
+	}
+	r := newReader(fset, file, obj.GetPkg())
+	switch decl := file.Decls[len(file.Decls)-1].(type) {
+	case *ast.FuncDecl: // get param and result var names from the source, as the obj names might not match
 		if decl.Recv != nil {
 			r.out(decl.Recv.List[0].Names[0], f.inputsNode.newOutput(obj.GetType().(*types.Signature).Recv))
 		}
 		r.fun(f, decl.Type, decl.Body)
-	} else {
-		// this is a new func; save it
-		if isMethod(obj) {
-			f.inputsNode.newOutput(obj.GetType().(*types.Signature).Recv)
-		}
-		saveFunc(f)
+	case *ast.GenDecl:
+		r.valueDecl(f, decl.Specs[0].(*ast.ValueSpec))
 	}
-	return f
+	r.removeDangling()
+	return nil
 }
 
 type reader struct {
@@ -62,14 +88,31 @@ type reader struct {
 	seqNodes map[int]node
 }
 
+// newReader returns a reader of file into pkg, with file's imports in scope.
+func newReader(fset *token.FileSet, file *ast.File, pkg *types.Package) *reader {
+	r := &reader{fset, pkg, types.NewScope(pkg.Scope()), map[string]*port{}, map[string][]*connection{}, ast.NewCommentMap(fset, file, file.Comments), map[int]node{}}
+	for _, i := range file.Imports {
+		path, _ := strconv.Unquote(i.Path.Value)
+		pkg, err := getPackage(path)
+		if err != nil {
+			fmt.Printf("error importing %s: %s\n", i.Path.Value, err)
+			continue
+		}
+		name := pkg.Name
+		if i.Name != nil {
+			name = i.Name.Name
+		}
+		r.scope.Insert(types.NewPkgName(0, pkg, name))
+	}
+	return r
+}
+
 func (r *reader) fun(n *funcNode, typ *ast.FuncType, body *ast.BlockStmt) {
-	obj := n.obj
 	f := n
-	if obj == nil {
-		obj = n.output.obj
+	if n.obj == nil {
 		f = n.blk.func_()
 	}
-	sig := obj.GetType().(*types.Signature)
+	sig := n.sig()
 
 	for i, p := range typ.Params.List {
 		v := sig.Params[i]
@@ -102,6 +145,26 @@ func (r *reader) fun(n *funcNode, typ *ast.FuncType, body *ast.BlockStmt) {
 	}
 }
 
+// valueDecl reads the value of f, a var or const.  A var's initializer is a call of a func literal, which is read as f's body; a const's expression is read as a tree of nodes.
+func (r *reader) valueDecl(f *funcNode, spec *ast.ValueSpec) {
+	switch f.obj.(type) {
+	case *types.Var:
+		if len(spec.Values) == 0 {
+			f.newValuePort()
+			return
+		}
+		lit := spec.Values[0].(*ast.CallExpr).Fun.(*ast.FuncLit)
+		r.fun(f, lit.Type, lit.Body)
+	case *types.Const:
+		p := f.newValuePort()
+		if len(spec.Values) > 0 {
+			c := newConnection()
+			c.setSrc(r.constOperand(f.funcblk, spec.Values[0]))
+			c.setDst(p)
+		}
+	}
+}
+
 func (r *reader) block(b *block, s []ast.Stmt) {
 	for _, s := range s {
 		switch s := s.(type) {
@@ -244,6 +307,13 @@ func (r *reader) block(b *block, s []ast.Stmt) {
 					}
 					r.out(v.Names[0], n.outs[0])
 					r.seq(n, s)
+				case *ast.UnaryExpr: // a negative number
+					lit := x.X.(*ast.BasicLit)
+					n := newBasicLiteralNode(lit.Kind)
+					b.addNode(n)
+					n.text.SetText(x.Op.String() + lit.Value)
+					r.out(v.Names[0], n.outs[0])
+					r.seq(n, s)
 				case *ast.Ident, *ast.SelectorExpr:
 					r.value(b, x, v.Names[0], false, s)
 				}
@@ -261,7 +331,7 @@ func (r *reader) block(b *block, s []ast.Stmt) {
 			n := newLoopNode(b.childArranged)
 			b.addNode(n)
 			if s.Cond != nil {
-				r.in(s.Cond.(*ast.BinaryExpr).Y, n.input)
+				r.loopInput(s.Cond.(*ast.BinaryExpr).Y, n)
 			}
 			if s.Init != nil {
 				r.out(s.Init.(*ast.AssignStmt).Lhs[0], n.inputsNode.outs[0])
@@ -289,7 +359,7 @@ func (r *reader) block(b *block, s []ast.Stmt) {
 		case *ast.RangeStmt:
 			n := newLoopNode(b.childArranged)
 			b.addNode(n)
-			r.in(s.X, n.input)
+			r.loopInput(s.X, n)
 			r.out(s.Key, n.inputsNode.outs[0])
 			if s.Value != nil {
 				r.out(s.Value, n.inputsNode.outs[1])
@@ -559,8 +629,11 @@ func (r *reader) typ(x ast.Expr) types.Type {
 	case *ast.ArrayType:
 		elem := r.typ(x.Elt)
 		if x.Len != nil {
-			// TODO: x.Len
-			return types.NewArray(elem, 0)
+			n := int64(0)
+			if lit, ok := x.Len.(*ast.BasicLit); ok {
+				n, _ = strconv.ParseInt(lit.Value, 0, 64)
+			}
+			return types.NewArray(elem, n)
 		}
 		return types.NewSlice(elem)
 	case *ast.Ellipsis:
@@ -578,8 +651,10 @@ func (r *reader) typ(x ast.Expr) types.Type {
 		return types.NewChan(dir, r.typ(x.Value))
 	case *ast.FuncType:
 		var params, results []*types.Var
+		variadic := false
 		for _, f := range x.Params.List {
 			t := r.typ(f.Type)
+			_, variadic = f.Type.(*ast.Ellipsis)
 			if f.Names == nil {
 				params = append(params, types.NewParam(0, r.pkg, "", t))
 			}
@@ -587,7 +662,6 @@ func (r *reader) typ(x ast.Expr) types.Type {
 				params = append(params, types.NewParam(0, r.pkg, n.Name, t))
 			}
 		}
-		variadic := false
 		if x.Results != nil {
 			for _, f := range x.Results.List {
 				t := r.typ(f.Type)
@@ -597,7 +671,6 @@ func (r *reader) typ(x ast.Expr) types.Type {
 				for _, n := range f.Names {
 					results = append(results, types.NewParam(0, r.pkg, n.Name, t))
 				}
-				_, variadic = f.Type.(*ast.Ellipsis)
 			}
 		}
 		return types.NewSignature(nil, nil, params, results, variadic)
@@ -633,6 +706,16 @@ func (r *reader) typ(x ast.Expr) types.Type {
 	panic("unreachable")
 }
 
+// loopInput connects x to n's input.  If it isn't connected (its connection was cut when n was copied), the input keeps the type of x so that the loop's ports keep theirs.
+func (r *reader) loopInput(x ast.Expr, n *loopNode) {
+	r.in(x, n.input)
+	if len(n.input.conns) == 0 {
+		if v, ok := r.scope.Lookup(name(x)).(*types.Var); ok {
+			n.setInputType(v.Type)
+		}
+	}
+}
+
 func (r *reader) out(x ast.Expr, out *port) {
 	r.ports[name(x)] = out
 }
@@ -651,6 +734,17 @@ func (r *reader) in(x ast.Expr, in *port) {
 	r.ports[name] = in //for feedback conns
 }
 
+// removeDangling removes the connections that were read without a destination:  those to a node that was not written because its results were unused, or that were cut when copied.
+func (r *reader) removeDangling() {
+	for _, conns := range r.conns {
+		for _, c := range conns {
+			if c.dst == nil {
+				c.blk.removeConn(c)
+			}
+		}
+	}
+}
+
 func (r *reader) seq(n node, an ast.Node) {
 	if c, ok := r.cmap[an]; ok {
 		s := strings.Split(c[0].List[0].Text[2:], ";")
@@ -0,0 +1,174 @@
+// Copyright 2014 Gordon Klaus. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"code.google.com/p/gordon-go/flux/go/types"
+	. "code.google.com/p/gordon-go/flux/gui"
+	"code.google.com/p/gordon-go/refactor"
+	"fmt"
+	"go/build"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Renames are carried out by the refactor package, which rewrites the uses of the renamed object in every package under GOPATH, or reports the conflicts and changes nothing if the new name would change the meaning of any code.  Flux then updates its loaded packages to match and rewrites the Flux files that refactor touched, so that they keep the form the writer gives them.
+
+// renameObj renames obj, a package-level object, method, or field of a Flux struct type, to name.
+func renameObj(obj types.Object, name string) error {
+	oldName := obj.GetName()
+	recv := ""
+	switch o := obj.(type) {
+	case *types.Func:
+		if r := o.Type.(*types.Signature).Recv; r != nil {
+			t, _ := indirect(r.Type)
+			recv = t.(*types.Named).Obj.Name
+		}
+	case field:
+		t, _ := indirect(o.recv)
+		recv = t.(*types.Named).Obj.Name
+	}
+
+	oldPaths := fluxPaths(obj)
+	setObjectName(obj, name)
+	newPaths := fluxPaths(obj)
+	setObjectName(obj, oldName)
+	for _, p := range newPaths {
+		if _, err := os.Stat(p); err == nil {
+			return fmt.Errorf("%s already exists", p)
+		}
+	}
+
+	files, undo, err := refactor.Rename(obj.GetPkg().Path, recv, oldName, name)
+	if err != nil {
+		return err
+	}
+	// the Flux files are moved along with the rewritten sources; if one can't be, the rest are moved back and the sources restored
+	for i := range oldPaths {
+		if err := os.Rename(oldPaths[i], newPaths[i]); err != nil {
+			for i--; i >= 0; i-- {
+				if err := os.Rename(newPaths[i], oldPaths[i]); err != nil {
+					fmt.Printf("error restoring %s: %s\n", oldPaths[i], err)
+				}
+			}
+			undo()
+			return err
+		}
+	}
+	removeObj(obj)
+	setObjectName(obj, name)
+	insertObj(obj)
+	for i, f := range files {
+		for j := range oldPaths {
+			if f == oldPaths[j] {
+				files[i] = newPaths[j]
+			}
+		}
+	}
+	resave(files)
+	return nil
+}
+
+// fluxPaths returns the paths of the Flux files of obj and, if it is a type, of its Flux methods.
+func fluxPaths(obj types.Object) (paths []string) {
+	if fluxObjs[obj] {
+		paths = append(paths, fluxPath(obj))
+	}
+	if t, ok := obj.(*types.TypeName); ok {
+		for _, m := range t.Type.(*types.Named).Methods {
+			if fluxObjs[m] {
+				paths = append(paths, fluxPath(m))
+			}
+		}
+	}
+	return
+}
+
+// movePackage moves the package at import path old, along with those below it, to new.
+func movePackage(old, new string) error {
+	oldDir := ""
+	if p, err := build.Import(old, "", build.FindOnly); err == nil {
+		oldDir = p.Dir
+	}
+	files, err := refactor.MovePackage(old, new)
+	if err != nil {
+		return err
+	}
+	moved := func(path string) (string, bool) {
+		if path == old || strings.HasPrefix(path, old+"/") {
+			return new + path[len(old):], true
+		}
+		return "", false
+	}
+	for path, pkg := range pkgs {
+		if newPath, ok := moved(path); ok {
+			delete(pkgs, path)
+			pkg.Path = newPath
+			pkgs[newPath] = pkg
+		}
+	}
+	for path, p := range pkgObjects {
+		if newPath, ok := moved(path); ok {
+			delete(pkgObjects, path)
+			p.importPath = newPath
+			pkgObjects[newPath] = p
+		}
+	}
+	for path := range gopathPkgs {
+		if _, ok := moved(path); ok {
+			delete(gopathPkgs, path)
+		}
+	}
+
+	if p, err := build.Import(new, "", build.FindOnly); err == nil && oldDir != "" {
+		for i, f := range files {
+			if strings.HasPrefix(f, oldDir+string(filepath.Separator)) {
+				files[i] = p.Dir + f[len(oldDir):]
+			}
+		}
+	}
+	resave(files)
+	return nil
+}
+
+// resave rewrites those of files that are Flux files from their objects.  Open funcs are written from their windows, unless they have unsaved changes.
+func resave(files []string) {
+	for _, file := range files {
+		if !strings.HasSuffix(file, ".flux.go") {
+			continue
+		}
+		p, err := build.ImportDir(filepath.Dir(file), build.FindOnly)
+		if err != nil {
+			continue
+		}
+		pkg, err := getPackage(p.ImportPath)
+		if err != nil {
+			fmt.Printf("error loading %s: %s\n", p.ImportPath, err)
+			continue
+		}
+		for _, obj := range pkgFluxObjs(pkg) {
+			if fluxPath(obj) != file {
+				continue
+			}
+			switch obj := obj.(type) {
+			case *types.TypeName:
+				saveType(obj.Type.(*types.Named))
+			case *types.Func, *types.Var, *types.Const:
+				if f := openFuncs[obj]; f != nil {
+					go Do(f, func() {
+						if !f.history.dirty() {
+							saveFunc(f)
+						}
+					})
+				} else if f, err := readFuncObj(obj); err == nil {
+					saveFunc(f)
+				} else {
+					fmt.Println(err)
+				}
+			}
+		}
+	}
+}
@@ -26,7 +26,7 @@ type selectNode struct {
 func newSelectNode(arranged blockchan) *selectNode {
 	n := &selectNode{focused: -2, arranged: arranged}
 	n.ViewBase = NewView(n)
-	n.AggregateMouser = AggregateMouser{NewClickFocuser(n), NewMover(n)}
+	n.AggregateMouser = AggregateMouser{NewClickFocuser(n), NewMover(n), newDropper(n)}
 	n.name = NewText("select")
 	n.name.SetBackgroundColor(noColor)
 	n.name.SetTextColor(color(special{}, true, false))
@@ -187,7 +187,7 @@ func (n *selectNode) LostKeyFocus() {
 func (n *selectNode) KeyPress(event KeyEvent) {
 	switch event.Key {
 	case KeyUp, KeyDown, KeyLeft, KeyRight:
-		if event.Alt {
+		if event.Alt || event.Shift {
 			n.ViewBase.KeyPress(event)
 			return
 		}
@@ -224,11 +224,13 @@ func (n *selectNode) KeyPress(event KeyEvent) {
 			if t := c.ch.obj.Type; t == nil || underlying(t).(*types.Chan).Dir == types.SendRecv {
 				c.send = !c.send
 				c.connsChanged()
+				recordEdit(n)
 			}
 		}
 	case KeyComma:
 		n.newCase()
 		n.focus(len(n.cases) - 1)
+		recordEdit(n)
 	case KeyBackspace, KeyDelete:
 		if i == -1 {
 			n.ViewBase.KeyPress(event)
@@ -255,6 +257,7 @@ func (n *selectNode) KeyPress(event KeyEvent) {
 		}
 		n.focus(i)
 		rearrange(n.blk)
+		recordEdit(n)
 	default:
 		n.ViewBase.KeyPress(event)
 	}
@@ -46,8 +46,9 @@ func (n *sliceNode) connectable(t types.Type, dst *port) bool {
 
 func (n *sliceNode) connsChanged() {
 	t := untypedToTyped(inputType(n.x))
+	y := t
 	if p, ok := underlying(t).(*types.Pointer); ok {
-		t = &types.Slice{underlying(p.Elem).(*types.Array).Elem}
+		y = &types.Slice{underlying(p.Elem).(*types.Array).Elem}
 	}
 	var i types.Type
 	if t != nil {
@@ -61,7 +62,7 @@ func (n *sliceNode) connsChanged() {
 	if n.max != nil {
 		n.max.setType(i)
 	}
-	n.y.setType(t)
+	n.y.setType(y)
 }
 
 func (n *sliceNode) KeyPress(event KeyEvent) {
@@ -82,6 +83,7 @@ func (n *sliceNode) KeyPress(event KeyEvent) {
 		}
 		n.connsChanged()
 		SetKeyFocus(n)
+		recordEdit(n)
 	} else {
 		n.nodeBase.KeyPress(event)
 	}
@@ -0,0 +1,2230 @@
+// Copyright 2014 Gordon Klaus. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"code.google.com/p/gordon-go/flux/go/exact"
+	"code.google.com/p/gordon-go/flux/go/types"
+	"code.google.com/p/gordon-go/refactor"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/parser"
+	"go/token"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// A hand-written func is imported by translating it into the subset of Go that the writer emits, which the reader then reads like any other Flux func.
+// A connection carries a single value, so a local var that is assigned after it is declared (or whose address is taken) is kept in a cell made by new, and is read and written through it.  Every other var is just the value it was declared with.
+// Effects (calls, channel operations, reads and writes of cells, pointers, maps and slices, and branches) are sequenced in the order they appear in each block.  Control flow that Flux lacks (switch, for with a condition, &&, ||) is lowered to if-nodes, loops and breaks.
+
+var errNoDecl = errors.New("no declaration")
+
+// importFunc reads f from the hand-written declaration of its func, translated into Flux, saves it, and removes the declaration from its file.  It returns errNoDecl if there is no such declaration, and otherwise any error that prevents the translation, in which case f and the file are unchanged.
+func importFunc(f *funcNode) error {
+	obj := f.obj
+	bp, err := build.Import(obj.GetPkg().Path, "", 0)
+	if err != nil {
+		return err
+	}
+	fset := token.NewFileSet()
+	var files []*ast.File
+	var file *ast.File
+	var decl *ast.FuncDecl
+	for _, name := range append(bp.GoFiles, bp.CgoFiles...) {
+		f, err := parser.ParseFile(fset, filepath.Join(bp.Dir, name), nil, parser.ParseComments)
+		if err != nil {
+			return err
+		}
+		files = append(files, f)
+		if d := findFuncDecl(f, obj); d != nil && !strings.HasSuffix(name, ".flux.go") {
+			file, decl = f, d
+		}
+	}
+	if decl == nil {
+		return errNoDecl
+	}
+	if decl.Body == nil {
+		return fmt.Errorf("%s has no body", obj.GetName())
+	}
+
+	info := &types.Info{
+		Types:      map[ast.Expr]types.Type{},
+		Values:     map[ast.Expr]exact.Value{},
+		Objects:    map[*ast.Ident]types.Object{},
+		Implicits:  map[ast.Node]types.Object{},
+		Selections: map[*ast.SelectorExpr]*types.Selection{},
+	}
+	conf := types.Config{FakeImportC: true, Import: srcImport}
+	pkg, err := conf.Check(bp.ImportPath, fset, files, info)
+	if err != nil {
+		return err
+	}
+
+	src, err := translateFunc(fset, info, pkg, obj, decl)
+	if err != nil {
+		return err
+	}
+	if err := readFunc(f, src); err != nil {
+		return err
+	}
+	removeDeadNodes(f.funcblk)
+
+	// the hand-written decl is replaced by the Flux file as one step:  if the source file can't be rewritten, the Flux file is rolled back
+	path, goSrc, err := removeDecl(fset, file, decl)
+	if err != nil {
+		return err
+	}
+	fluxFile := fluxPath(obj)
+	old, oldErr := ioutil.ReadFile(fluxFile)
+	if err := saveFunc(f); err != nil {
+		return err
+	}
+	if err := refactor.WriteFile(path, goSrc); err != nil {
+		delete(fluxObjs, obj)
+		if oldErr == nil {
+			refactor.WriteFile(fluxFile, old)
+		} else {
+			os.Remove(fluxFile)
+		}
+		return err
+	}
+	return nil
+}
+
+// findFuncDecl returns the declaration of the func or method obj in file, or nil if there is none.
+func findFuncDecl(file *ast.File, obj types.Object) *ast.FuncDecl {
+	recv := ""
+	if isMethod(obj) {
+		t, _ := indirect(obj.GetType().(*types.Signature).Recv.Type)
+		recv = t.(*types.Named).Obj.Name
+	}
+	for _, d := range file.Decls {
+		d, ok := d.(*ast.FuncDecl)
+		if !ok || d.Name.Name != obj.GetName() {
+			continue
+		}
+		r := ""
+		if d.Recv != nil && len(d.Recv.List) > 0 {
+			t := d.Recv.List[0].Type
+			if s, ok := t.(*ast.StarExpr); ok {
+				t = s.X
+			}
+			if id, ok := t.(*ast.Ident); ok {
+				r = id.Name
+			}
+		}
+		if r == recv {
+			return d
+		}
+	}
+	return nil
+}
+
+// removeDecl returns the path and the source of file without decl and its doc comment and without the imports that are no longer used.  It does not write the file.
+func removeDecl(fset *token.FileSet, file *ast.File, decl *ast.FuncDecl) (path string, src []byte, err error) {
+	path = fset.Position(file.Pos()).Filename
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", nil, err
+	}
+	start, end := decl.Pos(), decl.End()
+	if decl.Doc != nil {
+		start = decl.Doc.Pos()
+	}
+	src = cut(b, fset.Position(start).Offset, fset.Position(end).Offset)
+
+	fset = token.NewFileSet()
+	f, err := parser.ParseFile(fset, path, src, 0)
+	if err != nil {
+		return "", nil, err
+	}
+	used := map[string]bool{}
+	ast.Inspect(f, func(n ast.Node) bool {
+		if x, ok := n.(*ast.SelectorExpr); ok {
+			if id, ok := x.X.(*ast.Ident); ok && id.Obj == nil {
+				used[id.Name] = true
+			}
+		}
+		return true
+	})
+	type span struct{ start, end int }
+	spans := []span{}
+	for _, d := range f.Decls {
+		d, ok := d.(*ast.GenDecl)
+		if !ok || d.Tok != token.IMPORT {
+			break
+		}
+		unused := 0
+		for _, s := range d.Specs {
+			s := s.(*ast.ImportSpec)
+			importPath, _ := strconv.Unquote(s.Path.Value)
+			name := ""
+			if s.Name != nil {
+				name = s.Name.Name
+			} else if p, err := getPackage(importPath); err == nil {
+				name = p.Name
+			} else {
+				continue
+			}
+			if name == "_" || name == "." || used[name] {
+				continue
+			}
+			unused++
+			spans = append(spans, span{fset.Position(s.Pos()).Offset, fset.Position(s.End()).Offset})
+		}
+		if unused == len(d.Specs) {
+			spans = spans[:len(spans)-unused]
+			spans = append(spans, span{fset.Position(d.Pos()).Offset, fset.Position(d.End()).Offset})
+		}
+	}
+	for i := len(spans) - 1; i >= 0; i-- {
+		src = cut(src, spans[i].start, spans[i].end)
+	}
+	return path, src, nil
+}
+
+// cut returns src without src[start:end] and the blank space that follows it, up to and including the end of its line.
+func cut(src []byte, start, end int) []byte {
+	for end < len(src) && (src[end] == ' ' || src[end] == '\t') {
+		end++
+	}
+	if end < len(src) && src[end] == '\n' {
+		end++
+	}
+	for end < len(src) && src[end] == '\n' && (start == 0 || src[start-1] == '\n') {
+		end++
+	}
+	return append(append([]byte{}, src[:start]...), src[end:]...)
+}
+
+// removeDeadNodes removes the nodes in b that have no effect and whose results are unused.  The writer would drop them, leaving their inputs declared but unused.
+func removeDeadNodes(b *block) {
+	for {
+		dead := []node{}
+		b.walk(nil, func(n node) {
+			switch n := n.(type) {
+			case *indexNode:
+				if n.set {
+					return
+				}
+			case *valueNode:
+				if n.set {
+					return
+				}
+			case *operatorNode, *convertNode, *makeNode, *newNode, *sliceNode, *typeAssertNode, *complexNode, *realImagNode, *funcNode, *basicLiteralNode, *compositeLiteralNode, *lenCapNode, *appendNode:
+			default:
+				return
+			}
+			for _, p := range outs(n) {
+				if len(p.conns) > 0 {
+					return
+				}
+			}
+			dead = append(dead, n)
+		}, nil)
+		if len(dead) == 0 {
+			return
+		}
+		for _, n := range dead {
+			if in, out := seqIn(n), seqOut(n); in != nil && out != nil {
+				for _, c1 := range in.conns {
+					for _, c2 := range out.conns {
+						c := newConnection()
+						c.setSrc(c1.src)
+						c.setDst(c2.dst)
+					}
+				}
+			}
+			n.block().removeNode(n)
+		}
+	}
+}
+
+// translateFunc returns the Flux source of obj, translated from its declaration decl in the type-checked pkg.
+func translateFunc(fset *token.FileSet, info *types.Info, pkg *types.Package, obj types.Object, decl *ast.FuncDecl) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	w := newWriterTo(obj, struct {
+		*bytes.Buffer
+		io.Closer
+	}{buf, nil})
+	w.pkg = pkg // the objects of obj's package are those of the checked source
+	t := &translator{writer: w, fset: fset, info: info, boxed: map[*types.Var]bool{}, vals: map[*types.Var]value{}, countLoops: map[*ast.ForStmt]bool{}, resultOf: map[*types.Var]*tfunc{}}
+	t.analyze(decl)
+	w.importing(func() {
+		t.write("func ")
+		sig := info.Objects[decl.Name].GetType().(*types.Signature)
+		t.fun(decl.Name.Name, decl.Recv, decl.Type, sig, decl.Body)
+	})
+	if len(t.errs) > 0 {
+		return nil, errors.New(strings.Join(t.errs, "\n"))
+	}
+	return buf.Bytes(), nil
+}
+
+type translator struct {
+	*writer
+	fset *token.FileSet
+	info *types.Info
+
+	boxed      map[*types.Var]bool // the local vars kept in cells
+	vals       map[*types.Var]value
+	countLoops map[*ast.ForStmt]bool // for loops translated to counting loops
+	resultOf   map[*types.Var]*tfunc // the funcs of named results
+
+	fn      *tfunc
+	blk     *tblock
+	targets []*target
+	errs    []string
+}
+
+// A value is an output of a node, or the outputs of nodes in mutually exclusive blocks, all of which connect to each use of the value.  A value without outputs is the zero value of its type.
+type value struct {
+	names []string
+	typ   types.Type
+	blk   *tblock
+	seq   int // the sequencing ID of the node with the output, if it is ordered
+}
+
+type tblock struct {
+	last       int  // the sequencing ID of the last ordered node
+	ordered    bool // whether the block contains an ordered node
+	terminated bool // whether the rest of the block is unreachable
+}
+
+type tfunc struct {
+	results []tresult
+	named   []*types.Var // the named results, if any
+	top     *tblock
+}
+
+type tresult struct {
+	name string
+	typ  types.Type
+}
+
+// A target is a statement that a branch statement may break out of or continue.
+type target struct {
+	stmt   ast.Stmt
+	label  string
+	post   ast.Stmt // the post statement of a for loop, run before each continue
+	broken bool
+}
+
+func (t *translator) errorf(n ast.Node, format string, args ...interface{}) {
+	t.errs = append(t.errs, fmt.Sprintf("%s: cannot translate %s", t.fset.Position(n.Pos()), fmt.Sprintf(format, args...)))
+}
+
+// analyze finds the local vars that must be kept in cells, because they are assigned after they are declared or their address is taken, and the for loops that are counting loops.
+func (t *translator) analyze(root ast.Node) {
+	assigned := map[*types.Var]int{}
+	addressed := map[*types.Var]bool{}
+	assign := func(x ast.Expr) {
+		if id, ok := unparen(x).(*ast.Ident); ok {
+			if v := t.localVar(id); v != nil {
+				assigned[v]++
+			}
+		} else if v := t.root(x); v != nil {
+			addressed[v] = true
+		}
+	}
+	ast.Inspect(root, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.AssignStmt:
+			for _, x := range n.Lhs {
+				if n.Tok == token.DEFINE {
+					if id, ok := x.(*ast.Ident); ok {
+						if v := t.localVar(id); v != nil && v.Pos() != id.Pos() {
+							assigned[v]++
+						}
+					}
+				} else {
+					assign(x)
+				}
+			}
+		case *ast.IncDecStmt:
+			assign(n.X)
+		case *ast.RangeStmt:
+			if n.Tok == token.ASSIGN {
+				assign(n.Key)
+				if n.Value != nil {
+					assign(n.Value)
+				}
+			}
+		case *ast.UnaryExpr:
+			if n.Op == token.AND {
+				if v := t.root(n.X); v != nil {
+					addressed[v] = true
+				}
+			}
+		case *ast.SelectorExpr:
+			if sel := t.info.Selections[n]; sel != nil && sel.Kind == types.MethodVal && !sel.Indirect && pointerRecv(sel.Obj) && !isPointer(t.typeOf(n.X)) {
+				if v := t.root(n.X); v != nil {
+					addressed[v] = true
+				}
+			}
+		case *ast.SliceExpr:
+			if _, ok := underlying(t.typeOf(n.X)).(*types.Array); ok {
+				if v := t.root(n.X); v != nil {
+					addressed[v] = true
+				}
+			}
+		}
+		return true
+	})
+	ast.Inspect(root, func(n ast.Node) bool {
+		if s, ok := n.(*ast.ForStmt); ok {
+			if i := t.countVar(s, assigned, addressed); i != nil {
+				t.countLoops[s] = true
+				assigned[i] = 0
+			}
+		}
+		return true
+	})
+	for v, n := range assigned {
+		if n > 0 {
+			t.boxed[v] = true
+		}
+	}
+	for v := range addressed {
+		t.boxed[v] = true
+	}
+}
+
+// countVar returns the var counted by a for loop of the form "for i := 0; i < n; i++", where the body modifies neither i nor n, or nil if s is not of that form.
+func (t *translator) countVar(s *ast.ForStmt, assigned map[*types.Var]int, addressed map[*types.Var]bool) *types.Var {
+	init, ok := s.Init.(*ast.AssignStmt)
+	if !ok || init.Tok != token.DEFINE || len(init.Lhs) != 1 {
+		return nil
+	}
+	id, ok := init.Lhs[0].(*ast.Ident)
+	if !ok {
+		return nil
+	}
+	i := t.localVar(id)
+	if i == nil || assigned[i] != 1 || addressed[i] {
+		return nil
+	}
+	if _, ok := underlying(i.Type).(*types.Basic); !ok {
+		return nil
+	}
+	if v, ok := t.info.Values[init.Rhs[0]]; !ok || exact.Sign(v) != 0 {
+		return nil
+	}
+	cond, ok := s.Cond.(*ast.BinaryExpr)
+	if !ok || cond.Op != token.LSS || !t.isVar(cond.X, i) {
+		return nil
+	}
+	post, ok := s.Post.(*ast.IncDecStmt)
+	if !ok || post.Tok != token.INC || !t.isVar(post.X, i) {
+		return nil
+	}
+	invariant := func(x ast.Expr) bool {
+		if _, ok := t.info.Values[x]; ok {
+			return true
+		}
+		if id, ok := unparen(x).(*ast.Ident); ok {
+			v := t.localVar(id)
+			return v != nil && assigned[v] == 0 && !addressed[v]
+		}
+		return false
+	}
+	n := unparen(cond.Y)
+	if c, ok := n.(*ast.CallExpr); ok && len(c.Args) == 1 {
+		if b, ok := t.info.Objects[identOf(c.Fun)].(*types.Builtin); ok && (b.GetName() == "len" || b.GetName() == "cap") {
+			n = c.Args[0]
+		}
+	}
+	if !invariant(n) {
+		return nil
+	}
+	return i
+}
+
+func (t *translator) isVar(x ast.Expr, v *types.Var) bool {
+	id, ok := unparen(x).(*ast.Ident)
+	return ok && t.info.Objects[id] == v
+}
+
+// localVar returns the local var denoted by id, or nil if it denotes something else.
+func (t *translator) localVar(id *ast.Ident) *types.Var {
+	if v, ok := t.info.Objects[id].(*types.Var); ok && !v.IsField && v.GetPkg() != nil && v.Parent() != v.GetPkg().Scope() {
+		return v
+	}
+	return nil
+}
+
+// root returns the local var that x is part of (x itself, a field of it, or an element of it if it is an array), or nil if there is none.
+func (t *translator) root(x ast.Expr) *types.Var {
+	switch x := unparen(x).(type) {
+	case *ast.Ident:
+		return t.localVar(x)
+	case *ast.SelectorExpr:
+		if sel := t.info.Selections[x]; sel != nil && sel.Kind == types.FieldVal && !sel.Indirect {
+			return t.root(x.X)
+		}
+	case *ast.IndexExpr:
+		if _, ok := underlying(t.typeOf(x.X)).(*types.Array); ok {
+			return t.root(x.X)
+		}
+	}
+	return nil
+}
+
+// fun writes a func's signature, naming its params and results, and its body.  name and recv are empty for a func literal.
+func (t *translator) fun(name string, recv *ast.FieldList, typ *ast.FuncType, sig *types.Signature, body *ast.BlockStmt) {
+	params := []*types.Var{}
+	names := []string{}
+	if recv != nil {
+		v := t.fields(recv)[0]
+		n := t.name(nameOf(v))
+		t.write("(%s %s) ", n, t.typeString(sig.Recv.Type))
+		params = append(params, v)
+		names = append(names, n)
+	}
+	t.write("%s(", name)
+	for i, v := range t.fields(typ.Params) {
+		if i > 0 {
+			t.write(", ")
+		}
+		p := sig.Params[i]
+		n := t.name(nameOf(v))
+		T := t.typeString(p.Type)
+		if sig.IsVariadic && i == len(sig.Params)-1 {
+			T = "..." + t.typeString(p.Type.(*types.Slice).Elem)
+		}
+		t.write("%s %s", n, T)
+		params = append(params, v)
+		names = append(names, n)
+	}
+	t.write(") (")
+	fn := &tfunc{}
+	results := t.fields(typ.Results)
+	for i, r := range sig.Results {
+		if i > 0 {
+			t.write(", ")
+		}
+		n := t.name(nameOf(results[i]))
+		t.write("%s %s", n, t.typeString(r.Type))
+		fn.results = append(fn.results, tresult{n, r.Type})
+		if results[i] != nil {
+			fn.named = append(fn.named, results[i])
+			t.resultOf[results[i]] = fn
+		}
+	}
+	t.write(") {\n")
+
+	outerFn, outerTargets := t.fn, t.targets
+	t.fn, t.targets = fn, nil
+	t.block(func() {
+		fn.top = t.blk
+		for i, v := range params {
+			if v != nil {
+				t.store(&lvalue{kind: lvLocal, v: v, typ: v.Type, def: true}, t.def(names[i], v.Type, 0))
+			}
+		}
+		for i, v := range results {
+			if v != nil {
+				t.store(&lvalue{kind: lvLocal, v: v, typ: v.Type, def: true}, value{typ: fn.results[i].typ})
+			}
+		}
+		t.stmts(body.List)
+		if !t.blk.terminated {
+			t.ret(body, nil)
+		}
+		t.indent("return\n")
+	})
+	t.fn, t.targets = outerFn, outerTargets
+	t.indent("}\n")
+}
+
+// fields returns the vars declared by list, with nil for each unnamed one.
+func (t *translator) fields(list *ast.FieldList) (vars []*types.Var) {
+	if list == nil {
+		return
+	}
+	for _, f := range list.List {
+		if len(f.Names) == 0 {
+			vars = append(vars, nil)
+		}
+		for _, n := range f.Names {
+			v, _ := t.info.Objects[n].(*types.Var)
+			vars = append(vars, v)
+		}
+	}
+	return
+}
+
+func nameOf(v *types.Var) string {
+	if v == nil {
+		return ""
+	}
+	return v.Name
+}
+
+// block translates body into a new block.
+func (t *translator) block(body func()) *tblock {
+	b := &tblock{}
+	outer := t.blk
+	t.blk = b
+	t.nindent++
+	body()
+	t.nindent--
+	t.blk = outer
+	return b
+}
+
+func (t *translator) def(name string, typ types.Type, seq int) value {
+	return value{[]string{name}, typ, t.blk, seq}
+}
+
+// order returns the sequencing comment of the next node written, which is ordered:  it follows the last ordered node in the block, unless one of args comes from it, and becomes the last.
+func (t *translator) order(args ...value) (string, int) {
+	b := t.blk
+	in := ""
+	if b.last != 0 {
+		in = strconv.Itoa(b.last)
+		for _, a := range args {
+			if a.seq == b.last && a.blk == b {
+				in = ""
+			}
+		}
+	}
+	t.seqID++
+	b.last = t.seqID
+	b.ordered = true
+	return fmt.Sprintf("//%s;%d", in, t.seqID), t.seqID
+}
+
+// arg writes a var for the input of the next node written, connected to the outputs of v, and returns its name.  The var has type want, if it is not nil, and otherwise the type of v.
+func (t *translator) arg(v value, want types.Type) string {
+	if want == nil {
+		want = v.typ
+	}
+	name := t.name("v")
+	t.indent("var %s %s\n", name, t.typeString(want))
+	for _, n := range v.names {
+		t.indent("%s = %s\n", name, n)
+	}
+	return name
+}
+
+func (t *translator) typeString(T types.Type) string {
+	T = untypedToTyped(T)
+	if b, ok := T.(*types.Basic); ok && b.Kind == types.UntypedNil {
+		T = &types.Interface{}
+	}
+	t.collectPkgs(T)
+	return t.typ(T)
+}
+
+// qualified returns the name of obj, qualified by its package if it is not the current one.
+func (t *translator) qualified(obj types.Object) string {
+	if p := obj.GetPkg(); p != nil && p != t.pkg {
+		if _, ok := t.pkgNames[p]; !ok {
+			t.pkgNames[p] = t.name(p.Name)
+		}
+	}
+	return t.qualifiedName(obj)
+}
+
+func (t *translator) typeOf(x ast.Expr) types.Type {
+	T := t.info.Types[x]
+	if T == nil {
+		if id, ok := x.(*ast.Ident); ok {
+			if obj := t.info.Objects[id]; obj != nil {
+				T = obj.GetType()
+			}
+		}
+	}
+	if tuple, ok := T.(*types.Tuple); ok && tuple != nil && len(*tuple) > 0 {
+		T = (*tuple)[0].Type
+	}
+	return T
+}
+
+func (t *translator) stmts(list []ast.Stmt) {
+	for _, s := range list {
+		if t.blk.terminated {
+			return
+		}
+		t.stmt(s, "")
+	}
+}
+
+func (t *translator) stmt(s ast.Stmt, label string) {
+	switch s := s.(type) {
+	case *ast.AssignStmt:
+		t.assign(s)
+	case *ast.BlockStmt:
+		t.stmts(s.List)
+	case *ast.BranchStmt:
+		t.branch(s)
+	case *ast.DeclStmt:
+		t.decl(s.Decl.(*ast.GenDecl))
+	case *ast.DeferStmt:
+		t.call(s.Call, "defer ")
+	case *ast.EmptyStmt:
+	case *ast.ExprStmt:
+		switch x := unparen(s.X).(type) {
+		case *ast.CallExpr:
+			t.call(x, "")
+		case *ast.UnaryExpr:
+			t.expr(x)
+		default:
+			t.errorf(s, "expression statement")
+		}
+	case *ast.ForStmt:
+		t.forStmt(s, label)
+	case *ast.GoStmt:
+		t.call(s.Call, "go ")
+	case *ast.IfStmt:
+		t.ifStmt(s)
+	case *ast.IncDecStmt:
+		op := token.ADD
+		if s.Tok == token.DEC {
+			op = token.SUB
+		}
+		lv := t.lvalue(s.X, false)
+		one := t.literal(exact.MakeInt64(1), types.Typ[types.UntypedInt])
+		t.store(lv, t.operator(op, t.load(lv), one, lv.typ))
+	case *ast.LabeledStmt:
+		t.stmt(s.Stmt, s.Label.Name)
+	case *ast.RangeStmt:
+		t.rangeStmt(s, label)
+	case *ast.ReturnStmt:
+		t.ret(s, s.Results)
+	case *ast.SelectStmt:
+		t.selectStmt(s, label)
+	case *ast.SendStmt:
+		ch := t.expr(s.Chan)
+		elem := t.expr(s.Value)
+		c := t.arg(ch, nil)
+		e := t.arg(elem, underlying(ch.typ).(*types.Chan).Elem)
+		seq, _ := t.order(ch, elem)
+		t.indent("%s <- %s%s\n", c, e, seq)
+	case *ast.SwitchStmt:
+		t.switchStmt(s, label)
+	case *ast.TypeSwitchStmt:
+		t.typeSwitchStmt(s, label)
+	default:
+		t.errorf(s, "%T", s)
+	}
+}
+
+func (t *translator) assign(s *ast.AssignStmt) {
+	switch s.Tok {
+	case token.DEFINE, token.ASSIGN:
+		lvs := []*lvalue{}
+		for _, x := range s.Lhs {
+			lvs = append(lvs, t.lvalue(x, s.Tok == token.DEFINE))
+		}
+		vals := []value{}
+		if len(s.Rhs) == len(s.Lhs) {
+			for _, x := range s.Rhs {
+				vals = append(vals, t.expr(x))
+			}
+		} else {
+			vals = t.tuple(s.Rhs[0])
+		}
+		for i, lv := range lvs {
+			if i < len(vals) {
+				t.store(lv, vals[i])
+			}
+		}
+	default:
+		op := map[token.Token]token.Token{
+			token.ADD_ASSIGN: token.ADD, token.SUB_ASSIGN: token.SUB, token.MUL_ASSIGN: token.MUL, token.QUO_ASSIGN: token.QUO, token.REM_ASSIGN: token.REM,
+			token.AND_ASSIGN: token.AND, token.OR_ASSIGN: token.OR, token.XOR_ASSIGN: token.XOR, token.SHL_ASSIGN: token.SHL, token.SHR_ASSIGN: token.SHR, token.AND_NOT_ASSIGN: token.AND_NOT,
+		}[s.Tok]
+		lv := t.lvalue(s.Lhs[0], false)
+		t.store(lv, t.operator(op, t.load(lv), t.expr(s.Rhs[0]), lv.typ))
+	}
+}
+
+func (t *translator) decl(d *ast.GenDecl) {
+	switch d.Tok {
+	case token.VAR:
+		for _, s := range d.Specs {
+			s := s.(*ast.ValueSpec)
+			lvs := []*lvalue{}
+			for _, n := range s.Names {
+				lvs = append(lvs, t.lvalue(n, true))
+			}
+			vals := []value{}
+			switch {
+			case len(s.Values) == len(s.Names):
+				for _, x := range s.Values {
+					vals = append(vals, t.expr(x))
+				}
+			case len(s.Values) == 1:
+				vals = t.tuple(s.Values[0])
+			default:
+				for _, lv := range lvs {
+					vals = append(vals, value{typ: lv.typ})
+				}
+			}
+			for i, lv := range lvs {
+				t.store(lv, vals[i])
+			}
+		}
+	case token.CONST:
+		// constants are translated where they are used
+	case token.TYPE:
+		t.errorf(d, "local type declaration")
+	}
+}
+
+func (t *translator) branch(s *ast.BranchStmt) {
+	switch s.Tok {
+	case token.BREAK, token.CONTINUE:
+		var tg *target
+		for i := len(t.targets) - 1; i >= 0; i-- {
+			if _, ok := t.targets[i].stmt.(*ast.SelectStmt); ok && s.Tok == token.CONTINUE {
+				continue
+			}
+			tg = t.targets[i]
+			break
+		}
+		if tg == nil || s.Label != nil && s.Label.Name != tg.label {
+			t.errorf(s, "%s to an outer statement", s.Tok)
+			return
+		}
+		if s.Tok == token.CONTINUE && tg.post != nil {
+			t.stmt(tg.post, "")
+		}
+		if s.Tok == token.BREAK {
+			tg.broken = true
+		}
+		seq, _ := t.order()
+		t.indent("%s%s\n", s.Tok, seq)
+		t.blk.terminated = true
+	default:
+		t.errorf(s, "%s statement", s.Tok)
+	}
+}
+
+// ret writes a return from the func being translated, of the values of results, or of its named results if there are none.  The top block of the func connects to its results directly.  Elsewhere, every result is assigned in the returning block, so that it isn't left with a value assigned earlier for a later return.
+func (t *translator) ret(s ast.Node, results []ast.Expr) {
+	fn := t.fn
+	var vals []value
+	switch {
+	case len(results) == 0:
+		for _, r := range fn.named {
+			vals = append(vals, t.load(&lvalue{kind: lvLocal, v: r, typ: r.Type}))
+		}
+	case len(results) == 1 && len(fn.results) > 1:
+		vals = t.tuple(results[0])
+	default:
+		for _, x := range results {
+			vals = append(vals, t.expr(x))
+		}
+	}
+	top := t.blk == fn.top
+	for i, r := range fn.results {
+		v := value{typ: r.typ}
+		if i < len(vals) {
+			v = vals[i]
+		}
+		if !top && (len(v.names) == 0 || v.blk != t.blk) {
+			v = t.convert(v, r.typ, true)
+		}
+		for _, n := range v.names {
+			t.indent("%s = %s\n", r.name, n)
+		}
+	}
+	if !top {
+		seq, _ := t.order()
+		t.indent("return%s\n", seq)
+	}
+	t.blk.terminated = true
+}
+
+// A branch of an if-node runs its body if its condition holds and no earlier one does.  The last branch may have no condition.
+type branch struct {
+	cond func() value
+	safe bool // whether cond can be evaluated even if an earlier one holds
+	body func()
+}
+
+// ifNode writes an if-node for branches.  Each condition after the first is evaluated ahead of the node if it and those before it are safe; otherwise, it and the branches after it are written as an if-node in the else-block.
+func (t *translator) ifNode(branches []branch) {
+	conds := []value{}
+	for i, b := range branches {
+		if b.cond == nil || i > 0 && !b.safe {
+			break
+		}
+		conds = append(conds, b.cond())
+	}
+	names := []string{}
+	for _, c := range conds {
+		names = append(names, t.arg(c, types.Typ[types.Bool]))
+	}
+	blocks := []*tblock{}
+	for i := range conds {
+		if i == 0 {
+			t.indent("if %s {\n", names[i])
+		} else {
+			t.indent("} else if %s {\n", names[i])
+		}
+		blocks = append(blocks, t.block(branches[i].body))
+	}
+	rest := branches[len(conds):]
+	if len(rest) > 0 {
+		t.indent("} else {\n")
+		body := rest[0].body
+		if rest[0].cond != nil {
+			body = func() { t.ifNode(rest) }
+		}
+		blocks = append(blocks, t.block(body))
+	}
+	ordered, terminated := false, len(rest) > 0
+	for _, b := range blocks {
+		ordered = ordered || b.ordered
+		terminated = terminated && b.terminated
+	}
+	seq := ""
+	if ordered {
+		seq, _ = t.order(conds...)
+	}
+	t.indent("}%s\n", seq)
+	t.blk.terminated = t.blk.terminated || terminated
+}
+
+func (t *translator) ifStmt(s *ast.IfStmt) {
+	branches := []branch{}
+	for s := ast.Stmt(s); s != nil; {
+		switch s2 := s.(type) {
+		case *ast.IfStmt:
+			if s2.Init != nil {
+				if len(branches) > 0 {
+					branches = append(branches, branch{body: func() { t.ifStmt(s2) }})
+					s = nil
+					continue
+				}
+				t.stmt(s2.Init, "")
+			}
+			branches = append(branches, branch{func() value { return t.expr(s2.Cond) }, t.safe(s2.Cond), func() { t.stmts(s2.Body.List) }})
+			s = s2.Else
+		case *ast.BlockStmt:
+			branches = append(branches, branch{body: func() { t.stmts(s2.List) }})
+			s = nil
+		}
+	}
+	t.ifNode(branches)
+}
+
+// caseBranches returns a branch for each clause of a switch or type switch, with the default clause last.  cond returns the condition of a non-default clause.  Breaks out of the switch are removed from the bodies.
+func (t *translator) caseBranches(body *ast.BlockStmt, label string, cond func(*ast.CaseClause) (func() value, bool), bind func(*ast.CaseClause)) []branch {
+	branches := []branch{}
+	var def *branch
+	for _, c := range body.List {
+		c := c.(*ast.CaseClause)
+		list := withoutBreaks(c.Body, label) // a fallthrough is reported when the body is translated
+		b := branch{body: func() {
+			bind(c)
+			t.stmts(list)
+		}}
+		if c.List == nil {
+			def = &b
+			continue
+		}
+		b.cond, b.safe = cond(c)
+		branches = append(branches, b)
+	}
+	if def != nil {
+		if len(branches) == 0 {
+			def.cond, def.safe = func() value { return t.literal(exact.MakeBool(true), types.Typ[types.UntypedBool]) }, true
+		}
+		branches = append(branches, *def)
+	}
+	return branches
+}
+
+func (t *translator) switchStmt(s *ast.SwitchStmt, label string) {
+	if s.Init != nil {
+		t.stmt(s.Init, "")
+	}
+	var tag value
+	if s.Tag != nil {
+		tag = t.expr(s.Tag)
+	}
+	cond := func(c *ast.CaseClause) (func() value, bool) {
+		safe := true
+		for _, x := range c.List {
+			safe = safe && t.safe(x)
+		}
+		return func() value {
+			var v value
+			for i, x := range c.List {
+				x := x
+				test := func() value {
+					if s.Tag == nil {
+						return t.expr(x)
+					}
+					return t.operator(token.EQL, tag, t.expr(x), types.Typ[types.UntypedBool])
+				}
+				if i == 0 {
+					v = test()
+				} else {
+					v = t.logical(token.LOR, v, test, t.safe(x))
+				}
+			}
+			return v
+		}, safe
+	}
+	if branches := t.caseBranches(s.Body, label, cond, func(*ast.CaseClause) {}); len(branches) > 0 {
+		t.ifNode(branches)
+	}
+}
+
+func (t *translator) typeSwitchStmt(s *ast.TypeSwitchStmt, label string) {
+	if s.Init != nil {
+		t.stmt(s.Init, "")
+	}
+	var x ast.Expr
+	switch a := s.Assign.(type) {
+	case *ast.AssignStmt:
+		x = a.Rhs[0].(*ast.TypeAssertExpr).X
+	case *ast.ExprStmt:
+		x = a.X.(*ast.TypeAssertExpr).X
+	}
+	xv := t.expr(x)
+	asserted := map[*ast.CaseClause]value{}
+	cond := func(c *ast.CaseClause) (func() value, bool) {
+		return func() value {
+			var v value
+			for i, e := range c.List {
+				var ok value
+				if _, isNil := t.info.Objects[identOf(e)].(*types.Nil); isNil {
+					ok = t.operator(token.EQL, xv, value{typ: xv.typ}, types.Typ[types.UntypedBool])
+				} else {
+					T := t.typeOf(e)
+					x := t.arg(xv, nil)
+					elem, okName := t.name("v"), t.name("ok")
+					t.indent("%s, %s := %s.(%s)\n", elem, okName, x, t.typeString(T))
+					if len(c.List) == 1 {
+						asserted[c] = t.def(elem, T, 0)
+					}
+					ok = t.def(okName, types.Typ[types.Bool], 0)
+				}
+				if i == 0 {
+					v = ok
+				} else {
+					v = t.operator(token.LOR, v, ok, types.Typ[types.Bool])
+				}
+			}
+			return v
+		}, true
+	}
+	bind := func(c *ast.CaseClause) {
+		v, ok := t.info.Implicits[c].(*types.Var)
+		if !ok {
+			return
+		}
+		val, ok := asserted[c]
+		if !ok {
+			val = xv
+		}
+		t.store(&lvalue{kind: lvLocal, v: v, typ: v.Type, def: true}, val)
+	}
+	if branches := t.caseBranches(s.Body, label, cond, bind); len(branches) > 0 {
+		t.ifNode(branches)
+	}
+}
+
+// withoutBreaks returns the statements of a switch clause without the breaks out of the switch (unlabeled, or labeled with label).  The statements that follow a break are dropped, and those that follow an if statement containing one are moved into each of its branches.
+func withoutBreaks(list []ast.Stmt, label string) []ast.Stmt {
+	for i, s := range list {
+		if !breaks(s, label) {
+			continue
+		}
+		rest := list[i+1:]
+		join := func(list []ast.Stmt) []ast.Stmt {
+			return withoutBreaks(append(append([]ast.Stmt{}, list...), rest...), label)
+		}
+		switch s := s.(type) {
+		case *ast.BranchStmt:
+			return list[:i:i]
+		case *ast.BlockStmt:
+			return append(list[:i:i], join(s.List)...)
+		case *ast.LabeledStmt:
+			return append(list[:i:i], join([]ast.Stmt{s.Stmt})...)
+		case *ast.IfStmt:
+			s2 := *s
+			s2.Body = &ast.BlockStmt{List: join(s.Body.List)}
+			switch e := s.Else.(type) {
+			case nil:
+				s2.Else = &ast.BlockStmt{List: join(nil)}
+			case *ast.BlockStmt:
+				s2.Else = &ast.BlockStmt{List: join(e.List)}
+			case *ast.IfStmt:
+				s2.Else = &ast.BlockStmt{List: join([]ast.Stmt{e})}
+			}
+			return append(list[:i:i], &s2)
+		}
+	}
+	return list
+}
+
+// breaks reports whether s contains a break out of the switch statement whose clause it is in.
+func breaks(s ast.Stmt, label string) bool {
+	found := false
+	var inspect func(n ast.Node, nested bool)
+	inspect = func(n ast.Node, nested bool) {
+		ast.Inspect(n, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.BranchStmt:
+				if n.Tok == token.BREAK && (n.Label == nil && !nested || n.Label != nil && n.Label.Name == label) {
+					found = true
+				}
+			case *ast.ForStmt, *ast.RangeStmt, *ast.SwitchStmt, *ast.TypeSwitchStmt, *ast.SelectStmt:
+				if !nested {
+					inspect(n, true)
+					return false
+				}
+			case *ast.FuncLit:
+				return false
+			}
+			return !found
+		})
+	}
+	switch s.(type) {
+	case *ast.ForStmt, *ast.RangeStmt, *ast.SwitchStmt, *ast.TypeSwitchStmt, *ast.SelectStmt:
+		inspect(s, true)
+	default:
+		inspect(s, false)
+	}
+	return found
+}
+
+func (t *translator) forStmt(s *ast.ForStmt, label string) {
+	tg := &target{stmt: s, label: label}
+	if t.countLoops[s] {
+		i := t.localVar(s.Init.(*ast.AssignStmt).Lhs[0].(*ast.Ident))
+		n := t.expr(s.Cond.(*ast.BinaryExpr).Y)
+		n = t.convert(n, i.Type, isUntyped(n.typ)) // the counter takes its type from the input
+		c := t.arg(n, i.Type)
+		k := t.name("i")
+		t.indent("for %s := %s(0); %s < %s; %s++ {\n", k, t.typeString(i.Type), k, c, k)
+		b := t.loopBlock(tg, func() {
+			t.vals[i] = t.def(k, i.Type, 0)
+			t.stmts(s.Body.List)
+		})
+		seq := ""
+		if b.ordered {
+			seq, _ = t.order(n)
+		}
+		t.indent("}%s\n", seq)
+		return
+	}
+
+	if s.Init != nil {
+		t.stmt(s.Init, "")
+	}
+	tg.post = s.Post
+	t.indent("for {\n")
+	t.loopBlock(tg, func() {
+		if s.Cond != nil {
+			cond := t.expr(s.Cond)
+			t.ifNode([]branch{{cond: func() value { return t.not(cond) }, body: func() {
+				seq, _ := t.order()
+				t.indent("break%s\n", seq)
+				t.blk.terminated = true
+			}}})
+			tg.broken = true
+		}
+		t.stmts(s.Body.List)
+		if s.Post != nil && !t.blk.terminated {
+			t.stmt(s.Post, "")
+		}
+	})
+	seq, _ := t.order()
+	t.indent("}%s\n", seq)
+	if !tg.broken {
+		t.blk.terminated = true
+	}
+}
+
+// loopBlock translates body into the block of a loop that is the target tg of breaks and continues.
+func (t *translator) loopBlock(tg *target, body func()) *tblock {
+	t.targets = append(t.targets, tg)
+	b := t.block(body)
+	t.targets = t.targets[:len(t.targets)-1]
+	return b
+}
+
+func (t *translator) rangeStmt(s *ast.RangeStmt, label string) {
+	tg := &target{stmt: s, label: label}
+	x := t.expr(s.X)
+	blank := func(x ast.Expr) bool {
+		id, ok := x.(*ast.Ident)
+		return x == nil || ok && id.Name == "_"
+	}
+	keyType, elemType := types.Type(types.Typ[types.Int]), types.Type(nil)
+	indexed := false // whether elements are read by index
+	switch u := underlying(x.typ).(type) {
+	case *types.Basic:
+		if u.Info&types.IsString == 0 {
+			t.errorf(s, "range over %s", t.typeString(x.typ))
+			return
+		}
+		if !blank(s.Key) {
+			t.errorf(s.Key, "index of range over string")
+			return
+		}
+		runes := types.NewSlice(types.Typ[types.Rune])
+		x = t.convert(x, runes, false)
+		elemType, indexed = types.Typ[types.Rune], true
+	case *types.Slice:
+		elemType, indexed = u.Elem, true
+	case *types.Array:
+		elemType, indexed = u.Elem, true
+	case *types.Pointer:
+		elemType, indexed = underlying(u.Elem).(*types.Array).Elem, true
+	case *types.Map:
+		keyType, elemType = u.Key, u.Elem
+	case *types.Chan:
+		keyType = u.Elem
+	default:
+		t.errorf(s, "range over %s", t.typeString(x.typ))
+		return
+	}
+	c := t.arg(x, nil)
+	k, v := t.name("k"), ""
+	if !blank(s.Value) {
+		v = t.name("v")
+	}
+	if v != "" && !indexed {
+		t.indent("for %s, %s := range %s {\n", k, v, c)
+	} else {
+		t.indent("for %s := range %s {\n", k, c)
+	}
+	b := t.loopBlock(tg, func() {
+		var elem value
+		if v != "" {
+			if indexed {
+				amp := "&"
+				if _, ok := underlying(x.typ).(*types.Array); ok {
+					amp = ""
+				}
+				t.indent("var %s = %s%s[%s]\n", v, amp, c, k)
+				if amp == "" {
+					elem = t.def(v, elemType, 0)
+				} else {
+					elem = t.deref(t.def(v, types.NewPointer(elemType), 0), elemType)
+				}
+			} else {
+				elem = t.def(v, elemType, 0)
+			}
+		}
+		key := t.def(k, keyType, 0)
+		if s.Tok == token.DEFINE {
+			if !blank(s.Key) {
+				t.store(t.lvalue(s.Key, true), key)
+			}
+			if v != "" {
+				t.store(t.lvalue(s.Value, true), elem)
+			}
+		} else {
+			if !blank(s.Key) {
+				t.store(t.lvalue(s.Key, false), key)
+			}
+			if v != "" {
+				t.store(t.lvalue(s.Value, false), elem)
+			}
+		}
+		t.stmts(s.Body.List)
+	})
+	seq := ""
+	if b.ordered {
+		seq, _ = t.order(x)
+	}
+	t.indent("}%s\n", seq)
+}
+
+func (t *translator) selectStmt(s *ast.SelectStmt, label string) {
+	type comm struct {
+		ch, elem string
+		send     bool
+	}
+	comms := []comm{}
+	args := []value{}
+	// the channels and values to send are evaluated first, in order
+	for _, c := range s.Body.List {
+		var x comm
+		switch s := c.(*ast.CommClause).Comm.(type) {
+		case *ast.SendStmt:
+			ch := t.expr(s.Chan)
+			elem := t.expr(s.Value)
+			x = comm{t.arg(ch, nil), t.arg(elem, underlying(ch.typ).(*types.Chan).Elem), true}
+			args = append(args, ch, elem)
+		case *ast.ExprStmt:
+			ch := t.expr(unparen(s.X).(*ast.UnaryExpr).X)
+			x = comm{ch: t.arg(ch, nil)}
+			args = append(args, ch)
+		case *ast.AssignStmt:
+			ch := t.expr(unparen(s.Rhs[0]).(*ast.UnaryExpr).X)
+			x = comm{ch: t.arg(ch, nil)}
+			args = append(args, ch)
+		}
+		comms = append(comms, x)
+	}
+	tg := &target{stmt: s, label: label}
+	t.indent("select {\n")
+	for i, c := range s.Body.List {
+		c := c.(*ast.CommClause)
+		x := comms[i]
+		var elem, ok string
+		switch {
+		case c.Comm == nil:
+			t.indent("default:\n")
+		case x.send:
+			t.indent("case %s <- %s:\n", x.ch, x.elem)
+		default:
+			elem, ok = t.name("v"), t.name("ok")
+			t.indent("case %s, %s := <-%s:\n", elem, ok, x.ch)
+		}
+		t.loopBlock(tg, func() {
+			if s, isAssign := c.Comm.(*ast.AssignStmt); isAssign {
+				ch := t.typeOf(unparen(s.Rhs[0]).(*ast.UnaryExpr).X)
+				vals := []value{t.def(elem, underlying(ch).(*types.Chan).Elem, 0), t.def(ok, types.Typ[types.Bool], 0)}
+				for i, x := range s.Lhs {
+					t.store(t.lvalue(x, s.Tok == token.DEFINE), vals[i])
+				}
+			}
+			t.stmts(c.Body)
+		})
+	}
+	seq, _ := t.order(args...)
+	t.indent("}%s\n", seq)
+}
+
+type lvKind int
+
+const (
+	lvBlank lvKind = iota
+	lvLocal
+	lvGlobal
+	lvDeref // *x
+	lvField // x.name
+	lvIndex // x[key]
+)
+
+// An lvalue is an operand that may be assigned or whose address may be taken, with its operands evaluated.
+type lvalue struct {
+	kind   lvKind
+	v      *types.Var // of lvLocal and lvGlobal
+	def    bool       // whether the lvLocal is being declared
+	x, key value
+	name   string // of the field
+	typ    types.Type
+}
+
+func (t *translator) lvalue(x ast.Expr, def bool) *lvalue {
+	x = unparen(x)
+	typ := t.typeOf(x)
+	switch x := x.(type) {
+	case *ast.Ident:
+		if x.Name == "_" {
+			return &lvalue{kind: lvBlank, typ: typ}
+		}
+		v, ok := t.info.Objects[x].(*types.Var)
+		if !ok {
+			break
+		}
+		if t.localVar(x) == nil {
+			return &lvalue{kind: lvGlobal, v: v, typ: v.Type}
+		}
+		if fn := t.resultOf[v]; fn != nil && fn != t.fn {
+			t.errorf(x, "use of a named result in a func literal")
+		}
+		return &lvalue{kind: lvLocal, v: v, typ: v.Type, def: def && v.Pos() == x.Pos()}
+	case *ast.StarExpr:
+		return &lvalue{kind: lvDeref, x: t.expr(x.X), typ: typ}
+	case *ast.SelectorExpr:
+		sel := t.info.Selections[x]
+		if sel == nil {
+			if v, ok := t.info.Objects[x.Sel].(*types.Var); ok {
+				return &lvalue{kind: lvGlobal, v: v, typ: v.Type}
+			}
+			break
+		}
+		if sel.Kind != types.FieldVal {
+			break
+		}
+		var operand value
+		if isPointer(t.typeOf(x.X)) || sel.Indirect || !t.inMemory(x.X) {
+			operand = t.expr(x.X)
+		} else {
+			operand = t.addr(t.lvalue(x.X, false))
+		}
+		return &lvalue{kind: lvField, x: operand, name: x.Sel.Name, typ: typ}
+	case *ast.IndexExpr:
+		var operand value
+		if _, ok := underlying(t.typeOf(x.X)).(*types.Array); ok && t.inMemory(x.X) {
+			operand = t.addr(t.lvalue(x.X, false))
+		} else {
+			operand = t.expr(x.X)
+		}
+		var keyType types.Type = types.Typ[types.Int]
+		if m, ok := underlying(operand.typ).(*types.Map); ok {
+			keyType = m.Key
+		}
+		return &lvalue{kind: lvIndex, x: operand, key: t.convert(t.expr(x.Index), keyType, false), typ: typ}
+	}
+	t.errorf(x, "assignment to %s", types.ExprString(x))
+	return &lvalue{kind: lvBlank, typ: typ}
+}
+
+// inMemory reports whether x is addressable and stored in memory (and not merely the value of a connection), so that it must be read through its address.
+func (t *translator) inMemory(x ast.Expr) bool {
+	switch x := unparen(x).(type) {
+	case *ast.Ident:
+		v, ok := t.info.Objects[x].(*types.Var)
+		return ok && (t.boxed[v] || t.localVar(x) == nil)
+	case *ast.StarExpr:
+		return true
+	case *ast.SelectorExpr:
+		sel := t.info.Selections[x]
+		if sel == nil {
+			_, ok := t.info.Objects[x.Sel].(*types.Var)
+			return ok
+		}
+		return sel.Kind == types.FieldVal && (sel.Indirect || isPointer(t.typeOf(x.X)) || t.inMemory(x.X))
+	case *ast.IndexExpr:
+		switch underlying(t.typeOf(x.X)).(type) {
+		case *types.Slice, *types.Pointer:
+			return true
+		case *types.Array:
+			return t.inMemory(x.X)
+		}
+	}
+	return false
+}
+
+// addressable reports whether the field lv is accessed through a pointer.
+func (t *translator) addressable(lv *lvalue) bool {
+	_, _, indirect := types.LookupFieldOrMethod(lv.x.typ, t.pkg, lv.name)
+	return indirect
+}
+
+func (t *translator) load(lv *lvalue) value {
+	switch lv.kind {
+	case lvLocal:
+		if t.boxed[lv.v] {
+			return t.deref(t.vals[lv.v], lv.typ)
+		}
+		if v, ok := t.vals[lv.v]; ok {
+			return v
+		}
+		return value{typ: lv.typ}
+	case lvGlobal, lvDeref:
+		return t.deref(t.addr(lv), lv.typ)
+	case lvField:
+		if t.addressable(lv) {
+			return t.deref(t.addr(lv), lv.typ)
+		}
+		c := t.arg(lv.x, nil)
+		name := t.name(lv.name)
+		t.indent("%s := %s.%s\n", name, c, lv.name)
+		return t.def(name, lv.typ, 0)
+	case lvIndex:
+		switch underlying(lv.x.typ).(type) {
+		case *types.Slice, *types.Pointer:
+			return t.deref(t.addr(lv), lv.typ)
+		}
+		c := t.arg(lv.x, nil)
+		k := t.arg(lv.key, nil)
+		name, ok := t.name("v"), ""
+		if _, isMap := underlying(lv.x.typ).(*types.Map); isMap {
+			ok = ", " + t.name("ok")
+		}
+		seq, id := t.order(lv.x, lv.key)
+		t.indent("%s%s := %s[%s]%s\n", name, ok, c, k, seq)
+		return t.def(name, lv.typ, id)
+	}
+	return value{typ: lv.typ}
+}
+
+func (t *translator) addr(lv *lvalue) value {
+	T := types.NewPointer(lv.typ)
+	switch lv.kind {
+	case lvLocal:
+		if !t.boxed[lv.v] {
+			panic("address of unboxed var " + lv.v.Name)
+		}
+		return t.vals[lv.v]
+	case lvGlobal:
+		name := t.name(lv.v.Name)
+		t.indent("%s := &%s\n", name, t.qualified(lv.v))
+		return t.def(name, T, 0)
+	case lvDeref:
+		return lv.x
+	case lvField:
+		c := t.arg(lv.x, nil)
+		name := t.name(lv.name)
+		t.indent("%s := &%s.%s\n", name, c, lv.name)
+		return t.def(name, T, 0)
+	case lvIndex:
+		c := t.arg(lv.x, nil)
+		k := t.arg(lv.key, nil)
+		name := t.name("p")
+		seq, id := t.order(lv.x, lv.key)
+		t.indent("%s := &%s[%s]%s\n", name, c, k, seq)
+		return t.def(name, T, id)
+	}
+	return value{typ: T}
+}
+
+func (t *translator) store(lv *lvalue, v value) {
+	switch lv.kind {
+	case lvLocal:
+		if !t.boxed[lv.v] {
+			t.vals[lv.v] = t.convert(v, lv.typ, false)
+			return
+		}
+		if lv.def {
+			name := t.name(lv.v.Name)
+			t.indent("%s := new(%s)\n", name, t.typeString(lv.typ))
+			t.vals[lv.v] = t.def(name, types.NewPointer(lv.typ), 0)
+		}
+		t.storeThrough(t.vals[lv.v], v, lv.typ)
+	case lvGlobal:
+		d := t.arg(v, lv.typ)
+		seq, _ := t.order(v)
+		t.indent("%s = %s%s\n", t.qualified(lv.v), d, seq)
+	case lvDeref:
+		t.storeThrough(lv.x, v, lv.typ)
+	case lvField:
+		c := t.arg(lv.x, nil)
+		d := t.arg(v, lv.typ)
+		seq, _ := t.order(lv.x, v)
+		t.indent("%s.%s = %s%s\n", c, lv.name, d, seq)
+	case lvIndex:
+		c := t.arg(lv.x, nil)
+		k := t.arg(lv.key, nil)
+		d := t.arg(v, lv.typ)
+		seq, _ := t.order(lv.x, lv.key, v)
+		t.indent("%s[%s] = %s%s\n", c, k, d, seq)
+	}
+}
+
+func (t *translator) storeThrough(p, v value, typ types.Type) {
+	c := t.arg(p, nil)
+	d := t.arg(v, typ)
+	seq, _ := t.order(p, v)
+	t.indent("*%s = %s%s\n", c, d, seq)
+}
+
+func (t *translator) deref(p value, typ types.Type) value {
+	c := t.arg(p, nil)
+	name := t.name("v")
+	seq, id := t.order(p)
+	t.indent("%s := *%s%s\n", name, c, seq)
+	return t.def(name, typ, id)
+}
+
+// convert returns v converted to type T, if it isn't of that type already or if always is set.  A zero value becomes the output of a conversion node with an unconnected input only if always is set.
+func (t *translator) convert(v value, T types.Type, always bool) value {
+	if !always {
+		if len(v.names) == 0 {
+			return value{typ: T}
+		}
+		if b, ok := v.typ.(*types.Basic); ok && b.Info&types.IsUntyped != 0 {
+			if types.IsIdentical(untypedToTyped(b), T) {
+				return v
+			}
+		} else if types.IsIdentical(v.typ, T) {
+			return v
+		}
+	}
+	c := t.arg(v, nil)
+	if len(v.names) == 0 {
+		c = t.arg(value{}, T)
+	}
+	name := t.name("v")
+	t.indent("%s := (%s)(%s)\n", name, t.typeString(T), c)
+	return t.def(name, T, 0)
+}
+
+// tuple translates the multi-valued expression x.
+func (t *translator) tuple(x ast.Expr) []value {
+	switch x := unparen(x).(type) {
+	case *ast.CallExpr:
+		return t.call(x, "")
+	case *ast.IndexExpr:
+		lv := t.lvalue(x, false)
+		c := t.arg(lv.x, nil)
+		k := t.arg(lv.key, nil)
+		name, ok := t.name("v"), t.name("ok")
+		seq, id := t.order(lv.x, lv.key)
+		t.indent("%s, %s := %s[%s]%s\n", name, ok, c, k, seq)
+		return []value{t.def(name, lv.typ, id), t.def(ok, types.Typ[types.Bool], id)}
+	case *ast.TypeAssertExpr:
+		v := t.expr(x.X)
+		T := t.typeOf(x.Type)
+		c := t.arg(v, nil)
+		name, ok := t.name("v"), t.name("ok")
+		t.indent("%s, %s := %s.(%s)\n", name, ok, c, t.typeString(T))
+		return []value{t.def(name, T, 0), t.def(ok, types.Typ[types.Bool], 0)}
+	case *ast.UnaryExpr:
+		if x.Op == token.ARROW {
+			return t.recv(x)
+		}
+	}
+	t.errorf(x, "multi-valued %s", types.ExprString(x))
+	return nil
+}
+
+func (t *translator) recv(x *ast.UnaryExpr) []value {
+	ch := t.expr(x.X)
+	c := t.arg(ch, nil)
+	name, ok := t.name("v"), t.name("ok")
+	seq, id := t.order(ch)
+	t.indent("%s, %s := <-%s%s\n", name, ok, c, seq)
+	return []value{t.def(name, underlying(ch.typ).(*types.Chan).Elem, id), t.def(ok, types.Typ[types.Bool], id)}
+}
+
+func (t *translator) expr(x ast.Expr) value {
+	x = unparen(x)
+	if val, ok := t.info.Values[x]; ok {
+		return t.constant(x, val)
+	}
+	typ := t.typeOf(x)
+	switch x := x.(type) {
+	case *ast.Ident:
+		switch obj := t.info.Objects[x].(type) {
+		case *types.Var:
+			return t.load(t.lvalue(x, false))
+		case *types.Func:
+			return t.funcValue(obj, typ)
+		case *types.Nil:
+			return value{typ: typ}
+		}
+	case *ast.SelectorExpr:
+		sel := t.info.Selections[x]
+		if sel == nil {
+			switch obj := t.info.Objects[x.Sel].(type) {
+			case *types.Var:
+				return t.load(t.lvalue(x, false))
+			case *types.Func:
+				return t.funcValue(obj, typ)
+			}
+			break
+		}
+		switch sel.Kind {
+		case types.FieldVal:
+			return t.load(t.lvalue(x, false))
+		case types.MethodVal:
+			recv := t.recvArg(x, sel)
+			c := t.arg(recv, nil)
+			name := t.name(x.Sel.Name)
+			t.indent("%s := %s.%s\n", name, c, x.Sel.Name)
+			return t.def(name, typ, 0)
+		case types.MethodExpr:
+			t.errorf(x, "method expression")
+			return value{typ: typ}
+		}
+	case *ast.StarExpr, *ast.IndexExpr:
+		return t.load(t.lvalue(x, false))
+	case *ast.SliceExpr:
+		var operand value
+		if _, ok := underlying(t.typeOf(x.X)).(*types.Array); ok {
+			operand = t.addr(t.lvalue(x.X, false))
+		} else {
+			operand = t.expr(x.X)
+		}
+		indices := []value{}
+		for _, y := range []ast.Expr{x.Low, x.High, x.Max} {
+			if y != nil {
+				indices = append(indices, t.convert(t.expr(y), types.Typ[types.Int], false))
+			} else {
+				indices = append(indices, value{typ: types.Typ[types.Int]})
+			}
+		}
+		c := t.arg(operand, nil)
+		names := []string{t.arg(indices[0], nil), ""}
+		if x.High != nil {
+			names[1] = t.arg(indices[1], nil)
+		}
+		if x.Max != nil {
+			names = append(names, t.arg(indices[2], nil))
+		}
+		name := t.name("v")
+		t.indent("%s := %s[%s]\n", name, c, strings.Join(names, ":"))
+		return t.def(name, typ, 0)
+	case *ast.TypeAssertExpr:
+		return t.tuple(x)[0] // a Flux type assertion doesn't panic
+	case *ast.CallExpr:
+		if vals := t.call(x, ""); len(vals) > 0 {
+			return vals[0]
+		}
+		return value{typ: typ}
+	case *ast.FuncLit:
+		name := t.name("v")
+		t.indent("%s := func", name)
+		t.fun("", nil, x.Type, typ.(*types.Signature), x.Body)
+		return t.def(name, typ, 0)
+	case *ast.CompositeLit:
+		return t.compositeLit(x, typ)
+	case *ast.UnaryExpr:
+		switch x.Op {
+		case token.ADD:
+			return t.expr(x.X)
+		case token.SUB:
+			zero := t.literal(exact.MakeInt64(0), types.Typ[types.UntypedInt])
+			return t.operator(token.SUB, zero, t.expr(x.X), typ)
+		case token.NOT:
+			return t.not(t.expr(x.X))
+		case token.XOR:
+			return t.operator(token.XOR, t.expr(x.X), t.literal(allOnes(typ), typ), typ)
+		case token.AND:
+			if c, ok := unparen(x.X).(*ast.CompositeLit); ok {
+				return t.compositeLit(c, typ)
+			}
+			return t.addr(t.lvalue(x.X, false))
+		case token.ARROW:
+			return t.recv(x)[0]
+		}
+	case *ast.BinaryExpr:
+		if x.Op == token.LAND || x.Op == token.LOR {
+			return t.logical(x.Op, t.expr(x.X), func() value { return t.expr(x.Y) }, t.safe(x.Y))
+		}
+		return t.operator(x.Op, t.expr(x.X), t.expr(x.Y), typ)
+	}
+	t.errorf(x, "%s", types.ExprString(x))
+	return value{typ: typ}
+}
+
+func (t *translator) funcValue(f *types.Func, typ types.Type) value {
+	name := t.name(f.Name)
+	t.indent("%s := %s\n", name, t.qualified(f))
+	return t.def(name, typ, 0)
+}
+
+// constant translates the constant expression x, of value val.  A named constant is translated as itself, and other constants as literals.
+func (t *translator) constant(x ast.Expr, val exact.Value) value {
+	typ := t.typeOf(x)
+	if c, ok := t.info.Objects[identOf(x)].(*types.Const); ok && (c.Pkg == nil || c.Parent() == c.Pkg.Scope()) {
+		name := t.name(c.Name)
+		t.indent("const %s = %s\n", name, t.qualified(c))
+		return t.convert(t.def(name, c.Type, 0), typ, false)
+	}
+	return t.literal(val, typ)
+}
+
+// literal returns a literal of value val and type typ.  A negative number is subtracted from zero, and a literal that doesn't have typ by default is converted.
+func (t *translator) literal(val exact.Value, typ types.Type) value {
+	b, _ := underlying(typ).(*types.Basic)
+	if b == nil {
+		b = types.Typ[types.Invalid]
+	}
+	var v value
+	switch {
+	case b.Info&types.IsBoolean != 0:
+		name := t.name("v")
+		t.indent("const %s = %t\n", name, exact.BoolVal(val))
+		v = t.def(name, types.Typ[types.UntypedBool], 0)
+	case b.Info&types.IsString != 0:
+		v = t.basicLit(strconv.Quote(exact.StringVal(val)), types.Typ[types.UntypedString])
+	case b.Info&types.IsComplex != 0:
+		re := t.arg(t.literal(exact.Real(val), types.Typ[types.Float64]), nil)
+		im := t.arg(t.literal(exact.Imag(val), types.Typ[types.Float64]), nil)
+		name := t.name("v")
+		t.indent("%s := complex(%s, %s)\n", name, re, im)
+		v = t.def(name, types.Typ[types.Complex128], 0)
+	default:
+		neg := exact.Sign(val) < 0
+		if neg {
+			val = exact.UnaryOp(token.SUB, val, 0)
+		}
+		if b.Info&types.IsFloat != 0 {
+			f, _ := exact.Float64Val(val)
+			lit := strconv.FormatFloat(f, 'g', -1, 64)
+			if !strings.ContainsAny(lit, ".e") {
+				lit += ".0"
+			}
+			v = t.basicLit(lit, types.Typ[types.UntypedFloat])
+		} else if r, ok := exact.Int64Val(val); ok && (b.Kind == types.UntypedRune || b.Kind == types.Int32 && b.Name == "rune") && r < utf8.MaxRune && utf8.ValidRune(rune(r)) {
+			v = t.basicLit(strconv.QuoteRune(rune(r)), types.Typ[types.UntypedRune])
+		} else {
+			v = t.basicLit(val.String(), types.Typ[types.UntypedInt])
+		}
+		if neg {
+			zero := t.basicLit("0", v.typ)
+			v = t.operator(token.SUB, zero, v, v.typ)
+		}
+	}
+	if b.Info&types.IsUntyped != 0 {
+		return v
+	}
+	return t.convert(v, typ, false)
+}
+
+func (t *translator) basicLit(lit string, typ types.Type) value {
+	name := t.name("v")
+	t.indent("const %s = %s\n", name, lit)
+	return t.def(name, typ, 0)
+}
+
+// allOnes returns the value of typ with all bits set.
+func allOnes(typ types.Type) exact.Value {
+	b, _ := underlying(typ).(*types.Basic)
+	if b == nil || b.Info&types.IsUnsigned == 0 {
+		return exact.MakeInt64(-1)
+	}
+	bits := map[types.BasicKind]uint{types.Uint8: 8, types.Uint16: 16, types.Uint32: 32}[b.Kind]
+	if bits == 0 {
+		return exact.MakeUint64(1<<64 - 1)
+	}
+	return exact.MakeUint64(1<<bits - 1)
+}
+
+func (t *translator) not(v value) value {
+	c := t.arg(v, nil)
+	name := t.name("v")
+	t.indent("%s := !%s\n", name, c)
+	return t.def(name, v.typ, 0)
+}
+
+// operator writes an operator node for x op y.  An untyped operand takes the type of the other; an untyped shift count is made unsigned.
+func (t *translator) operator(op token.Token, x, y value, typ types.Type) value {
+	wx, wy := x.typ, y.typ
+	if op == token.SHL || op == token.SHR {
+		if isUntyped(wy) {
+			y = t.convert(y, types.Typ[types.Uint], false)
+			wy = types.Typ[types.Uint]
+		}
+		if isUntyped(wx) {
+			wx = typ
+		}
+	} else {
+		if isUntyped(wx) && !isUntyped(wy) {
+			wx = wy
+		}
+		if isUntyped(wy) && !isUntyped(wx) {
+			wy = wx
+		}
+	}
+	a := t.arg(x, wx)
+	b := t.arg(y, wy)
+	name := t.name("v")
+	t.indent("%s := %s %s %s\n", name, a, op, b)
+	return t.def(name, typ, 0)
+}
+
+// logical returns x op y, where op is && or ||.  y is evaluated only if x doesn't determine the result, unless it is safe to evaluate anyway.
+func (t *translator) logical(op token.Token, x value, y func() value, safe bool) value {
+	typ := types.Type(types.Typ[types.Bool])
+	if safe {
+		return t.operator(op, x, y(), typ)
+	}
+	var yv value
+	eval := func() {
+		yv = y()
+		if len(yv.names) == 0 {
+			yv = t.convert(yv, typ, true)
+		}
+	}
+	cond := func() value { return x }
+	if op == token.LAND {
+		t.ifNode([]branch{{cond: cond, body: eval}})
+	} else {
+		t.ifNode([]branch{{cond: cond, body: func() {}}, {body: eval}})
+	}
+	return value{append(append([]string{}, x.names...), yv.names...), typ, t.blk, 0}
+}
+
+// safe reports whether evaluating x has no effects and cannot panic, so that it may be evaluated even when Go would not evaluate it.
+func (t *translator) safe(x ast.Expr) bool {
+	safe := true
+	ast.Inspect(x, func(n ast.Node) bool {
+		if x, ok := n.(ast.Expr); ok {
+			if _, ok := t.info.Values[x]; ok {
+				return false
+			}
+		}
+		switch n := n.(type) {
+		case *ast.CallExpr:
+			if !t.isType(n.Fun) {
+				safe = false
+			}
+		case *ast.IndexExpr, *ast.SliceExpr, *ast.StarExpr, *ast.FuncLit:
+			safe = false
+		case *ast.UnaryExpr:
+			if n.Op == token.ARROW {
+				safe = false
+			}
+		case *ast.BinaryExpr:
+			if n.Op == token.QUO || n.Op == token.REM {
+				if _, ok := t.info.Values[n.Y]; !ok {
+					safe = false
+				}
+			}
+		case *ast.SelectorExpr:
+			if sel := t.info.Selections[n]; sel != nil && sel.Indirect {
+				safe = false
+			}
+		}
+		return safe
+	})
+	return safe
+}
+
+// call translates a call (deferred or run in a new goroutine if godefer is set) and returns its results.
+func (t *translator) call(x *ast.CallExpr, godefer string) []value {
+	typ := t.typeOf(x)
+	fun := unparen(x.Fun)
+	if t.isType(fun) {
+		v := t.expr(x.Args[0])
+		if len(v.names) == 0 {
+			return []value{{typ: typ}}
+		}
+		return []value{t.convert(v, typ, true)}
+	}
+
+	var f string
+	var sig *types.Signature
+	args := []value{}
+	switch obj := t.info.Objects[identOf(fun)].(type) {
+	case *types.Builtin:
+		return t.builtin(obj.GetName(), x, godefer)
+	case *types.Func:
+		sig = obj.Type.(*types.Signature)
+		if sig.Recv != nil {
+			if sel := t.info.Selections[fun.(*ast.SelectorExpr)]; sel.Kind == types.MethodVal {
+				recv := t.recvArg(fun.(*ast.SelectorExpr), sel)
+				args = append(args, recv)
+				f = "." + obj.Name
+				break
+			}
+			t.errorf(fun, "method expression")
+			return nil
+		}
+		f = t.qualified(obj)
+	}
+	if f == "" {
+		fv := t.expr(fun)
+		sig, _ = underlying(fv.typ).(*types.Signature)
+		if sig == nil {
+			t.errorf(x, "call of %s", types.ExprString(fun))
+			return nil
+		}
+		args = append(args, fv)
+	}
+
+	params := []value{}
+	if len(x.Args) == 1 && len(sig.Params) > 1 {
+		params = t.tuple(x.Args[0])
+	} else {
+		for _, a := range x.Args {
+			params = append(params, t.expr(a))
+		}
+	}
+	names := []string{}
+	for _, a := range args {
+		names = append(names, t.arg(a, nil))
+	}
+	for i, p := range params {
+		var want types.Type
+		if sig.IsVariadic && i >= len(sig.Params)-1 {
+			want = sig.Params[len(sig.Params)-1].Type
+			if x.Ellipsis == token.NoPos {
+				want = want.(*types.Slice).Elem
+			}
+		} else if i < len(sig.Params) {
+			want = sig.Params[i].Type
+		}
+		names = append(names, t.arg(p, want))
+	}
+	if x.Ellipsis != token.NoPos {
+		names[len(names)-1] += "..."
+	}
+	if strings.HasPrefix(f, ".") {
+		f = names[0] + f
+		names = names[1:]
+	} else if len(args) > 0 {
+		f = names[0]
+		names = names[1:]
+	}
+
+	seq, id := t.order(append(args, params...)...)
+	results := []value{}
+	resultNames := []string{}
+	for _, r := range sig.Results {
+		n := t.name(r.Name)
+		resultNames = append(resultNames, n)
+		results = append(results, t.def(n, r.Type, id))
+	}
+	t.indent("")
+	if len(results) > 0 && godefer == "" {
+		t.write("%s := ", strings.Join(resultNames, ", "))
+	}
+	t.write("%s%s(%s)%s\n", godefer, f, strings.Join(names, ", "), seq)
+	return results
+}
+
+// recvArg returns the receiver of the method selected by x.  A method with a pointer receiver is given the address of an addressable receiver.
+func (t *translator) recvArg(x *ast.SelectorExpr, sel *types.Selection) value {
+	if pointerRecv(sel.Obj) && !sel.Indirect && !isPointer(t.typeOf(x.X)) && t.inMemory(x.X) {
+		return t.addr(t.lvalue(x.X, false))
+	}
+	return t.expr(x.X)
+}
+
+func (t *translator) builtin(name string, x *ast.CallExpr, godefer string) []value {
+	typ := t.typeOf(x)
+	args := []value{}
+	switch name {
+	case "make", "new":
+		for _, a := range x.Args[1:] {
+			args = append(args, t.convert(t.expr(a), types.Typ[types.Int], false))
+		}
+		names := []string{}
+		for _, a := range args {
+			names = append(names, t.arg(a, nil))
+		}
+		T := t.typeOf(x.Args[0])
+		v := t.name("v")
+		if name == "new" {
+			t.indent("%s := new(%s)\n", v, t.typeString(T))
+		} else {
+			if len(names) == 0 {
+				names = append(names, t.arg(value{}, types.Typ[types.Int]))
+			}
+			t.indent("%s := make(%s, %s)\n", v, t.typeString(T), strings.Join(names, ", "))
+		}
+		return []value{t.def(v, typ, 0)}
+	case "print", "println":
+		t.errorf(x, "%s", name)
+		return nil
+	}
+	for _, a := range x.Args {
+		args = append(args, t.expr(a))
+	}
+	names := []string{}
+	for i, a := range args {
+		var want types.Type
+		switch name {
+		case "append":
+			if i > 0 && x.Ellipsis == token.NoPos {
+				want = underlying(typ).(*types.Slice).Elem
+			} else if i == 0 {
+				want = typ
+			}
+		case "panic":
+			want = &types.Interface{}
+		case "delete":
+			if i == 1 {
+				want = underlying(args[0].typ).(*types.Map).Key
+			}
+		case "complex":
+			want = untypedToTyped(a.typ)
+			if isUntyped(a.typ) {
+				want = types.Typ[types.Float64]
+			}
+		}
+		names = append(names, t.arg(a, want))
+	}
+	if x.Ellipsis != token.NoPos {
+		names[len(names)-1] += "..."
+	}
+	switch name {
+	case "len", "cap", "real", "imag", "complex":
+		v := t.name("v")
+		t.indent("%s := %s(%s)\n", v, name, strings.Join(names, ", "))
+		return []value{t.def(v, typ, 0)}
+	case "append", "copy", "recover":
+		seq, id := t.order(args...)
+		v := t.name("v")
+		t.indent("")
+		if godefer == "" {
+			t.write("%s := ", v)
+		}
+		t.write("%s%s(%s)%s\n", godefer, name, strings.Join(names, ", "), seq)
+		return []value{t.def(v, typ, id)}
+	case "close", "delete", "panic":
+		seq, _ := t.order(args...)
+		t.indent("%s%s(%s)%s\n", godefer, name, strings.Join(names, ", "), seq)
+		return nil
+	}
+	t.errorf(x, "%s", name)
+	return nil
+}
+
+// compositeLit translates a composite literal of type typ, or of a pointer to it.  Flux only has literals of structs; other literals are made and then filled in.
+func (t *translator) compositeLit(x *ast.CompositeLit, typ types.Type) value {
+	T, ptr := indirect(typ)
+	var v value
+	switch u := underlying(T).(type) {
+	case *types.Struct:
+		fields := []string{}
+		vals := []value{}
+		for i, e := range x.Elts {
+			f := u.Fields[i]
+			if kv, ok := e.(*ast.KeyValueExpr); ok {
+				for _, f2 := range u.Fields {
+					if f2.Name == kv.Key.(*ast.Ident).Name {
+						f = f2
+					}
+				}
+				e = kv.Value
+			}
+			fields = append(fields, f.Name)
+			vals = append(vals, t.expr(e))
+		}
+		elts := []string{}
+		for i, v := range vals {
+			elts = append(elts, fields[i]+": "+t.arg(v, nil))
+		}
+		amp := ""
+		if ptr {
+			amp = "&"
+		}
+		name := t.name("v")
+		t.indent("%s := %s%s{%s}\n", name, amp, t.typeString(T), strings.Join(elts, ", "))
+		return t.def(name, typ, 0)
+	case *types.Slice, *types.Array, *types.Map:
+		var elemType, keyType types.Type = nil, types.Typ[types.Int]
+		switch u := u.(type) {
+		case *types.Slice:
+			elemType = u.Elem
+		case *types.Array:
+			elemType = u.Elem
+		case *types.Map:
+			elemType, keyType = u.Elem, u.Key
+		}
+		_, isMap := u.(*types.Map)
+		n, max := int64(0), int64(0) // the index of the next element, and the length of the literal
+		keys := []value{}
+		vals := []value{}
+		for _, e := range x.Elts {
+			if kv, ok := e.(*ast.KeyValueExpr); ok {
+				keys = append(keys, t.expr(kv.Key))
+				if !isMap {
+					n, _ = exact.Int64Val(t.info.Values[kv.Key])
+				}
+				e = kv.Value
+			} else {
+				keys = append(keys, t.literal(exact.MakeInt64(n), types.Typ[types.UntypedInt]))
+			}
+			vals = append(vals, t.expr(e))
+			n++
+			if n > max {
+				max = n
+			}
+		}
+		if _, ok := u.(*types.Array); ok {
+			a := t.builtinValue("new", T, nil)
+			for i := range vals {
+				t.store(&lvalue{kind: lvIndex, x: a, key: keys[i], typ: elemType}, vals[i])
+			}
+			if ptr {
+				return a
+			}
+			return t.deref(a, T)
+		}
+		length := []value{}
+		if !isMap {
+			length = append(length, t.literal(exact.MakeInt64(max), types.Typ[types.UntypedInt]))
+		}
+		v = t.builtinValue("make", T, length)
+		for i := range vals {
+			t.store(&lvalue{kind: lvIndex, x: v, key: t.convert(keys[i], keyType, false), typ: elemType}, vals[i])
+		}
+	default:
+		t.errorf(x, "composite literal of type %s", t.typeString(T))
+		return value{typ: typ}
+	}
+	if ptr {
+		p := t.builtinValue("new", T, nil)
+		t.storeThrough(p, v, T)
+		return p
+	}
+	return v
+}
+
+// builtinValue writes a make or new node of type T.
+func (t *translator) builtinValue(name string, T types.Type, args []value) value {
+	names := []string{}
+	for _, a := range args {
+		names = append(names, t.arg(a, types.Typ[types.Int]))
+	}
+	v := t.name("v")
+	if name == "new" {
+		t.indent("%s := new(%s)\n", v, t.typeString(T))
+		return t.def(v, types.NewPointer(T), 0)
+	}
+	if len(names) == 0 {
+		names = append(names, t.arg(value{}, types.Typ[types.Int]))
+	}
+	t.indent("%s := make(%s, %s)\n", v, t.typeString(T), strings.Join(names, ", "))
+	return t.def(v, T, 0)
+}
+
+func (t *translator) isType(x ast.Expr) bool {
+	switch x := unparen(x).(type) {
+	case *ast.Ident, *ast.SelectorExpr:
+		_, ok := t.info.Objects[identOf(x)].(*types.TypeName)
+		return ok
+	case *ast.StarExpr:
+		return t.isType(x.X)
+	case *ast.ArrayType, *ast.ChanType, *ast.FuncType, *ast.InterfaceType, *ast.MapType, *ast.StructType:
+		return true
+	}
+	return false
+}
+
+// identOf returns the identifier that x names, or nil if it is not a (qualified) identifier.
+func identOf(x ast.Expr) *ast.Ident {
+	switch x := unparen(x).(type) {
+	case *ast.Ident:
+		return x
+	case *ast.SelectorExpr:
+		return x.Sel
+	}
+	return nil
+}
+
+func unparen(x ast.Expr) ast.Expr {
+	if p, ok := x.(*ast.ParenExpr); ok {
+		return unparen(p.X)
+	}
+	return x
+}
+
+func isPointer(t types.Type) bool {
+	_, ok := underlying(t).(*types.Pointer)
+	return ok
+}
+
+func isUntyped(t types.Type) bool {
+	b, ok := t.(*types.Basic)
+	return ok && b.Info&types.IsUntyped != 0
+}
+
+// pointerRecv reports whether obj is a method with a pointer receiver.
+func pointerRecv(obj types.Object) bool {
+	f, ok := obj.(*types.Func)
+	if !ok {
+		return false
+	}
+	recv := f.Type.(*types.Signature).Recv
+	return recv != nil && isPointer(recv.Type) && !isInterfaceRecv(recv.Type)
+}
+
+func isInterfaceRecv(t types.Type) bool {
+	_, ok := underlying(t).(*types.Interface)
+	return ok
+}
@@ -18,6 +18,7 @@ type typeView struct {
 	val        types.Object // non-nil if this is a valueView
 	currentPkg *types.Package
 	done       func()
+	history    *history // non-nil if this is the root of a type editor
 
 	name       *Text // non-nil if this is a valueView
 	pkg        *pkgText
@@ -245,6 +246,7 @@ func (v *typeView) reform() {
 }
 
 func (v *typeView) edit(done func()) {
+	done = v.transaction(done)
 	if v.name == nil {
 		v.editType(done)
 		return
@@ -319,6 +321,7 @@ func (v *typeView) unique(name string) bool {
 }
 
 func (v *typeView) editType(done func()) {
+	done = v.transaction(done)
 	switch t := (*v.typ).(type) {
 	case nil:
 		opts := browserOptions{acceptTypes: true}
@@ -392,6 +395,15 @@ func (v *typeView) editType(done func()) {
 	}
 }
 
+// transaction begins an edit that ends after done.
+func (v *typeView) transaction(done func()) func() {
+	end := beginEdit(v)
+	return func() {
+		done()
+		end()
+	}
+}
+
 func (v *typeView) insertVar(vs *[]*types.Var, elems *[]*typeView, before bool, i int, success, fail func()) {
 	if !before {
 		i++
@@ -471,6 +483,14 @@ func (v *typeView) TookKeyFocus() { v.focused = true; Repaint(v) }
 func (v *typeView) LostKeyFocus() { v.focused = false; Repaint(v) }
 
 func (v *typeView) KeyPress(event KeyEvent) {
+	if event.Command && event.Key == KeyZ && v.history != nil {
+		if event.Shift {
+			v.history.redo()
+		} else {
+			v.history.undo()
+		}
+		return
+	}
 	switch event.Key {
 	case KeyLeft, KeyRight, KeyUp, KeyDown:
 		foc := KeyFocus(v)
@@ -679,6 +699,7 @@ func (v *typeView) KeyPress(event KeyEvent) {
 					}
 				}
 			}
+			recordEdit(p)
 		}
 	default:
 		v.ViewBase.KeyPress(event)
@@ -81,8 +81,10 @@ func (n *valueNode) connsChanged() {
 		n.addressable = true
 	case *types.Func:
 		if isMethod(obj) {
-			xt = obj.Type.(*types.Signature).Recv.Type
-			// TODO: remove Recv? (from copy)
+			sig := *obj.Type.(*types.Signature)
+			xt = sig.Recv.Type
+			sig.Recv = nil
+			yt = &sig
 		}
 	case field:
 		xt = obj.recv
@@ -110,6 +112,7 @@ func (n *valueNode) KeyPress(event KeyEvent) {
 		n.set = !n.set
 		n.connsChanged()
 		SetKeyFocus(n)
+		recordEdit(n)
 	} else {
 		n.nodeBase.KeyPress(event)
 	}
@@ -0,0 +1,206 @@
+// Copyright 2014 Gordon Klaus. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"code.google.com/p/gordon-go/flux/go/exact"
+	"code.google.com/p/gordon-go/flux/go/types"
+	. "code.google.com/p/gordon-go/flux/gui"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strconv"
+)
+
+// The value of a package-level var or const is edited like the body of a func that takes no arguments and returns the value.  A var's initializer is saved as a call of such a func literal (or omitted if the body is empty); a const's graph is saved as the single constant expression it computes.
+
+// newValuePort adds the input for the value of f, a var's initializer or a const's graph.  A const takes the type of whatever is connected to it.
+func (f *funcNode) newValuePort() *port {
+	p := f.outputsNode.newInput(f.sig().Results[0])
+	if f.isConst() {
+		p.connsChanged = func() {
+			var t types.Type
+			for _, c := range p.conns {
+				if c.src != nil {
+					t = c.src.obj.Type
+				}
+			}
+			p.setType(t)
+		}
+	}
+	return p
+}
+
+func (f *funcNode) isConst() bool {
+	_, ok := f.obj.(*types.Const)
+	return ok
+}
+
+// isConstValue reports whether p is the value of a const's graph, to which a value of any basic type may be connected.
+func isConstValue(p *port) bool {
+	b := p.node.block()
+	if b == nil {
+		return false
+	}
+	f, ok := b.node.(*funcNode)
+	return ok && p.node == f.outputsNode && f.isConst()
+}
+
+// isConstOperand reports whether obj may be used in a const's graph:  a constant, an operator on constants, or a conversion.
+func isConstOperand(obj types.Object) bool {
+	switch obj := obj.(type) {
+	case *types.Const:
+		return true
+	case special:
+		return obj.Name == "convert"
+	case *types.Func:
+		switch obj.Name {
+		case "[]", "[:]", "<-":
+			return false
+		}
+		return isOperator(obj)
+	}
+	return false
+}
+
+// constExpr returns the expression computed by f, a const's graph, or "" if its value is not connected.  Only the nodes that feed the value are written; there is no place in a constant expression for the rest.
+func (w *writer) constExpr(f *funcNode) string {
+	x, _ := w.constOperand(f.outputsNode.ins[0])
+	return x
+}
+
+// constOperand returns the expression connected to p, or "" if there is none, and whether it is an operation (which is parenthesized when it is itself an operand).
+func (w *writer) constOperand(p *port) (x string, op bool) {
+	conns := w.conns(p)
+	if len(conns) == 0 {
+		return "", false
+	}
+	operand := func(p *port) string {
+		x, op := w.constOperand(p)
+		if op {
+			x = "(" + x + ")"
+		}
+		return x
+	}
+	switch n := conns[0].src.node.(type) {
+	case *basicLiteralNode:
+		val := n.text.Text()
+		switch n.kind {
+		case token.STRING:
+			val = strconv.Quote(val)
+		case token.CHAR:
+			if val == "" {
+				return "", false
+			}
+			val = strconv.QuoteRune([]rune(val)[0])
+		}
+		return val, false
+	case *valueNode:
+		return w.qualifiedName(n.obj), false
+	case *convertNode:
+		if x, _ := w.constOperand(n.ins[0]); x != "" {
+			return fmt.Sprintf("(%s)(%s)", w.typ(*n.typ.typ), x), false
+		}
+	case *operatorNode:
+		x := operand(n.ins[0])
+		if x == "" {
+			break
+		}
+		if n.op == "!" {
+			return "!" + x, true
+		}
+		if y := operand(n.ins[1]); y != "" {
+			return x + " " + n.op + " " + y, true
+		}
+	}
+	return "", false
+}
+
+// constOperand reads x, part of a const's expression, into b and returns the output of its value.
+func (r *reader) constOperand(b *block, x ast.Expr) *port {
+	connect := func(x ast.Expr, in *port) {
+		c := newConnection()
+		c.setSrc(r.constOperand(b, x))
+		if !c.connectable(c.src, in) {
+			c.bad = true
+		}
+		c.setDst(in)
+	}
+	switch y := x.(type) {
+	case *ast.ParenExpr:
+		return r.constOperand(b, y.X)
+	case *ast.BinaryExpr:
+		n := newOperatorNode(types.NewFunc(0, nil, y.Op.String(), nil))
+		b.addNode(n)
+		connect(y.X, n.ins[0])
+		connect(y.Y, n.ins[1])
+		return n.outs[0]
+	case *ast.UnaryExpr:
+		if y.Op == token.NOT {
+			n := newOperatorNode(types.NewFunc(0, nil, y.Op.String(), nil))
+			b.addNode(n)
+			connect(y.X, n.ins[0])
+			return n.outs[0]
+		}
+		if _, ok := y.X.(*ast.BasicLit); !ok { // Flux has no unary minus or plus
+			return r.constOperand(b, &ast.BinaryExpr{X: &ast.BasicLit{Kind: token.INT, Value: "0"}, Op: y.Op, Y: y.X})
+		}
+	case *ast.CallExpr:
+		t := y.Fun
+		if p, ok := t.(*ast.ParenExpr); ok {
+			t = p.X
+		}
+		n := newConvertNode(r.pkg)
+		b.addNode(n)
+		n.setType(r.typ(t))
+		connect(y.Args[0], n.ins[0])
+		return n.outs[0]
+	}
+	// a literal or a named constant, read as the writer would have written it in a func body
+	name := "v"
+	for i := 2; r.ports[name] != nil; i++ {
+		name = "v" + strconv.Itoa(i)
+	}
+	r.block(b, []ast.Stmt{&ast.DeclStmt{Decl: &ast.GenDecl{Tok: token.CONST, Specs: []ast.Spec{&ast.ValueSpec{Names: []*ast.Ident{ast.NewIdent(name)}, Values: []ast.Expr{x}}}}}})
+	return r.ports[name]
+}
+
+// fold evaluates the expression of f, a const's graph.
+func fold(f *funcNode) (t types.Type, val exact.Value, err error) {
+	defer func() {
+		if x := recover(); x != nil { // e.g., an operand whose value is not yet known
+			err = fmt.Errorf("cannot evaluate %s: %v", f.obj.GetName(), x)
+		}
+	}()
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", funcSource(f), 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	spec := file.Decls[len(file.Decls)-1].(*ast.GenDecl).Specs[0].(*ast.ValueSpec)
+	if len(spec.Values) == 0 {
+		return nil, nil, fmt.Errorf("%s has no value", f.obj.GetName())
+	}
+	r := newReader(fset, file, f.pkg())
+	return types.EvalNode(fset, spec.Values[0], f.pkg(), r.scope)
+}
+
+// showValue displays the folded value of f, a const's graph, beside its value port.
+func (f *funcNode) showValue() {
+	p := f.outputsNode.ins[0]
+	if f.value == nil || Parent(f.value) != p {
+		f.value = NewText("")
+		f.value.SetTextColor(color(&types.Const{}, true, false))
+		f.value.SetBackgroundColor(noColor)
+		p.Add(f.value)
+	}
+	text := "?"
+	if _, val, err := fold(f); err == nil {
+		text = val.String()
+	}
+	f.value.SetText("= " + text)
+	f.value.Move(Pt(portSize, -Height(f.value)/2))
+}
@@ -7,91 +7,153 @@ package main
 import (
 	"bytes"
 	"code.google.com/p/gordon-go/flux/go/types"
+	"code.google.com/p/gordon-go/refactor"
 	"fmt"
 	"go/build"
-	"go/parser"
 	"go/token"
 	"io"
-	"io/ioutil"
-	"os"
 	"path/filepath"
+	"runtime/debug"
+	"sort"
 	"strconv"
 	"strings"
 )
 
-func savePackageName(importPath, name string) {
+// savePackageName changes the name of the package at importPath, updating the files that import it without naming it.  The package and its importers are rewritten all or nothing.
+func savePackageName(importPath, name string) error {
 	p, _ := build.Import(importPath, "", 0)
-	files := append(append(append(p.GoFiles, p.IgnoredGoFiles...), p.CgoFiles...), p.TestGoFiles...)
-	for _, file := range files {
-		path := filepath.Join(p.Dir, file)
-		b, err := ioutil.ReadFile(path)
-		if err != nil {
-			fmt.Printf("error reading %s: %s\n", path, err)
-			return
-		}
-		src := string(b)
-		fset := token.NewFileSet()
-		astFile, err := parser.ParseFile(fset, "", src, parser.PackageClauseOnly)
-		if err != nil {
-			fmt.Printf("error parsing %s: %s\n", path, err)
-			return
-		}
-		oldName := astFile.Name
-		i := fset.Position(oldName.Pos()).Offset
-		src = src[:i] + name + src[i+len(oldName.Name):]
-		if err := ioutil.WriteFile(path, []byte(src), 0666); err != nil {
-			fmt.Printf("error writing %s: %s\n", path, err)
-			return
-		}
-	}
-	if len(files) == 0 {
-		path := filepath.Join(p.Dir, "package.flux.go")
-		if err := ioutil.WriteFile(path, []byte("package "+name), 0666); err != nil {
-			fmt.Printf("error writing %s: %s\n", path, err)
-			return
+	var clients []string
+	if len(p.GoFiles)+len(p.IgnoredGoFiles)+len(p.CgoFiles)+len(p.TestGoFiles) > 0 {
+		var err error
+		if clients, err = refactor.RenamePackage(importPath, name); err != nil {
+			return err
 		}
+	} else if err := refactor.WriteFile(filepath.Join(p.Dir, "package.flux.go"), []byte("package "+name)); err != nil {
+		return err
 	}
 
 	if pkg, ok := pkgs[p.ImportPath]; ok {
 		pkg.Name = name
 	}
+	resave(clients)
+	return nil
+}
 
-	// TODO: update all uses
+func saveType(t *types.Named) error {
+	return save(t.Obj, func() []byte { return typeSource(t) })
 }
 
-func saveType(t *types.Named) {
-	w := newWriter(t.Obj)
-	if w == nil {
-		return
-	}
-	defer w.close()
+// typeSource returns the source that saveType would write for t.
+func typeSource(t *types.Named) []byte {
+	return source(t.Obj, func(w *writer) { w.typeDecl(t) })
+}
 
+func (w *writer) typeDecl(t *types.Named) {
 	u := t.UnderlyingT
 	w.collectPkgs(u)
 	w.imports()
 
-	w.write("type %s %s", t.Obj.Name, w.typ(u))
+	w.write("type %s %s\n", t.Obj.Name, w.typ(u))
 }
 
-func saveFunc(f *funcNode) {
-	w := newWriter(f.obj)
-	if w == nil {
-		return
+// saveFunc saves f, a func or the value of a var or const.  A const is saved only if its value is a valid constant expression, the type and value of which it then takes.
+func saveFunc(f *funcNode) error {
+	if c, ok := f.obj.(*types.Const); ok {
+		t, val, err := fold(f)
+		if err != nil {
+			fmt.Printf("error saving %s: %s\n", c.Name, err)
+			return err
+		}
+		c.Type = t
+		c.SetVal(val)
+	}
+	if err := save(f.obj, func() []byte { return funcSource(f) }); err != nil {
+		return err
+	}
+	if f.history != nil {
+		f.history.markSaved()
+	}
+	f.savedSig = saveSig(f.sig())
+	removeAutosave(f.obj)
+	return nil
+}
+
+// funcSource returns the source that saveFunc would write for f.
+func funcSource(f *funcNode) []byte {
+	return source(f.obj, func(w *writer) { w.funcDecl(f) })
+}
+
+// source returns what write writes for obj.
+func source(obj types.Object, write func(w *writer)) []byte {
+	buf := &bytes.Buffer{}
+	write(newWriterTo(obj, struct {
+		*bytes.Buffer
+		io.Closer
+	}{buf, nil}))
+	return buf.Bytes()
+}
+
+// save writes the source of obj to its file, reporting any error.  The source is generated before the file is touched and the file is replaced only once the new one is completely written, so a failed save (even a panic in the writer) leaves the old file intact.
+func save(obj types.Object, src func() []byte) (err error) {
+	path := fluxPath(obj)
+	defer func() {
+		if x := recover(); x != nil {
+			err = fmt.Errorf("panic: %v\n%s", x, debug.Stack())
+		}
+		if err != nil {
+			fmt.Printf("error saving %s: %s\n", path, err)
+		}
+	}()
+	if path == "" {
+		return fmt.Errorf("no file for %s", obj.GetName())
+	}
+	if err := refactor.WriteFile(path, src()); err != nil {
+		return err
 	}
-	defer w.close()
+	fluxObjs[obj] = true
+	return nil
+}
 
+func (w *writer) funcDecl(f *funcNode) {
+	pkgs := []*types.Package{}
 	for p := range f.pkgRefs {
+		pkgs = append(pkgs, p)
+	}
+	sort.Sort(pkgsByPath(pkgs))
+	for _, p := range pkgs {
 		w.pkgNames[p] = w.name(p.Name)
 	}
+	w.importing(func() {
+		switch obj := f.obj.(type) {
+		case *types.Var:
+			w.collectPkgs(obj.Type)
+			w.write("var %s %s", obj.Name, w.typ(obj.Type))
+			if len(f.funcblk.nodes) > 2 { // more than the inputs and outputs nodes
+				w.write(" = ")
+				w.fun(f, map[*port]string{})
+				w.write("()")
+			}
+		case *types.Const:
+			w.write("const %s", obj.Name)
+			if x := w.constExpr(f); x != "" {
+				w.write(" = %s", x)
+			}
+		default:
+			w.fun(f, map[*port]string{})
+		}
+		w.write("\n")
+	})
+}
 
-	// some package names are collected during w.fun, so delay w.imports
+// importing writes the imports followed by whatever body writes.  Some package names are collected while writing, so the body is buffered until they are known.
+func (w *writer) importing(body func()) {
 	buf := bytes.Buffer{}
 	src := w.src
 	w.src = struct {
 		*bytes.Buffer
 		io.Closer
 	}{&buf, nil}
-	w.fun(f, map[*port]string{})
+	body()
 	w.src = src
 
 	w.imports()
@@ -106,17 +168,14 @@ type writer struct {
 	seqID    int
 	seqIDs   map[node]int
 	nindent  int
-}
 
-func newWriter(obj types.Object) *writer {
-	src, err := os.Create(fluxPath(obj))
-	if err != nil {
-		fmt.Printf("error creating %s: %s\n", fluxPath(obj), err)
-		return nil
-	}
-	w := &writer{src, obj.GetPkg(), map[*types.Package]string{}, map[string]int{}, 0, map[node]int{}, 0}
-	fluxObjs[obj] = true
+	// when writing a selection of nodes, top is their block and sel the selection
+	top *block
+	sel map[node]bool
+}
 
+func newWriterTo(obj types.Object, src io.WriteCloser) *writer {
+	w := &writer{src, obj.GetPkg(), map[*types.Package]string{}, map[string]int{}, 0, map[node]int{}, 0, nil, nil}
 	w.write("// Generated by Flux, not meant for human consumption.  Editing may make it unreadable by Flux.\n\n")
 	w.write("package %s\n\n", w.pkg.Name)
 	for _, name := range append(types.Universe.Names(), w.pkg.Scope().Names()...) {
@@ -133,10 +192,6 @@ func (w *writer) indent(format string, a ...interface{}) {
 	w.write(strings.Repeat("\t", w.nindent)+format, a...)
 }
 
-func (w *writer) close() {
-	w.src.Close()
-}
-
 func (w *writer) collectPkgs(t types.Type) {
 	walkType(t, func(n *types.Named) {
 		if p := n.Obj.Pkg; p != nil && p != w.pkg {
@@ -151,8 +206,14 @@ func (w *writer) imports() {
 	if len(w.pkgNames) == 0 {
 		return
 	}
+	pkgs := []*types.Package{}
+	for p := range w.pkgNames {
+		pkgs = append(pkgs, p)
+	}
+	sort.Sort(pkgsByPath(pkgs))
 	w.write("import (\n")
-	for p, id := range w.pkgNames {
+	for _, p := range pkgs {
+		id := w.pkgNames[p]
 		w.write("\t")
 		if id != p.Name {
 			w.write(id + " ")
@@ -162,6 +223,12 @@ func (w *writer) imports() {
 	w.write(")\n\n")
 }
 
+type pkgsByPath []*types.Package
+
+func (p pkgsByPath) Len() int           { return len(p) }
+func (p pkgsByPath) Less(i, j int) bool { return p[i].Path < p[j].Path }
+func (p pkgsByPath) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
+
 func (w *writer) fun(f *funcNode, vars map[*port]string) {
 	vars, varsCopy := map[*port]string{}, vars
 	for k, v := range varsCopy {
@@ -183,7 +250,11 @@ func (w *writer) fun(f *funcNode, vars map[*port]string) {
 		vars[p] = name
 		w.write("(%s %s) ", name, w.typ(p.obj.Type))
 	}
-	w.write("%s(", obj.GetName())
+	name := ""
+	if _, ok := obj.(*types.Func); ok {
+		name = obj.GetName()
+	}
+	w.write("%s(", name)
 	for i, p := range params {
 		if i > 0 {
 			w.write(", ")
@@ -215,12 +286,21 @@ func (w *writer) fun(f *funcNode, vars map[*port]string) {
 	w.nindent--
 	w.block(f.funcblk, vars)
 	w.indent("\treturn\n")
-	w.indent("}\n")
+	w.indent("}")
 }
 
 // vars maps inputs to variable names.  additionally, it stores the ouputs corresponding to func args and loops vars for special handling.
 func (w *writer) block(b *block, vars map[*port]string) {
 	order := b.nodeOrder()
+	if b == w.top {
+		sel := []node{}
+		for _, n := range order {
+			if w.sel[n] {
+				sel = append(sel, n)
+			}
+		}
+		order = sel
+	}
 
 	vars, varsCopy := map[*port]string{}, vars
 	for k, v := range varsCopy {
@@ -229,8 +309,15 @@ func (w *writer) block(b *block, vars map[*port]string) {
 
 	w.nindent++
 
+	conns := []*connection{}
 	for c := range b.conns {
-		if _, ok := vars[c.dst]; ok {
+		if c.connected() { // a connection still being made is not written
+			conns = append(conns, c)
+		}
+	}
+	sort.Sort(connsByDst{conns, w.positions(b)})
+	for _, c := range conns {
+		if _, ok := vars[c.dst]; ok || w.cut(c) {
 			continue
 		}
 		if t := c.dst.obj.Type; t != seqType {
@@ -359,6 +446,7 @@ func (w *writer) block(b *block, vars map[*port]string) {
 				if len(results) > 0 {
 					w.indent("%s := ", results[0])
 					w.fun(n, vars)
+					w.write("\n")
 				}
 			case *indexNode:
 				if n.set && len(args) > 0 {
@@ -388,7 +476,7 @@ func (w *writer) block(b *block, vars map[*port]string) {
 			case *operatorNode:
 				c := 0
 				for _, p := range ins {
-					c += len(p.conns)
+					c += len(w.conns(p))
 				}
 				if c > 0 && len(results) > 0 {
 					// TODO: handle constant expressions
@@ -476,7 +564,7 @@ func (w *writer) block(b *block, vars map[*port]string) {
 				w.write("%s{", w.typ(t))
 				first := true
 				for _, in := range ins(n) {
-					if len(in.conns) > 0 {
+					if len(w.conns(in)) > 0 {
 						if !first {
 							w.write(", ")
 						}
@@ -495,9 +583,9 @@ func (w *writer) block(b *block, vars map[*port]string) {
 					w.write(" else ")
 				}
 				cond := n.cond[i]
-				if i == 0 || i < len(n.blocks)-1 || len(cond.conns) > 0 {
+				if i == 0 || i < len(n.blocks)-1 || len(w.conns(cond)) > 0 {
 					w.write("if ")
-					if len(cond.conns) > 0 {
+					if len(w.conns(cond)) > 0 {
 						w.write(vars[cond])
 					} else {
 						w.write("false")
@@ -509,6 +597,14 @@ func (w *writer) block(b *block, vars map[*port]string) {
 			}
 			w.seq(n)
 		case *loopNode:
+			if _, ok := vars[n.input]; !ok {
+				if t := n.input.obj.Type; t != nil {
+					w.collectPkgs(t)
+					name := w.name("v")
+					w.indent("var %s %s\n", name, w.typ(t))
+					vars[n.input] = name
+				}
+			}
 			w.indent("for ")
 			key, val := "_", "_"
 			kv := n.inputsNode.outs
@@ -571,11 +667,14 @@ func (w *writer) block(b *block, vars map[*port]string) {
 			w.seq(n)
 		case *selectNode:
 			for _, c := range n.cases {
-				if c.elem != nil && vars[c.elem] == "" {
-					if t := c.elem.obj.Type; t != nil {
-						name := w.name("v")
-						w.indent("var %s %s\n", name, w.typ(t))
-						vars[c.elem] = name
+				for _, p := range []*port{c.ch, c.elem} {
+					if p != nil && vars[p] == "" {
+						if t := p.obj.Type; t != nil {
+							w.collectPkgs(t)
+							name := w.name("v")
+							w.indent("var %s %s\n", name, w.typ(t))
+							vars[p] = name
+						}
 					}
 				}
 			}
@@ -619,6 +718,68 @@ func (w *writer) block(b *block, vars map[*port]string) {
 	w.nindent--
 }
 
+// positions returns the position in the output of each node in b and in the blocks nested in it.
+func (w *writer) positions(b *block) map[node]int {
+	pos := map[node]int{}
+	var number func(b *block)
+	number = func(b *block) {
+		for _, n := range b.nodeOrder() {
+			pos[n] = len(pos)
+			switch n := n.(type) {
+			case *ifNode:
+				for _, b := range n.blocks {
+					number(b)
+				}
+			case *selectNode:
+				for _, c := range n.cases {
+					number(c.blk)
+				}
+			case *loopNode:
+				number(n.loopblk)
+			case *funcNode:
+				number(n.funcblk)
+			}
+		}
+	}
+	number(b)
+	return pos
+}
+
+// connsByDst orders connections by the output position of their destination nodes and then by port, so that the variables they are written as are declared and named in a deterministic order.  The connections must be connected at both ends.
+type connsByDst struct {
+	conns []*connection
+	pos   map[node]int
+}
+
+func (c connsByDst) Len() int      { return len(c.conns) }
+func (c connsByDst) Swap(i, j int) { c.conns[i], c.conns[j] = c.conns[j], c.conns[i] }
+func (c connsByDst) Less(i, j int) bool {
+	c1, c2 := c.conns[i], c.conns[j]
+	if n1, n2 := c.pos[c1.dst.node], c.pos[c2.dst.node]; n1 != n2 {
+		return n1 < n2
+	}
+	if p1, p2 := portIndex(c1.dst), portIndex(c2.dst); p1 != p2 {
+		return p1 < p2
+	}
+	if n1, n2 := c.pos[c1.src.node], c.pos[c2.src.node]; n1 != n2 {
+		return n1 < n2
+	}
+	return portIndex(c1.src) < portIndex(c2.src)
+}
+
+func portIndex(p *port) int {
+	ports := p.node.inputs()
+	if p.out {
+		ports = p.node.outputs()
+	}
+	for i, p2 := range ports {
+		if p2 == p {
+			return i
+		}
+	}
+	return -1
+}
+
 func (w *writer) results(n node, vars map[*port]string) (results []string, existing map[string]string) {
 	existing = map[string]string{}
 	any := false
@@ -632,6 +793,9 @@ func (w *writer) results(n node, vars map[*port]string) (results []string, exist
 				name = w.name(p.obj.GetName())
 			}
 			for _, c := range p.conns {
+				if !c.connected() {
+					continue
+				}
 				v := name
 				if !assignable(c.src.obj.Type, c.dst.obj.Type) {
 					v = "*" + v
@@ -652,16 +816,21 @@ func (w *writer) results(n node, vars map[*port]string) (results []string, exist
 
 func (w *writer) seq(n node) {
 	seqIn, seqOut := seqIn(n), seqOut(n)
-	in := seqIn != nil && len(seqIn.conns) > 0
+	in := seqIn != nil && len(w.conns(seqIn)) > 0
 	out := seqOut != nil && len(seqOut.conns) > 0
 	if in || out {
 		w.write("//")
 		if in {
-			for i, c := range seqIn.conns {
+			ids := []int{}
+			for _, c := range w.conns(seqIn) {
+				ids = append(ids, w.seqIDs[c.src.node])
+			}
+			sort.Ints(ids)
+			for i, id := range ids {
 				if i > 0 {
 					w.write(",")
 				}
-				w.write(strconv.Itoa(w.seqIDs[c.src.node]))
+				w.write(strconv.Itoa(id))
 			}
 		}
 		w.write(";")
@@ -675,9 +844,33 @@ func (w *writer) seq(n node) {
 	w.write("\n")
 }
 
+// cut reports whether c is cut by the selection being written, i.e. whether its source is outside of the selection.
+func (w *writer) cut(c *connection) bool {
+	return w.sel != nil && c.src != nil && !w.inside(c.src.node)
+}
+
+func (w *writer) inside(n node) bool {
+	return w.sel[w.top.find(n)]
+}
+
+// conns returns the connections to the input p that are connected and not cut.
+func (w *writer) conns(p *port) (conns []*connection) {
+	for _, c := range p.conns {
+		if c.connected() && !w.cut(c) {
+			conns = append(conns, c)
+		}
+	}
+	return
+}
+
 func (w *writer) assignExisting(m map[string]string) {
-	for v1, v2 := range m {
-		w.indent("%s = %s\n", v1, v2)
+	vars := []string{}
+	for v := range m {
+		vars = append(vars, v)
+	}
+	sort.Strings(vars)
+	for _, v := range vars {
+		w.indent("%s = %s\n", v, m[v])
 	}
 }
 
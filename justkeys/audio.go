@@ -6,7 +6,7 @@ import (
 	"code.google.com/p/gordon-go/audio"
 )
 
-var tones Tones
+var tones = Tones{audio.MultiVoice{Max: 24, Steal: audio.StealQuietest}}
 
 type Tones struct {
 	audio.MultiVoice
@@ -46,7 +46,7 @@ func (v *pressedTone) attack(amp float64) {
 	v.Amp.SetPoints([]*audio.ControlPoint{{0, a}, {t, amp}, {9999, amp}})
 }
 
-func (v *pressedTone) release() {
+func (v *pressedTone) Release() {
 	a := v.Amp.Sing()
 	v.Amp.SetPoints([]*audio.ControlPoint{{0, a}, {4, -12}})
 }
@@ -31,10 +31,13 @@ type keyBase struct {
 	y          float64
 	size       float64
 	voice      ampVoice
+	handle     *audio.VoiceHandle
 }
 
 func (k *keyBase) base() *keyBase { return k }
 
+func (k *keyBase) playing() bool { return k.handle != nil && !k.handle.Done() }
+
 type ampVoice interface {
 	audio.Voice
 	amp() float64
@@ -45,9 +48,9 @@ type pressedKey struct {
 }
 
 func (k *pressedKey) press(loc geom.Point) {
-	if k.voice == nil || k.voice.Done() {
+	if !k.playing() {
 		k.voice = newPressedTone(math.Exp2(k.pitch))
-		tones.Add(k.voice)
+		k.handle = tones.AddPitch(k.voice, k.pitch)
 	}
 	updateKeys(k.ratio)
 }
@@ -60,7 +63,7 @@ func (k *pressedKey) release(loc geom.Point) {
 	if loc.Y < geom.Height-8 {
 		k.move(loc)
 	} else {
-		k.voice.(*pressedTone).release()
+		k.handle.Release()
 	}
 }
 
@@ -80,8 +83,8 @@ func (k *pluckedKey) release(loc geom.Point) {
 
 	updateKeys(k.ratio)
 	v := newPluckedTone(amp, math.Exp2(k.pitch))
-	tones.Add(v)
 	k.keyBase.voice = v
+	k.handle = tones.AddPitch(v, k.pitch)
 }
 
 type bowedKey struct {
@@ -97,10 +100,10 @@ func (k *bowedKey) press(loc geom.Point) {
 	k.moveLoc = loc
 	k.moveTime = time.Now()
 
-	if k.voice == nil || k.voice.Done() {
+	if !k.playing() {
 		k.voice = newBowedTone(math.Exp2(k.pitch))
-		tones.Add(k.voice)
 		k.keyBase.voice = k.voice
+		k.handle = tones.AddPitch(k.voice, k.pitch)
 	}
 
 	updateKeys(k.ratio)
@@ -194,7 +197,7 @@ func updateKeys(last ratio) {
 	playing := []ratio{{1, 1}}
 	for _, k := range keys {
 		k := k.base()
-		if k.voice != nil && !k.voice.Done() {
+		if k.playing() {
 			playing = append(playing, k.ratio.div(last))
 		}
 	}
@@ -237,7 +240,7 @@ func drawKeys() {
 	amps := []float64{}
 	for i, k := range keys {
 		k := k.base()
-		if k.voice != nil && !k.voice.Done() {
+		if k.playing() {
 			iPlaying = append(iPlaying, i)
 			playing = append(playing, k.ratio)
 			amps = append(amps, k.voice.amp())
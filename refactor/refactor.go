@@ -4,55 +4,63 @@ import (
 	_ "golang.org/x/tools/go/gcimporter"
 	"golang.org/x/tools/go/loader"
 	"golang.org/x/tools/go/types"
+	"bytes"
 	"fmt"
 	"go/ast"
 	"go/build"
+	"go/format"
 	"go/parser"
-	"go/printer"
 	"go/token"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
 )
 
-func Rename(importPath, recv, name, newName string) error {
-	paths, err := importers(importPath)
-	if err != nil {
-		return err
-	}
-	config := loader.Config{
-		ParserMode:          parser.ParseComments,
-		TypeCheckFuncBodies: func(path string) bool { return paths[path] },
-		AllowErrors:         true,
-	}
-	for p := range paths {
-		config.Import(p)
-	}
-	prog, err := config.Load()
+// Conflicts is the error returned when a change would alter the meaning of
+// existing code.  Each element describes one such place.  No files are
+// written when there are conflicts, and none are changed when there is any
+// other error.
+type Conflicts []string
+
+func (c Conflicts) Error() string {
+	return strings.Join(c, "\n")
+}
+
+// Rename renames the package-level object, or the field or method of the
+// named type recv if recv is not empty, to newName in the package at
+// importPath and in every package in the Go environment that imports it.  It
+// returns the names of the files it rewrote and a func that restores them, for
+// the caller to use if a change of its own that goes with the rename fails.
+//
+func Rename(importPath, recv, name, newName string) (files []string, undo func(), err error) {
+	prog, paths, err := load(importPath)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 	pkg := prog.Imported[importPath]
 	for _, err := range pkg.Errors {
 		fmt.Println(err)
 	}
 	obj := pkg.Pkg.Scope().Lookup(name)
+	var named *types.Named
 	if recv != "" {
 		tn, ok := pkg.Pkg.Scope().Lookup(recv).(*types.TypeName)
 		if !ok {
-			return fmt.Errorf("type %s.%s not found", importPath, recv)
+			return nil, nil, fmt.Errorf("type %s.%s not found", importPath, recv)
 		}
-		t := tn.Type().(*types.Named)
-		for i := 0; i < t.NumMethods(); i++ {
-			m := t.Method(i)
+		named = tn.Type().(*types.Named)
+		obj = nil
+		for i := 0; i < named.NumMethods(); i++ {
+			m := named.Method(i)
 			if m.Name() == name {
 				obj = m
 				break
 			}
 		}
-		if t, ok := t.Underlying().(*types.Struct); ok && obj == nil {
+		if t, ok := named.Underlying().(*types.Struct); ok && obj == nil {
 			for i := 0; i < t.NumFields(); i++ {
 				f := t.Field(i)
 				if f.Name() == name {
@@ -62,35 +70,361 @@ func Rename(importPath, recv, name, newName string) error {
 			}
 		}
 		if obj == nil {
-			return fmt.Errorf("field or method %s.%s.%s not found", importPath, recv, name)
+			return nil, nil, fmt.Errorf("field or method %s.%s.%s not found", importPath, recv, name)
 		}
 	}
 	if obj == nil {
-		return fmt.Errorf("object %s.%s not found", importPath, name)
+		return nil, nil, fmt.Errorf("object %s.%s not found", importPath, name)
+	}
+
+	var conflicts Conflicts
+	conflict := func(pos token.Pos, msg string, args ...interface{}) {
+		conflicts = append(conflicts, prog.Fset.Position(pos).String()+": "+fmt.Sprintf(msg, args...))
 	}
+	if named != nil {
+		for _, T := range []types.Type{named, types.NewPointer(named)} {
+			if o, _, _ := types.LookupFieldOrMethod(T, pkg.Pkg, newName); o != nil {
+				conflict(o.Pos(), "%s.%s already has a field or method %s", pkg.Pkg.Name(), recv, newName)
+				break
+			}
+		}
+		if m, ok := obj.(*types.Func); ok {
+			for _, x := range lostInterfaces(prog, paths, m) {
+				conflict(m.Pos(), "%s would no longer implement %s", x[0], x[1])
+			}
+		}
+	} else {
+		if o := pkg.Pkg.Scope().Lookup(newName); o != nil {
+			conflict(o.Pos(), "%s is already declared in package %s", newName, pkg.Pkg.Name())
+		}
+		for _, f := range pkg.Files {
+			if o := pkg.Scopes[f].Lookup(newName); o != nil {
+				conflict(o.Pos(), "%s would conflict with an import", newName)
+			}
+		}
+		if types.Universe.Lookup(newName) != nil {
+			conflict(obj.Pos(), "%s would shadow the predeclared %s", name, newName)
+		}
+	}
+
+	modified := map[*ast.File]*loader.PackageInfo{}
 	for path := range paths {
 		pkg := prog.Imported[path]
 		for _, f := range pkg.Files {
-			modified := false
 			ast.Inspect(f, func(node ast.Node) bool {
-				if id, ok := node.(*ast.Ident); ok && pkg.ObjectOf(id) == obj {
-					id.Name = newName
-					modified = true
+				id, ok := node.(*ast.Ident)
+				if !ok || pkg.ObjectOf(id) != obj {
+					return true
+				}
+				modified[f] = pkg
+				if pkg.Pkg != obj.Pkg() && !ast.IsExported(newName) {
+					conflict(id.Pos(), "%s would be unexported but is used here", newName)
+				}
+				if named == nil && pkg.Pkg == obj.Pkg() && id.Pos() != obj.Pos() {
+					if o := scopeAt(pkg, f, id.Pos()).LookupParent(newName); o != nil && o.Parent() != obj.Parent() && o.Parent() != types.Universe {
+						conflict(id.Pos(), "%s would be shadowed by %s declared at %s", name, newName, prog.Fset.Position(o.Pos()))
+					}
 				}
 				return true
 			})
-			if !modified {
-				continue
+		}
+	}
+	if len(conflicts) > 0 {
+		return nil, nil, conflicts
+	}
+
+	srcs := map[string][]byte{}
+	for f, pkg := range modified {
+		ast.Inspect(f, func(node ast.Node) bool {
+			if id, ok := node.(*ast.Ident); ok && pkg.ObjectOf(id) == obj {
+				id.Name = newName
+			}
+			return true
+		})
+		var buf bytes.Buffer
+		if err := format.Node(&buf, prog.Fset, f); err != nil {
+			return nil, nil, err
+		}
+		srcs[prog.Fset.File(f.Package).Name()] = buf.Bytes()
+	}
+	return WriteFiles(srcs)
+}
+
+// lostInterfaces returns the types, each paired with an interface that it (or
+// a pointer to it) implements, that would no longer implement the interface
+// if method m were renamed.  The types are those in the loaded program whose
+// pointers have m in their method sets, i.e. m's receiver and the types that
+// embed it; the interfaces are those in the loaded program to which a value
+// might be converted: the types of its expressions and of the parameters and
+// results of the funcs it refers to.
+func lostInterfaces(prog *loader.Program, paths map[string]bool, m *types.Func) (lost [][2]string) {
+	var recvs []types.Type
+	var ifaces []types.Type
+	addIface := func(t types.Type) {
+		i, ok := t.Underlying().(*types.Interface)
+		if !ok {
+			return
+		}
+		for j := 0; j < i.NumMethods(); j++ {
+			if i.Method(j).Name() == m.Name() {
+				for _, t2 := range ifaces {
+					if types.Identical(t, t2) {
+						return
+					}
+				}
+				ifaces = append(ifaces, t)
+				return
+			}
+		}
+	}
+	addTuple := func(t *types.Tuple) {
+		for i := 0; i < t.Len(); i++ {
+			addIface(t.At(i).Type())
+		}
+	}
+	for path := range paths {
+		pkg := prog.Imported[path]
+		for _, o := range pkg.Defs {
+			if tn, ok := o.(*types.TypeName); ok {
+				if o, _, _ := types.LookupFieldOrMethod(types.NewPointer(tn.Type()), m.Pkg(), m.Name()); o == m {
+					recvs = append(recvs, tn.Type())
+				}
+			}
+		}
+		for _, tv := range pkg.Types {
+			addIface(tv.Type)
+			if sig, ok := tv.Type.Underlying().(*types.Signature); ok {
+				addTuple(sig.Params())
+				addTuple(sig.Results())
+			}
+		}
+	}
+	for _, T := range recvs {
+		for _, i := range ifaces {
+			if types.Implements(types.NewPointer(T), i.Underlying().(*types.Interface)) {
+				lost = append(lost, [2]string{types.TypeString(m.Pkg(), T), types.TypeString(m.Pkg(), i)})
+			}
+		}
+	}
+	return lost
+}
+
+// RenamePackage renames the package at importPath to newName.  It rewrites the
+// package clauses of the package's files (including ignored and test files)
+// and every file in the Go environment that imports the package without
+// naming the import, so that it refers to the package by newName.  It returns
+// the names of the files it rewrote.
+//
+func RenamePackage(importPath, newName string) ([]string, error) {
+	p, err := build.Import(importPath, "", 0)
+	if _, ok := err.(*build.NoGoError); err != nil && !ok {
+		return nil, err
+	}
+	srcs := map[string][]byte{}
+	if len(p.GoFiles)+len(p.CgoFiles) > 0 {
+		if srcs, err = importerSources(importPath, newName); err != nil {
+			return nil, err
+		}
+	}
+	for _, file := range append(append(append(p.GoFiles, p.IgnoredGoFiles...), p.CgoFiles...), p.TestGoFiles...) {
+		path := filepath.Join(p.Dir, file)
+		src, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		fset := token.NewFileSet()
+		f, err := parser.ParseFile(fset, path, src, parser.PackageClauseOnly)
+		if err != nil {
+			return nil, err
+		}
+		i := fset.Position(f.Name.Pos()).Offset
+		srcs[path] = append(append(append([]byte{}, src[:i]...), newName...), src[i+len(f.Name.Name):]...)
+	}
+	files, _, err := WriteFiles(srcs)
+	return files, err
+}
+
+// importerSources returns the sources of the files that import the package at
+// importPath without naming the import, rewritten to refer to it by newName.
+func importerSources(importPath, newName string) (map[string][]byte, error) {
+	prog, paths, err := load(importPath)
+	if err != nil {
+		return nil, err
+	}
+	var conflicts Conflicts
+	conflict := func(pos token.Pos, msg string, args ...interface{}) {
+		conflicts = append(conflicts, prog.Fset.Position(pos).String()+": "+fmt.Sprintf(msg, args...))
+	}
+	uses := map[*ast.File][]*ast.Ident{}
+	for path := range paths {
+		if path == importPath {
+			continue
+		}
+		pkg := prog.Imported[path]
+		for _, f := range pkg.Files {
+			for _, imp := range f.Imports {
+				if p, _ := strconv.Unquote(imp.Path.Value); p != importPath || imp.Name != nil {
+					continue
+				}
+				pkgName := pkg.Implicits[imp]
+				if pkgName == nil {
+					continue
+				}
+				if o := pkg.Scopes[f].Lookup(newName); o != nil && o != pkgName {
+					conflict(o.Pos(), "%s is already imported in this file", newName)
+				}
+				if o := pkg.Pkg.Scope().Lookup(newName); o != nil {
+					conflict(o.Pos(), "%s is already declared in package %s", newName, pkg.Pkg.Name())
+				}
+				ast.Inspect(f, func(node ast.Node) bool {
+					id, ok := node.(*ast.Ident)
+					if !ok || pkg.ObjectOf(id) != pkgName {
+						return true
+					}
+					if o := scopeAt(pkg, f, id.Pos()).LookupParent(newName); o != nil && o.Parent() != pkgName.Parent() && o.Parent() != pkg.Pkg.Scope() && o.Parent() != types.Universe {
+						conflict(id.Pos(), "%s would be shadowed by %s declared at %s", pkgName.Name(), newName, prog.Fset.Position(o.Pos()))
+					}
+					uses[f] = append(uses[f], id)
+					return true
+				})
+			}
+		}
+	}
+	if len(conflicts) > 0 {
+		return nil, conflicts
+	}
+
+	srcs := map[string][]byte{}
+	for f, ids := range uses {
+		for _, id := range ids {
+			id.Name = newName
+		}
+		var buf bytes.Buffer
+		if err := format.Node(&buf, prog.Fset, f); err != nil {
+			return nil, err
+		}
+		srcs[prog.Fset.File(f.Package).Name()] = buf.Bytes()
+	}
+	return srcs, nil
+}
+
+// WriteFiles replaces the contents of the named files with srcs, creating
+// those that don't exist, and returns their sorted names and a func that puts
+// back their old contents (removing those it created).  Each file is replaced
+// atomically, and if one can't be, those already replaced are put back, so
+// that on error the files are left as they were.
+//
+func WriteFiles(srcs map[string][]byte) (files []string, undo func(), err error) {
+	old := map[string][]byte{}
+	for file := range srcs {
+		b, err := ioutil.ReadFile(file)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, nil, err
+		}
+		if err == nil {
+			old[file] = b
+		}
+		files = append(files, file)
+	}
+	sort.Strings(files)
+	var written []string
+	undo = func() {
+		for _, file := range written {
+			var err error
+			if b, ok := old[file]; ok {
+				err = WriteFile(file, b)
+			} else {
+				err = os.Remove(file)
 			}
-			file, err := os.Create(prog.Fset.File(f.Package).Name())
 			if err != nil {
-				return err
+				fmt.Printf("error restoring %s: %s\n", file, err)
 			}
-			printer.Fprint(file, prog.Fset, f)
-			file.Close()
 		}
 	}
-	return nil
+	for _, file := range files {
+		if err := WriteFile(file, srcs[file]); err != nil {
+			undo()
+			return nil, nil, err
+		}
+		written = append(written, file)
+	}
+	return files, undo, nil
+}
+
+// WriteFile atomically replaces the file at path with one containing data, by
+// writing a temporary file in the same directory and renaming it.  A new file
+// is created with mode 0644; an existing one keeps its mode.
+//
+func WriteFile(path string, data []byte) error {
+	f, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if err2 := f.Close(); err == nil {
+		err = err2
+	}
+	if err == nil {
+		mode := os.FileMode(0644)
+		if fi, err := os.Stat(path); err == nil {
+			mode = fi.Mode()
+		}
+		err = os.Chmod(f.Name(), mode)
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+// load loads the package at importPath and all of its importers, type-checking
+// their function bodies.
+func load(importPath string) (*loader.Program, map[string]bool, error) {
+	paths, err := importers(importPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	config := loader.Config{
+		ParserMode:          parser.ParseComments,
+		TypeCheckFuncBodies: func(path string) bool { return paths[path] },
+		AllowErrors:         true,
+	}
+	for p := range paths {
+		config.Import(p)
+	}
+	prog, err := config.Load()
+	if err != nil {
+		return nil, nil, err
+	}
+	return prog, paths, nil
+}
+
+// scopeAt returns the innermost scope enclosing pos in f.
+func scopeAt(pkg *loader.PackageInfo, f *ast.File, pos token.Pos) *types.Scope {
+	scope := pkg.Scopes[f]
+	ast.Inspect(f, func(node ast.Node) bool {
+		if node == nil || pos < node.Pos() || pos >= node.End() {
+			return false
+		}
+		// a function's scope is recorded on its type but extends over its body
+		switch n := node.(type) {
+		case *ast.FuncDecl:
+			node = n.Type
+		case *ast.FuncLit:
+			node = n.Type
+		}
+		if s, ok := pkg.Scopes[node]; ok {
+			scope = s
+		}
+		return true
+	})
+	return scope
 }
 
 func importers(importPath string) (map[string]bool, error) {
@@ -115,19 +449,21 @@ func importers(importPath string) (map[string]bool, error) {
 
 // MovePackage moves the package from the old import path to the new one.  Any
 // packages in subdirectories are also moved.  All uses of the old import path
-// in the Go environment are updated to the new import path.
+// in the Go environment are updated to the new import path.  It returns the
+// names of the files it rewrote, as they were named before the move.
 //
-func MovePackage(old, new string) error {
+func MovePackage(old, new string) ([]string, error) {
 	p, err := build.Import(old, "", build.FindOnly)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	oldFullPath := p.Dir
 	if p, err := build.Import(new, "", build.FindOnly); err == nil {
-		return fmt.Errorf("package %s already exists at %s", new, p.Dir)
+		return nil, fmt.Errorf("package %s already exists at %s", new, p.Dir)
 	}
 	newFullPath := filepath.Join(p.SrcRoot, new)
 
+	srcs := map[string][]byte{}
 	for _, srcDir := range build.Default.SrcDirs() {
 		if err := filepath.Walk(srcDir, func(path string, f os.FileInfo, err error) error {
 			if c := filepath.Base(path)[0]; c == '_' || c == '.' {
@@ -137,30 +473,44 @@ func MovePackage(old, new string) error {
 				return nil
 			}
 			if f.Mode().IsRegular() && filepath.Ext(path) == ".go" {
-				astFile, err := parser.ParseFile(token.NewFileSet(), path, nil, parser.ImportsOnly)
+				b, err := ioutil.ReadFile(path)
 				if err != nil {
 					return nil
 				}
-				// TODO: correctly rewrite multiple imports.  should read the file once outside the loop
-				for _, imp := range astFile.Imports {
+				astFile, err := parser.ParseFile(token.NewFileSet(), path, b, parser.ImportsOnly)
+				if err != nil {
+					return nil
+				}
+				// splice from the end so that earlier offsets stay valid
+				modified := false
+				for i := len(astFile.Imports) - 1; i >= 0; i-- {
+					imp := astFile.Imports[i]
 					importPath := imp.Path.Value[1 : len(imp.Path.Value)-1]
 					if importPath == old || strings.HasPrefix(importPath, old+"/") {
-						b, err := ioutil.ReadFile(path)
-						if err != nil {
-							return nil
-						}
-						i := int(imp.Path.Pos())
-						s := string(b[:i]) + new + string(b[i+len(old):])
-						ioutil.WriteFile(path, []byte(s), f.Mode())
+						// the file's base is 1, so Pos is the offset just past the opening quote
+						j := int(imp.Path.Pos())
+						b = append(append(append([]byte{}, b[:j]...), new...), b[j+len(old):]...)
+						modified = true
 					}
 				}
+				if modified {
+					srcs[path] = b
+				}
 			}
 			return nil
 		}); err != nil {
-			return err
+			return nil, err
 		}
 	}
-	return os.Rename(oldFullPath, newFullPath)
+	files, undo, err := WriteFiles(srcs)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Rename(oldFullPath, newFullPath); err != nil {
+		undo()
+		return nil, err
+	}
+	return files, nil
 }
 
 func ReportShadowedPackages() {
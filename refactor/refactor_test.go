@@ -0,0 +1,271 @@
+package refactor
+
+import (
+	"go/build"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testGopath writes packages, keyed by import path and then by file name,
+// into a temporary GOPATH and returns it and a func that removes it.
+func testGopath(t *testing.T, pkgs map[string]map[string]string) (string, func()) {
+	gopath, err := ioutil.TempDir("", "refactor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for path, files := range pkgs {
+		dir := filepath.Join(gopath, "src", path)
+		if err := os.MkdirAll(dir, 0777); err != nil {
+			t.Fatal(err)
+		}
+		for name, src := range files {
+			if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(src), 0666); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	oldGopath := build.Default.GOPATH
+	build.Default.GOPATH = gopath
+	return gopath, func() {
+		build.Default.GOPATH = oldGopath
+		os.RemoveAll(gopath)
+	}
+}
+
+// readAll returns the contents of the files under dir, keyed by path.
+func readAll(t *testing.T, dir string) map[string]string {
+	m := map[string]string{}
+	filepath.Walk(dir, func(path string, f os.FileInfo, err error) error {
+		if err == nil && f.Mode().IsRegular() {
+			b, err := ioutil.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			m[path] = string(b)
+		}
+		return nil
+	})
+	return m
+}
+
+var renameTests = []struct {
+	a, b                string // the sources of packages r/a and r/b, which imports r/a
+	recv, name, newName string
+	conflict            string // the expected conflict, or "" if the rename succeeds
+}{
+	{`package a
+
+func F() {}
+func G() {}
+`, `package b
+
+import "r/a"
+
+func H() { a.F() }
+`, "", "F", "G", "G is already declared in package a"},
+	{`package a
+
+import "unsafe"
+
+var _ = unsafe.Sizeof(0)
+
+func F() {}
+`, `package b`, "", "F", "unsafe", "unsafe would conflict with an import"},
+	{`package a
+
+func F() {}
+`, `package b`, "", "F", "len", "F would shadow the predeclared len"},
+	{`package a
+
+func F() {}
+`, `package b
+
+import "r/a"
+
+func H() { a.F() }
+`, "", "F", "f", "f would be unexported but is used here"},
+	{`package a
+
+func F() {}
+
+func H() {
+	G := 1
+	_ = G
+	F()
+}
+`, `package b`, "", "F", "G", "F would be shadowed by G"},
+	{`package a
+
+type T struct{ X int }
+
+func (T) M() {}
+`, `package b`, "T", "M", "X", "a.T already has a field or method X"},
+	{`package a
+
+type I interface {
+	M()
+}
+
+type T struct{}
+
+func (*T) M() {}
+
+var _ I = &T{}
+`, `package b`, "T", "M", "N", "T would no longer implement I"},
+	{`package a
+
+type T struct{}
+
+func (T) M() {}
+`, `package b
+
+import "r/a"
+
+type I interface {
+	M()
+}
+
+type U struct{ a.T }
+
+func use(I) {}
+
+func H() { use(U{}) }
+`, "T", "M", "N", "U would no longer implement r/b.I"},
+	{`package a
+
+type T struct{}
+
+func (T) M() {}
+`, `package b
+
+import "r/a"
+
+func H() { a.T{}.M() }
+`, "T", "M", "N", ""},
+}
+
+func TestRename(t *testing.T) {
+	for _, test := range renameTests {
+		gopath, cleanup := testGopath(t, map[string]map[string]string{
+			"r/a": {"a.go": test.a},
+			"r/b": {"b.go": test.b},
+		})
+		before := readAll(t, gopath)
+		files, _, err := Rename("r/a", test.recv, test.name, test.newName)
+		if test.conflict == "" {
+			if err != nil {
+				t.Errorf("renaming %s to %s: %s", test.name, test.newName, err)
+			} else if len(files) != 2 {
+				t.Errorf("renaming %s to %s rewrote %v, want a.go and b.go", test.name, test.newName, files)
+			}
+			after := readAll(t, gopath)
+			for _, path := range files {
+				if strings.Contains(after[path], "M()") {
+					t.Errorf("%s still uses M:\n%s", path, after[path])
+				}
+			}
+		} else {
+			c, ok := err.(Conflicts)
+			if !ok {
+				t.Errorf("renaming %s to %s: got %v, want conflict %q", test.name, test.newName, err, test.conflict)
+			} else if !strings.Contains(c.Error(), test.conflict) {
+				t.Errorf("renaming %s to %s: got conflicts\n%s\nwant %q", test.name, test.newName, c, test.conflict)
+			}
+			after := readAll(t, gopath)
+			for path, src := range before {
+				if after[path] != src {
+					t.Errorf("%s changed despite the conflict", path)
+				}
+			}
+		}
+		cleanup()
+	}
+}
+
+var renamePackageTests = []struct {
+	newName  string
+	conflict string
+}{
+	{"unsafe", "unsafe is already imported in this file"},
+	{"z", "z is already declared in package c"},
+	{"y", "a would be shadowed by y"},
+}
+
+func TestRenamePackage(t *testing.T) {
+	gopath, cleanup := testGopath(t, map[string]map[string]string{
+		"r/a": {"a.go": "package a\n\nfunc F() {}\n"},
+		"r/b": {"b.go": "package b\n\nimport (\n\t\"r/a\"\n\t\"unsafe\"\n)\n\nvar _ = unsafe.Sizeof(0)\n\nfunc H() { a.F() }\n"},
+		"r/c": {"c.go": "package c\n\nimport \"r/a\"\n\nfunc z() {}\n\nfunc H() {\n\ty := 1\n\t_ = y\n\ta.F()\n}\n"},
+	})
+	defer cleanup()
+
+	before := readAll(t, gopath)
+	for _, test := range renamePackageTests {
+		if _, err := RenamePackage("r/a", test.newName); err == nil || !strings.Contains(err.Error(), test.conflict) {
+			t.Errorf("renaming to %s: got %v, want conflict %q", test.newName, err, test.conflict)
+		}
+	}
+	after := readAll(t, gopath)
+	for path, src := range before {
+		if after[path] != src {
+			t.Errorf("%s changed despite the conflicts", path)
+		}
+	}
+
+	files, err := RenamePackage("r/a", "x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 3 {
+		t.Errorf("rewrote %v, want a.go, b.go and c.go", files)
+	}
+	after = readAll(t, gopath)
+	for path, want := range map[string]string{
+		"r/a/a.go": "package x\n",
+		"r/b/b.go": "x.F()",
+		"r/c/c.go": "x.F()",
+	} {
+		if src := after[filepath.Join(gopath, "src", path)]; !strings.Contains(src, want) {
+			t.Errorf("%s does not contain %q:\n%s", path, want, src)
+		}
+	}
+}
+
+func TestWriteFilesRollback(t *testing.T) {
+	gopath, cleanup := testGopath(t, map[string]map[string]string{
+		"r/a": {"a.go": "package a\n"},
+	})
+	defer cleanup()
+
+	dir := filepath.Join(gopath, "src", "r", "a")
+	a, b := filepath.Join(dir, "a.go"), filepath.Join(dir, "b.go")
+	bad := filepath.Join(dir, "missing", "c.go") // in a directory that doesn't exist, so that it can't be written
+	_, _, err := WriteFiles(map[string][]byte{a: []byte("package a // changed\n"), b: []byte("package a\n"), bad: []byte("package a\n")})
+	if err == nil {
+		t.Fatal("writing into a missing directory succeeded")
+	}
+	if src, _ := ioutil.ReadFile(a); string(src) != "package a\n" {
+		t.Errorf("a.go was not restored:\n%s", src)
+	}
+	if _, err := os.Stat(b); !os.IsNotExist(err) {
+		t.Errorf("b.go was not removed")
+	}
+
+	files, undo, err := WriteFiles(map[string][]byte{a: []byte("package a // changed\n"), b: []byte("package a\n")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 || files[0] != a || files[1] != b {
+		t.Errorf("got files %v, want %v", files, []string{a, b})
+	}
+	undo()
+	if src, _ := ioutil.ReadFile(a); string(src) != "package a\n" {
+		t.Errorf("undo did not restore a.go:\n%s", src)
+	}
+	if _, err := os.Stat(b); !os.IsNotExist(err) {
+		t.Errorf("undo did not remove b.go")
+	}
+}
@@ -56,7 +56,7 @@ func (r *reverb) Stop() {
 	r.InitAudio(r.params)
 }
 
-func (r *reverb) reverb(dry float64) float64 {
+func (r *reverb) Process(dry float64) float64 {
 	f := func(x float64) float64 { return x * math.Sqrt(x*(6*x*x-15*x+10)) }
 
 	size := .05
@@ -5,8 +5,10 @@ import "code.google.com/p/gordon-go/audio"
 var score = &audio.Score{[]*audio.Part{
 	{"Sines", []*audio.PatternEvent{
 		{0, sines_pattern},
+	}, &audio.Strip{
+		Inserts: []string{"Reverb"},
 	}},
 	{"Reverb", []*audio.PatternEvent{
 		{0, reverb_pattern},
-	}},
+	}, nil},
 }}
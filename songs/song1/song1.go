@@ -18,18 +18,11 @@ type band struct {
 	Reverb reverb
 }
 
-func (b *band) Sing() float64 {
-	return b.Reverb.reverb(b.Sines.Sing())
-}
-
-func (b *band) Done() bool {
-	return b.Sines.Done() && b.Reverb.Done()
-}
-
 type sines struct {
 	audio.MultiVoice
 	Distortion audio.Control
 	Amplitude  audio.Control
+	over       *audio.Oversampler
 }
 
 func (s *sines) Play(n struct{ Pitch, Amplitude []*audio.ControlPoint }) {
@@ -40,7 +33,11 @@ func (s *sines) Play(n struct{ Pitch, Amplitude []*audio.ControlPoint }) {
 }
 
 func (s *sines) Sing() float64 {
-	return math.Exp2(s.Amplitude.Sing()) * math.Tanh(s.MultiVoice.Sing()*math.Exp2(s.Distortion.Sing()))
+	if s.over == nil {
+		s.over = audio.NewOversampler(4)
+	}
+	x := s.MultiVoice.Sing() * math.Exp2(s.Distortion.Sing())
+	return math.Exp2(s.Amplitude.Sing()) * s.over.Process(x, math.Tanh)
 }
 
 func (s *sines) Done() bool {
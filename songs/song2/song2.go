@@ -15,14 +15,6 @@ type band struct {
 	Sines sines
 }
 
-func (b *band) Sing() float64 {
-	return b.Sines.Sing()
-}
-
-func (b *band) Done() bool {
-	return b.Sines.Done()
-}
-
 type sines struct {
 	audio.MultiVoice
 }